@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/samber/lo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxLockStatsRows is the number of rows of lock statistics reported with aborted transactions.
+const maxLockStatsRows = 10
+
+// abortTableRe captures the table and the column of the conflict from messages of aborted transactions,
+// e.g. "... due to conflict on keys in range [[1], [1]], column Name in table Singers."
+var abortTableRe = regexp.MustCompile(`(?:column (\S+) )?in table (\S+?)\.?(?:$|\s)`)
+
+type abortRecorderKey struct{}
+
+// abortRecorder records Aborted errors of the RPCs of read-write transactions, including those retried by the client library.
+type abortRecorder struct {
+	mu     sync.Mutex
+	aborts []transactionAbort
+}
+
+// recordAborts returns the context whose RPCs record Aborted errors in a new recorder.
+func recordAborts(ctx context.Context) (context.Context, *abortRecorder) {
+	r := &abortRecorder{}
+	return r.context(ctx), r
+}
+
+func (r *abortRecorder) context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, abortRecorderKey{}, r)
+}
+
+func (r *abortRecorder) record(method string, err error) {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.Aborted {
+		return
+	}
+	a := transactionAbort{RPC: path.Base(method), Message: s.Message(), At: time.Now().UTC()}
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			a.RetryDelay = info.GetRetryDelay().AsDuration().String()
+		}
+	}
+	if m := abortTableRe.FindStringSubmatch(a.Message); m != nil {
+		a.Column, a.Table = m[1], m[2]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aborts = append(r.aborts, a)
+}
+
+// abortUnaryInterceptor records Aborted errors of unary RPCs such as ExecuteSql and Commit.
+func abortUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, callOpts...)
+	if r, ok := ctx.Value(abortRecorderKey{}).(*abortRecorder); ok && err != nil {
+		r.record(method, err)
+	}
+	return err
+}
+
+// abortStreamInterceptor records Aborted errors of streaming RPCs such as ExecuteStreamingSql, which are returned by RecvMsg.
+func abortStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, callOpts...)
+	r, ok := ctx.Value(abortRecorderKey{}).(*abortRecorder)
+	if !ok {
+		return stream, err
+	}
+	if err != nil {
+		r.record(method, err)
+		return stream, err
+	}
+	return &abortRecordingStream{ClientStream: stream, recorder: r, method: method}, nil
+}
+
+type abortRecordingStream struct {
+	grpc.ClientStream
+	recorder *abortRecorder
+	method   string
+}
+
+func (s *abortRecordingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.recorder.record(s.method, err)
+	}
+	return err
+}
+
+// diagnostics returns the aborts of the transactions with lock statistics of the conflicting tables,
+// or nil if no transaction is aborted. err is the result of the transactions.
+func (r *abortRecorder) diagnostics(ctx context.Context, client *spanner.Client, err error) *transactionDiagnostics {
+	r.mu.Lock()
+	aborts := slices.Clone(r.aborts)
+	r.mu.Unlock()
+	if len(aborts) == 0 {
+		return nil
+	}
+
+	// Each abort ends an attempt, and the last attempt is not aborted unless retries are given up.
+	d := &transactionDiagnostics{Attempts: len(aborts), Aborts: aborts}
+	if spanner.ErrCode(err) != codes.Aborted {
+		d.Attempts++
+	}
+	tables := lo.Uniq(lo.FilterMap(aborts, func(a transactionAbort, _ int) (string, bool) { return a.Table, a.Table != "" }))
+
+	stmt := spanner.Statement{
+		SQL: `SELECT INTERVAL_END, ROW_RANGE_START_KEY, LOCK_WAIT_SECONDS,
+  ARRAY(SELECT CONCAT(s.lock_mode, ' ', s.column) FROM UNNEST(SAMPLE_LOCK_REQUESTS) AS s) AS SAMPLE_LOCK_REQUESTS
+FROM SPANNER_SYS.LOCK_STATS_TOP_MINUTE
+WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM SPANNER_SYS.LOCK_STATS_TOP_MINUTE)
+  AND (ARRAY_LENGTH(@tables) = 0 OR EXISTS(SELECT 1 FROM UNNEST(@tables) AS t WHERE STARTS_WITH(ROW_RANGE_START_KEY, CONCAT(t, '('))))
+ORDER BY LOCK_WAIT_SECONDS DESC
+LIMIT @limit`,
+		Params: map[string]any{"tables": lo.Ternary(tables == nil, []string{}, tables), "limit": maxLockStatsRows},
+	}
+	// The transaction may have been cancelled, but the statistics are still useful.
+	lockStats, lockErr := queryRows(context.WithoutCancel(ctx), client, stmt)
+	if lockErr != nil {
+		slog.WarnContext(ctx, "failed to read lock statistics", "error", lockErr)
+		d.Note = fmt.Sprintf("Lock statistics are not available: %v", lockErr)
+		return d
+	}
+	d.LockStats = lockStats
+	d.Note = "Lock statistics are of the latest minute of SPANNER_SYS.LOCK_STATS_TOP_MINUTE, which may not include the conflicts of this call until the minute ends"
+	return d
+}
+
+// renderTransactionDiagnostics renders the aborts and the lock statistics following the result of a tool.
+func renderTransactionDiagnostics(d *transactionDiagnostics) string {
+	if d == nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nTransactions were aborted %d times in %d attempts:\n", len(d.Aborts), d.Attempts)
+	for i, a := range d.Aborts {
+		fmt.Fprintf(&b, "%d. %s: %s", i+1, a.RPC, a.Message)
+		if a.RetryDelay != "" {
+			fmt.Fprintf(&b, " (retry delay %s)", a.RetryDelay)
+		}
+		b.WriteString("\n")
+	}
+	if len(d.LockStats) > 0 {
+		b.WriteString("Lock statistics:\n")
+		table := newTable(&b)
+		table.SetHeader([]string{"Row range start key", "Lock wait seconds", "Sample lock requests"})
+		for _, row := range d.LockStats {
+			samples, _ := row["SAMPLE_LOCK_REQUESTS"].([]any)
+			table.Append([]string{
+				fmt.Sprint(row["ROW_RANGE_START_KEY"]),
+				fmt.Sprint(row["LOCK_WAIT_SECONDS"]),
+				strings.Join(lo.Map(samples, func(s any, _ int) string { return fmt.Sprint(s) }), "\n"),
+			})
+		}
+		table.Render()
+	}
+	if d.Note != "" {
+		fmt.Fprintf(&b, "Note: %s\n", d.Note)
+	}
+	return b.String()
+}
+
+// withAbortDiagnostics appends the diagnostics of aborts to the error of transactions which failed after aborts.
+func withAbortDiagnostics(err error, d *transactionDiagnostics) error {
+	if err == nil || d == nil {
+		return err
+	}
+	return fmt.Errorf("%w%s", err, strings.TrimRight(renderTransactionDiagnostics(d), "\n"))
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/samber/lo"
+)
+
+// accessOptions fences off sensitive tables and columns from tools which read or write data. Patterns are in the syntax of path.Match
+// and compared case-insensitively, and tables may be qualified by the named schema.
+//
+//	access:
+//	  deny_tables: [Secrets, "audit.*"]
+//	  deny_columns: [Users.SSN, "*.Password*"]
+type accessOptions struct {
+	DenyTables  []string `yaml:"deny_tables"`
+	DenyColumns []string `yaml:"deny_columns"`
+}
+
+func (o *accessOptions) validate() error {
+	for _, p := range o.DenyTables {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q of deny_tables: %w", p, err)
+		}
+	}
+	for _, p := range o.DenyColumns {
+		if !strings.Contains(p, ".") {
+			return fmt.Errorf("%q of deny_columns is not a pattern of table.column", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q of deny_columns: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func (o *accessOptions) enabled() bool {
+	return len(o.DenyTables) > 0 || len(o.DenyColumns) > 0
+}
+
+func matchPattern(pattern, name string) bool {
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return ok
+}
+
+// deniedTable returns the pattern of deny_tables matching the table.
+func (o *accessOptions) deniedTable(table string) (string, bool) {
+	return lo.Find(o.DenyTables, func(p string) bool { return matchPattern(p, table) })
+}
+
+// deniedColumn returns the pattern of deny_columns matching the column of a table which satisfies matchTable.
+func (o *accessOptions) deniedColumn(column string, matchTable func(pattern string) bool) (string, bool) {
+	return lo.Find(o.DenyColumns, func(p string) bool {
+		i := strings.LastIndex(p, ".")
+		return matchPattern(p[i+1:], column) && matchTable(p[:i])
+	})
+}
+
+// checkTable returns an error if the table is denied.
+func (o *accessOptions) checkTable(field, table string) error {
+	if p, ok := o.deniedTable(strings.ReplaceAll(table, "`", "")); ok {
+		return &validationError{Field: field, Message: fmt.Sprintf("table %s is denied by access.deny_tables %q of the server config", table, p)}
+	}
+	return nil
+}
+
+// checkStatement returns an error if the statement refers to denied tables or columns.
+// Names in the SQL are checked first, and then scan targets and scanned columns in the plan,
+// which catch tables referred through views, indexes, graphs and SELECT *.
+func (o *accessOptions) checkStatement(ctx context.Context, target *profile, field, sql string) error {
+	if !o.enabled() {
+		return nil
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var names []string
+	tokens := scanSQL(sql)
+	for i, t := range tokens {
+		if t.kind != sqlWord {
+			continue
+		}
+		name := strings.ReplaceAll(t.text, "`", "")
+		names = append(names, name)
+		if i+2 < len(tokens) && tokens[i+1].text == "." && tokens[i+2].kind == sqlWord {
+			names = append(names, name+"."+strings.ReplaceAll(tokens[i+2].text, "`", ""))
+		}
+	}
+	referred := func(pattern string) bool {
+		return lo.ContainsBy(names, func(name string) bool { return matchPattern(pattern, name) })
+	}
+	for _, name := range names {
+		if err := o.checkTable(field, name); err != nil {
+			return err
+		}
+		if p, ok := o.deniedColumn(name, referred); ok {
+			return &validationError{Field: field, Message: fmt.Sprintf("column %s is denied by access.deny_columns %q of the server config", name, p)}
+		}
+	}
+
+	qp, err := analyzeStatement(ctx, target, client, sql)
+	if err != nil {
+		return err
+	}
+	scans := planScans(qp)
+	var indexTables map[string]string
+	if lo.ContainsBy(scans, func(s planScan) bool { return s.kind == "index" }) {
+		if indexTables, err = indexedTables(ctx, client); err != nil {
+			return err
+		}
+	}
+	for _, s := range scans {
+		table := s.target
+		switch s.kind {
+		case "batch":
+			continue
+		case "index":
+			table = lo.CoalesceOrEmpty(indexTables[strings.ToLower(s.target)], s.target)
+		}
+		if err := o.checkTable(field, table); err != nil {
+			return err
+		}
+		for _, column := range s.columns {
+			if p, ok := o.deniedColumn(column, func(pattern string) bool { return matchPattern(pattern, table) }); ok {
+				return &validationError{Field: field, Message: fmt.Sprintf("column %s.%s is denied by access.deny_columns %q of the server config", table, column, p)}
+			}
+		}
+	}
+	return nil
+}
+
+// indexedTables returns the tables of the indexes keyed by the lowercase index names.
+func indexedTables(ctx context.Context, client *spanner.Client) (map[string]string, error) {
+	rows, err := queryRows(ctx, client, spanner.NewStatement(`SELECT TABLE_SCHEMA, TABLE_NAME, INDEX_NAME FROM INFORMATION_SCHEMA.INDEXES
+WHERE INDEX_TYPE = 'INDEX'`))
+	if err != nil {
+		return nil, err
+	}
+	return lo.SliceToMap(rows, func(row map[string]any) (string, string) {
+		schema, _ := row["TABLE_SCHEMA"].(string)
+		table, _ := row["TABLE_NAME"].(string)
+		index, _ := row["INDEX_NAME"].(string)
+		return strings.ToLower(qualifiedTableName(schema, index)), qualifiedTableName(schema, table)
+	}), nil
+}
+
+// filterDataChangeRecord removes denied columns from the mods of the data change record, and returns false if the table is denied.
+func (o *accessOptions) filterDataChangeRecord(record json.RawMessage) (json.RawMessage, bool) {
+	if !o.enabled() {
+		return record, true
+	}
+
+	d := json.NewDecoder(bytes.NewReader(record))
+	d.UseNumber()
+	var r map[string]any
+	if err := d.Decode(&r); err != nil {
+		return nil, false
+	}
+	table, _ := r["table_name"].(string)
+	if _, ok := o.deniedTable(table); ok {
+		return nil, false
+	}
+	if len(o.DenyColumns) == 0 {
+		return record, true
+	}
+
+	matchTable := func(pattern string) bool { return matchPattern(pattern, table) }
+	mods, _ := r["mods"].([]any)
+	for _, mod := range mods {
+		mod, ok := mod.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"keys", "new_values", "old_values"} {
+			values, _ := mod[key].(map[string]any)
+			for column := range values {
+				if _, ok := o.deniedColumn(column, matchTable); ok {
+					delete(values, column)
+				}
+			}
+		}
+	}
+	if types, ok := r["column_types"].([]any); ok {
+		r["column_types"] = lo.Filter(types, func(t any, _ int) bool {
+			name, _ := t.(map[string]any)["name"].(string)
+			_, denied := o.deniedColumn(name, matchTable)
+			return !denied
+		})
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
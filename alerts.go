@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// Recommended thresholds of alerts. High priority CPU utilization should be lower in multi-region instances
+// so the other regions can serve the traffic of a failed region.
+const (
+	defaultRegionalCPUThresholdPercent    = 65
+	defaultMultiRegionCPUThresholdPercent = 45
+	defaultSmoothedCPUThresholdPercent    = 90
+	defaultStorageThresholdPercent        = 75
+	defaultLatencyThresholdMillis         = 500
+)
+
+// defaultCPUThresholdPercent returns the recommended maximum of high priority CPU utilization of the instance configuration.
+func defaultCPUThresholdPercent(config string) float64 {
+	if strings.HasPrefix(config, "regional-") {
+		return defaultRegionalCPUThresholdPercent
+	}
+	return defaultMultiRegionCPUThresholdPercent
+}
+
+var alertPolicyFormats = []string{"json", "terraform"}
+
+// alertPolicy is an AlertPolicy of the Cloud Monitoring API in JSON.
+type alertPolicy struct {
+	DisplayName          string             `json:"displayName"`
+	Documentation        alertDocumentation `json:"documentation"`
+	UserLabels           map[string]string  `json:"userLabels"`
+	Combiner             string             `json:"combiner"`
+	Conditions           []alertCondition   `json:"conditions"`
+	NotificationChannels []string           `json:"notificationChannels,omitempty"`
+}
+
+type alertDocumentation struct {
+	Content  string `json:"content"`
+	MimeType string `json:"mimeType"`
+}
+
+type alertCondition struct {
+	DisplayName        string         `json:"displayName"`
+	ConditionThreshold alertThreshold `json:"conditionThreshold"`
+}
+
+type alertThreshold struct {
+	Filter         string             `json:"filter"`
+	Aggregations   []alertAggregation `json:"aggregations"`
+	Comparison     string             `json:"comparison"`
+	ThresholdValue float64            `json:"thresholdValue"`
+	Duration       string             `json:"duration"`
+	Trigger        alertTrigger       `json:"trigger"`
+}
+
+type alertAggregation struct {
+	AlignmentPeriod    string   `json:"alignmentPeriod"`
+	PerSeriesAligner   string   `json:"perSeriesAligner"`
+	CrossSeriesReducer string   `json:"crossSeriesReducer,omitempty"`
+	GroupByFields      []string `json:"groupByFields,omitempty"`
+}
+
+type alertTrigger struct {
+	Count int `json:"count"`
+}
+
+// alertPolicyName is the name of an alert policy, used as the name of its Terraform resource.
+type alertPolicyName struct {
+	name   string
+	policy alertPolicy
+}
+
+func generateAlertPoliciesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs            `mapstructure:",squash"`
+		Format                  string   `mapstructure:"format"`
+		NotificationChannels    []string `mapstructure:"notification_channels"`
+		CPUThresholdPercent     float64  `mapstructure:"cpu_threshold_percent"`
+		StorageThresholdPercent float64  `mapstructure:"storage_threshold_percent"`
+		LatencyThresholdMillis  float64  `mapstructure:"latency_threshold_ms"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Format == "" {
+		req.Format = "json"
+	}
+	if !lo.Contains(alertPolicyFormats, req.Format) {
+		return nil, &validationError{Field: "format", Message: fmt.Sprintf("must be one of %v", alertPolicyFormats)}
+	}
+	for field, v := range map[string]float64{"cpu_threshold_percent": req.CPUThresholdPercent, "storage_threshold_percent": req.StorageThresholdPercent} {
+		if v < 0 || v > 100 {
+			return nil, &validationError{Field: field, Message: "must be between 0 and 100"}
+		}
+	}
+	if req.LatencyThresholdMillis < 0 {
+		return nil, &validationError{Field: "latency_threshold_ms", Message: "must not be negative"}
+	}
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := clients.instanceAdminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var inst *instancepb.Instance
+	err = retry(ctx, func(ctx context.Context) error {
+		inst, err = admin.GetInstance(ctx, &instancepb.GetInstanceRequest{
+			Name: fmt.Sprintf("projects/%s/instances/%s", target.Project, target.Instance),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	config := path.Base(inst.GetConfig())
+	cpu := lo.CoalesceOrEmpty(req.CPUThresholdPercent, defaultCPUThresholdPercent(config))
+	policies := alertPolicies(target.Instance, config, req.NotificationChannels,
+		cpu, lo.CoalesceOrEmpty(req.StorageThresholdPercent, defaultStorageThresholdPercent),
+		lo.CoalesceOrEmpty(req.LatencyThresholdMillis, defaultLatencyThresholdMillis))
+
+	out := generateAlertPoliciesOutput{
+		Instance: target.Instance,
+		Config:   config,
+		Policies: lo.Map(policies, func(p alertPolicyName, _ int) alertPolicy { return p.policy }),
+	}
+	if req.Format == "terraform" {
+		var b strings.Builder
+		for i, p := range policies {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			terraformAlertPolicy(target.Project, p).write(&b, "")
+		}
+		out.Terraform = b.String()
+		return mcp.NewToolResultStructured(out, out.Terraform), nil
+	}
+
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out.Policies); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&b, "\nCreate each policy by gcloud monitoring policies create --policy-from-file=FILE --project=%s\n", target.Project)
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// alertPolicies returns the recommended alert policies of the instance. Metrics are filtered by the instance ID
+// and summed over databases, so the policies are created in the project of the instance.
+func alertPolicies(instanceID, config string, channels []string, cpuPercent, storagePercent, latencyMillis float64) []alertPolicyName {
+	filter := func(metric string, labels ...string) string {
+		s := fmt.Sprintf(`resource.type = "spanner_instance" AND resource.labels.instance_id = %q AND metric.type = "spanner.googleapis.com/%s"`, instanceID, metric)
+		for i := 0; i+1 < len(labels); i += 2 {
+			s += fmt.Sprintf(` AND metric.labels.%s = %q`, labels[i], labels[i+1])
+		}
+		return s
+	}
+	sum := []alertAggregation{{
+		AlignmentPeriod:    "60s",
+		PerSeriesAligner:   "ALIGN_MEAN",
+		CrossSeriesReducer: "REDUCE_SUM",
+		GroupByFields:      []string{"resource.label.instance_id"},
+	}}
+	policy := func(name, title, doc string, threshold alertThreshold) alertPolicyName {
+		threshold.Comparison = "COMPARISON_GT"
+		threshold.Trigger = alertTrigger{Count: 1}
+		displayName := fmt.Sprintf("Spanner %s: %s", instanceID, title)
+		return alertPolicyName{
+			name: terraformName(instanceID + "_" + name),
+			policy: alertPolicy{
+				DisplayName:          displayName,
+				Documentation:        alertDocumentation{Content: doc, MimeType: "text/markdown"},
+				UserLabels:           map[string]string{"spanner_instance": strings.ToLower(instanceID)},
+				Combiner:             "OR",
+				Conditions:           []alertCondition{{DisplayName: displayName, ConditionThreshold: threshold}},
+				NotificationChannels: channels,
+			},
+		}
+	}
+	percent := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) + "%" }
+
+	return []alertPolicyName{
+		policy("cpu_high_priority", "high priority CPU utilization > "+percent(cpuPercent),
+			fmt.Sprintf("High priority CPU utilization of the instance %s (%s) is over %s. Add compute capacity or reduce the load so latency doesn't increase and the instance can serve traffic during failovers.",
+				instanceID, config, percent(cpuPercent)),
+			alertThreshold{
+				Filter:         filter("instance/cpu/utilization_by_priority", "priority", "high"),
+				Aggregations:   sum,
+				ThresholdValue: cpuPercent / 100,
+				Duration:       "600s",
+			}),
+		policy("cpu_smoothed", "24-hour smoothed CPU utilization > "+percent(defaultSmoothedCPUThresholdPercent),
+			fmt.Sprintf("The rolling 24-hour average of CPU utilization of the instance %s is over %s. Low priority tasks like schema changes and backups may be delayed.",
+				instanceID, percent(defaultSmoothedCPUThresholdPercent)),
+			alertThreshold{
+				Filter:         filter("instance/cpu/smoothed_utilization"),
+				Aggregations:   sum,
+				ThresholdValue: defaultSmoothedCPUThresholdPercent / 100,
+				Duration:       "600s",
+			}),
+		policy("storage", "storage utilization > "+percent(storagePercent),
+			fmt.Sprintf("Storage of the instance %s is over %s of its limit. Add compute capacity before writes fail at the limit.", instanceID, percent(storagePercent)),
+			alertThreshold{
+				Filter:         filter("instance/storage/utilization"),
+				Aggregations:   []alertAggregation{{AlignmentPeriod: "300s", PerSeriesAligner: "ALIGN_MAX"}},
+				ThresholdValue: storagePercent / 100,
+				Duration:       "0s",
+			}),
+		policy("latency", fmt.Sprintf("p99 request latency > %sms", strconv.FormatFloat(latencyMillis, 'f', -1, 64)),
+			fmt.Sprintf("The 99th percentile of server latency of %s requests to the instance %s is over the SLO of %sms. Check the query stats and lock stats of the databases.",
+				"${metric.label.method}", instanceID, strconv.FormatFloat(latencyMillis, 'f', -1, 64)),
+			alertThreshold{
+				Filter: filter("api/request_latencies"),
+				Aggregations: []alertAggregation{{
+					AlignmentPeriod:    "60s",
+					PerSeriesAligner:   "ALIGN_DELTA",
+					CrossSeriesReducer: "REDUCE_PERCENTILE_99",
+					GroupByFields:      []string{"resource.label.instance_id", "metric.label.method"},
+				}},
+				// request_latencies is in seconds.
+				ThresholdValue: latencyMillis / 1000,
+				Duration:       "300s",
+			}),
+	}
+}
+
+func terraformAlertPolicy(project string, p alertPolicyName) *hclBlock {
+	b := &hclBlock{header: fmt.Sprintf("resource \"google_monitoring_alert_policy\" %s", quoteHCL(p.name))}
+	b.set("project", quoteHCL(project))
+	b.set("display_name", quoteHCL(p.policy.DisplayName))
+	b.set("combiner", quoteHCL(p.policy.Combiner))
+	if len(p.policy.NotificationChannels) > 0 {
+		b.set("notification_channels", hclList(p.policy.NotificationChannels))
+	}
+	b.set("user_labels", fmt.Sprintf("{\n    spanner_instance = %s\n  }", quoteHCL(p.policy.UserLabels["spanner_instance"])))
+
+	doc := b.block("documentation")
+	doc.set("content", quoteHCL(p.policy.Documentation.Content))
+	doc.set("mime_type", quoteHCL(p.policy.Documentation.MimeType))
+
+	for _, c := range p.policy.Conditions {
+		cond := b.block("conditions")
+		cond.set("display_name", quoteHCL(c.DisplayName))
+		t := c.ConditionThreshold
+		threshold := cond.block("condition_threshold")
+		threshold.set("filter", quoteHCL(t.Filter))
+		threshold.set("comparison", quoteHCL(t.Comparison))
+		threshold.set("threshold_value", strconv.FormatFloat(t.ThresholdValue, 'f', -1, 64))
+		threshold.set("duration", quoteHCL(t.Duration))
+		for _, a := range t.Aggregations {
+			agg := threshold.block("aggregations")
+			agg.set("alignment_period", quoteHCL(a.AlignmentPeriod))
+			agg.set("per_series_aligner", quoteHCL(a.PerSeriesAligner))
+			if a.CrossSeriesReducer != "" {
+				agg.set("cross_series_reducer", quoteHCL(a.CrossSeriesReducer))
+				agg.set("group_by_fields", hclList(a.GroupByFields))
+			}
+		}
+		threshold.block("trigger").set("count", strconv.Itoa(t.Trigger.Count))
+	}
+	return b
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apache/arrow/go/v13/arrow"
+	"github.com/apache/arrow/go/v13/arrow/array"
+	"github.com/apache/arrow/go/v13/arrow/ipc"
+	"github.com/apache/arrow/go/v13/arrow/memory"
+	"github.com/apache/arrow/go/v13/parquet"
+	"github.com/apache/arrow/go/v13/parquet/compress"
+	"github.com/apache/arrow/go/v13/parquet/pqarrow"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// artifactDir is the directory where execute_query writes results as artifacts. It is set by --artifact-dir.
+var artifactDir string
+
+// defaultArtifactMaxRows is the default of max_rows of execute_query writing an artifact.
+const defaultArtifactMaxRows = 1_000_000
+
+var artifactFormats = []string{"parquet", "arrow"}
+
+// artifactMIMETypes are the media types of artifacts. arrow is the Arrow IPC file format.
+var artifactMIMETypes = map[string]string{
+	"parquet": "application/vnd.apache.parquet",
+	"arrow":   "application/vnd.apache.arrow.file",
+}
+
+// artifactResult executes the statement like queryResult, writes the rows to a file in artifactDir in the format,
+// and returns a link to the file instead of the rows. PROTO values are bytes and ENUM values are numbers.
+func artifactResult(ctx context.Context, target *profile, stmt spanner.Statement, maxRows int, format string, profile bool) (*mcp.CallToolResult, error) {
+	if artifactDir == "" {
+		return nil, &validationError{Field: "artifact", Message: "artifacts are disabled, start the server with --artifact-dir"}
+	}
+	if _, ok := artifactMIMETypes[format]; !ok {
+		return nil, &validationError{Field: "artifact", Message: fmt.Sprintf("must be one of %v", artifactFormats)}
+	}
+
+	result, rowType, err := runQuery(ctx, target, stmt, maxRows, protoFormatNone, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	path, size, err := writeArtifact(format, rowType, result.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	out := executeQueryOutput{
+		Columns:     result.Columns,
+		Rows:        [][]any{},
+		HasMoreRows: result.HasMoreRows,
+		Stats:       result.Stats,
+		Artifact:    &queryArtifact{Path: path, URI: uri, Format: format, Rows: len(result.Rows), SizeBytes: size},
+	}
+	text := fmt.Sprintf("Wrote %d rows (%d bytes) to %s", len(result.Rows), size, path)
+	if out.HasMoreRows {
+		text += " (more rows are omitted by max_rows)"
+	}
+	text += "\n" + renderQueryStats(out.Stats)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(text),
+			mcp.NewResourceLink(uri, filepath.Base(path), fmt.Sprintf("Result of the query in %s", format), artifactMIMETypes[format]),
+		},
+		StructuredContent: out,
+	}, nil
+}
+
+// writeArtifact writes the rows to a new file in artifactDir and returns its path and size.
+func writeArtifact(format string, rowType *sppb.StructType, rows [][]any) (string, int64, error) {
+	schema, err := arrowSchema(rowType)
+	if err != nil {
+		return "", 0, err
+	}
+
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for _, row := range rows {
+		for i, f := range rowType.GetFields() {
+			if err := appendArrowValue(b.Field(i), f.GetType(), row[i]); err != nil {
+				return "", 0, fmt.Errorf("column %s: %w", f.GetName(), err)
+			}
+		}
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	f, err := os.CreateTemp(artifactDir, fmt.Sprintf("query-%s-*.%s", time.Now().UTC().Format("20060102T150405"), format))
+	if err != nil {
+		return "", 0, err
+	}
+	if err := writeRecord(format, f, rec); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+	// The Parquet writer closes the file by itself.
+	if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return "", 0, err
+	}
+	return f.Name(), info.Size(), nil
+}
+
+func writeRecord(format string, w io.WriteSeeker, rec arrow.Record) error {
+	switch format {
+	case "parquet":
+		pw, err := pqarrow.NewFileWriter(rec.Schema(), w, parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy)),
+			pqarrow.DefaultWriterProps())
+		if err != nil {
+			return err
+		}
+		if err := pw.Write(rec); err != nil {
+			return err
+		}
+		return pw.Close()
+	default:
+		iw, err := ipc.NewFileWriter(w, ipc.WithSchema(rec.Schema()))
+		if err != nil {
+			return err
+		}
+		if err := iw.Write(rec); err != nil {
+			return err
+		}
+		return iw.Close()
+	}
+}
+
+func arrowSchema(rowType *sppb.StructType) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(rowType.GetFields()))
+	for i, f := range rowType.GetFields() {
+		typ, err := arrowType(f.GetType())
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", f.GetName(), err)
+		}
+		fields[i] = arrow.Field{Name: avroFieldName(f.GetName(), i), Type: typ, Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// arrowType returns the Arrow type of the Spanner type. Like avro of export_to_gcs, NUMERIC, JSON, INTERVAL and UUID are strings,
+// PROTO is binary and ENUM is int64. STRUCT is not supported because it can't be stored in tables.
+func arrowType(t *sppb.Type) (arrow.DataType, error) {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return arrow.FixedWidthTypes.Boolean, nil
+	case sppb.TypeCode_INT64, sppb.TypeCode_ENUM:
+		return arrow.PrimitiveTypes.Int64, nil
+	case sppb.TypeCode_FLOAT32:
+		return arrow.PrimitiveTypes.Float32, nil
+	case sppb.TypeCode_FLOAT64:
+		return arrow.PrimitiveTypes.Float64, nil
+	case sppb.TypeCode_BYTES, sppb.TypeCode_PROTO:
+		return arrow.BinaryTypes.Binary, nil
+	case sppb.TypeCode_DATE:
+		return arrow.FixedWidthTypes.Date32, nil
+	case sppb.TypeCode_TIMESTAMP:
+		return &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}, nil
+	case sppb.TypeCode_ARRAY:
+		elem, err := arrowType(t.GetArrayElementType())
+		if err != nil {
+			return nil, err
+		}
+		return arrow.ListOf(elem), nil
+	case sppb.TypeCode_STRUCT:
+		return nil, fmt.Errorf("STRUCT is not supported in artifacts")
+	default:
+		return arrow.BinaryTypes.String, nil
+	}
+}
+
+// appendArrowValue appends a value decoded by decodeValue to the builder of arrowType(t).
+func appendArrowValue(b array.Builder, t *sppb.Type, v any) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		b.Append(v.(bool))
+	case *array.Int64Builder:
+		switch v := v.(type) {
+		case int64:
+			b.Append(v)
+		default:
+			// ENUM values are strings of numbers.
+			n, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+			if err != nil {
+				return err
+			}
+			b.Append(n)
+		}
+	case *array.Float32Builder:
+		f, err := floatValue(v)
+		if err != nil {
+			return err
+		}
+		b.Append(float32(f))
+	case *array.Float64Builder:
+		f, err := floatValue(v)
+		if err != nil {
+			return err
+		}
+		b.Append(f)
+	case *array.BinaryBuilder:
+		bytes, err := base64.StdEncoding.DecodeString(fmt.Sprint(v))
+		if err != nil {
+			return err
+		}
+		b.Append(bytes)
+	case *array.Date32Builder:
+		d, err := time.Parse(time.DateOnly, fmt.Sprint(v))
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Date32FromTime(d))
+	case *array.TimestampBuilder:
+		ts, err := time.Parse(time.RFC3339Nano, fmt.Sprint(v))
+		if err != nil {
+			return err
+		}
+		b.AppendTime(ts)
+	case *array.ListBuilder:
+		elems, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("unexpected value %v", v)
+		}
+		b.Append(true)
+		for _, elem := range elems {
+			if err := appendArrowValue(b.ValueBuilder(), t.GetArrayElementType(), elem); err != nil {
+				return err
+			}
+		}
+	case *array.StringBuilder:
+		switch v := v.(type) {
+		case string:
+			b.Append(v)
+		case json.RawMessage:
+			b.Append(string(v))
+		default:
+			s, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Append(string(s))
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", formatType(t))
+	}
+	return nil
+}
+
+// floatValue returns the float of a value decoded by decodeValue, where NaN and Infinity are strings.
+func floatValue(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return math.NaN(), fmt.Errorf("unexpected value %v", v)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxSessionHistory is the number of audit entries kept in memory for each session.
+const maxSessionHistory = 100
+
+// auditEntry is an audit record of a tool call.
+type auditEntry struct {
+	Index            int            `json:"index,omitempty" jsonschema:"Sequence number of the tool call in the session, starting from 1"`
+	Time             time.Time      `json:"time"`
+	Session          string         `json:"session,omitempty"`
+	RequestID        string         `json:"request_id,omitempty" jsonschema:"ID of the tool call sent with its requests to Spanner"`
+	Tool             string         `json:"tool"`
+	Arguments        map[string]any `json:"arguments,omitempty"`
+	DurationMillis   int64          `json:"duration_millis"`
+	Error            string         `json:"error,omitempty"`
+	Operations       []string       `json:"operations,omitempty" jsonschema:"Names of long-running operations started by the tool call"`
+	CommitTimestamps []time.Time    `json:"commit_timestamps,omitempty"`
+	AffectedRows     *int64         `json:"affected_rows,omitempty"`
+}
+
+// auditLog writes audit entries as JSON Lines and keeps recent entries of each session for session_history.
+type auditLog struct {
+	mu       sync.Mutex
+	w        io.Writer
+	sessions map[string][]*auditEntry
+	// calls are the numbers of tool calls of each session, which index audit entries.
+	calls map[string]int
+}
+
+var audit = &auditLog{sessions: make(map[string][]*auditEntry), calls: make(map[string]int)}
+
+// openAuditLog sets the destination of audit entries.
+// "stderr" writes them to stderr, which is ingested as structured logs on Cloud Run and GKE.
+func openAuditLog(path string) (closeFunc func() error, err error) {
+	switch path {
+	case "":
+		return func() error { return nil }, nil
+	case "stderr":
+		audit.w = os.Stderr
+		return func() error { return nil }, nil
+	default:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		audit.w = f
+		return f.Close, nil
+	}
+}
+
+func (l *auditLog) record(e *auditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.Session != "" {
+		l.calls[e.Session]++
+		e.Index = l.calls[e.Session]
+		history := append(l.sessions[e.Session], e)
+		if len(history) > maxSessionHistory {
+			history = history[len(history)-maxSessionHistory:]
+		}
+		l.sessions[e.Session] = history
+	}
+
+	if l.w == nil {
+		return
+	}
+	b, err := json.Marshal(struct {
+		*auditEntry
+		Type string `json:"type"`
+	}{e, "audit"})
+	if err != nil {
+		slog.Error("failed to marshal audit entry", "error", err)
+		return
+	}
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		slog.Error("failed to write audit entry", "error", err)
+	}
+}
+
+func (l *auditLog) history(session string) []*auditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*auditEntry(nil), l.sessions[session]...)
+}
+
+func (l *auditLog) forgetSession(_ context.Context, session server.ClientSession) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, session.SessionID())
+	delete(l.calls, session.SessionID())
+}
+
+type auditEntryKey struct{}
+
+// auditOperation records the long-running operation started by the current tool call.
+func auditOperation(ctx context.Context, name string) {
+	if e, ok := ctx.Value(auditEntryKey{}).(*auditEntry); ok {
+		e.Operations = append(e.Operations, name)
+	}
+}
+
+// auditCommitTimestamps records commit timestamps of the current tool call.
+func auditCommitTimestamps(ctx context.Context, timestamps ...time.Time) {
+	if e, ok := ctx.Value(auditEntryKey{}).(*auditEntry); ok {
+		e.CommitTimestamps = append(e.CommitTimestamps, timestamps...)
+	}
+}
+
+// auditAffectedRows records the number of rows modified by the current tool call.
+func auditAffectedRows(ctx context.Context, n int64) {
+	if e, ok := ctx.Value(auditEntryKey{}).(*auditEntry); ok {
+		e.AffectedRows = &n
+	}
+}
+
+// redactedArgument replaces values of secret arguments in the audit log.
+const redactedArgument = "REDACTED"
+
+// secretArgumentRe matches names of arguments which must not be written to the audit log.
+var secretArgumentRe = regexp.MustCompile(`(?i)token|password|secret|credential`)
+
+func redactArguments(args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	result := make(map[string]any, len(args))
+	for k, v := range args {
+		if secretArgumentRe.MatchString(k) {
+			v = redactedArgument
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// auditMiddleware records every tool call to the audit log.
+func auditMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		e := &auditEntry{
+			Time:      time.Now(),
+			Session:   sessionID(ctx),
+			RequestID: requestID(ctx),
+			Tool:      request.Params.Name,
+			Arguments: redactArguments(request.GetArguments()),
+		}
+		result, err := next(context.WithValue(ctx, auditEntryKey{}, e), request)
+
+		e.DurationMillis = time.Since(e.Time).Milliseconds()
+		switch {
+		case err != nil:
+			e.Error = err.Error()
+		case result != nil && result.IsError:
+			e.Error = "tool returned error result"
+		}
+		audit.record(e)
+		return result, err
+	}
+}
+
+func sessionHistoryHandler(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id := sessionID(ctx)
+	if id == "" {
+		return nil, fmt.Errorf("session_history requires a stateful MCP session")
+	}
+
+	entries := audit.history(id)
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultStructured(sessionHistoryOutput{Entries: entries}, string(b)), nil
+}
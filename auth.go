@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+)
+
+// authConfig configures verification of the bearer token of HTTP requests.
+// If both token and oidcAudience are empty, requests are not verified.
+type authConfig struct {
+	// token is a static bearer token shared with clients.
+	token string
+
+	// oidcAudience is the expected audience of Google-signed OIDC ID tokens, e.g. the Cloud Run service URL.
+	oidcAudience string
+
+	// allowedPrincipals restricts the email claim of OIDC ID tokens if not empty.
+	allowedPrincipals []string
+}
+
+func (c *authConfig) enabled() bool {
+	return c.token != "" || c.oidcAudience != ""
+}
+
+// middleware rejects requests without a valid bearer token.
+func (c *authConfig) middleware(next http.Handler) http.Handler {
+	if !c.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.verify(r); err != nil {
+			slog.Warn("unauthorized request", "remote_addr", r.RemoteAddr, "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="spanner-mcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *authConfig) verify(r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return fmt.Errorf("missing bearer token")
+	}
+
+	if c.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) == 1 {
+		return nil
+	}
+
+	if c.oidcAudience == "" {
+		return fmt.Errorf("invalid bearer token")
+	}
+
+	payload, err := idtoken.Validate(r.Context(), token, c.oidcAudience)
+	if err != nil {
+		return err
+	}
+
+	if len(c.allowedPrincipals) == 0 {
+		return nil
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	if verified, _ := payload.Claims["email_verified"].(bool); !verified || !slices.Contains(c.allowedPrincipals, email) {
+		return fmt.Errorf("principal %q is not allowed", email)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+func listBackupChainsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[databaseArgs](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := listBackups(ctx, target, req.Database != "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := listBackupChainsOutput{Chains: backupChains(backups)}
+	if len(out.Chains) == 0 {
+		return mcp.NewToolResultStructured(out, "No incremental backups are found"), nil
+	}
+
+	var b strings.Builder
+	for _, c := range out.Chains {
+		fmt.Fprintf(&b, "Chain %s of %s: %d backups, %d bytes, restorable from %s to %s\n", c.ChainID, c.Database, len(c.Backups), c.SizeBytes, c.OldestRestorableTime, c.LatestRestorableTime)
+		if len(c.BackupSchedules) > 0 {
+			fmt.Fprintf(&b, "  schedules: %s\n", strings.Join(c.BackupSchedules, ", "))
+		}
+		for _, backup := range c.Backups {
+			fmt.Fprintf(&b, "  %s\t%s\tversion=%s\texpires=%s\texclusive=%d bytes\tfreeable=%d bytes\t", backup.Backup, backup.State, backup.VersionTime, backup.ExpireTime, backup.ExclusiveSizeBytes, backup.FreeableSizeBytes)
+			if backup.DependsOn == "" {
+				b.WriteString("full\n")
+			} else {
+				fmt.Fprintf(&b, "incremental on %s\n", backup.DependsOn)
+			}
+		}
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// backupChains groups the incremental backups by their chains in the order of version times.
+// The oldest backup in a chain is the full backup, and each incremental backup stores only changes since the previous backup.
+// Deleting a backup merges its exclusive data into the next backup, so every remaining backup stays restorable.
+func backupChains(backups []*databasepb.Backup) []backupChain {
+	grouped := lo.GroupBy(lo.Filter(backups, func(b *databasepb.Backup, _ int) bool { return b.GetIncrementalBackupChainId() != "" }),
+		func(b *databasepb.Backup) string { return b.GetIncrementalBackupChainId() })
+
+	chains := make([]backupChain, 0, len(grouped))
+	for id, members := range grouped {
+		slices.SortFunc(members, func(a, b *databasepb.Backup) int {
+			return a.GetVersionTime().AsTime().Compare(b.GetVersionTime().AsTime())
+		})
+		newest := members[len(members)-1]
+		c := backupChain{
+			ChainID:  id,
+			Database: newest.GetDatabase()[strings.LastIndex(newest.GetDatabase(), "/")+1:],
+			// The size of a backup in a chain includes the exclusive sizes of all older backups in the chain.
+			SizeBytes:         newest.GetSizeBytes(),
+			OldestVersionTime: formatTimestamp(newest.GetOldestVersionTime()),
+		}
+		for i, b := range members {
+			backup := backupChainMember{
+				Backup:             b.GetName()[strings.LastIndex(b.GetName(), "/")+1:],
+				State:              b.GetState().String(),
+				VersionTime:        formatTimestamp(b.GetVersionTime()),
+				ExpireTime:         formatTimestamp(b.GetExpireTime()),
+				ExclusiveSizeBytes: b.GetExclusiveSizeBytes(),
+				FreeableSizeBytes:  b.GetFreeableSizeBytes(),
+			}
+			if i > 0 {
+				backup.DependsOn = c.Backups[i-1].Backup
+			}
+			c.Backups = append(c.Backups, backup)
+			c.BackupSchedules = append(c.BackupSchedules, lo.Map(b.GetBackupSchedules(), func(s string, _ int) string { return s[strings.LastIndex(s, "/")+1:] })...)
+
+			if b.GetState() == databasepb.Backup_READY {
+				if c.OldestRestorableTime == "" {
+					c.OldestRestorableTime = backup.VersionTime
+				}
+				c.LatestRestorableTime = backup.VersionTime
+			}
+		}
+		c.BackupSchedules = lo.Uniq(c.BackupSchedules)
+		chains = append(chains, c)
+	}
+	slices.SortFunc(chains, func(a, b backupChain) int {
+		return cmp.Or(cmp.Compare(a.Database, b.Database), cmp.Compare(a.ChainID, b.ChainID))
+	})
+	return chains
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// Defaults of the thresholds of backup_coverage.
+const (
+	defaultExpiringWithinHours = 7 * 24
+	defaultStaleAfterHours     = 24
+)
+
+func backupCoverageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs        `mapstructure:",squash"`
+		ExpiringWithinHours float64 `mapstructure:"expiring_within_hours"`
+		StaleAfterHours     float64 `mapstructure:"stale_after_hours"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiringWithinHours < 0 {
+		return nil, &validationError{Field: "expiring_within_hours", Message: "must not be negative"}
+	}
+	if req.StaleAfterHours < 0 {
+		return nil, &validationError{Field: "stale_after_hours", Message: "must not be negative"}
+	}
+	expiringWithin := time.Duration(lo.CoalesceOrEmpty(req.ExpiringWithinHours, defaultExpiringWithinHours) * float64(time.Hour))
+	staleAfter := time.Duration(lo.CoalesceOrEmpty(req.StaleAfterHours, defaultStaleAfterHours) * float64(time.Hour))
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	databases, err := listInstanceDatabases(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	backups, err := listBackups(ctx, target, false)
+	if err != nil {
+		return nil, err
+	}
+
+	out := backupCoverage(databases, backups, time.Now(), expiringWithin, staleAfter)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d databases are not backed up, %d have stale backups\n", len(out.Unprotected), len(out.Databases), len(out.Stale))
+	for _, db := range out.Databases {
+		fmt.Fprintf(&b, "%s\t%s", db.Database, db.Status)
+		if db.LatestBackup != "" {
+			fmt.Fprintf(&b, "\tlatest=%s version=%s age=%.1fh", db.LatestBackup, db.LatestVersionTime, db.LatestAgeHours)
+		}
+		fmt.Fprintf(&b, "\tbackups=%d\n", db.Backups)
+		for _, e := range db.Expiring {
+			fmt.Fprintf(&b, "  %s expires at %s%s\n", e.Backup, e.ExpireTime, lo.Ternary(e.Latest, " (the latest backup)", ""))
+		}
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// backupCoverage summarizes the backups of each database at now.
+// Only ready backups protect the database, and a database whose latest ready backup expires within expiringWithin
+// loses its protection unless a new backup is created.
+func backupCoverage(databases []*databasepb.Database, backups []*databasepb.Backup, now time.Time, expiringWithin, staleAfter time.Duration) backupCoverageOutput {
+	ready := lo.GroupBy(lo.Filter(backups, func(b *databasepb.Backup, _ int) bool { return b.GetState() == databasepb.Backup_READY }),
+		func(b *databasepb.Backup) string { return b.GetDatabase() })
+
+	out := backupCoverageOutput{
+		ExpiringWithinHours: expiringWithin.Hours(),
+		StaleAfterHours:     staleAfter.Hours(),
+		Databases:           make([]databaseBackupCoverage, 0, len(databases)),
+	}
+	for _, db := range databases {
+		c := databaseBackupCoverage{
+			Database: db.GetName()[strings.LastIndex(db.GetName(), "/")+1:],
+			Backups:  len(ready[db.GetName()]),
+		}
+		if c.Backups == 0 {
+			c.Status = "unprotected"
+			out.Unprotected = append(out.Unprotected, c.Database)
+			out.Databases = append(out.Databases, c)
+			continue
+		}
+
+		latest := lo.MaxBy(ready[db.GetName()], func(a, b *databasepb.Backup) bool {
+			return a.GetVersionTime().AsTime().After(b.GetVersionTime().AsTime())
+		})
+		c.LatestBackup = latest.GetName()[strings.LastIndex(latest.GetName(), "/")+1:]
+		c.LatestVersionTime = formatTimestamp(latest.GetVersionTime())
+		c.LatestAgeHours = now.Sub(latest.GetVersionTime().AsTime()).Hours()
+		for _, b := range ready[db.GetName()] {
+			if b.GetExpireTime().AsTime().Sub(now) > expiringWithin {
+				continue
+			}
+			c.Expiring = append(c.Expiring, expiringBackup{
+				Backup:     b.GetName()[strings.LastIndex(b.GetName(), "/")+1:],
+				ExpireTime: formatTimestamp(b.GetExpireTime()),
+				Latest:     b == latest,
+			})
+		}
+
+		switch {
+		case now.Sub(latest.GetVersionTime().AsTime()) > staleAfter:
+			c.Status = "stale"
+			out.Stale = append(out.Stale, c.Database)
+		case len(c.Expiring) == c.Backups:
+			c.Status = "expiring"
+		default:
+			c.Status = "protected"
+		}
+		out.Databases = append(out.Databases, c)
+	}
+	return out
+}
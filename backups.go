@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultBackupRetention is the retention of backups created without expire_time.
+const defaultBackupRetention = 7 * 24 * time.Hour
+
+var (
+	backupIDRe   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,58}[a-z0-9]$`)
+	kmsKeyNameRe = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+)
+
+// backupEncryptionTypes are the values of encryption_type of create_backup.
+var backupEncryptionTypes = map[string]databasepb.CreateBackupEncryptionConfig_EncryptionType{
+	"use_database_encryption":     databasepb.CreateBackupEncryptionConfig_USE_DATABASE_ENCRYPTION,
+	"google_default_encryption":   databasepb.CreateBackupEncryptionConfig_GOOGLE_DEFAULT_ENCRYPTION,
+	"customer_managed_encryption": databasepb.CreateBackupEncryptionConfig_CUSTOMER_MANAGED_ENCRYPTION,
+}
+
+func createBackupHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs   `mapstructure:",squash"`
+		BackupID       string   `mapstructure:"backup_id"`
+		ExpireTime     string   `mapstructure:"expire_time"`
+		VersionTime    string   `mapstructure:"version_time"`
+		EncryptionType string   `mapstructure:"encryption_type"`
+		KMSKeyNames    []string `mapstructure:"kms_key_names"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if !backupIDRe.MatchString(req.BackupID) {
+		return nil, &validationError{Field: "backup_id", Message: fmt.Sprintf("%q is not a backup ID of 2-60 lowercase letters, digits, hyphens and underscores starting with a letter", req.BackupID)}
+	}
+	expireTime := time.Now().Add(defaultBackupRetention)
+	if req.ExpireTime != "" {
+		if expireTime, err = time.Parse(time.RFC3339Nano, req.ExpireTime); err != nil {
+			return nil, &validationError{Field: "expire_time", Message: "must be an RFC 3339 timestamp"}
+		}
+	}
+	var versionTime *timestamppb.Timestamp
+	if req.VersionTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, req.VersionTime)
+		if err != nil {
+			return nil, &validationError{Field: "version_time", Message: "must be an RFC 3339 timestamp"}
+		}
+		versionTime = timestamppb.New(t)
+	}
+	encryption, err := backupEncryptionConfig(req.EncryptionType, req.KMSKeyNames)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// CreateBackup is not retried because it fails with AlreadyExists once the first attempt is accepted.
+	op, err := client.CreateBackup(ctx, &databasepb.CreateBackupRequest{
+		Parent:   fmt.Sprintf("projects/%s/instances/%s", target.Project, target.Instance),
+		BackupId: req.BackupID,
+		Backup: &databasepb.Backup{
+			Database:    target.databasePath(),
+			ExpireTime:  timestamppb.New(expireTime),
+			VersionTime: versionTime,
+		},
+		EncryptionConfig: encryption,
+	})
+	if err != nil {
+		return nil, err
+	}
+	auditOperation(ctx, op.Name())
+
+	out := createBackupOutput{
+		Backup:     req.BackupID,
+		Database:   target.Database,
+		Operation:  op.Name(),
+		ExpireTime: expireTime.UTC().Format(time.RFC3339Nano),
+	}
+	return mcp.NewToolResultStructured(out, fmt.Sprintf("Started creating backup %s of %s expiring at %s by operation %s; use list_backups to track the state",
+		req.BackupID, target.databasePath(), out.ExpireTime, op.Name())), nil
+}
+
+// backupEncryptionConfig returns the encryption config of the arguments, or nil to use the default of the API, which is the encryption of the database.
+func backupEncryptionConfig(encryptionType string, kmsKeyNames []string) (*databasepb.CreateBackupEncryptionConfig, error) {
+	if encryptionType == "" {
+		if len(kmsKeyNames) > 0 {
+			return nil, &validationError{Field: "kms_key_names", Message: "requires encryption_type customer_managed_encryption"}
+		}
+		return nil, nil
+	}
+	t, ok := backupEncryptionTypes[encryptionType]
+	if !ok {
+		return nil, &validationError{Field: "encryption_type", Message: "must be use_database_encryption, google_default_encryption or customer_managed_encryption"}
+	}
+	if t != databasepb.CreateBackupEncryptionConfig_CUSTOMER_MANAGED_ENCRYPTION {
+		if len(kmsKeyNames) > 0 {
+			return nil, &validationError{Field: "kms_key_names", Message: "requires encryption_type customer_managed_encryption"}
+		}
+		return &databasepb.CreateBackupEncryptionConfig{EncryptionType: t}, nil
+	}
+
+	if len(kmsKeyNames) == 0 {
+		return nil, &validationError{Field: "kms_key_names", Message: "is required for customer_managed_encryption"}
+	}
+	for _, name := range kmsKeyNames {
+		if !kmsKeyNameRe.MatchString(name) {
+			return nil, &validationError{Field: "kms_key_names", Message: fmt.Sprintf("%q is not projects/{project}/locations/{location}/keyRings/{key_ring}/cryptoKeys/{key}", name)}
+		}
+	}
+	// Multi-region instances require a key in each region of the instance, which is given by kms_key_names instead of kms_key_name.
+	config := &databasepb.CreateBackupEncryptionConfig{EncryptionType: t}
+	if len(kmsKeyNames) == 1 {
+		config.KmsKeyName = kmsKeyNames[0]
+	} else {
+		config.KmsKeyNames = kmsKeyNames
+	}
+	return config, nil
+}
+
+func listBackupsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[databaseArgs](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := listBackups(ctx, target, req.Database != "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := listBackupsOutput{Backups: lo.Map(backups, func(b *databasepb.Backup, _ int) listedBackup { return newListedBackup(b) })}
+	if len(out.Backups) == 0 {
+		return mcp.NewToolResultStructured(out, "No backups are found"), nil
+	}
+
+	var b strings.Builder
+	for _, backup := range out.Backups {
+		fmt.Fprintf(&b, "%s\t%s\t%s\tcreated=%s\texpires=%s\t%d bytes\t%s", backup.Backup, backup.Database, backup.State,
+			backup.CreateTime, backup.ExpireTime, backup.SizeBytes, backup.EncryptionType)
+		if len(backup.KMSKeyVersions) > 0 {
+			fmt.Fprintf(&b, "\t%s", strings.Join(backup.KMSKeyVersions, ","))
+		}
+		b.WriteString("\n")
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// listBackups returns the backups in the instance of the target, or only the backups of the database of the target if ofDatabase is true.
+func listBackups(ctx context.Context, target *profile, ofDatabase bool) ([]*databasepb.Backup, error) {
+	client, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &databasepb.ListBackupsRequest{
+		Parent: fmt.Sprintf("projects/%s/instances/%s", target.Project, target.Instance),
+	}
+	if ofDatabase {
+		req.Filter = fmt.Sprintf("database:%s", target.databasePath())
+	}
+	var backups []*databasepb.Backup
+	it := client.ListBackups(ctx, req)
+	for {
+		backup, err := it.Next()
+		if err == iterator.Done {
+			return backups, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+}
+
+func newListedBackup(b *databasepb.Backup) listedBackup {
+	out := listedBackup{
+		Backup:      b.GetName()[strings.LastIndex(b.GetName(), "/")+1:],
+		Database:    b.GetDatabase()[strings.LastIndex(b.GetDatabase(), "/")+1:],
+		State:       b.GetState().String(),
+		CreateTime:  formatTimestamp(b.GetCreateTime()),
+		VersionTime: formatTimestamp(b.GetVersionTime()),
+		ExpireTime:  formatTimestamp(b.GetExpireTime()),
+		SizeBytes:   b.GetSizeBytes(),
+	}
+
+	// Backups of multi-region instances have encryption information of each region.
+	infos := b.GetEncryptionInformation()
+	if len(infos) == 0 && b.GetEncryptionInfo() != nil {
+		infos = []*databasepb.EncryptionInfo{b.GetEncryptionInfo()}
+	}
+	for _, info := range infos {
+		out.EncryptionType = info.GetEncryptionType().String()
+		if v := info.GetKmsKeyVersion(); v != "" {
+			out.KMSKeyVersions = append(out.KMSKeyVersions, v)
+		}
+		if s := info.GetEncryptionStatus(); s.GetCode() != 0 {
+			out.EncryptionErrors = append(out.EncryptionErrors, s.GetMessage())
+		}
+	}
+	return out
+}
+
+// formatTimestamp formats the timestamp in RFC 3339, or returns an empty string if it is not set.
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339Nano)
+}
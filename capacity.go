@@ -0,0 +1,354 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Defaults and limits of capacity_planning. Cloud Monitoring keeps Spanner metrics for 6 weeks.
+const (
+	defaultCapacityDays        = 14
+	maxCapacityDays            = 42
+	defaultCapacityHorizonDays = 90
+)
+
+func capacityPlanningHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs            `mapstructure:",squash"`
+		Days                    int     `mapstructure:"days"`
+		HorizonDays             int     `mapstructure:"horizon_days"`
+		CPUThresholdPercent     float64 `mapstructure:"cpu_threshold_percent"`
+		StorageThresholdPercent float64 `mapstructure:"storage_threshold_percent"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	days := lo.CoalesceOrEmpty(req.Days, defaultCapacityDays)
+	if days < 2 || days > maxCapacityDays {
+		return nil, &validationError{Field: "days", Message: fmt.Sprintf("must be between 2 and %d", maxCapacityDays)}
+	}
+	horizon := lo.CoalesceOrEmpty(req.HorizonDays, defaultCapacityHorizonDays)
+	if horizon < 1 {
+		return nil, &validationError{Field: "horizon_days", Message: "must be positive"}
+	}
+	for field, v := range map[string]float64{"cpu_threshold_percent": req.CPUThresholdPercent, "storage_threshold_percent": req.StorageThresholdPercent} {
+		if v < 0 || v > 100 {
+			return nil, &validationError{Field: field, Message: "must be between 0 and 100"}
+		}
+	}
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := clients.instanceAdminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var inst *instancepb.Instance
+	err = retry(ctx, func(ctx context.Context) error {
+		inst, err = admin.GetInstance(ctx, &instancepb.GetInstanceRequest{
+			Name: fmt.Sprintf("projects/%s/instances/%s", target.Project, target.Instance),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	config := path.Base(inst.GetConfig())
+	out := capacityPlanningOutput{
+		Instance:        target.Instance,
+		Config:          config,
+		ProcessingUnits: inst.GetProcessingUnits(),
+		Autoscaling:     inst.GetAutoscalingConfig() != nil,
+		Days:            days,
+		HorizonDays:     horizon,
+	}
+
+	m := &capacityMetrics{project: target.Project, instance: target.Instance, end: time.Now().UTC().Truncate(time.Hour)}
+	m.start = m.end.Add(-time.Duration(days) * 24 * time.Hour)
+
+	// Daily peaks of hourly means of high priority CPU utilization, because the threshold is for the peak load.
+	cpu, err := m.daily(ctx, "instance/cpu/utilization_by_priority", []string{"priority", "high"}, slices.Max)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CPU utilization: %w", err)
+	}
+	out.CPU = projectCapacity(cpu, lo.CoalesceOrEmpty(req.CPUThresholdPercent, defaultCPUThresholdPercent(config)), horizon)
+
+	storage, err := m.daily(ctx, "instance/storage/utilization", nil, mean)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage utilization: %w", err)
+	}
+	out.Storage = projectCapacity(storage, lo.CoalesceOrEmpty(req.StorageThresholdPercent, defaultStorageThresholdPercent), horizon)
+
+	out.Databases, err = m.databaseQPS(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request counts: %w", err)
+	}
+
+	out.Recommendation = recommendCapacity(out.ProcessingUnits, out.CPU, out.Storage)
+	if out.Autoscaling {
+		out.Notes = append(out.Notes, "The instance uses managed autoscaling, so processing units are the current capacity chosen by the autoscaler. Apply the recommendation to the autoscaling limits rather than the instance")
+	}
+	if out.CPU.Samples < 2 || out.Storage.Samples < 2 {
+		out.Notes = append(out.Notes, "Fewer than 2 days of metrics are found, so growth is not projected")
+	}
+	return mcp.NewToolResultStructured(out, renderCapacityPlanning(out)), nil
+}
+
+// capacityMetrics reads metrics of the instance from Cloud Monitoring in the interval.
+type capacityMetrics struct {
+	project, instance string
+	start, end        time.Time
+}
+
+func (m *capacityMetrics) filter(metric string, labels ...string) string {
+	s := fmt.Sprintf(`resource.type = "spanner_instance" AND resource.labels.instance_id = %q AND metric.type = "spanner.googleapis.com/%s"`, m.instance, metric)
+	for i := 0; i+1 < len(labels); i += 2 {
+		s += fmt.Sprintf(` AND metric.labels.%s = %q`, labels[i], labels[i+1])
+	}
+	return s
+}
+
+// list returns the time series of the metric aggregated by the aggregation.
+func (m *capacityMetrics) list(ctx context.Context, filter string, aggregation *monitoringpb.Aggregation) ([]*monitoringpb.TimeSeries, error) {
+	client, err := clients.metricClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var series []*monitoringpb.TimeSeries
+	err = retry(ctx, func(ctx context.Context) error {
+		series = nil
+		it := client.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{
+			Name:        "projects/" + m.project,
+			Filter:      filter,
+			Interval:    &monitoringpb.TimeInterval{StartTime: timestamppb.New(m.start), EndTime: timestamppb.New(m.end)},
+			Aggregation: aggregation,
+			View:        monitoringpb.ListTimeSeriesRequest_FULL,
+		})
+		for {
+			ts, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			series = append(series, ts)
+		}
+	})
+	return series, err
+}
+
+// daily returns daily values of the utilization metric in percent, which reduce hourly means of the day by reduce.
+// Values of databases and other labels are summed for the instance.
+func (m *capacityMetrics) daily(ctx context.Context, metric string, labels []string, reduce func([]float64) float64) ([]capacitySample, error) {
+	series, err := m.list(ctx, m.filter(metric, labels...), &monitoringpb.Aggregation{
+		AlignmentPeriod:    durationpb.New(time.Hour),
+		PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_MEAN,
+		CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+		GroupByFields:      []string{"resource.label.instance_id"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	hourly := make(map[string][]float64)
+	for _, ts := range series {
+		for _, p := range ts.GetPoints() {
+			day := p.GetInterval().GetEndTime().AsTime().UTC().Format(time.DateOnly)
+			hourly[day] = append(hourly[day], p.GetValue().GetDoubleValue()*100)
+		}
+	}
+	samples := lo.MapToSlice(hourly, func(day string, values []float64) capacitySample {
+		return capacitySample{Date: day, Percent: reduce(values)}
+	})
+	slices.SortFunc(samples, func(a, b capacitySample) int { return strings.Compare(a.Date, b.Date) })
+	return samples, nil
+}
+
+// databaseQPS returns the mean QPS of the interval and of the last day by database.
+func (m *capacityMetrics) databaseQPS(ctx context.Context) ([]databaseQPS, error) {
+	series, err := m.list(ctx, m.filter("api/request_count"), &monitoringpb.Aggregation{
+		AlignmentPeriod:    durationpb.New(24 * time.Hour),
+		PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_RATE,
+		CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+		GroupByFields:      []string{"metric.label.database"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []databaseQPS
+	for _, ts := range series {
+		// Points are in the reverse order of time.
+		points := lo.Map(ts.GetPoints(), func(p *monitoringpb.Point, _ int) float64 { return p.GetValue().GetDoubleValue() })
+		if len(points) == 0 {
+			continue
+		}
+		out = append(out, databaseQPS{
+			Database:   ts.GetMetric().GetLabels()["database"],
+			MeanQPS:    mean(points),
+			LastDayQPS: points[0],
+		})
+	}
+	slices.SortFunc(out, func(a, b databaseQPS) int { return cmp.Compare(b.MeanQPS, a.MeanQPS) })
+	return out, nil
+}
+
+func mean(values []float64) float64 {
+	return lo.Sum(values) / float64(len(values))
+}
+
+// projectCapacity fits daily values by least squares, and projects them to the horizon and the threshold.
+func projectCapacity(samples []capacitySample, threshold float64, horizon int) capacityProjection {
+	p := capacityProjection{ThresholdPercent: threshold, Samples: len(samples), Daily: samples}
+	if len(samples) == 0 {
+		return p
+	}
+	p.PeakPercent = lo.MaxBy(samples, func(a, b capacitySample) bool { return a.Percent > b.Percent }).Percent
+	if len(samples) < 2 {
+		p.CurrentPercent = samples[0].Percent
+		p.ProjectedPercent = p.CurrentPercent
+		return p
+	}
+
+	// x is the index of the day, so missing days are not weighted.
+	n := float64(len(samples))
+	var sx, sy, sxx, sxy float64
+	for i, s := range samples {
+		x := float64(i)
+		sx += x
+		sy += s.Percent
+		sxx += x * x
+		sxy += x * s.Percent
+	}
+	slope := (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	intercept := (sy - slope*sx) / n
+
+	p.GrowthPercentPerDay = slope
+	p.CurrentPercent = math.Max(intercept+slope*(n-1), 0)
+	p.ProjectedPercent = math.Max(p.CurrentPercent+slope*float64(horizon), 0)
+	switch {
+	case p.CurrentPercent >= threshold:
+		p.DaysToThreshold = lo.ToPtr(0.0)
+	case slope > 0:
+		p.DaysToThreshold = lo.ToPtr((threshold - p.CurrentPercent) / slope)
+	}
+	return p
+}
+
+// recommendCapacity scales the processing units so that the utilizations are at most the thresholds.
+// Instances of 1000 processing units or more are scaled by nodes, and smaller ones by 100 processing units.
+func recommendCapacity(processingUnits int32, cpu, storage capacityProjection) capacityRecommendation {
+	required := func(percent func(capacityProjection) float64) int32 {
+		var units float64
+		for _, p := range []capacityProjection{cpu, storage} {
+			if p.Samples > 0 && p.ThresholdPercent > 0 {
+				units = math.Max(units, float64(processingUnits)*percent(p)/p.ThresholdPercent)
+			}
+		}
+		return roundProcessingUnits(units)
+	}
+	r := capacityRecommendation{
+		ProcessingUnits:        required(func(p capacityProjection) float64 { return math.Max(p.CurrentPercent, p.PeakPercent) }),
+		HorizonProcessingUnits: required(func(p capacityProjection) float64 { return math.Max(p.ProjectedPercent, p.PeakPercent) }),
+	}
+	r.Nodes = float64(r.ProcessingUnits) / 1000
+	r.HorizonNodes = float64(r.HorizonProcessingUnits) / 1000
+	return r
+}
+
+func roundProcessingUnits(units float64) int32 {
+	if units <= 1000 {
+		return int32(math.Max(math.Ceil(units/100), 1)) * 100
+	}
+	return int32(math.Ceil(units/1000)) * 1000
+}
+
+// formatDays formats the number of days roughly, e.g. ~6 weeks.
+func formatDays(days float64) string {
+	switch {
+	case days < 1:
+		return "less than a day"
+	case days < 14:
+		return fmt.Sprintf("~%.0f days", days)
+	case days < 120:
+		return fmt.Sprintf("~%.0f weeks", days/7)
+	default:
+		return fmt.Sprintf("~%.0f months", days/30)
+	}
+}
+
+func renderCapacityPlanning(out capacityPlanningOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Instance %s (%s, %d processing units%s), metrics of the last %d days:\n\n",
+		out.Instance, out.Config, out.ProcessingUnits, lo.Ternary(out.Autoscaling, ", autoscaling", ""), out.Days)
+
+	table := newTable(&b)
+	table.SetHeader([]string{"Metric", "Current %", "Peak %", "Growth %/day", fmt.Sprintf("In %d days %%", out.HorizonDays), "Threshold %"})
+	for _, p := range []struct {
+		name string
+		capacityProjection
+	}{{"High priority CPU (daily peak)", out.CPU}, {"Storage (daily mean)", out.Storage}} {
+		table.Append([]string{p.name, fmt.Sprintf("%.1f", p.CurrentPercent), fmt.Sprintf("%.1f", p.PeakPercent),
+			fmt.Sprintf("%+.2f", p.GrowthPercentPerDay), fmt.Sprintf("%.1f", p.ProjectedPercent), fmt.Sprintf("%.0f", p.ThresholdPercent)})
+	}
+	table.Render()
+
+	b.WriteString("\n")
+	for _, p := range []struct {
+		name string
+		capacityProjection
+	}{{"high priority CPU utilization", out.CPU}, {"storage utilization", out.Storage}} {
+		switch d := p.DaysToThreshold; {
+		case p.Samples == 0:
+			fmt.Fprintf(&b, "No metrics of %s are found.\n", p.name)
+		case d != nil && *d == 0:
+			fmt.Fprintf(&b, "%s is already over %.0f%%.\n", upperFirst(p.name), p.ThresholdPercent)
+		case d != nil:
+			fmt.Fprintf(&b, "At the current growth, %s exceeds %.0f%% in %s.\n", p.name, p.ThresholdPercent, formatDays(*d))
+		default:
+			fmt.Fprintf(&b, "%s is not growing toward %.0f%%.\n", upperFirst(p.name), p.ThresholdPercent)
+		}
+	}
+
+	r := out.Recommendation
+	fmt.Fprintf(&b, "\nRecommended compute capacity: %d processing units (%g nodes) now, %d processing units (%g nodes) in %d days.\n",
+		r.ProcessingUnits, r.Nodes, r.HorizonProcessingUnits, r.HorizonNodes, out.HorizonDays)
+
+	if len(out.Databases) > 0 {
+		b.WriteString("\nQPS by database:\n")
+		table := newTable(&b)
+		table.SetHeader([]string{"Database", "Mean QPS", "Last day QPS"})
+		for _, d := range out.Databases {
+			table.Append([]string{d.Database, fmt.Sprintf("%.1f", d.MeanQPS), fmt.Sprintf("%.1f", d.LastDayQPS)})
+		}
+		table.Render()
+	}
+	for _, note := range out.Notes {
+		fmt.Fprintf(&b, "\nNote: %s\n", note)
+	}
+	return b.String()
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// catalogParamRe matches parameters of catalog queries, which are written as @p1, @p2, ... for both dialects.
+var catalogParamRe = regexp.MustCompile(`@p(\d+)`)
+
+// catalogSystemSchemas excludes the schemas of system views from catalog queries of both dialects.
+const catalogSystemSchemas = `('INFORMATION_SCHEMA', 'SPANNER_SYS', 'information_schema', 'spanner_sys', 'pg_catalog')`
+
+// schemaCatalog queries INFORMATION_SCHEMA of either dialect. PostgreSQL-dialect databases have the same views in lower case
+// with positional parameters, so the queries are written once, and column names of the results are in upper case for both dialects.
+type schemaCatalog struct {
+	client     *spanner.Client
+	postgreSQL bool
+}
+
+func newSchemaCatalog(ctx context.Context, target *profile, client *spanner.Client) (*schemaCatalog, error) {
+	features, err := detectFeatures(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaCatalog{client: client, postgreSQL: features.Dialect == databasepb.DatabaseDialect_POSTGRESQL}, nil
+}
+
+func (c *schemaCatalog) dialect() string {
+	return lo.Ternary(c.postgreSQL, databasepb.DatabaseDialect_POSTGRESQL, databasepb.DatabaseDialect_GOOGLE_STANDARD_SQL).String()
+}
+
+// defaultSchema is the schema of unqualified table names.
+func (c *schemaCatalog) defaultSchema() string {
+	return lo.Ternary(c.postgreSQL, "public", "")
+}
+
+// splitTable splits the table name optionally qualified by the named schema.
+func (c *schemaCatalog) splitTable(table string) (schema, name string) {
+	table = strings.NewReplacer("`", "", `"`, "").Replace(table)
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		return schema, name
+	}
+	return c.defaultSchema(), table
+}
+
+// qualifiedName qualifies the table name by the schema unless it is the default schema.
+func (c *schemaCatalog) qualifiedName(schema, name string) string {
+	return qualifiedTableName(lo.Ternary(schema == c.defaultSchema(), "", schema), name)
+}
+
+// query runs the catalog query with the arguments bound to @p1, @p2, ... in order.
+func (c *schemaCatalog) query(ctx context.Context, sql string, args ...any) ([]map[string]any, error) {
+	if c.postgreSQL {
+		sql = catalogParamRe.ReplaceAllString(sql, `$$$1`)
+	}
+	params := make(map[string]any, len(args))
+	for i, arg := range args {
+		params[fmt.Sprintf("p%d", i+1)] = arg
+	}
+	rows, err := queryRows(ctx, c.client, spanner.Statement{SQL: sql, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(rows, func(row map[string]any, _ int) map[string]any {
+		return lo.MapKeys(row, func(_ any, key string) string { return strings.ToUpper(key) })
+	}), nil
+}
+
+// catalogString returns the string value of the column, or empty if it is NULL.
+func catalogString(row map[string]any, column string) string {
+	s, _ := row[column].(string)
+	return s
+}
+
+// catalogBool returns the boolean value of the column, which is BOOL in GoogleSQL and 'YES' or 'NO' in PostgreSQL.
+func catalogBool(row map[string]any, column string) bool {
+	switch v := row[column].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "YES")
+	default:
+		return false
+	}
+}
+
+func (c *schemaCatalog) tables(ctx context.Context, schema string) ([]catalogTable, error) {
+	sql := `SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, PARENT_TABLE_NAME, ON_DELETE_ACTION
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_SCHEMA NOT IN ` + catalogSystemSchemas
+	var args []any
+	if schema != "" {
+		sql += " AND TABLE_SCHEMA = @p1"
+		args = append(args, schema)
+	}
+	rows, err := c.query(ctx, sql+"\nORDER BY TABLE_SCHEMA, TABLE_NAME", args...)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(rows, func(row map[string]any, _ int) catalogTable {
+		return catalogTable{
+			Name:     c.qualifiedName(catalogString(row, "TABLE_SCHEMA"), catalogString(row, "TABLE_NAME")),
+			Type:     catalogString(row, "TABLE_TYPE"),
+			Parent:   catalogString(row, "PARENT_TABLE_NAME"),
+			OnDelete: catalogString(row, "ON_DELETE_ACTION"),
+		}
+	}), nil
+}
+
+// describe returns the table, its columns and its secondary indexes, or nil if the table is not found.
+func (c *schemaCatalog) describe(ctx context.Context, table string) (*describeTableOutput, error) {
+	schema, name := c.splitTable(table)
+	tables, err := c.query(ctx, `SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, PARENT_TABLE_NAME, ON_DELETE_ACTION
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_SCHEMA = @p1 AND TABLE_NAME = @p2`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, nil
+	}
+
+	columns, err := c.query(ctx, `SELECT c.COLUMN_NAME, c.SPANNER_TYPE, c.IS_NULLABLE, c.COLUMN_DEFAULT, c.GENERATION_EXPRESSION,
+  ic.ORDINAL_POSITION AS PRIMARY_KEY_POSITION, ic.COLUMN_ORDERING AS PRIMARY_KEY_ORDERING
+FROM INFORMATION_SCHEMA.COLUMNS AS c
+LEFT JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+  ON ic.TABLE_SCHEMA = c.TABLE_SCHEMA AND ic.TABLE_NAME = c.TABLE_NAME
+  AND ic.COLUMN_NAME = c.COLUMN_NAME AND ic.INDEX_TYPE = 'PRIMARY_KEY'
+WHERE c.TABLE_SCHEMA = @p1 AND c.TABLE_NAME = @p2
+ORDER BY c.ORDINAL_POSITION`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := c.query(ctx, `SELECT i.INDEX_NAME, i.INDEX_TYPE, i.IS_UNIQUE, i.IS_NULL_FILTERED, i.PARENT_TABLE_NAME, i.INDEX_STATE,
+  ic.COLUMN_NAME, ic.COLUMN_ORDERING
+FROM INFORMATION_SCHEMA.INDEXES AS i
+JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+  ON ic.TABLE_SCHEMA = i.TABLE_SCHEMA AND ic.TABLE_NAME = i.TABLE_NAME AND ic.INDEX_NAME = i.INDEX_NAME
+WHERE i.TABLE_SCHEMA = @p1 AND i.TABLE_NAME = @p2 AND i.INDEX_TYPE != 'PRIMARY_KEY' AND ic.ORDINAL_POSITION IS NOT NULL
+ORDER BY i.INDEX_NAME, ic.ORDINAL_POSITION`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+
+	commitTimestamps, err := c.commitTimestampColumns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	allowCommitTimestamp := lo.SliceToMap(commitTimestamps, func(c commitTimestampColumn) (string, bool) { return c.Column, true })
+
+	t := tables[0]
+	out := &describeTableOutput{
+		Dialect: c.dialect(),
+		Table: catalogTable{
+			Name:     c.qualifiedName(schema, name),
+			Type:     catalogString(t, "TABLE_TYPE"),
+			Parent:   catalogString(t, "PARENT_TABLE_NAME"),
+			OnDelete: catalogString(t, "ON_DELETE_ACTION"),
+		},
+		Indexes: []catalogIndex{},
+	}
+	var keyColumns []map[string]any
+	for _, row := range columns {
+		out.Columns = append(out.Columns, catalogColumn{
+			Name:       catalogString(row, "COLUMN_NAME"),
+			Type:       catalogString(row, "SPANNER_TYPE"),
+			Nullable:   catalogBool(row, "IS_NULLABLE"),
+			Default:    catalogString(row, "COLUMN_DEFAULT"),
+			Generation: catalogString(row, "GENERATION_EXPRESSION"),
+
+			CommitTimestamp: allowCommitTimestamp[catalogString(row, "COLUMN_NAME")],
+		})
+		if row["PRIMARY_KEY_POSITION"] != nil {
+			keyColumns = append(keyColumns, row)
+		}
+	}
+	// Columns are in the order of the table, which may differ from the order of the primary key.
+	slices.SortFunc(keyColumns, func(a, b map[string]any) int {
+		return cmp.Compare(a["PRIMARY_KEY_POSITION"].(int64), b["PRIMARY_KEY_POSITION"].(int64))
+	})
+	out.PrimaryKey = lo.Map(keyColumns, func(row map[string]any, _ int) string {
+		return catalogKeyColumn(catalogString(row, "COLUMN_NAME"), catalogString(row, "PRIMARY_KEY_ORDERING"))
+	})
+
+	for _, group := range lo.PartitionBy(indexes, func(row map[string]any) string { return catalogString(row, "INDEX_NAME") }) {
+		i := group[0]
+		out.Indexes = append(out.Indexes, catalogIndex{
+			Name: catalogString(i, "INDEX_NAME"),
+			Type: catalogString(i, "INDEX_TYPE"),
+			Columns: lo.Map(group, func(row map[string]any, _ int) string {
+				return catalogKeyColumn(catalogString(row, "COLUMN_NAME"), catalogString(row, "COLUMN_ORDERING"))
+			}),
+			Unique:       catalogBool(i, "IS_UNIQUE"),
+			NullFiltered: catalogBool(i, "IS_NULL_FILTERED"),
+			Parent:       catalogString(i, "PARENT_TABLE_NAME"),
+			State:        catalogString(i, "INDEX_STATE"),
+		})
+	}
+	return out, nil
+}
+
+// catalogKeyColumn returns the column of a key with DESC if it is descending.
+func catalogKeyColumn(column, ordering string) string {
+	if strings.EqualFold(ordering, "DESC") {
+		return column + " DESC"
+	}
+	return column
+}
+
+func listTablesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Schema    string `mapstructure:"schema"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	catalog, err := newSchemaCatalog(ctx, target, client)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := catalog.tables(ctx, req.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	out := listTablesOutput{Dialect: catalog.dialect(), Tables: tables}
+	if len(tables) == 0 {
+		return mcp.NewToolResultStructured(out, "No tables\n"), nil
+	}
+	var b strings.Builder
+	table := newTable(&b)
+	table.SetHeader([]string{"Table", "Type", "Parent", "On delete"})
+	for _, t := range tables {
+		table.Append([]string{t.Name, t.Type, t.Parent, t.OnDelete})
+	}
+	table.Render()
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+func describeTableHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Table == "" {
+		return nil, &validationError{Field: "table", Message: "is required"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	catalog, err := newSchemaCatalog(ctx, target, client)
+	if err != nil {
+		return nil, err
+	}
+	out, err := catalog.describe(ctx, req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, &validationError{Field: "table", Message: fmt.Sprintf("table %s is not found in %s; call list_tables to see the tables", req.Table, target.databasePath())}
+	}
+
+	// GetDatabaseDdl returns DDL in the dialect of the database.
+	statements, err := databaseStatements(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	out.DDL = tableStatements(statements, out.Table.Name)
+	return mcp.NewToolResultStructured(out, renderDescribeTable(out)), nil
+}
+
+func renderDescribeTable(out *describeTableOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (%s)", lo.CoalesceOrEmpty(out.Table.Type, "BASE TABLE"), out.Table.Name, out.Dialect)
+	if out.Table.Parent != "" {
+		fmt.Fprintf(&b, " interleaved in %s ON DELETE %s", out.Table.Parent, lo.CoalesceOrEmpty(out.Table.OnDelete, "NO ACTION"))
+	}
+	fmt.Fprintf(&b, "\nPrimary key: (%s)\n", strings.Join(out.PrimaryKey, ", "))
+
+	table := newTable(&b)
+	table.SetHeader([]string{"Column", "Type", "Nullable", "Default", "Generated"})
+	for _, c := range out.Columns {
+		table.Append([]string{c.Name, c.Type + lo.Ternary(c.CommitTimestamp, " (commit timestamp)", ""), lo.Ternary(c.Nullable, "YES", "NO"), c.Default, c.Generation})
+	}
+	table.Render()
+
+	if len(out.Indexes) > 0 {
+		b.WriteString("Indexes:\n")
+		for _, i := range out.Indexes {
+			fmt.Fprintf(&b, "- %s (%s)", i.Name, strings.Join(i.Columns, ", "))
+			if i.Unique {
+				b.WriteString(" UNIQUE")
+			}
+			if i.NullFiltered {
+				b.WriteString(" NULL_FILTERED")
+			}
+			if i.Parent != "" {
+				fmt.Fprintf(&b, " interleaved in %s", i.Parent)
+			}
+			if i.State != "" && i.State != "READ_WRITE" {
+				fmt.Fprintf(&b, " %s", i.State)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(out.DDL) > 0 {
+		b.WriteString("DDL:\n")
+		b.WriteString(formatStatements(out.DDL))
+	}
+	return b.String()
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"github.com/xlab/treeprint"
+	"golang.org/x/sync/errgroup"
+)
+
+var changeStreamNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// changeRecord is a element of the ChangeRecord column of the GoogleSQL change stream query.
+type changeRecord struct {
+	DataChangeRecord      []json.RawMessage        `json:"data_change_record"`
+	HeartbeatRecord       []json.RawMessage        `json:"heartbeat_record"`
+	ChildPartitionsRecord []*childPartitionsRecord `json:"child_partitions_record"`
+}
+
+type childPartitionsRecord struct {
+	StartTimestamp  time.Time         `json:"start_timestamp"`
+	RecordSequence  string            `json:"record_sequence"`
+	ChildPartitions []*childPartition `json:"child_partitions"`
+}
+
+type childPartition struct {
+	Token                 string   `json:"token"`
+	ParentPartitionTokens []string `json:"parent_partition_tokens"`
+}
+
+// changeStreamReader reads a change stream and follows its child partitions until the end timestamp.
+type changeStreamReader struct {
+	client    *spanner.Client
+	stream    string
+	end       time.Time
+	heartbeat time.Duration
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// newChangeStreamReader returns a reader of the change stream of the target database, which must be GoogleSQL
+// because change streams of PostgreSQL-dialect databases are read by spanner.read_json_<stream> in another format.
+func newChangeStreamReader(ctx context.Context, target *profile, client *spanner.Client, stream string, end time.Time) (*changeStreamReader, error) {
+	if !changeStreamNameRe.MatchString(stream) {
+		return nil, &validationError{Field: "change_stream", Message: fmt.Sprintf("%q is not a valid change stream name", stream)}
+	}
+	features, err := detectFeatures(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if features.Dialect == databasepb.DatabaseDialect_POSTGRESQL {
+		return nil, &validationError{Field: "change_stream", Message: "change streams of PostgreSQL-dialect databases are not supported"}
+	}
+
+	return &changeStreamReader{
+		client:    client,
+		stream:    stream,
+		end:       end,
+		heartbeat: 10 * time.Second,
+		seen:      make(map[string]bool),
+	}, nil
+}
+
+// readPartition runs the change stream query for the partition. Empty token means the initial query.
+func (r *changeStreamReader) readPartition(ctx context.Context, token string, start time.Time, fn func(*changeRecord) error) error {
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT ChangeRecord FROM READ_%s(
+  start_timestamp => @start,
+  end_timestamp => @end,
+  partition_token => @token,
+  heartbeat_milliseconds => @heartbeat
+)`, r.stream),
+		Params: map[string]any{
+			"start":     start,
+			"end":       r.end,
+			"token":     spanner.NullString{StringVal: token, Valid: token != ""},
+			"heartbeat": r.heartbeat.Milliseconds(),
+		},
+	}
+
+	return r.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var gcv spanner.GenericColumnValue
+		if err := row.Column(0, &gcv); err != nil {
+			return err
+		}
+
+		v, err := decodeGenericColumnValue(gcv)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		var records []*changeRecord
+		if err := json.Unmarshal(b, &records); err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// follow reads the partition and all of its descendant partitions concurrently.
+// fn is called with the token of the partition which the record is read from, and may be called concurrently.
+func (r *changeStreamReader) follow(ctx context.Context, token string, start time.Time, fn func(token string, record *changeRecord) error) error {
+	g, ctx := errgroup.WithContext(ctx)
+
+	var read func(token string, start time.Time)
+	read = func(token string, start time.Time) {
+		g.Go(func() error {
+			return r.readPartition(ctx, token, start, func(record *changeRecord) error {
+				for _, cpr := range record.ChildPartitionsRecord {
+					for _, child := range cpr.ChildPartitions {
+						// A merged partition is reported by all of its parents.
+						if r.markSeen(child.Token) {
+							read(child.Token, cpr.StartTimestamp)
+						}
+					}
+				}
+				return fn(token, record)
+			})
+		})
+	}
+
+	read(token, start)
+	return g.Wait()
+}
+
+func (r *changeStreamReader) markSeen(token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.seen[token] {
+		return false
+	}
+	r.seen[token] = true
+	return true
+}
+
+var errMaxRecordsReached = errors.New("max records reached")
+
+func tailChangeStreamHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs       `mapstructure:",squash"`
+		ChangeStream    string `mapstructure:"change_stream"`
+		DurationSeconds int    `mapstructure:"duration_seconds"`
+		MaxRecords      int    `mapstructure:"max_records"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DurationSeconds <= 0 {
+		req.DurationSeconds = 10
+	}
+	if req.MaxRecords <= 0 {
+		req.MaxRecords = 100
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	start := time.Now()
+	end := start.Add(time.Duration(req.DurationSeconds) * time.Second)
+	reader, err := newChangeStreamReader(ctx, target, client, req.ChangeStream, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var records []json.RawMessage
+	err = reader.follow(ctx, "", start, func(_ string, record *changeRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, dcr := range record.DataChangeRecord {
+			if len(records) >= req.MaxRecords {
+				return errMaxRecordsReached
+			}
+			dcr, ok := cfg.Access.filterDataChangeRecord(dcr)
+			if !ok {
+				continue
+			}
+			dcr = cfg.Masking.maskDataChangeRecord(dcr)
+			records = append(records, dcr)
+			sendLogNotification(ctx, mcp.LoggingLevelInfo, dcr)
+			sendProgressNotification(ctx, request, len(records), req.MaxRecords)
+		}
+
+		if len(records) >= req.MaxRecords {
+			return errMaxRecordsReached
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errMaxRecordsReached) {
+		return nil, err
+	}
+
+	recordRows(ctx, len(records))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d data change records of %s from %s to %s\n",
+		len(records), req.ChangeStream, start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano))
+	for _, record := range records {
+		b.Write(record)
+		b.WriteString("\n")
+	}
+
+	return mcp.NewToolResultStructured(tailChangeStreamOutput{
+		ChangeStream:      req.ChangeStream,
+		StartTimestamp:    start,
+		EndTimestamp:      end,
+		DataChangeRecords: lo.ToAnySlice(records),
+	}, b.String()), nil
+}
+
+// partitionInfo is a node of the partition lineage of a change stream.
+type partitionInfo struct {
+	ID                string    `json:"id"`
+	Token             string    `json:"token"`
+	StartTimestamp    time.Time `json:"start_timestamp"`
+	Parents           []string  `json:"parents,omitempty"`
+	Children          []string  `json:"children,omitempty"`
+	DataChangeRecords int       `json:"data_change_records"`
+	ChildPartitionsAt time.Time `json:"child_partitions_at,omitzero"`
+}
+
+// partitionTopology collects the partition lineage from child partitions records.
+type partitionTopology struct {
+	mu         sync.Mutex
+	partitions map[string]*partitionInfo
+}
+
+func (t *partitionTopology) partition(token string) *partitionInfo {
+	p, ok := t.partitions[token]
+	if !ok {
+		p = &partitionInfo{Token: token}
+		t.partitions[token] = p
+	}
+	return p
+}
+
+func (t *partitionTopology) add(token string, record *changeRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The initial query is not a real partition.
+	if token != "" {
+		t.partition(token).DataChangeRecords += len(record.DataChangeRecord)
+	}
+
+	for _, cpr := range record.ChildPartitionsRecord {
+		if token != "" {
+			t.partition(token).ChildPartitionsAt = cpr.StartTimestamp
+		}
+		for _, cp := range cpr.ChildPartitions {
+			child := t.partition(cp.Token)
+			child.StartTimestamp = cpr.StartTimestamp
+			child.Parents = cp.ParentPartitionTokens
+			if token != "" && !lo.Contains(t.partition(token).Children, cp.Token) {
+				t.partition(token).Children = append(t.partition(token).Children, cp.Token)
+			}
+		}
+	}
+}
+
+// sorted assigns short IDs in order of start timestamp and returns partitions in the order.
+func (t *partitionTopology) sorted() []*partitionInfo {
+	partitions := lo.Values(t.partitions)
+	slices.SortStableFunc(partitions, func(a, b *partitionInfo) int {
+		if c := a.StartTimestamp.Compare(b.StartTimestamp); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Token, b.Token)
+	})
+	for i, p := range partitions {
+		p.ID = fmt.Sprintf("P%d", i+1)
+	}
+	return partitions
+}
+
+func (t *partitionTopology) render(partitions []*partitionInfo) string {
+	idOf := func(token string) string {
+		if p, ok := t.partitions[token]; ok {
+			return p.ID
+		}
+		return "?"
+	}
+
+	tree := treeprint.NewWithRoot("change stream")
+	rendered := make(map[string]bool)
+	var add func(parent treeprint.Tree, p *partitionInfo)
+	add = func(parent treeprint.Tree, p *partitionInfo) {
+		label := fmt.Sprintf("%s start=%s records=%d", p.ID, p.StartTimestamp.UTC().Format(time.RFC3339Nano), p.DataChangeRecords)
+		if len(p.Parents) > 1 {
+			label += " merged from " + strings.Join(lo.Map(p.Parents, func(token string, _ int) string { return idOf(token) }), ",")
+		}
+		if rendered[p.Token] {
+			parent.AddNode(label + " (see above)")
+			return
+		}
+		rendered[p.Token] = true
+
+		switch {
+		case len(p.Children) > 1:
+			label += fmt.Sprintf(" split at %s", p.ChildPartitionsAt.UTC().Format(time.RFC3339Nano))
+		case len(p.Children) == 1 && len(t.partitions[p.Children[0]].Parents) > 1:
+			label += fmt.Sprintf(" merged at %s", p.ChildPartitionsAt.UTC().Format(time.RFC3339Nano))
+		case len(p.Children) == 1:
+			label += fmt.Sprintf(" moved at %s", p.ChildPartitionsAt.UTC().Format(time.RFC3339Nano))
+		case p.ChildPartitionsAt.IsZero():
+			label += " active"
+		}
+
+		branch := parent.AddBranch(label)
+		for _, child := range p.Children {
+			add(branch, t.partitions[child])
+		}
+	}
+
+	for _, p := range partitions {
+		if len(p.Parents) == 0 {
+			add(tree, p)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(tree.String())
+	b.WriteString("\nPartition tokens:\n")
+	for _, p := range partitions {
+		fmt.Fprintf(&b, " %s: %s\n", p.ID, p.Token)
+	}
+	return b.String()
+}
+
+func inspectChangeStreamPartitionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs      `mapstructure:",squash"`
+		ChangeStream   string `mapstructure:"change_stream"`
+		StartTimestamp string `mapstructure:"start_timestamp"`
+		EndTimestamp   string `mapstructure:"end_timestamp"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	if req.EndTimestamp != "" {
+		if end, err = time.Parse(time.RFC3339Nano, req.EndTimestamp); err != nil {
+			return nil, fmt.Errorf("invalid end_timestamp: %w", err)
+		}
+	}
+
+	start := end.Add(-10 * time.Minute)
+	if req.StartTimestamp != "" {
+		if start, err = time.Parse(time.RFC3339Nano, req.StartTimestamp); err != nil {
+			return nil, fmt.Errorf("invalid start_timestamp: %w", err)
+		}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	reader, err := newChangeStreamReader(ctx, target, client, req.ChangeStream, end)
+	if err != nil {
+		return nil, err
+	}
+
+	topology := &partitionTopology{partitions: make(map[string]*partitionInfo)}
+	err = reader.follow(ctx, "", start, func(token string, record *changeRecord) error {
+		topology.add(token, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := topology.sorted()
+	b, err := json.MarshalIndent(partitions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(string(b)),
+			mcp.NewTextContent(topology.render(partitions)),
+		},
+		StructuredContent: inspectChangeStreamPartitionsOutput{Partitions: partitions},
+	}, nil
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/storage"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var errClientCacheClosed = errors.New("client cache is closed")
+
+// clients is the client cache shared by tool handlers. It is initialized in main.
+var clients *clientCache
+
+// clientCache caches Spanner clients across tool calls to avoid session pool warmup on every call.
+// Clients which are not used for idleTimeout are closed.
+type clientCache struct {
+	idleTimeout time.Duration
+	opts        clientOptions
+	clientOpts  []option.ClientOption
+
+	mu       sync.Mutex
+	entries  map[profile]*cachedClient
+	pending  map[profile]*pendingClient
+	admin    *database.DatabaseAdminClient
+	instance *instance.InstanceAdminClient
+	storage  *storage.Client
+	dataflow *dataflow.Service
+	metrics  *monitoring.MetricClient
+	closed   bool
+	done     chan struct{}
+}
+
+type cachedClient struct {
+	client   *spanner.Client
+	inUse    int
+	lastUsed time.Time
+}
+
+// pendingClient is a client being created outside of the lock. Concurrent calls for the same profile wait for done.
+type pendingClient struct {
+	done chan struct{}
+	err  error
+}
+
+func newClientCache(ctx context.Context, idleTimeout time.Duration, opts clientOptions) (*clientCache, error) {
+	clientOpts, err := opts.clientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Request IDs of tool calls are sent with the RPCs of both data and admin clients.
+	clientOpts = append(clientOpts,
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(requestIDUnaryInterceptor)),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(requestIDStreamInterceptor)),
+	)
+
+	c := &clientCache{
+		idleTimeout: idleTimeout,
+		opts:        opts,
+		clientOpts:  clientOpts,
+		entries:     make(map[profile]*cachedClient),
+		pending:     make(map[profile]*pendingClient),
+		done:        make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go c.evictLoop()
+	}
+	return c, nil
+}
+
+// client returns a Spanner client for the target database. Call release when the client is no longer used.
+// The key of the cache is the whole profile because it contains client options.
+func (c *clientCache) client(ctx context.Context, t *profile) (client *spanner.Client, release func(), err error) {
+	key := *t
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entry *cachedClient
+	for entry == nil {
+		if c.closed {
+			return nil, nil, errClientCacheClosed
+		}
+		if entry = c.entries[key]; entry != nil {
+			break
+		}
+
+		// Creating a client dials and creates sessions, so it is done outside of the lock not to block calls for other databases.
+		if p, ok := c.pending[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-p.done:
+			case <-ctx.Done():
+				c.mu.Lock()
+				return nil, nil, ctx.Err()
+			}
+			c.mu.Lock()
+			if p.err != nil {
+				return nil, nil, p.err
+			}
+			continue
+		}
+
+		p := &pendingClient{done: make(chan struct{})}
+		c.pending[key] = p
+		c.mu.Unlock()
+		// The client outlives the tool call.
+		client, err := c.newClient(context.WithoutCancel(ctx), t)
+		c.mu.Lock()
+		delete(c.pending, key)
+		p.err = err
+		close(p.done)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.closed {
+			// Close outside of the lock like evictIdle.
+			c.mu.Unlock()
+			client.Close()
+			c.mu.Lock()
+			return nil, nil, errClientCacheClosed
+		}
+		entry = &cachedClient{client: client}
+		c.entries[key] = entry
+	}
+
+	entry.inUse++
+	var once sync.Once
+	return entry.client, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			entry.inUse--
+			entry.lastUsed = time.Now()
+		})
+	}, nil
+}
+
+// adminClient returns the shared database admin client. It must not be closed by callers.
+func (c *clientCache) adminClient(ctx context.Context) (*database.DatabaseAdminClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errClientCacheClosed
+	}
+
+	if c.admin == nil {
+		admin, err := database.NewDatabaseAdminClient(context.WithoutCancel(ctx), c.clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		c.admin = admin
+	}
+	return c.admin, nil
+}
+
+// instanceAdminClient returns the shared instance admin client. It must not be closed by callers.
+func (c *clientCache) instanceAdminClient(ctx context.Context) (*instance.InstanceAdminClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errClientCacheClosed
+	}
+
+	if c.instance == nil {
+		admin, err := instance.NewInstanceAdminClient(context.WithoutCancel(ctx), c.clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		c.instance = admin
+	}
+	return c.instance, nil
+}
+
+// storageClient returns the shared Cloud Storage client. It must not be closed by callers.
+// It uses the credentials of Spanner clients but not the endpoint.
+func (c *clientCache) storageClient(ctx context.Context) (*storage.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errClientCacheClosed
+	}
+
+	if c.storage == nil {
+		opts, err := c.apiOptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		client, err := storage.NewClient(context.WithoutCancel(ctx), opts...)
+		if err != nil {
+			return nil, err
+		}
+		c.storage = client
+	}
+	return c.storage, nil
+}
+
+// dataflowService returns the shared Dataflow service. Like storageClient, it uses the credentials of Spanner clients.
+func (c *clientCache) dataflowService(ctx context.Context) (*dataflow.Service, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errClientCacheClosed
+	}
+
+	if c.dataflow == nil {
+		opts, err := c.apiOptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		service, err := dataflow.NewService(context.WithoutCancel(ctx), opts...)
+		if err != nil {
+			return nil, err
+		}
+		c.dataflow = service
+	}
+	return c.dataflow, nil
+}
+
+// metricClient returns the shared Cloud Monitoring client. Like storageClient, it uses the credentials of Spanner clients.
+func (c *clientCache) metricClient(ctx context.Context) (*monitoring.MetricClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errClientCacheClosed
+	}
+
+	if c.metrics == nil {
+		opts, err := c.apiOptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		client, err := monitoring.NewMetricClient(context.WithoutCancel(ctx), opts...)
+		if err != nil {
+			return nil, err
+		}
+		c.metrics = client
+	}
+	return c.metrics, nil
+}
+
+// apiOptions returns the options for clients of Google Cloud APIs other than Spanner.
+func (c *clientCache) apiOptions(ctx context.Context) ([]option.ClientOption, error) {
+	opts, err := c.opts.credentialOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.QuotaProject != "" {
+		opts = append(opts, option.WithQuotaProject(c.opts.QuotaProject))
+	}
+	return append(opts, option.WithUserAgent(c.opts.userAgent())), nil
+}
+
+func (c *clientCache) evictLoop() {
+	ticker := time.NewTicker(min(c.idleTimeout, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.evictIdle(now)
+		}
+	}
+}
+
+func (c *clientCache) evictIdle(now time.Time) {
+	c.mu.Lock()
+	var evicted []*spanner.Client
+	for key, entry := range c.entries {
+		if entry.inUse == 0 && now.Sub(entry.lastUsed) > c.idleTimeout {
+			evicted = append(evicted, entry.client)
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+
+	// Close outside of the lock because Close waits for sessions to be deleted.
+	for _, client := range evicted {
+		client.Close()
+	}
+}
+
+// Close closes all cached clients. Subsequent calls of client, adminClient, instanceAdminClient, storageClient, dataflowService
+// and metricClient fail.
+func (c *clientCache) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.done)
+	entries := c.entries
+	c.entries = nil
+	admin := c.admin
+	c.admin = nil
+	instanceAdmin := c.instance
+	c.instance = nil
+	storageClient := c.storage
+	c.storage = nil
+	// The Dataflow service has nothing to close.
+	c.dataflow = nil
+	metricClient := c.metrics
+	c.metrics = nil
+	c.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.client.Close()
+	}
+	if admin != nil {
+		if err := admin.Close(); err != nil {
+			slog.Warn("failed to close admin client", "error", err)
+		}
+	}
+	if instanceAdmin != nil {
+		if err := instanceAdmin.Close(); err != nil {
+			slog.Warn("failed to close instance admin client", "error", err)
+		}
+	}
+	if storageClient != nil {
+		if err := storageClient.Close(); err != nil {
+			slog.Warn("failed to close storage client", "error", err)
+		}
+	}
+	if metricClient != nil {
+		if err := metricClient.Close(); err != nil {
+			slog.Warn("failed to close metric client", "error", err)
+		}
+	}
+}
+
+// newClient creates a Spanner client for the target database with the defaults of the profile.
+func (c *clientCache) newClient(ctx context.Context, t *profile) (*spanner.Client, error) {
+	priority, err := parsePriority(t.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	config := spanner.ClientConfig{
+		SessionPoolConfig:  spanner.DefaultSessionPoolConfig,
+		DatabaseRole:       t.DatabaseRole,
+		QueryOptions:       spanner.QueryOptions{Priority: priority},
+		ReadOptions:        spanner.ReadOptions{Priority: priority},
+		TransactionOptions: spanner.TransactionOptions{CommitPriority: priority},
+	}
+	c.opts.applyConfig(&config)
+
+	// Aborted errors are recorded for diagnostics of read-write transactions, including those retried by the client library.
+	opts := append(slices.Clip(c.clientOpts),
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(abortUnaryInterceptor)),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(abortStreamInterceptor)),
+	)
+	switch t.routeToLeader {
+	case "false":
+		config.DisableRouteToLeader = true
+	case "true":
+		// The client routes only read-write transactions and partitioned DML to the leader, so the header is added to the other requests.
+		config.DisableRouteToLeader = false
+		opts = append(opts,
+			option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+				return invoker(routeToLeaderContext(ctx), method, req, reply, cc, callOpts...)
+			})),
+			option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return streamer(routeToLeaderContext(ctx), desc, cc, method, callOpts...)
+			})),
+		)
+	}
+	return spanner.NewClientWithConfig(ctx, t.databasePath(), config, opts...)
+}
+
+// routeToLeaderHeader is the metadata header which routes a request to the leader region.
+const routeToLeaderHeader = "x-goog-spanner-route-to-leader"
+
+func routeToLeaderContext(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(routeToLeaderHeader)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, routeToLeaderHeader, "true")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/format"
+	"go/token"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var clientCodeLanguages = []string{"go", "java", "python"}
+
+// clientCodeParam is a query parameter of generated code.
+type clientCodeParam struct {
+	Name string
+	Type *sppb.Type
+}
+
+func generateClientCodeHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Query     string            `mapstructure:"query"`
+		Params    map[string]string `mapstructure:"params"`
+		Language  string            `mapstructure:"language"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Language == "" {
+		req.Language = "go"
+	}
+	if !lo.Contains(clientCodeLanguages, req.Language) {
+		return nil, &validationError{Field: "language", Message: fmt.Sprintf("must be one of %v", clientCodeLanguages)}
+	}
+
+	params := make([]clientCodeParam, 0, len(req.Params))
+	for _, name := range slices.Sorted(maps.Keys(req.Params)) {
+		typ, err := parseSpannerType(strings.ToUpper(strings.TrimSpace(req.Params[name])))
+		if err != nil {
+			return nil, &validationError{Field: "params", Message: fmt.Sprintf("%s: %v", name, err)}
+		}
+		params = append(params, clientCodeParam{Name: strings.TrimPrefix(name, "@"), Type: typ})
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkStatement(ctx, target, "query", req.Query); err != nil {
+		return nil, err
+	}
+
+	rowType, err := queryRowType(ctx, target, req.Query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var code string
+	switch req.Language {
+	case "go":
+		code = goClientCode(target, req.Query, params, rowType)
+	case "java":
+		code = javaClientCode(target, req.Query, params, rowType)
+	case "python":
+		code = pythonClientCode(target, req.Query, params, rowType)
+	}
+
+	out := generateClientCodeOutput{Language: req.Language, Code: code, Columns: queryColumns(rowType)}
+	return mcp.NewToolResultStructured(out, fmt.Sprintf("```%s\n%s```\n", req.Language, code)), nil
+}
+
+// queryRowType returns the row type of the query without executing it. Parameters are bound to NULL of their types.
+func queryRowType(ctx context.Context, target *profile, query string, params []clientCodeParam) (*sppb.StructType, error) {
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	stmt := spanner.NewStatement(query)
+	for _, p := range params {
+		stmt.Params[p.Name] = spanner.GenericColumnValue{Type: p.Type, Value: structpb.NewNullValue()}
+	}
+
+	var rowType *sppb.StructType
+	err = retry(ctx, func(ctx context.Context) error {
+		it := client.Single().QueryWithOptions(ctx, stmt, spanner.QueryOptions{Mode: sppb.ExecuteSqlRequest_PLAN.Enum()})
+		defer it.Stop()
+		if _, err := it.Next(); err != iterator.Done {
+			return err
+		}
+		rowType = it.Metadata.GetRowType()
+		return nil
+	})
+	return rowType, err
+}
+
+// clientCodeIdentifier returns an identifier of the generated code for the column, e.g. singerId or singer_id.
+// Anonymous columns and names which are keywords of the language have ordinal names or a trailing underscore.
+func clientCodeIdentifier(name string, i int, snake bool, keyword func(string) bool) string {
+	var b strings.Builder
+	for j, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			r = '_'
+		case snake && unicode.IsUpper(r):
+			if j > 0 && !unicode.IsUpper(rune(name[j-1])) && name[j-1] != '_' {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		case !snake && j == 0:
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	switch {
+	case s == "" || unicode.IsDigit(rune(s[0])):
+		return fmt.Sprintf("col%d", i+1)
+	case keyword(s):
+		return s + "_"
+	default:
+		return s
+	}
+}
+
+// quoteDoubleQuoted quotes s as a double-quoted string literal of Java and Python.
+func quoteDoubleQuoted(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func goClientCode(target *profile, query string, params []clientCodeParam, rowType *sppb.StructType) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// client is created by spanner.NewClient(ctx, %q).\n", target.databasePath())
+	b.WriteString("func query(ctx context.Context, client *spanner.Client) error {\n")
+	sql := "`" + query + "`"
+	if strings.Contains(query, "`") {
+		sql = strconv.Quote(query)
+	}
+	if len(params) == 0 {
+		fmt.Fprintf(&b, "\tstmt := spanner.NewStatement(%s)\n", sql)
+	} else {
+		fmt.Fprintf(&b, "\tstmt := spanner.Statement{\n\t\tSQL: %s,\n\t\tParams: map[string]any{\n", sql)
+		for _, p := range params {
+			fmt.Fprintf(&b, "\t\t\t%q: %s, // %s\n", p.Name, goValue(p.Type), formatType(p.Type))
+		}
+		b.WriteString("\t\t},\n\t}\n")
+	}
+	b.WriteString("\treturn client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {\n")
+
+	fields := rowType.GetFields()
+	names := lo.Map(fields, func(f *sppb.StructType_Field, i int) string {
+		return clientCodeIdentifier(f.GetName(), i, false, token.IsKeyword)
+	})
+	if len(fields) > 0 {
+		b.WriteString("\t\tvar (\n")
+		for i, f := range fields {
+			fmt.Fprintf(&b, "\t\t\t%s %s\n", names[i], goType(f.GetType()))
+		}
+		b.WriteString("\t\t)\n")
+		fmt.Fprintf(&b, "\t\tif err := row.Columns(%s); err != nil {\n\t\t\treturn err\n\t\t}\n",
+			strings.Join(lo.Map(names, func(name string, _ int) string { return "&" + name }), ", "))
+		fmt.Fprintf(&b, "\t\tfmt.Println(%s)\n", strings.Join(names, ", "))
+	}
+	b.WriteString("\t\treturn nil\n\t})\n}\n")
+	if formatted, err := format.Source([]byte(b.String())); err == nil {
+		return string(formatted)
+	}
+	return b.String()
+}
+
+// goType returns the Go type which a nullable column of the type is decoded into.
+func goType(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return "spanner.NullBool"
+	case sppb.TypeCode_INT64, sppb.TypeCode_ENUM:
+		return "spanner.NullInt64"
+	case sppb.TypeCode_FLOAT32:
+		return "spanner.NullFloat32"
+	case sppb.TypeCode_FLOAT64:
+		return "spanner.NullFloat64"
+	case sppb.TypeCode_NUMERIC:
+		if t.GetTypeAnnotation() == sppb.TypeAnnotationCode_PG_NUMERIC {
+			return "spanner.PGNumeric"
+		}
+		return "spanner.NullNumeric"
+	case sppb.TypeCode_STRING:
+		return "spanner.NullString"
+	case sppb.TypeCode_JSON:
+		if t.GetTypeAnnotation() == sppb.TypeAnnotationCode_PG_JSONB {
+			return "spanner.PGJsonB"
+		}
+		return "spanner.NullJSON"
+	case sppb.TypeCode_BYTES, sppb.TypeCode_PROTO:
+		return "[]byte"
+	case sppb.TypeCode_DATE:
+		return "spanner.NullDate"
+	case sppb.TypeCode_TIMESTAMP:
+		return "spanner.NullTime"
+	case sppb.TypeCode_ARRAY:
+		if elem := goType(t.GetArrayElementType()); elem != "spanner.GenericColumnValue" {
+			return "[]" + elem
+		}
+	}
+	return "spanner.GenericColumnValue"
+}
+
+// goValue returns a placeholder value of a parameter of the type.
+func goValue(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return "false"
+	case sppb.TypeCode_INT64:
+		return "int64(0)"
+	case sppb.TypeCode_FLOAT32:
+		return "float32(0)"
+	case sppb.TypeCode_FLOAT64:
+		return "float64(0)"
+	case sppb.TypeCode_NUMERIC:
+		return "big.NewRat(0, 1)"
+	case sppb.TypeCode_STRING:
+		return `""`
+	case sppb.TypeCode_JSON:
+		return "spanner.NullJSON{Value: map[string]any{}, Valid: true}"
+	case sppb.TypeCode_BYTES:
+		return "[]byte{}"
+	case sppb.TypeCode_DATE:
+		return "civil.DateOf(time.Now())"
+	case sppb.TypeCode_TIMESTAMP:
+		return "time.Now()"
+	case sppb.TypeCode_ARRAY:
+		elem := strings.TrimPrefix(goType(t.GetArrayElementType()), "spanner.Null")
+		switch elem {
+		case "Bool", "Int64", "Float32", "Float64", "String":
+			return fmt.Sprintf("[]%s{}", strings.ToLower(elem))
+		}
+		return fmt.Sprintf("[]%s{}", goType(t.GetArrayElementType()))
+	default:
+		return "nil"
+	}
+}
+
+var javaKeywords = strings.Fields(`abstract assert boolean break byte case catch char class const continue default do double else enum extends
+	final finally float for goto if implements import instanceof int interface long native new package private protected public return
+	short static strictfp super switch synchronized this throw throws transient try void volatile while true false null var record`)
+
+func javaClientCode(target *profile, query string, params []clientCodeParam, rowType *sppb.StructType) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DatabaseClient dbClient =\n    spanner.getDatabaseClient(DatabaseId.of(%s, %s, %s));\n",
+		quoteDoubleQuoted(target.Project), quoteDoubleQuoted(target.Instance), quoteDoubleQuoted(target.Database))
+	fmt.Fprintf(&b, "Statement statement =\n    Statement.newBuilder(%s)\n", quoteDoubleQuoted(query))
+	for _, p := range params {
+		fmt.Fprintf(&b, "        .bind(%s).%s\n", quoteDoubleQuoted(p.Name), javaBinding(p.Type))
+	}
+	b.WriteString("        .build();\n")
+	b.WriteString("try (ResultSet resultSet = dbClient.singleUse().executeQuery(statement)) {\n  while (resultSet.next()) {\n")
+	for i, f := range rowType.GetFields() {
+		name := clientCodeIdentifier(f.GetName(), i, false, func(s string) bool { return lo.Contains(javaKeywords, s) })
+		column := strconv.Itoa(i)
+		if f.GetName() != "" {
+			column = quoteDoubleQuoted(f.GetName())
+		}
+		typ, getter := javaGetter(f.GetType())
+		fmt.Fprintf(&b, "    %s %s = resultSet.isNull(%s) ? null : resultSet.%s(%s);\n", typ, name, column, getter, column)
+	}
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
+
+// javaGetter returns the Java type of a column of the type and the method of ResultSet getting it.
+func javaGetter(t *sppb.Type) (string, string) {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return "Boolean", "getBoolean"
+	case sppb.TypeCode_INT64, sppb.TypeCode_ENUM:
+		return "Long", "getLong"
+	case sppb.TypeCode_FLOAT32:
+		return "Float", "getFloat"
+	case sppb.TypeCode_FLOAT64:
+		return "Double", "getDouble"
+	case sppb.TypeCode_NUMERIC:
+		return "BigDecimal", "getBigDecimal"
+	case sppb.TypeCode_STRING:
+		return "String", "getString"
+	case sppb.TypeCode_JSON:
+		return "String", "getJson"
+	case sppb.TypeCode_BYTES, sppb.TypeCode_PROTO:
+		return "ByteArray", "getBytes"
+	case sppb.TypeCode_DATE:
+		return "Date", "getDate"
+	case sppb.TypeCode_TIMESTAMP:
+		return "Timestamp", "getTimestamp"
+	case sppb.TypeCode_ARRAY:
+		if elem, getter := javaGetter(t.GetArrayElementType()); elem != "Value" && t.GetArrayElementType().GetCode() != sppb.TypeCode_ARRAY {
+			return fmt.Sprintf("List<%s>", elem), getter + "List"
+		}
+	}
+	return "Value", "getValue"
+}
+
+// javaBinding returns the call of ValueBinder binding a placeholder value of the type.
+func javaBinding(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return "to(false)"
+	case sppb.TypeCode_INT64:
+		return "to(0L)"
+	case sppb.TypeCode_FLOAT32:
+		return "to(0f)"
+	case sppb.TypeCode_FLOAT64:
+		return "to(0.0)"
+	case sppb.TypeCode_NUMERIC:
+		return "to(BigDecimal.ZERO)"
+	case sppb.TypeCode_STRING:
+		return `to("")`
+	case sppb.TypeCode_JSON:
+		return `to(Value.json("{}"))`
+	case sppb.TypeCode_BYTES:
+		return `to(ByteArray.copyFrom(""))`
+	case sppb.TypeCode_DATE:
+		return `to(Date.parseDate("2000-01-01"))`
+	case sppb.TypeCode_TIMESTAMP:
+		return "to(Timestamp.now())"
+	case sppb.TypeCode_ARRAY:
+		methods := map[sppb.TypeCode]string{
+			sppb.TypeCode_BOOL:      "toBoolArray",
+			sppb.TypeCode_INT64:     "toInt64Array",
+			sppb.TypeCode_FLOAT32:   "toFloat32Array",
+			sppb.TypeCode_FLOAT64:   "toFloat64Array",
+			sppb.TypeCode_NUMERIC:   "toNumericArray",
+			sppb.TypeCode_STRING:    "toStringArray",
+			sppb.TypeCode_JSON:      "toJsonArray",
+			sppb.TypeCode_BYTES:     "toBytesArray",
+			sppb.TypeCode_DATE:      "toDateArray",
+			sppb.TypeCode_TIMESTAMP: "toTimestampArray",
+		}
+		if method, ok := methods[t.GetArrayElementType().GetCode()]; ok {
+			return method + "(List.of())"
+		}
+	}
+	return fmt.Sprintf("to((Value) null) // %s", formatType(t))
+}
+
+var pythonKeywords = strings.Fields(`False None True and as assert async await break class continue def del elif else except finally for from
+	global if import in is lambda nonlocal not or pass raise return try while with yield`)
+
+func pythonClientCode(target *profile, query string, params []clientCodeParam, rowType *sppb.StructType) string {
+	var b strings.Builder
+	b.WriteString("from google.cloud import spanner\n")
+	if len(params) > 0 {
+		b.WriteString("from google.cloud.spanner_v1 import param_types\n")
+	}
+	fmt.Fprintf(&b, "\nclient = spanner.Client(project=%s)\ndatabase = client.instance(%s).database(%s)\n\n",
+		quoteDoubleQuoted(target.Project), quoteDoubleQuoted(target.Instance), quoteDoubleQuoted(target.Database))
+	b.WriteString("with database.snapshot() as snapshot:\n    results = snapshot.execute_sql(\n")
+	fmt.Fprintf(&b, "        %s,\n", quoteDoubleQuoted(query))
+	if len(params) > 0 {
+		b.WriteString("        params={\n")
+		for _, p := range params {
+			fmt.Fprintf(&b, "            %s: %s,\n", quoteDoubleQuoted(p.Name), pythonValue(p.Type))
+		}
+		b.WriteString("        },\n        param_types={\n")
+		for _, p := range params {
+			fmt.Fprintf(&b, "            %s: %s,\n", quoteDoubleQuoted(p.Name), pythonParamType(p.Type))
+		}
+		b.WriteString("        },\n")
+	}
+	b.WriteString("    )\n    for row in results:\n")
+	names := lo.Map(rowType.GetFields(), func(f *sppb.StructType_Field, i int) string {
+		return clientCodeIdentifier(f.GetName(), i, true, func(s string) bool { return lo.Contains(pythonKeywords, s) })
+	})
+	switch len(names) {
+	case 0:
+		b.WriteString("        print(row)\n")
+	case 1:
+		fmt.Fprintf(&b, "        (%s,) = row\n        print(%s)\n", names[0], names[0])
+	default:
+		fmt.Fprintf(&b, "        %s = row\n        print(%s)\n", strings.Join(names, ", "), strings.Join(names, ", "))
+	}
+	return b.String()
+}
+
+func pythonParamType(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_ARRAY:
+		return fmt.Sprintf("param_types.Array(%s)", pythonParamType(t.GetArrayElementType()))
+	case sppb.TypeCode_JSON:
+		return "param_types.JSON"
+	default:
+		return "param_types." + t.GetCode().String()
+	}
+}
+
+// pythonValue returns a placeholder value of a parameter of the type.
+func pythonValue(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return "False"
+	case sppb.TypeCode_INT64:
+		return "0"
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64:
+		return "0.0"
+	case sppb.TypeCode_NUMERIC:
+		return `decimal.Decimal("0")`
+	case sppb.TypeCode_STRING:
+		return `""`
+	case sppb.TypeCode_JSON:
+		return "JsonObject({})"
+	case sppb.TypeCode_BYTES:
+		return `b""`
+	case sppb.TypeCode_DATE:
+		return "datetime.date.today()"
+	case sppb.TypeCode_TIMESTAMP:
+		return "datetime.datetime.now(datetime.timezone.utc)"
+	case sppb.TypeCode_ARRAY:
+		return "[]"
+	default:
+		return "None"
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+var columnExpressionKinds = []string{"all", "generated", "default"}
+
+func listColumnExpressionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+		Kind      string `mapstructure:"kind"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Kind == "" {
+		req.Kind = "all"
+	}
+	if !lo.Contains(columnExpressionKinds, req.Kind) {
+		return nil, &validationError{Field: "kind", Message: fmt.Sprintf("must be one of %v", columnExpressionKinds)}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	filter := map[string]string{
+		"all":       "(c.GENERATION_EXPRESSION IS NOT NULL OR c.COLUMN_DEFAULT IS NOT NULL)",
+		"generated": "c.GENERATION_EXPRESSION IS NOT NULL",
+		"default":   "c.COLUMN_DEFAULT IS NOT NULL",
+	}[req.Kind]
+	stmt := spanner.Statement{Params: map[string]any{}}
+	if req.Table != "" {
+		if err := cfg.Access.checkTable("table", req.Table); err != nil {
+			return nil, err
+		}
+		schema, name, ok := strings.Cut(strings.ReplaceAll(req.Table, "`", ""), ".")
+		if !ok {
+			schema, name = "", schema
+		}
+		filter += " AND c.TABLE_SCHEMA = @schema AND c.TABLE_NAME = @table"
+		stmt.Params["schema"], stmt.Params["table"] = schema, name
+	}
+	// INDEX_COLUMNS has the primary key as the PRIMARY_KEY index, so INDEXES contains it as well.
+	stmt.SQL = `SELECT c.TABLE_SCHEMA, c.TABLE_NAME, c.COLUMN_NAME, c.SPANNER_TYPE, c.GENERATION_EXPRESSION, c.IS_STORED = 'YES' AS STORED,
+  c.COLUMN_DEFAULT,
+  ARRAY(SELECT ic.INDEX_NAME FROM INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+    WHERE ic.TABLE_SCHEMA = c.TABLE_SCHEMA AND ic.TABLE_NAME = c.TABLE_NAME AND ic.COLUMN_NAME = c.COLUMN_NAME
+    ORDER BY ic.INDEX_NAME) AS INDEXES
+FROM INFORMATION_SCHEMA.COLUMNS AS c
+WHERE c.TABLE_SCHEMA NOT IN ('INFORMATION_SCHEMA', 'SPANNER_SYS') AND ` + filter + `
+ORDER BY c.TABLE_SCHEMA, c.TABLE_NAME, c.ORDINAL_POSITION`
+
+	rows, err := queryRows(ctx, client, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	out := listColumnExpressionsOutput{Columns: lo.Map(rows, func(row map[string]any, _ int) columnExpression {
+		str := func(key string) string {
+			s, _ := row[key].(string)
+			return s
+		}
+		c := columnExpression{
+			Table:   qualifiedTableName(str("TABLE_SCHEMA"), str("TABLE_NAME")),
+			Column:  str("COLUMN_NAME"),
+			Type:    str("SPANNER_TYPE"),
+			Default: str("COLUMN_DEFAULT"),
+		}
+		if expr := str("GENERATION_EXPRESSION"); expr != "" {
+			c.Generated = expr
+			c.Stored = row["STORED"] == true
+		}
+		indexes, _ := row["INDEXES"].([]any)
+		for _, index := range indexes {
+			if name, _ := index.(string); name == "PRIMARY_KEY" {
+				c.PrimaryKey = true
+			} else if name != "" {
+				c.Indexes = append(c.Indexes, name)
+			}
+		}
+		return c
+	})}
+	out.Generated = lo.CountBy(out.Columns, func(c columnExpression) bool { return c.Generated != "" })
+	out.Defaults = lo.CountBy(out.Columns, func(c columnExpression) bool { return c.Default != "" })
+
+	if len(out.Columns) == 0 {
+		return mcp.NewToolResultStructured(out, "No columns have generation or default expressions\n"), nil
+	}
+	var b strings.Builder
+	table := newTable(&b)
+	table.SetHeader([]string{"Table", "Column", "Type", "Expression", "Used by"})
+	for _, c := range out.Columns {
+		var exprs []string
+		if c.Generated != "" {
+			expr := fmt.Sprintf("AS (%s)", c.Generated)
+			if c.Stored {
+				expr += " STORED"
+			}
+			exprs = append(exprs, expr)
+		}
+		if c.Default != "" {
+			exprs = append(exprs, fmt.Sprintf("DEFAULT (%s)", c.Default))
+		}
+		usedBy := c.Indexes
+		if c.PrimaryKey {
+			usedBy = append([]string{"PRIMARY KEY"}, usedBy...)
+		}
+		table.Append([]string{c.Table, c.Column, c.Type, strings.Join(exprs, "\n"), strings.Join(usedBy, ", ")})
+	}
+	table.Render()
+	fmt.Fprintf(&b, "%d generated columns, %d columns with defaults\n", out.Generated, out.Defaults)
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
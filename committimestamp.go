@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// commitTimestampPlaceholder is the value of spanner.CommitTimestamp in mutations, which Spanner replaces by the commit timestamp.
+// Rows of stage_mutation and import_data can have it in TIMESTAMP columns with allow_commit_timestamp.
+const commitTimestampPlaceholder = "spanner.commit_timestamp()"
+
+// isCommitTimestampPlaceholder reports whether the value is the placeholder or the PENDING_COMMIT_TIMESTAMP() function,
+// which agents often write in mutations as in DML.
+func isCommitTimestampPlaceholder(v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "spanner.")
+	return s == "commit_timestamp()" || s == "pending_commit_timestamp()"
+}
+
+// commitTimestampColumns returns the columns which allow commit timestamps in the table, or in all tables if the table is empty.
+// They are TIMESTAMP columns with allow_commit_timestamp in GoogleSQL, and SPANNER.COMMIT_TIMESTAMP columns in PostgreSQL.
+func (c *schemaCatalog) commitTimestampColumns(ctx context.Context, table string) ([]commitTimestampColumn, error) {
+	sql := `SELECT c.TABLE_SCHEMA, c.TABLE_NAME, c.COLUMN_NAME, c.SPANNER_TYPE, c.IS_NULLABLE, ic.ORDINAL_POSITION AS PRIMARY_KEY_POSITION
+FROM INFORMATION_SCHEMA.COLUMNS AS c
+LEFT JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+  ON ic.TABLE_SCHEMA = c.TABLE_SCHEMA AND ic.TABLE_NAME = c.TABLE_NAME
+  AND ic.COLUMN_NAME = c.COLUMN_NAME AND ic.INDEX_TYPE = 'PRIMARY_KEY'
+WHERE c.TABLE_SCHEMA NOT IN ` + catalogSystemSchemas
+	if c.postgreSQL {
+		sql += `
+  AND c.SPANNER_TYPE = 'spanner.commit_timestamp'`
+	} else {
+		sql += `
+  AND EXISTS (SELECT 1 FROM INFORMATION_SCHEMA.COLUMN_OPTIONS AS o
+    WHERE o.TABLE_SCHEMA = c.TABLE_SCHEMA AND o.TABLE_NAME = c.TABLE_NAME AND o.COLUMN_NAME = c.COLUMN_NAME
+    AND o.OPTION_NAME = 'allow_commit_timestamp' AND o.OPTION_VALUE = 'TRUE')`
+	}
+	var args []any
+	if table != "" {
+		schema, name := c.splitTable(table)
+		sql += "\n  AND c.TABLE_SCHEMA = @p1 AND c.TABLE_NAME = @p2"
+		args = append(args, schema, name)
+	}
+	rows, err := c.query(ctx, sql+"\nORDER BY c.TABLE_SCHEMA, c.TABLE_NAME, c.ORDINAL_POSITION", args...)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(rows, func(row map[string]any, _ int) commitTimestampColumn {
+		return commitTimestampColumn{
+			Table:      c.qualifiedName(catalogString(row, "TABLE_SCHEMA"), catalogString(row, "TABLE_NAME")),
+			Column:     catalogString(row, "COLUMN_NAME"),
+			Type:       catalogString(row, "SPANNER_TYPE"),
+			Nullable:   catalogBool(row, "IS_NULLABLE"),
+			PrimaryKey: row["PRIMARY_KEY_POSITION"] != nil,
+		}
+	}), nil
+}
+
+// commitTimestampRows returns the rows with the commit timestamp placeholder in the columns which allow commit timestamps.
+// Placeholders written as PENDING_COMMIT_TIMESTAMP() are normalized, placeholders in other columns are errors because Spanner
+// rejects them, and columns missing in the rows are filled if fill is true. The rows of the arguments are not modified.
+func commitTimestampRows(ctx context.Context, client *spanner.Client, table string, rows []map[string]any, fill bool) ([]map[string]any, error) {
+	// Mutations are written to GoogleSQL databases like tableColumnTypes.
+	columns, err := (&schemaCatalog{client: client}).commitTimestampColumns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	allowed := lo.SliceToMap(columns, func(c commitTimestampColumn) (string, bool) { return c.Column, true })
+	if fill && len(columns) == 0 {
+		return nil, &validationError{Field: "fill_commit_timestamps", Message: fmt.Sprintf("table %s has no columns with allow_commit_timestamp", table)}
+	}
+
+	out := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		row = maps.Clone(row)
+		for name, v := range row {
+			if !isCommitTimestampPlaceholder(v) {
+				continue
+			}
+			if !allowed[name] {
+				return nil, &validationError{Field: "rows", Message: fmt.Sprintf("row %d: column %s doesn't have allow_commit_timestamp, so it can't be %s; call list_commit_timestamp_columns to see the columns which can", i+1, name, commitTimestampPlaceholder)}
+			}
+			row[name] = commitTimestampPlaceholder
+		}
+		if fill {
+			for _, c := range columns {
+				if _, ok := row[c.Column]; !ok {
+					row[c.Column] = commitTimestampPlaceholder
+				}
+			}
+		}
+		out[i] = row
+	}
+	return out, nil
+}
+
+func listCommitTimestampColumnsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	catalog, err := newSchemaCatalog(ctx, target, client)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := catalog.commitTimestampColumns(ctx, req.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	out := listCommitTimestampColumnsOutput{
+		Dialect:       catalog.dialect(),
+		Columns:       columns,
+		MutationValue: commitTimestampPlaceholder,
+		DMLValue:      lo.Ternary(catalog.postgreSQL, "SPANNER.PENDING_COMMIT_TIMESTAMP()", "PENDING_COMMIT_TIMESTAMP()"),
+	}
+	if len(columns) == 0 {
+		return mcp.NewToolResultStructured(out, "No columns allow commit timestamps\n"), nil
+	}
+
+	var b strings.Builder
+	table := newTable(&b)
+	table.SetHeader([]string{"Table", "Column", "Type", "Nullable", "Primary key"})
+	for _, c := range columns {
+		table.Append([]string{c.Table, c.Column, c.Type, lo.Ternary(c.Nullable, "YES", "NO"), lo.Ternary(c.PrimaryKey, "YES", "")})
+	}
+	table.Render()
+	fmt.Fprintf(&b, "\nWrite the commit timestamp by %q as the value in rows of stage_mutation and import_data, or by fill_commit_timestamps of stage_mutation, and by %s in DML. Values of the columns can't be read in the transaction which writes them.\n",
+		out.MutationValue, out.DMLValue)
+	if lo.ContainsBy(columns, func(c commitTimestampColumn) bool { return c.PrimaryKey }) {
+		b.WriteString("Commit timestamps leading primary keys write to the end of the key space, which is a hotspot.\n")
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// maxCompletionValues is the maximum number of values in a completion response defined by the MCP specification.
+const maxCompletionValues = 100
+
+// completionProvider completes arguments of resource templates and prompts by their names.
+type completionProvider struct{}
+
+func (completionProvider) CompleteResourceArgument(ctx context.Context, _ string, argument mcp.CompleteArgument, cctx mcp.CompleteContext) (*mcp.Completion, error) {
+	return completeArgument(ctx, argument, cctx.Arguments)
+}
+
+func (completionProvider) CompletePromptArgument(ctx context.Context, _ string, argument mcp.CompleteArgument, cctx mcp.CompleteContext) (*mcp.Completion, error) {
+	return completeArgument(ctx, argument, cctx.Arguments)
+}
+
+func completeArgument(ctx context.Context, argument mcp.CompleteArgument, args map[string]string) (*mcp.Completion, error) {
+	var candidates []string
+	switch argument.Name {
+	case "profile":
+		candidates = lo.Keys(cfg.Profiles)
+	case "project":
+		candidates = lo.Map(knownTargets(ctx), func(t *profile, _ int) string { return t.Project })
+	case "instance":
+		candidates = lo.FilterMap(knownTargets(ctx), func(t *profile, _ int) (string, bool) {
+			return t.Instance, args["project"] == "" || args["project"] == t.Project
+		})
+	case "database":
+		candidates = lo.FilterMap(knownTargets(ctx), func(t *profile, _ int) (string, bool) {
+			return t.Database, (args["project"] == "" || args["project"] == t.Project) &&
+				(args["instance"] == "" || args["instance"] == t.Instance)
+		})
+	case "table":
+		if args["project"] == "" || args["instance"] == "" || args["database"] == "" {
+			break
+		}
+		tables, err := tableNames(ctx, &profile{Project: args["project"], Instance: args["instance"], Database: args["database"]})
+		if err != nil {
+			return nil, err
+		}
+		candidates = tables
+	case "interval":
+		candidates = lo.Keys(queryStatsTables)
+	case "snapshot", "base", "other":
+		if planDir == "" {
+			break
+		}
+		names, err := planSnapshotNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+		candidates = names
+	}
+
+	values := lo.Uniq(lo.Filter(candidates, func(v string, _ int) bool {
+		return v != "" && strings.HasPrefix(strings.ToLower(v), strings.ToLower(argument.Value))
+	}))
+	slices.Sort(values)
+
+	completion := &mcp.Completion{Values: values, Total: len(values)}
+	if len(values) > maxCompletionValues {
+		completion.Values = values[:maxCompletionValues]
+		completion.HasMore = true
+	}
+	return completion, nil
+}
+
+// knownTargets returns the databases of profiles and the default database of the session.
+func knownTargets(ctx context.Context) []*profile {
+	targets := lo.Values(cfg.Profiles)
+	if t, err := (databaseArgs{}).target(ctx); err == nil {
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// tableNames returns the names of tables in the database. Tables in named schemas are qualified by schema names.
+func tableNames(ctx context.Context, target *profile) ([]string, error) {
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rows, err := queryRows(ctx, client, spanner.NewStatement(`SELECT IF(TABLE_SCHEMA = '', TABLE_NAME, TABLE_SCHEMA || '.' || TABLE_NAME) AS NAME
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN ('INFORMATION_SCHEMA', 'SPANNER_SYS')`))
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(rows, func(row map[string]any, _ int) string {
+		name, _ := row["NAME"].(string)
+		return name
+	}), nil
+}
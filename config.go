@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+	"google.golang.org/api/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the content of the configuration file.
+//
+//	default_profile: dev
+//	profiles:
+//	  prod-eu:
+//	    project: my-project
+//	    instance: prod-eu
+//	    database: app
+//	    database_role: analyst
+//	    priority: low
+//	    tier: read-only
+//	client:
+//	  min_sessions: 10
+//	  num_channels: 4
+//	retry:
+//	  max_attempts: 5
+//	  initial_backoff: 100ms
+//	timeouts:
+//	  query: 5m
+type config struct {
+	// DefaultProfile is used when a tool call specifies neither profile nor database.
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*profile `yaml:"profiles"`
+	Client         clientOptions       `yaml:"client"`
+	Retry          retryOptions        `yaml:"retry"`
+	Limits         limitOptions        `yaml:"limits"`
+	Output         outputOptions       `yaml:"output"`
+	Masking        maskingOptions      `yaml:"masking"`
+	Access         accessOptions       `yaml:"access"`
+	Timeouts       timeoutOptions      `yaml:"timeouts"`
+
+	// DefaultTier is the tier of targets which match no profile with a tier. Empty means unrestricted.
+	DefaultTier string `yaml:"default_tier"`
+}
+
+// clientOptions configures all Spanner clients created by the server. Zero values mean the library defaults.
+type clientOptions struct {
+	MinSessions         uint64 `yaml:"min_sessions"`
+	MaxSessions         uint64 `yaml:"max_sessions"`
+	MultiplexedSessions bool   `yaml:"multiplexed_sessions"`
+	NumChannels         int    `yaml:"num_channels"`
+
+	// Endpoint is the host:port of the Spanner API, e.g. a regional endpoint, a Private Service Connect endpoint or a test proxy.
+	// It is used by both the data and admin clients.
+	Endpoint string `yaml:"endpoint"`
+
+	// Insecure connects to the endpoint without TLS and authentication, e.g. to a local test proxy.
+	Insecure bool `yaml:"insecure"`
+
+	// CACertFile is a PEM file of CA certificates used to verify the endpoint instead of the system roots.
+	CACertFile string `yaml:"ca_cert_file"`
+
+	// TLSServerName overrides the server name used to verify the certificate of the endpoint.
+	TLSServerName string `yaml:"tls_server_name"`
+
+	// CredentialsFile is a service account key or other credential JSON file instead of Application Default Credentials.
+	CredentialsFile string `yaml:"credentials_file"`
+
+	// ImpersonateServiceAccount is the email of the service account to impersonate using the base credentials.
+	ImpersonateServiceAccount string `yaml:"impersonate_service_account"`
+
+	// QuotaProject is the project used for quota and billing.
+	QuotaProject string `yaml:"quota_project"`
+
+	// DisableRouteToLeader stops routing read-write transactions and partitioned DML to the leader region,
+	// so they are served by the nearest replica and forwarded to the leader by Spanner.
+	DisableRouteToLeader bool `yaml:"disable_route_to_leader"`
+
+	// Compression is the compressor of requests and responses of all RPCs: gzip, or identity which disables compression.
+	// Compression reduces the transfer of large results at the cost of CPU.
+	Compression string `yaml:"compression"`
+
+	// KeepaliveTime is the interval of keepalive pings on idle connections, which keeps them open
+	// through proxies and load balancers closing idle connections.
+	KeepaliveTime time.Duration `yaml:"keepalive_time"`
+
+	// KeepaliveTimeout is the time to wait for the acknowledgement of a keepalive ping before closing the connection.
+	KeepaliveTimeout time.Duration `yaml:"keepalive_timeout"`
+
+	// MaxRecvMessageBytes and MaxSendMessageBytes are the maximum sizes of gRPC messages received and sent by the clients.
+	MaxRecvMessageBytes int `yaml:"max_recv_message_bytes"`
+	MaxSendMessageBytes int `yaml:"max_send_message_bytes"`
+
+	// UserAgent is appended to the user agent of all requests, e.g. the name of the agent or the deployment,
+	// which is recorded as callerSuppliedUserAgent in Cloud Audit Logs.
+	UserAgent string `yaml:"user_agent"`
+}
+
+// userAgent returns the user agent of all requests.
+func (o *clientOptions) userAgent() string {
+	if o.UserAgent == "" {
+		return "spanner-mcp/" + version
+	}
+	return "spanner-mcp/" + version + " " + o.UserAgent
+}
+
+// applyConfig applies the options to the client config of the data client.
+func (o *clientOptions) applyConfig(c *spanner.ClientConfig) {
+	if o.MinSessions > 0 {
+		c.MinOpened = o.MinSessions
+	}
+	if o.MaxSessions > 0 {
+		c.MaxOpened = o.MaxSessions
+	}
+	if o.NumChannels > 0 {
+		c.NumChannels = o.NumChannels
+	}
+	c.DisableRouteToLeader = o.DisableRouteToLeader
+	// The library also asks the server to compress responses.
+	c.Compression = o.Compression
+}
+
+// clientOptions returns the options for both data and admin clients.
+func (o *clientOptions) clientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	opts := []option.ClientOption{option.WithUserAgent(o.userAgent())}
+	if o.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(o.Endpoint))
+	}
+	if o.QuotaProject != "" {
+		opts = append(opts, option.WithQuotaProject(o.QuotaProject))
+	}
+	grpcOpts, err := o.grpcDialOptions()
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range grpcOpts {
+		opts = append(opts, option.WithGRPCDialOption(opt))
+	}
+
+	switch {
+	case o.Insecure:
+		if o.Endpoint == "" {
+			return nil, fmt.Errorf("insecure requires endpoint")
+		}
+		if o.CACertFile != "" || o.TLSServerName != "" {
+			return nil, fmt.Errorf("insecure can't be combined with ca_cert_file or tls_server_name")
+		}
+		// Per-RPC credentials require transport security, so authentication is disabled as well.
+		return append(opts,
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			option.WithoutAuthentication(),
+		), nil
+	case o.CACertFile != "" || o.TLSServerName != "":
+		tlsConfig := &tls.Config{ServerName: o.TLSServerName}
+		if o.CACertFile != "" {
+			pem, err := os.ReadFile(o.CACertFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in %s", o.CACertFile)
+			}
+		}
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))))
+	}
+
+	credOpts, err := o.credentialOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, credOpts...), nil
+}
+
+// grpcDialOptions returns the options of gRPC connections of both data and admin clients.
+func (o *clientOptions) grpcDialOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	var callOpts []grpc.CallOption
+	switch o.Compression {
+	case "", "identity":
+	case gzip.Name:
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	default:
+		return nil, fmt.Errorf("compression must be gzip or identity: %q", o.Compression)
+	}
+	if o.MaxRecvMessageBytes < 0 || o.MaxSendMessageBytes < 0 {
+		return nil, fmt.Errorf("max_recv_message_bytes and max_send_message_bytes must not be negative")
+	}
+	if o.MaxRecvMessageBytes > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(o.MaxRecvMessageBytes))
+	}
+	if o.MaxSendMessageBytes > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(o.MaxSendMessageBytes))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+
+	if o.KeepaliveTime > 0 || o.KeepaliveTimeout > 0 {
+		if o.KeepaliveTime <= 0 {
+			return nil, fmt.Errorf("keepalive_timeout requires keepalive_time")
+		}
+		// Pings are sent without active RPCs because idle connections of session pools are closed by proxies.
+		// Servers close connections which ping more often than they permit with GOAWAY, so the time shouldn't be too short.
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                o.KeepaliveTime,
+			Timeout:             o.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+	return opts, nil
+}
+
+// credentialOptions returns the options of credentials, which are also used by clients of other Google Cloud APIs like Cloud Storage.
+func (o *clientOptions) credentialOptions(ctx context.Context) ([]option.ClientOption, error) {
+	var credOpts []option.ClientOption
+	if o.CredentialsFile != "" {
+		credOpts = append(credOpts, option.WithCredentialsFile(o.CredentialsFile))
+	}
+
+	if o.ImpersonateServiceAccount == "" {
+		return credOpts, nil
+	}
+
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: o.ImpersonateServiceAccount,
+		Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform"},
+	}, credOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate %s: %w", o.ImpersonateServiceAccount, err)
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
+
+// principal returns the email of the principal which accesses Spanner.
+// It is resolved from the service account key or by the tokeninfo endpoint, so it may be empty for some credentials.
+func (o *clientOptions) principal(ctx context.Context) (string, error) {
+	if o.ImpersonateServiceAccount != "" {
+		return o.ImpersonateServiceAccount, nil
+	}
+	if o.Insecure {
+		return "", nil
+	}
+
+	opts := []option.ClientOption{option.WithScopes("https://www.googleapis.com/auth/cloud-platform")}
+	if o.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(o.CredentialsFile))
+	}
+	creds, err := transport.Creds(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	var key struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if err := json.Unmarshal(creds.JSON, &key); err == nil && key.ClientEmail != "" {
+		return key.ClientEmail, nil
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://oauth2.googleapis.com/tokeninfo?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("tokeninfo returned %s", resp.Status)
+	}
+
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", err
+	}
+	return info.Email, nil
+}
+
+// applyEnv enables multiplexed sessions. The client library only supports environment variables to enable them.
+func (o *clientOptions) applyEnv() error {
+	if !o.MultiplexedSessions {
+		return nil
+	}
+
+	for _, key := range []string{
+		"GOOGLE_CLOUD_SPANNER_MULTIPLEXED_SESSIONS",
+		"GOOGLE_CLOUD_SPANNER_MULTIPLEXED_SESSIONS_FOR_RW",
+		"GOOGLE_CLOUD_SPANNER_MULTIPLEXED_SESSIONS_PARTITIONED_OPS",
+	} {
+		if err := os.Setenv(key, "true"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profile is a named connection profile. It is also used as the resolved target of a tool call.
+type profile struct {
+	Project      string `yaml:"project"`
+	Instance     string `yaml:"instance"`
+	Database     string `yaml:"database"`
+	DatabaseRole string `yaml:"database_role"`
+	Priority     string `yaml:"priority"`
+
+	// Tier is read-only, read-write or admin, which limits the tools callable for the database of the profile.
+	Tier string `yaml:"tier"`
+
+	// routeToLeader is the route_to_leader argument of the tool call: "true", "false" or empty for the client option.
+	// It is a part of the key of cached clients, so each routing has its own client.
+	routeToLeader string
+}
+
+// cfg is the loaded configuration. It is empty if no configuration file is given.
+var cfg = &config{}
+
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	for name, p := range c.Profiles {
+		if _, err := parsePriority(p.Priority); err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %w", name, err)
+		}
+		if err := validateTier(p.Tier); err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %w", name, err)
+		}
+	}
+
+	if c.Client.MinSessions > 0 && c.Client.MaxSessions > 0 && c.Client.MinSessions > c.Client.MaxSessions {
+		return nil, fmt.Errorf("client.min_sessions must not be greater than client.max_sessions")
+	}
+
+	for name, t := range c.Limits.Tools {
+		if t == nil || t.MaxConcurrentCalls < 0 || t.QPS < 0 || t.Burst < 0 {
+			return nil, fmt.Errorf("invalid limits of tool %q", name)
+		}
+	}
+
+	if err := c.Output.Render.validate(); err != nil {
+		return nil, fmt.Errorf("invalid output.render: %w", err)
+	}
+
+	if err := c.Masking.validate(); err != nil {
+		return nil, fmt.Errorf("invalid masking: %w", err)
+	}
+
+	if err := c.Access.validate(); err != nil {
+		return nil, fmt.Errorf("invalid access: %w", err)
+	}
+
+	if err := c.Timeouts.validate(); err != nil {
+		return nil, fmt.Errorf("invalid timeouts: %w", err)
+	}
+
+	if err := validateTier(c.DefaultTier); err != nil {
+		return nil, fmt.Errorf("invalid default_tier: %w", err)
+	}
+
+	if c.DefaultProfile != "" && c.Profiles[c.DefaultProfile] == nil {
+		return nil, fmt.Errorf("default_profile %q is not defined", c.DefaultProfile)
+	}
+	return &c, nil
+}
+
+func parsePriority(s string) (sppb.RequestOptions_Priority, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return sppb.RequestOptions_PRIORITY_UNSPECIFIED, nil
+	case "low":
+		return sppb.RequestOptions_PRIORITY_LOW, nil
+	case "medium":
+		return sppb.RequestOptions_PRIORITY_MEDIUM, nil
+	case "high":
+		return sppb.RequestOptions_PRIORITY_HIGH, nil
+	default:
+		return sppb.RequestOptions_PRIORITY_UNSPECIFIED, fmt.Errorf("unknown priority: %q", s)
+	}
+}
+
+// databaseArgs are the arguments to identify the target database, shared by tools.
+// Use with `mapstructure:",squash"`.
+type databaseArgs struct {
+	Profile  string `mapstructure:"profile"`
+	Project  string `mapstructure:"project"`
+	Instance string `mapstructure:"instance"`
+	Database string `mapstructure:"database"`
+}
+
+// target resolves the arguments using the profile. Explicit arguments override the profile.
+// If no arguments are given, the default database of the session set by use_database or default_profile is used.
+func (a databaseArgs) target(ctx context.Context) (*profile, error) {
+	t, err := a.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Project == "" || t.Instance == "" || t.Database == "" {
+		return nil, &validationError{Message: "project, instance and database are required unless profile or use_database is specified"}
+	}
+	if err := validateTarget(t); err != nil {
+		return nil, err
+	}
+	if err := checkTier(ctx, t); err != nil {
+		return nil, err
+	}
+	updateSessionTools(ctx, t)
+	return t, nil
+}
+
+// instanceTarget resolves the arguments like target but only requires the project and the instance.
+func (a databaseArgs) instanceTarget(ctx context.Context) (*profile, error) {
+	t, err := a.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Project == "" || t.Instance == "" {
+		return nil, &validationError{Message: "project and instance are required unless profile or use_database is specified"}
+	}
+	if err := validateTarget(t); err != nil {
+		return nil, err
+	}
+	return t, checkTier(ctx, t)
+}
+
+// databaseNameRe matches projects/{project}/instances/{instance}/databases/{database}
+// and spanner://{project}/{instance}/{database}, optionally followed by a resource path.
+var databaseNameRe = regexp.MustCompile(`^(?:projects/([^/]+)/instances/([^/]+)/databases/([^/]+)|spanner://([^/]+)/([^/]+)/([^/]+)(?:/.*)?)$`)
+
+// expandDatabaseName splits the database argument if it is a full resource name or a URI.
+// Project and instance arguments are allowed only if they match the name.
+func (a databaseArgs) expandDatabaseName() (databaseArgs, error) {
+	if !strings.Contains(a.Database, "/") {
+		return a, nil
+	}
+
+	m := databaseNameRe.FindStringSubmatch(a.Database)
+	if m == nil {
+		return a, &validationError{Field: "database", Message: fmt.Sprintf("%q is neither a database ID, projects/{project}/instances/{instance}/databases/{database} nor spanner://{project}/{instance}/{database}", a.Database)}
+	}
+	project, instance, database := m[1]+m[4], m[2]+m[5], m[3]+m[6]
+
+	if a.Project != "" && a.Project != project {
+		return a, &validationError{Field: "project", Message: fmt.Sprintf("%q conflicts with the database %q", a.Project, a.Database)}
+	}
+	if a.Instance != "" && a.Instance != instance {
+		return a, &validationError{Field: "instance", Message: fmt.Sprintf("%q conflicts with the database %q", a.Instance, a.Database)}
+	}
+	a.Project, a.Instance, a.Database = project, instance, database
+	return a, nil
+}
+
+func (a databaseArgs) resolve(ctx context.Context) (*profile, error) {
+	if a == (databaseArgs{}) {
+		if args, ok := sessionDatabase(ctx); ok {
+			a = args
+		}
+	}
+
+	a, err := a.expandDatabaseName()
+	if err != nil {
+		return nil, err
+	}
+
+	var t profile
+
+	name := a.Profile
+	if a == (databaseArgs{}) {
+		name = cfg.DefaultProfile
+	}
+
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile: %q", name)
+		}
+		t = *p
+	}
+
+	if a.Project != "" {
+		t.Project = a.Project
+	}
+	if a.Instance != "" {
+		t.Instance = a.Instance
+	}
+	if a.Database != "" {
+		t.Database = a.Database
+	}
+	return &t, nil
+}
+
+// queryArgs are databaseArgs with the arguments for tools using the data client.
+// Use with `mapstructure:",squash"`.
+type queryArgs struct {
+	databaseArgs  `mapstructure:",squash"`
+	DatabaseRole  string `mapstructure:"database_role"`
+	RouteToLeader *bool  `mapstructure:"route_to_leader"`
+}
+
+// target resolves the arguments like databaseArgs.target and overrides the database role and routing of the profile.
+func (a queryArgs) target(ctx context.Context) (*profile, error) {
+	t, err := a.databaseArgs.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.DatabaseRole != "" {
+		t.DatabaseRole = a.DatabaseRole
+	}
+	if a.RouteToLeader != nil {
+		t.routeToLeader = strconv.FormatBool(*a.RouteToLeader)
+	}
+	return t, nil
+}
+
+func (p *profile) databasePath() string {
+	return databasePath(p.Project, p.Instance, p.Database)
+}
+
+// withDatabaseArgs adds the arguments of databaseArgs to the tool.
+func withDatabaseArgs() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		for _, opt := range []mcp.ToolOption{
+			mcp.WithString("profile",
+				mcp.Description("Name of the connection profile in the config file. Alternative to project, instance and database"),
+			),
+			mcp.WithString("project",
+				mcp.Description("Google Cloud project"),
+			),
+			mcp.WithString("instance",
+				mcp.Description("Spanner instance id"),
+			),
+			mcp.WithString("database",
+				mcp.Description("Spanner database id. Also accepts projects/{project}/instances/{instance}/databases/{database} or spanner://{project}/{instance}/{database}, which make project and instance unnecessary"),
+			),
+		} {
+			opt(t)
+		}
+	}
+}
+
+// withQueryArgs adds the arguments of queryArgs to the tool.
+func withQueryArgs() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		withDatabaseArgs()(t)
+		mcp.WithString("database_role",
+			mcp.Description("Database role used for fine-grained access control (overrides the profile)"),
+		)(t)
+		mcp.WithBoolean("route_to_leader",
+			mcp.Description("true routes all requests including read-only queries to the leader region, false routes no requests to the leader (default: the disable_route_to_leader client option). Useful to compare latency in multi-region instances"),
+		)(t)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// confirmDestructive enables confirmation of destructive statements by elicitation. It is set by --confirm-destructive.
+var confirmDestructive = true
+
+var errConfirmationUnsupported = errors.New("destructive statements require confirmation but the client does not support elicitation (start the server with --confirm-destructive=false to skip confirmation)")
+
+// destructiveKeywords start statements which drop schema objects or delete data.
+var destructiveKeywords = []string{"DROP", "DELETE", "TRUNCATE"}
+
+// safeKeywords start statements which are not destructive, except ALTER with DROP.
+var safeKeywords = []string{"ALTER", "CREATE", "INSERT", "UPDATE", "SELECT", "WITH", "GRAPH", "GRANT", "REVOKE", "RENAME", "ANALYZE", "CALL"}
+
+// isDestructiveStatement decides by the first keyword after the statement hint, so comments and hints don't hide it.
+// Statements which can't be classified are destructive to be confirmed.
+func isDestructiveStatement(stmt string) bool {
+	tokens, ok := skipStatementHint(scanSQL(stmt))
+	if !ok || len(tokens) == 0 || tokens[0].kind != sqlWord {
+		return true
+	}
+	switch first := tokens[0]; {
+	case slices.ContainsFunc(destructiveKeywords, first.isKeyword):
+		return true
+	case first.isKeyword("ALTER"):
+		return slices.ContainsFunc(tokens, func(t sqlToken) bool { return t.depth == 0 && t.isKeyword("DROP") })
+	default:
+		return !slices.ContainsFunc(safeKeywords, first.isKeyword)
+	}
+}
+
+// confirmStatements asks the user to type the database ID before destructive statements are executed.
+// It returns nil if no statement is destructive or the user confirmed.
+func confirmStatements(ctx context.Context, target *profile, statements []string) error {
+	destructive := lo.Filter(statements, func(stmt string, _ int) bool { return isDestructiveStatement(stmt) })
+	if !confirmDestructive || len(destructive) == 0 {
+		return nil
+	}
+
+	s := server.ServerFromContext(ctx)
+	if s == nil || !clientSupportsElicitation(ctx) {
+		return errConfirmationUnsupported
+	}
+
+	result, err := s.RequestElicitation(ctx, mcp.ElicitationRequest{
+		Params: mcp.ElicitationParams{
+			Message: fmt.Sprintf("The following statements will be executed on %s:\n\n%s\nType the database ID %q to confirm.",
+				target.databasePath(), formatStatements(destructive), target.Database),
+			RequestedSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"database": map[string]any{
+						"type":        "string",
+						"title":       "Database ID",
+						"description": "Type the database ID to confirm",
+					},
+				},
+				"required": []string{"database"},
+			},
+		},
+	})
+	if errors.Is(err, server.ErrElicitationNotSupported) {
+		return errConfirmationUnsupported
+	}
+	if err != nil {
+		return fmt.Errorf("failed to confirm destructive statements: %w", err)
+	}
+
+	if result.Action != mcp.ElicitationResponseActionAccept {
+		return fmt.Errorf("destructive statements are not confirmed by the user (%s)", result.Action)
+	}
+
+	content, _ := result.Content.(map[string]any)
+	if typed, _ := content["database"].(string); strings.TrimSpace(typed) != target.Database {
+		return fmt.Errorf("destructive statements are not confirmed: typed database ID %q doesn't match %q", typed, target.Database)
+	}
+	return nil
+}
+
+func clientSupportsElicitation(ctx context.Context) bool {
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	return ok && session.GetClientCapabilities().Elicitation != nil
+}
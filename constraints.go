@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// foreignKeysSQL queries the columns of foreign keys, which is followed by filters of tc for referencing tables
+// and pk for referenced tables, and foreignKeysOrder. constraintsOf converts the rows into foreign keys.
+const foreignKeysSQL = `SELECT tc.TABLE_SCHEMA, tc.TABLE_NAME, rc.CONSTRAINT_NAME, rc.DELETE_RULE, tc.ENFORCED = 'YES' AS ENFORCED, rc.SPANNER_STATE,
+  fk.COLUMN_NAME, pk.TABLE_SCHEMA AS REF_SCHEMA, pk.TABLE_NAME AS REF_TABLE, pk.COLUMN_NAME AS REF_COLUMN
+FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc
+JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS tc
+  ON tc.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND tc.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS fk
+  ON fk.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND fk.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS pk
+  ON pk.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND pk.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME
+  AND pk.ORDINAL_POSITION = fk.POSITION_IN_UNIQUE_CONSTRAINT
+WHERE TRUE`
+
+const foreignKeysOrder = `
+ORDER BY tc.TABLE_SCHEMA, tc.TABLE_NAME, rc.CONSTRAINT_NAME, fk.ORDINAL_POSITION`
+
+func listConstraintsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]any{}
+	checkFilter, fkFilter := "", ""
+	if req.Table != "" {
+		if err := cfg.Access.checkTable("table", req.Table); err != nil {
+			return nil, err
+		}
+		schema, name, ok := strings.Cut(strings.ReplaceAll(req.Table, "`", ""), ".")
+		if !ok {
+			schema, name = "", schema
+		}
+		params["schema"], params["table"] = schema, name
+		checkFilter = " AND tc.TABLE_SCHEMA = @schema AND tc.TABLE_NAME = @table"
+		// Foreign keys referencing the table also restrict its deletes.
+		fkFilter = " AND (tc.TABLE_SCHEMA = @schema AND tc.TABLE_NAME = @table OR pk.TABLE_SCHEMA = @schema AND pk.TABLE_NAME = @table)"
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// NOT NULL columns are implicit CHECK constraints named CK_IS_NOT_NULL_*, which are omitted.
+	checks, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT tc.TABLE_SCHEMA, tc.TABLE_NAME, tc.CONSTRAINT_NAME, cc.CHECK_CLAUSE, tc.ENFORCED = 'YES' AS ENFORCED, cc.SPANNER_STATE
+FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS tc
+JOIN INFORMATION_SCHEMA.CHECK_CONSTRAINTS AS cc
+  ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+WHERE tc.CONSTRAINT_TYPE = 'CHECK' AND NOT STARTS_WITH(tc.CONSTRAINT_NAME, 'CK_IS_NOT_NULL_')` + checkFilter + `
+ORDER BY tc.TABLE_SCHEMA, tc.TABLE_NAME, tc.CONSTRAINT_NAME`,
+		Params: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fks, err := queryRows(ctx, client, spanner.Statement{
+		SQL:    foreignKeysSQL + fkFilter + foreignKeysOrder,
+		Params: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := constraintsOf(checks, fks)
+	if len(out.Checks) == 0 && len(out.ForeignKeys) == 0 {
+		return mcp.NewToolResultStructured(out, "No CHECK constraints or foreign keys\n"), nil
+	}
+	return mcp.NewToolResultStructured(out, renderConstraints(out)), nil
+}
+
+// constraintsOf converts rows of INFORMATION_SCHEMA into constraints. Rows of a foreign key are its columns in order.
+func constraintsOf(checks, fks []map[string]any) listConstraintsOutput {
+	str := func(row map[string]any, key string) string {
+		s, _ := row[key].(string)
+		return s
+	}
+	out := listConstraintsOutput{
+		Checks: lo.Map(checks, func(row map[string]any, _ int) checkConstraint {
+			return checkConstraint{
+				Table:    qualifiedTableName(str(row, "TABLE_SCHEMA"), str(row, "TABLE_NAME")),
+				Name:     str(row, "CONSTRAINT_NAME"),
+				Clause:   str(row, "CHECK_CLAUSE"),
+				Enforced: row["ENFORCED"] != false,
+				State:    str(row, "SPANNER_STATE"),
+			}
+		}),
+		ForeignKeys: []foreignKeyConstraint{},
+	}
+	for _, group := range lo.PartitionBy(fks, func(row map[string]any) string {
+		return qualifiedTableName(str(row, "TABLE_SCHEMA"), str(row, "TABLE_NAME")) + "." + str(row, "CONSTRAINT_NAME")
+	}) {
+		fk := group[0]
+		out.ForeignKeys = append(out.ForeignKeys, foreignKeyConstraint{
+			Table:             qualifiedTableName(str(fk, "TABLE_SCHEMA"), str(fk, "TABLE_NAME")),
+			Name:              str(fk, "CONSTRAINT_NAME"),
+			Columns:           lo.Map(group, func(row map[string]any, _ int) string { return str(row, "COLUMN_NAME") }),
+			ReferencedTable:   qualifiedTableName(str(fk, "REF_SCHEMA"), str(fk, "REF_TABLE")),
+			ReferencedColumns: lo.Map(group, func(row map[string]any, _ int) string { return str(row, "REF_COLUMN") }),
+			OnDelete:          lo.CoalesceOrEmpty(str(fk, "DELETE_RULE"), "NO ACTION"),
+			Enforced:          fk["ENFORCED"] != false,
+			State:             str(fk, "SPANNER_STATE"),
+		})
+	}
+	return out
+}
+
+func renderConstraints(out listConstraintsOutput) string {
+	enforcement := func(enforced bool, state string) string {
+		s := "ENFORCED"
+		if !enforced {
+			s = "NOT ENFORCED"
+		}
+		// Constraints being added to existing rows are VALIDATING_DATA until the validation completes.
+		if state != "" && state != "COMMITTED" {
+			s += " (" + state + ")"
+		}
+		return s
+	}
+
+	var b strings.Builder
+	if len(out.Checks) > 0 {
+		fmt.Fprintf(&b, "CHECK constraints (%d):\n", len(out.Checks))
+		table := newTable(&b)
+		table.SetHeader([]string{"Table", "Name", "Check", "Enforcement"})
+		for _, c := range out.Checks {
+			table.Append([]string{c.Table, c.Name, c.Clause, enforcement(c.Enforced, c.State)})
+		}
+		table.Render()
+	}
+	if len(out.ForeignKeys) > 0 {
+		fmt.Fprintf(&b, "Foreign keys (%d):\n", len(out.ForeignKeys))
+		table := newTable(&b)
+		table.SetHeader([]string{"Table", "Name", "Foreign key", "On delete", "Enforcement"})
+		for _, fk := range out.ForeignKeys {
+			table.Append([]string{
+				fk.Table,
+				fk.Name,
+				fmt.Sprintf("(%s) REFERENCES %s (%s)", strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", ")),
+				fk.OnDelete,
+				enforcement(fk.Enforced, fk.State),
+			})
+		}
+		table.Render()
+	}
+	return b.String()
+}
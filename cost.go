@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// Thresholds of estimate_cost. Full scans up to cheapScanRows rows are cheap, and more than expensiveScanRows rows or expensiveScanBytes bytes are expensive.
+const (
+	cheapScanRows      = 10_000
+	expensiveScanRows  = 1_000_000
+	expensiveScanBytes = 1 << 30
+)
+
+func estimateCostHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Query     string `mapstructure:"query"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	qp, err := analyzeStatement(ctx, target, client, req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan the query: %w", err)
+	}
+
+	out, err := estimateCost(ctx, client, qp)
+	if err != nil {
+		return nil, err
+	}
+	if !isDML(req.Query) {
+		out.DataBoost = estimateDataBoost(ctx, client, req.Query, out)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Cost: %s\n", out.Cost)
+	if out.EstimatedRowsScanned != nil {
+		fmt.Fprintf(&b, "Estimated rows scanned by full scans: %d\n", *out.EstimatedRowsScanned)
+	}
+	for _, s := range out.Scans {
+		fmt.Fprintf(&b, "- node %d: %s scan of the %s %s", s.Node, lo.Ternary(s.Full, "full", "ranged"), s.Kind, s.Target)
+		if s.EstimatedRows != nil {
+			fmt.Fprintf(&b, ", about %d rows", *s.EstimatedRows)
+		}
+		if s.UsedBytes != nil {
+			fmt.Fprintf(&b, ", %d bytes", *s.UsedBytes)
+		}
+		b.WriteString("\n")
+	}
+	if out.DistributedCrossApplies > 0 {
+		fmt.Fprintf(&b, "Distributed cross applies: %d\n", out.DistributedCrossApplies)
+	}
+	for _, r := range out.Reasons {
+		fmt.Fprintf(&b, "Reason: %s\n", r)
+	}
+	if d := out.DataBoost; d != nil {
+		fmt.Fprintf(&b, "Data Boost: %s", lo.Ternary(d.Eligible, fmt.Sprintf("eligible with %d partitions", d.Partitions), "not eligible"))
+		if d.EstimatedBytesScanned != nil {
+			fmt.Fprintf(&b, ", about %d bytes read by full scans", *d.EstimatedBytesScanned)
+		}
+		fmt.Fprintf(&b, ", %s: %s\n", lo.Ternary(d.Recommended, "recommended", "not recommended"), d.Reason)
+	}
+	fmt.Fprintf(&b, "Note: %s\n", out.Note)
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// estimateDataBoost checks whether the query can run on Data Boost, which requires a root-partitionable query,
+// by partitioning it without execution. Data Boost bills serverless processing by the work of the query, which is
+// estimated by the bytes read by full scans, and it is worth its startup overhead only for queries which are not cheap.
+func estimateDataBoost(ctx context.Context, client *spanner.Client, query string, cost *estimateCostOutput) *dataBoostEstimate {
+	d := &dataBoostEstimate{}
+	txn, err := client.BatchReadOnlyTransaction(ctx, spanner.StrongRead())
+	if err != nil {
+		d.Reason = fmt.Sprintf("failed to begin a batch transaction: %v", spanner.ErrDesc(err))
+		return d
+	}
+	defer txn.Close()
+
+	var partitions []*spanner.Partition
+	err = retry(ctx, func(ctx context.Context) error {
+		partitions, err = txn.PartitionQueryWithOptions(ctx, spanner.NewStatement(query), spanner.PartitionOptions{},
+			spanner.QueryOptions{DataBoostEnabled: true})
+		return err
+	})
+	if err != nil {
+		d.Reason = fmt.Sprintf("the query is not root-partitionable: %s", spanner.ErrDesc(err))
+		return d
+	}
+	d.Eligible, d.Partitions = true, len(partitions)
+
+	// Bytes are only known if the sizes of all fully scanned tables and indexes are known. Sizes of ranged scans are not known.
+	full := lo.Filter(cost.Scans, func(s costScan, _ int) bool { return s.Full && s.Kind != "batch" })
+	if len(full) > 0 && lo.EveryBy(full, func(s costScan) bool { return s.UsedBytes != nil }) {
+		bytes := lo.SumBy(full, func(s costScan) int64 { return *s.UsedBytes })
+		d.EstimatedBytesScanned = &bytes
+	}
+
+	if cost.Cost == "cheap" {
+		d.Reason = "the query is cheap, so it runs faster on the provisioned compute of the instance than with the startup overhead of Data Boost"
+		return d
+	}
+	d.Recommended = true
+	d.Reason = fmt.Sprintf("the query is %s, so run it by execute_partitioned_query or export_to_gcs with data_boost to avoid its load on the provisioned compute. "+
+		"Serverless processing is billed by the work of the query, which grows with the bytes read. It requires the spanner.databases.useDataBoost permission", cost.Cost)
+	return d
+}
+
+// estimateCost classifies the plan by the sizes of fully scanned tables and distributed cross applies.
+// Ranged scans are assumed to be cheap because their sizes depend on the key ranges, which are not known before execution.
+func estimateCost(ctx context.Context, client *spanner.Client, qp *sppb.QueryPlan) (*estimateCostOutput, error) {
+	out := &estimateCostOutput{
+		Cost: "cheap",
+		Note: "The estimate is based on the query plan and hourly table size statistics without executing the query. Use execute_query with query_mode PROFILE for actual rows scanned",
+	}
+
+	sizes := make(map[string]*tableSize)
+	var scanned int64
+	// Rows of indexes are not sampled, so the total of rows is only known if all fully scanned targets are tables with statistics.
+	rowsKnown := true
+	for _, scan := range planScans(qp) {
+		s := costScan{Node: scan.index, Target: scan.target, Kind: scan.kind, Full: scan.full}
+		if scan.full && scan.kind != "batch" {
+			size, ok := sizes[scan.target]
+			if !ok {
+				var err error
+				size, err = latestTableSize(ctx, client, scan.target)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get the size of %s: %w", scan.target, err)
+				}
+				sizes[scan.target] = size
+			}
+
+			switch {
+			case size == nil:
+				rowsKnown = false
+				out.raise("moderate", fmt.Sprintf("Full scan of the %s %s whose size is not available yet", scan.kind, scan.target))
+			case scan.kind == "table":
+				quoted, err := quoteTableName(scan.target)
+				if err != nil {
+					return nil, err
+				}
+				rows, err := estimateRows(ctx, client, quoted, size.usedBytes)
+				if err != nil {
+					return nil, fmt.Errorf("failed to estimate rows of %s: %w", scan.target, err)
+				}
+				s.EstimatedRows = &rows
+				s.UsedBytes = &size.usedBytes
+				scanned += rows
+				out.raise(costClass(rows, size.usedBytes), fmt.Sprintf("Full scan of the table %s with about %d rows", scan.target, rows))
+			default:
+				s.UsedBytes = &size.usedBytes
+				rowsKnown = false
+				out.raise(costClass(0, size.usedBytes), fmt.Sprintf("Full scan of the index %s with %d bytes", scan.target, size.usedBytes))
+			}
+		}
+		out.Scans = append(out.Scans, s)
+	}
+	if rowsKnown {
+		out.EstimatedRowsScanned = &scanned
+	}
+
+	for _, node := range qp.GetPlanNodes() {
+		name := node.GetDisplayName()
+		if strings.HasPrefix(name, "Distributed") && strings.HasSuffix(name, "Apply") {
+			out.DistributedCrossApplies++
+		}
+	}
+	if out.DistributedCrossApplies > 0 {
+		out.raise("moderate", fmt.Sprintf("The plan has %d distributed cross apply operators, which send a batch of rows to remote splits for each batch of the input", out.DistributedCrossApplies))
+	}
+	return out, nil
+}
+
+// costClasses are the classes of estimate_cost in ascending order.
+var costClasses = []string{"cheap", "moderate", "expensive"}
+
+func costClass(rows, usedBytes int64) string {
+	switch {
+	case rows > expensiveScanRows || usedBytes > expensiveScanBytes:
+		return "expensive"
+	case rows > cheapScanRows:
+		return "moderate"
+	default:
+		return "cheap"
+	}
+}
+
+// raise raises the class of the output to class if it is higher, and records the reason unless the class is cheap.
+func (out *estimateCostOutput) raise(class, reason string) {
+	if class != "cheap" {
+		out.Reasons = append(out.Reasons, reason)
+	}
+	if slices.Index(costClasses, class) > slices.Index(costClasses, out.Cost) {
+		out.Cost = class
+	}
+}
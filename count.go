@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"google.golang.org/protobuf/proto"
+)
+
+// sizeSampleRows is the number of rows read to estimate the average row size in the approximate mode of count_rows.
+const sizeSampleRows = 100
+
+const exactCountWarning = "COUNT(*) scans the whole table or an index unless the filter is satisfied by a key range, which may be expensive on large tables"
+
+func countRowsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+		Mode      string `mapstructure:"mode"`
+		Filter    string `mapstructure:"filter"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := quoteTableName(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	out := countRowsOutput{Table: strings.ReplaceAll(req.Table, "`", ""), Mode: req.Mode}
+	switch req.Mode {
+	case "", "exact":
+		out.Mode = "exact"
+		out.Warning = exactCountWarning
+		sql := "SELECT COUNT(*) FROM " + table
+		if req.Filter != "" {
+			sql += " WHERE " + req.Filter
+		}
+		if err := cfg.Access.checkStatement(ctx, target, "filter", sql); err != nil {
+			return nil, err
+		}
+		count, err := queryInt64(ctx, client, spanner.NewStatement(sql))
+		if err != nil {
+			return nil, err
+		}
+		out.Count = &count
+	case "approximate":
+		if req.Filter != "" {
+			return nil, &validationError{Field: "filter", Message: "is only supported in the exact mode"}
+		}
+		if err := approximateCount(ctx, client, table, &out); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &validationError{Field: "mode", Message: "must be exact or approximate"}
+	}
+
+	var b strings.Builder
+	switch {
+	case out.Count != nil:
+		fmt.Fprintf(&b, "%s has %d rows\nWarning: %s\n", out.Table, *out.Count, out.Warning)
+	case out.EstimatedRows != nil:
+		fmt.Fprintf(&b, "%s has about %d rows (%d bytes as of %s)\n", out.Table, *out.EstimatedRows, *out.UsedBytes, out.StatsIntervalEnd.Format(time.RFC3339))
+	default:
+		fmt.Fprintf(&b, "The size of %s is not available\n", out.Table)
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "Note: %s\n", out.Note)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// approximateCount estimates the number of rows by the latest table size statistics divided by the average size of first rows.
+// Table size statistics are updated hourly, and the size is compressed, so the estimate is rough.
+func approximateCount(ctx context.Context, client *spanner.Client, table string, out *countRowsOutput) error {
+	size, err := latestTableSize(ctx, client, out.Table)
+	if err != nil {
+		return err
+	}
+	if size == nil {
+		out.Note = "SPANNER_SYS.TABLE_SIZES_STATS_1HOUR has no statistics of the table yet. Use the exact mode instead"
+		return nil
+	}
+	out.StatsIntervalEnd, out.UsedBytes = &size.intervalEnd, &size.usedBytes
+
+	estimated, err := estimateRows(ctx, client, table, size.usedBytes)
+	if err != nil {
+		return err
+	}
+	out.EstimatedRows = &estimated
+	out.Note = "The estimate is the size of the table divided by the average size of first rows, which may differ from the exact count by an order of magnitude"
+	return nil
+}
+
+type tableSize struct {
+	intervalEnd time.Time
+	usedBytes   int64
+}
+
+// latestTableSize returns the latest size of the table or the index from SPANNER_SYS.TABLE_SIZES_STATS_1HOUR, or nil if there are no statistics yet.
+func latestTableSize(ctx context.Context, client *spanner.Client, name string) (*tableSize, error) {
+	var size *tableSize
+	err := retry(ctx, func(ctx context.Context) error {
+		size = nil
+		return client.Single().Query(ctx, spanner.Statement{
+			SQL: `SELECT INTERVAL_END, USED_BYTES FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR
+WHERE TABLE_NAME = @table
+ORDER BY INTERVAL_END DESC
+LIMIT 1`,
+			Params: map[string]any{"table": name},
+		}).Do(func(row *spanner.Row) error {
+			var s tableSize
+			if err := row.Columns(&s.intervalEnd, &s.usedBytes); err != nil {
+				return err
+			}
+			size = &s
+			return nil
+		})
+	})
+	return size, err
+}
+
+// estimateRows estimates the number of rows of the quoted table from its size and the average size of first rows.
+func estimateRows(ctx context.Context, client *spanner.Client, table string, usedBytes int64) (int64, error) {
+	// Reading first rows by LIMIT doesn't scan the table.
+	var sampled, sampledBytes int64
+	err := retry(ctx, func(ctx context.Context) error {
+		sampled, sampledBytes = 0, 0
+		return client.Single().Query(ctx, spanner.NewStatement(fmt.Sprintf("SELECT * FROM %s LIMIT %d", table, sizeSampleRows))).Do(func(row *spanner.Row) error {
+			sampled++
+			for i := range row.Size() {
+				var gcv spanner.GenericColumnValue
+				if err := row.Column(i, &gcv); err != nil {
+					return err
+				}
+				sampledBytes += int64(proto.Size(gcv.Value))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if sampled == 0 || sampledBytes == 0 {
+		return 0, nil
+	}
+	return usedBytes * sampled / sampledBytes, nil
+}
+
+// queryInt64 executes the statement which returns a single INT64 value.
+func queryInt64(ctx context.Context, client *spanner.Client, stmt spanner.Statement) (int64, error) {
+	var n int64
+	err := retry(ctx, func(ctx context.Context) error {
+		return client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			return row.Column(0, &n)
+		})
+	})
+	return n, err
+}
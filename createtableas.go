@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	defaultCreateTableAsMaxRows = 100000
+	maxCreateTableAsRows        = 1000000
+)
+
+func createTableAsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs  `mapstructure:",squash"`
+		Table      string   `mapstructure:"table"`
+		Query      string   `mapstructure:"query"`
+		Mode       string   `mapstructure:"mode"`
+		PrimaryKey []string `mapstructure:"primary_key"`
+		OnConflict string   `mapstructure:"on_conflict"`
+		MaxRows    int      `mapstructure:"max_rows"`
+		BatchSize  int      `mapstructure:"batch_size"`
+		DryRun     bool     `mapstructure:"dry_run"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	quoted, err := quoteTableName(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	table := strings.ReplaceAll(req.Table, "`", "")
+	if req.Mode == "" {
+		req.Mode = "create"
+	}
+	if req.OnConflict == "" {
+		req.OnConflict = "error"
+	}
+	newMutation, ok := conflictMutations[req.OnConflict]
+	if !ok {
+		return nil, &validationError{Field: "on_conflict", Message: "must be error, update or replace"}
+	}
+	switch req.Mode {
+	case "create":
+		if len(req.PrimaryKey) == 0 {
+			return nil, &validationError{Field: "primary_key", Message: "is required to create the table"}
+		}
+		if req.OnConflict != "error" {
+			return nil, &validationError{Field: "on_conflict", Message: "is only for the append mode"}
+		}
+	case "append":
+		if len(req.PrimaryKey) > 0 {
+			return nil, &validationError{Field: "primary_key", Message: "is only for the create mode"}
+		}
+	default:
+		return nil, &validationError{Field: "mode", Message: "must be create or append"}
+	}
+	if req.MaxRows <= 0 {
+		req.MaxRows = defaultCreateTableAsMaxRows
+	}
+	if req.MaxRows > maxCreateTableAsRows {
+		return nil, &validationError{Field: "max_rows", Message: fmt.Sprintf("must not be greater than %d", maxCreateTableAsRows)}
+	}
+	if req.BatchSize <= 0 {
+		req.BatchSize = defaultImportBatchSize
+	}
+	if req.BatchSize > maxImportBatchSize {
+		return nil, &validationError{Field: "batch_size", Message: fmt.Sprintf("must not be greater than %d", maxImportBatchSize)}
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkStatement(ctx, target, "query", req.Query); err != nil {
+		return nil, err
+	}
+	masks, err := cfg.Masking.queryMasks(ctx, target, "query", req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	rowType, err := queryRowType(ctx, target, req.Query, nil)
+	if err != nil {
+		return nil, err
+	}
+	names, err := materializedColumns(masks, rowType)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	out := createTableAsOutput{Table: req.Table, Mode: req.Mode, Columns: queryColumns(rowType), DryRun: req.DryRun}
+	switch req.Mode {
+	case "create":
+		features, err := detectFeatures(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		if features.Dialect == databasepb.DatabaseDialect_POSTGRESQL {
+			return nil, &validationError{Field: "mode", Message: "create is not supported in PostgreSQL-dialect databases, create the table by update_ddl and use append"}
+		}
+		if out.DDL, err = createTableStatement(quoted, rowType, req.PrimaryKey); err != nil {
+			return nil, err
+		}
+	case "append":
+		columns, err := tableColumnTypes(ctx, client, req.Table)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range rowType.GetFields() {
+			typ, ok := columns[field.GetName()]
+			if !ok {
+				return nil, &validationError{Field: "query", Message: fmt.Sprintf("column %s is not a writable column of %s", field.GetName(), req.Table)}
+			}
+			if formatType(typ) != formatType(field.GetType()) {
+				return nil, &validationError{Field: "query", Message: fmt.Sprintf("column %s is %s in the result but %s in %s, so cast it in the query", field.GetName(), formatType(field.GetType()), formatType(typ), req.Table)}
+			}
+		}
+	}
+
+	if out.DDL != "" && !req.DryRun {
+		if _, err := applyDDL(ctx, target, []string{out.DDL}); err != nil {
+			return nil, err
+		}
+	}
+
+	batcher := &mutationBatcher{client: client, size: req.BatchSize, dryRun: req.DryRun}
+	truncated, err := materializeRows(ctx, client, spanner.NewStatement(req.Query), req.MaxRows, func(values []any) error {
+		return batcher.add(ctx, newMutation(table, names, values))
+	})
+	if err == nil {
+		err = batcher.flush(ctx)
+	}
+	if err != nil {
+		if out.DDL != "" && !req.DryRun {
+			return nil, fmt.Errorf("%w (table %s is created with %d rows)", err, req.Table, batcher.rows)
+		}
+		return nil, err
+	}
+	out.Rows, out.Batches, out.Truncated = batcher.rows, batcher.batches, truncated
+
+	var b strings.Builder
+	switch {
+	case req.DryRun && out.DDL != "":
+		fmt.Fprintf(&b, "Dry run: %s would be created and %d rows would be inserted in %d batches\n%s;\n", req.Table, out.Rows, out.Batches, out.DDL)
+	case req.DryRun:
+		fmt.Fprintf(&b, "Dry run: %d rows would be written into %s in %d batches\n", out.Rows, req.Table, out.Batches)
+	case out.DDL != "":
+		fmt.Fprintf(&b, "Created %s and inserted %d rows in %d batches\n%s;\n", req.Table, out.Rows, out.Batches, out.DDL)
+	default:
+		fmt.Fprintf(&b, "Wrote %d rows into %s in %d batches\n", out.Rows, req.Table, out.Batches)
+	}
+	if out.Truncated {
+		fmt.Fprintf(&b, "The query returned more than %d rows, and the rest are not written. Increase max_rows to write them.\n", req.MaxRows)
+	}
+	if req.DryRun {
+		return mcp.NewToolResultStructured(out, b.String()), nil
+	}
+	auditAffectedRows(ctx, out.Rows)
+	out.Transaction = batcher.diagnostics(ctx)
+	return mcp.NewToolResultStructured(out, b.String()+renderTransactionDiagnostics(out.Transaction)), nil
+}
+
+// materializedColumns returns the names of the columns of the result, which must be named uniquely to be written into a table.
+// Masked columns are rejected because masking would be bypassed by reading the table.
+func materializedColumns(masks map[string]string, rowType *sppb.StructType) ([]string, error) {
+	if len(rowType.GetFields()) == 0 {
+		return nil, &validationError{Field: "query", Message: "the query returns no columns"}
+	}
+	names := make([]string, len(rowType.GetFields()))
+	seen := make(map[string]bool)
+	for i, field := range rowType.GetFields() {
+		name := field.GetName()
+		switch {
+		case name == "":
+			return nil, &validationError{Field: "query", Message: fmt.Sprintf("column %d has no name, so give it an alias", i+1)}
+		case seen[strings.ToLower(name)]:
+			return nil, &validationError{Field: "query", Message: fmt.Sprintf("column %s is duplicated, so give it an alias", name)}
+		case masks[strings.ToLower(name)] != "":
+			return nil, &validationError{Field: "query", Message: fmt.Sprintf("column %s is masked by the server config and can't be written into a table", name)}
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names, nil
+}
+
+// createTableStatement returns CREATE TABLE of the columns of the result in GoogleSQL.
+func createTableStatement(quotedTable string, rowType *sppb.StructType, primaryKey []string) (string, error) {
+	columns := make([]string, len(rowType.GetFields()))
+	for i, field := range rowType.GetFields() {
+		typ, err := columnType(field.GetType())
+		if err != nil {
+			return "", &validationError{Field: "query", Message: fmt.Sprintf("column %s: %v", field.GetName(), err)}
+		}
+		columns[i] = fmt.Sprintf("  `%s` %s", field.GetName(), typ)
+	}
+	for _, key := range primaryKey {
+		if !lo.ContainsBy(rowType.GetFields(), func(field *sppb.StructType_Field) bool { return strings.EqualFold(field.GetName(), key) }) {
+			return "", &validationError{Field: "primary_key", Message: fmt.Sprintf("column %s is not in the result", key)}
+		}
+	}
+	keys := lo.Map(primaryKey, func(key string, _ int) string { return "`" + key + "`" })
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n) PRIMARY KEY (%s)", quotedTable, strings.Join(columns, ",\n"), strings.Join(keys, ", ")), nil
+}
+
+// columnType returns the column type of the result type. STRING and BYTES are unlimited.
+func columnType(typ *sppb.Type) (string, error) {
+	switch typ.GetCode() {
+	case sppb.TypeCode_STRING, sppb.TypeCode_BYTES:
+		return typ.GetCode().String() + "(MAX)", nil
+	case sppb.TypeCode_ARRAY:
+		if typ.GetArrayElementType().GetCode() == sppb.TypeCode_ARRAY {
+			return "", errors.New("nested ARRAY is not a column type")
+		}
+		elem, err := columnType(typ.GetArrayElementType())
+		if err != nil {
+			return "", err
+		}
+		return "ARRAY<" + elem + ">", nil
+	case sppb.TypeCode_STRUCT, sppb.TypeCode_INTERVAL:
+		return "", fmt.Errorf("%s is not a column type, so convert it in the query, e.g. by TO_JSON", typ.GetCode())
+	default:
+		return formatType(typ), nil
+	}
+}
+
+// materializeRows executes the query in a single-use read-only transaction and passes the values of at most maxRows rows to write.
+// It returns true if the result has more rows.
+func materializeRows(ctx context.Context, client *spanner.Client, stmt spanner.Statement, maxRows int, write func(values []any) error) (truncated bool, err error) {
+	it := client.Single().Query(ctx, stmt)
+	defer it.Stop()
+
+	for n := 0; ; n++ {
+		row, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if n == maxRows {
+			return true, nil
+		}
+
+		values := make([]any, row.Size())
+		for i := range values {
+			var v spanner.GenericColumnValue
+			if err := row.Column(i, &v); err != nil {
+				return false, err
+			}
+			values[i] = v
+		}
+		if err := write(values); err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"google.golang.org/api/iterator"
+)
+
+func listDatabasesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[databaseArgs](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	databases, err := listInstanceDatabases(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var out listDatabasesOutput
+	for _, db := range databases {
+		out.Databases = append(out.Databases, listedDatabase{
+			Database: db.GetName()[strings.LastIndex(db.GetName(), "/")+1:],
+			State:    db.GetState().String(),
+			Dialect:  db.GetDatabaseDialect().String(),
+		})
+	}
+
+	var b strings.Builder
+	for _, db := range out.Databases {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", db.Database, db.State, db.Dialect)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// listInstanceDatabases returns the databases in the instance of the target.
+func listInstanceDatabases(ctx context.Context, target *profile) ([]*databasepb.Database, error) {
+	client, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var databases []*databasepb.Database
+	it := client.ListDatabases(ctx, &databasepb.ListDatabasesRequest{
+		Parent: fmt.Sprintf("projects/%s/instances/%s", target.Project, target.Instance),
+	})
+	for {
+		db, err := it.Next()
+		if err == iterator.Done {
+			return databases, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, db)
+	}
+}
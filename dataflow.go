@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+)
+
+// Google-provided classic templates for Spanner. See https://cloud.google.com/dataflow/docs/guides/templates/provided-templates.
+const (
+	exportTemplate = "Cloud_Spanner_to_GCS_Avro"
+	importTemplate = "GCS_Avro_to_Cloud_Spanner"
+)
+
+// maxJobErrors is the number of recent error messages returned by get_dataflow_job.
+const maxJobErrors = 10
+
+var (
+	regionRe  = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+	jobIDRe   = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+	jobNameRe = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,62}[a-z0-9])?$`)
+)
+
+// dataflowArgs are the arguments shared by tools launching Dataflow templates.
+// Use with `mapstructure:",squash"`.
+type dataflowArgs struct {
+	Region         string `mapstructure:"region"`
+	JobName        string `mapstructure:"job_name"`
+	TempLocation   string `mapstructure:"temp_location"`
+	ServiceAccount string `mapstructure:"service_account"`
+	MaxWorkers     int64  `mapstructure:"max_workers"`
+}
+
+// withDataflowArgs adds the arguments of dataflowArgs to the tool.
+func withDataflowArgs() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		for _, opt := range []mcp.ToolOption{
+			mcp.WithString("region",
+				mcp.Required(),
+				mcp.Description("Region to run the Dataflow job, e.g. us-central1. The same region as the instance avoids cross-region traffic"),
+			),
+			mcp.WithString("job_name",
+				mcp.Description("Name of the Dataflow job (default: spanner-{export|import}-{database}-{timestamp})"),
+			),
+			mcp.WithString("temp_location",
+				mcp.Description("gs://{bucket}/{prefix} for temporary files of the job (default: the staging bucket of Dataflow)"),
+			),
+			mcp.WithString("service_account",
+				mcp.Description("Email of the worker service account (default: the Compute Engine default service account)"),
+			),
+			mcp.WithNumber("max_workers",
+				mcp.Description("Maximum number of workers, 0 means the default of Dataflow"),
+			),
+		} {
+			opt(t)
+		}
+	}
+}
+
+func startDataflowExportHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs `mapstructure:",squash"`
+		dataflowArgs `mapstructure:",squash"`
+		OutputDir    string   `mapstructure:"output_dir"`
+		Tables       []string `mapstructure:"tables"`
+		SnapshotTime string   `mapstructure:"snapshot_time"`
+		DataBoost    bool     `mapstructure:"data_boost"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := parseGCSURI(req.OutputDir); err != nil {
+		return nil, &validationError{Field: "output_dir", Message: err.Error()}
+	}
+	if req.SnapshotTime != "" {
+		if _, err := time.Parse(time.RFC3339Nano, req.SnapshotTime); err != nil {
+			return nil, &validationError{Field: "snapshot_time", Message: "must be an RFC 3339 timestamp"}
+		}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"outputDir": req.OutputDir,
+	}
+	if len(req.Tables) > 0 {
+		params["tableNames"] = strings.Join(req.Tables, ",")
+	}
+	if req.SnapshotTime != "" {
+		params["snapshotTime"] = req.SnapshotTime
+	}
+	if req.DataBoost {
+		params["dataBoostEnabled"] = "true"
+	}
+	return launchTemplate(ctx, target, req.dataflowArgs, "export", exportTemplate, params)
+}
+
+func startDataflowImportHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs   `mapstructure:",squash"`
+		dataflowArgs   `mapstructure:",squash"`
+		InputDir       string `mapstructure:"input_dir"`
+		WaitForIndexes bool   `mapstructure:"wait_for_indexes"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := parseGCSURI(req.InputDir); err != nil {
+		return nil, &validationError{Field: "input_dir", Message: err.Error()}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"inputDir": req.InputDir,
+	}
+	if req.WaitForIndexes {
+		params["waitForIndexes"] = "true"
+	}
+	return launchTemplate(ctx, target, req.dataflowArgs, "import", importTemplate, params)
+}
+
+// launchTemplate launches the template for the target database in the project of the database.
+// The job keeps running after the tool call, so the caller tracks it by get_dataflow_job.
+func launchTemplate(ctx context.Context, target *profile, args dataflowArgs, kind, template string, params map[string]string) (*mcp.CallToolResult, error) {
+	if !regionRe.MatchString(args.Region) {
+		return nil, &validationError{Field: "region", Message: fmt.Sprintf("%q is not a region", args.Region)}
+	}
+	if args.JobName == "" {
+		args.JobName = fmt.Sprintf("spanner-%s-%s-%s", kind, strings.ReplaceAll(target.Database, "_", "-"), time.Now().UTC().Format("20060102-150405"))
+	}
+	if !jobNameRe.MatchString(args.JobName) {
+		return nil, &validationError{Field: "job_name", Message: fmt.Sprintf("must match %s", jobNameRe)}
+	}
+	if args.TempLocation != "" {
+		if _, _, err := parseGCSURI(args.TempLocation); err != nil {
+			return nil, &validationError{Field: "temp_location", Message: err.Error()}
+		}
+	}
+	if args.MaxWorkers < 0 {
+		return nil, &validationError{Field: "max_workers", Message: "must not be negative"}
+	}
+
+	service, err := clients.dataflowService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params["spannerProjectId"] = target.Project
+	params["instanceId"] = target.Instance
+	params["databaseId"] = target.Database
+
+	// Regional buckets of templates avoid cross-region staging.
+	gcsPath := fmt.Sprintf("gs://dataflow-templates-%s/latest/%s", args.Region, template)
+	resp, err := service.Projects.Locations.Templates.Launch(target.Project, args.Region, &dataflow.LaunchTemplateParameters{
+		JobName:    args.JobName,
+		Parameters: params,
+		Environment: &dataflow.RuntimeEnvironment{
+			TempLocation:        args.TempLocation,
+			ServiceAccountEmail: args.ServiceAccount,
+			MaxWorkers:          args.MaxWorkers,
+		},
+	}).GcsPath(gcsPath).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch %s: %w", template, err)
+	}
+	if resp.Job == nil {
+		return nil, fmt.Errorf("failed to launch %s: no job in the response", template)
+	}
+	auditOperation(ctx, dataflowJobName(target.Project, args.Region, resp.Job.Id))
+
+	out := newDataflowJobOutput(target.Project, args.Region, resp.Job)
+	text := fmt.Sprintf("Launched Dataflow job %s (%s) to %s %s.\nCheck the status by get_dataflow_job with job_id %q and region %q: %s\n",
+		out.Name, out.ID, kind, target.databasePath(), out.ID, out.Region, out.ConsoleURL)
+	return mcp.NewToolResultStructured(out, text), nil
+}
+
+func getDataflowJobHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		Profile string `mapstructure:"profile"`
+		Project string `mapstructure:"project"`
+		Region  string `mapstructure:"region"`
+		JobID   string `mapstructure:"job_id"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if !regionRe.MatchString(req.Region) {
+		return nil, &validationError{Field: "region", Message: fmt.Sprintf("%q is not a region", req.Region)}
+	}
+	if !jobIDRe.MatchString(req.JobID) {
+		return nil, &validationError{Field: "job_id", Message: fmt.Sprintf("%q is not a Dataflow job ID", req.JobID)}
+	}
+
+	// The job runs in the project of the database.
+	t, err := databaseArgs{Profile: req.Profile, Project: req.Project}.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if t.Project == "" {
+		return nil, &validationError{Field: "project", Message: "is required unless profile or use_database is specified"}
+	}
+	if err := validateTarget(t); err != nil {
+		return nil, err
+	}
+
+	service, err := clients.dataflowService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := service.Projects.Locations.Jobs.Get(t.Project, req.Region, req.JobID).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	out := newDataflowJobOutput(t.Project, req.Region, job)
+
+	if job.CurrentState == "JOB_STATE_FAILED" {
+		messages, err := service.Projects.Locations.Jobs.Messages.List(t.Project, req.Region, req.JobID).
+			MinimumImportance("JOB_MESSAGE_ERROR").PageSize(maxJobErrors).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get error messages: %w", err)
+		}
+		for _, m := range messages.JobMessages {
+			out.Errors = append(out.Errors, fmt.Sprintf("%s %s", m.Time, m.MessageText))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dataflow job %s (%s): %s", out.Name, out.ID, strings.TrimPrefix(out.State, "JOB_STATE_"))
+	if out.StateTime != "" {
+		fmt.Fprintf(&b, " since %s", out.StateTime)
+	}
+	fmt.Fprintf(&b, "\n%s\n", out.ConsoleURL)
+	for _, e := range out.Errors {
+		fmt.Fprintf(&b, "Error: %s\n", e)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+func newDataflowJobOutput(project, region string, job *dataflow.Job) dataflowJobOutput {
+	return dataflowJobOutput{
+		ID:         job.Id,
+		Name:       job.Name,
+		Project:    project,
+		Region:     region,
+		State:      job.CurrentState,
+		StateTime:  job.CurrentStateTime,
+		CreateTime: job.CreateTime,
+		ConsoleURL: fmt.Sprintf("https://console.cloud.google.com/dataflow/jobs/%s/%s?project=%s", region, job.Id, project),
+	}
+}
+
+// dataflowJobName returns the resource name of the job, which is recorded in the audit log like operations.
+func dataflowJobName(project, region, id string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/jobs/%s", project, region, id)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// dbmlIdentifierRe matches names and types which don't need quotes in DBML.
+var dbmlIdentifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func dbmlName(name string) string {
+	if dbmlIdentifierRe.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+// dbmlTableName returns the name of the table in DBML, where tables in named schemas are schema.table.
+func dbmlTableName(schema, table string) string {
+	if schema == "" {
+		return dbmlName(table)
+	}
+	return dbmlName(schema) + "." + dbmlName(table)
+}
+
+func dbmlString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(s) + "'"
+}
+
+// dbmlColumns returns the column list of a Ref, e.g. Albums.(SingerId, AlbumId).
+func dbmlColumns(table string, columns []string) string {
+	names := lo.Map(columns, func(c string, _ int) string { return dbmlName(c) })
+	if len(names) == 1 {
+		return table + "." + names[0]
+	}
+	return fmt.Sprintf("%s.(%s)", table, strings.Join(names, ", "))
+}
+
+func exportDBMLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	out, err := databaseDBML(ctx, client, target)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultStructured(out, out.DBML), nil
+}
+
+// databaseDBML converts the tables, indexes, foreign keys and interleaving of the database in INFORMATION_SCHEMA to DBML.
+// Interleaved tables are Refs from the key columns of the parent table.
+func databaseDBML(ctx context.Context, client *spanner.Client, target *profile) (exportDBMLOutput, error) {
+	const userTables = `TABLE_SCHEMA NOT IN ('INFORMATION_SCHEMA', 'SPANNER_SYS')`
+
+	tables, err := queryRows(ctx, client, spanner.NewStatement(`SELECT TABLE_SCHEMA, TABLE_NAME, PARENT_TABLE_NAME, ON_DELETE_ACTION, INTERLEAVE_TYPE
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_TYPE = 'BASE TABLE' AND `+userTables+`
+ORDER BY TABLE_SCHEMA, TABLE_NAME`))
+	if err != nil {
+		return exportDBMLOutput{}, err
+	}
+
+	columns, err := queryRows(ctx, client, spanner.NewStatement(`SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, SPANNER_TYPE, IS_NULLABLE = 'YES' AS NULLABLE,
+  COLUMN_DEFAULT, GENERATION_EXPRESSION, IS_STORED = 'YES' AS STORED
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE `+userTables+`
+ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`))
+	if err != nil {
+		return exportDBMLOutput{}, err
+	}
+
+	// Storing columns of indexes have no ordinal positions.
+	indexColumns, err := queryRows(ctx, client, spanner.NewStatement(`SELECT i.TABLE_SCHEMA, i.TABLE_NAME, i.INDEX_NAME, i.INDEX_TYPE, i.IS_UNIQUE, ic.COLUMN_NAME
+FROM INFORMATION_SCHEMA.INDEXES AS i
+JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+  ON ic.TABLE_SCHEMA = i.TABLE_SCHEMA AND ic.TABLE_NAME = i.TABLE_NAME AND ic.INDEX_NAME = i.INDEX_NAME
+WHERE i.`+userTables+` AND i.INDEX_TYPE IN ('PRIMARY_KEY', 'INDEX') AND ic.ORDINAL_POSITION IS NOT NULL
+ORDER BY i.TABLE_SCHEMA, i.TABLE_NAME, i.INDEX_TYPE DESC, i.INDEX_NAME, ic.ORDINAL_POSITION`))
+	if err != nil {
+		return exportDBMLOutput{}, err
+	}
+
+	fks, err := queryRows(ctx, client, spanner.NewStatement(`SELECT rc.CONSTRAINT_NAME, rc.DELETE_RULE, fk.TABLE_SCHEMA, fk.TABLE_NAME, fk.COLUMN_NAME,
+  pk.TABLE_SCHEMA AS REF_SCHEMA, pk.TABLE_NAME AS REF_TABLE, pk.COLUMN_NAME AS REF_COLUMN
+FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS fk
+  ON fk.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND fk.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS pk
+  ON pk.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND pk.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME
+  AND pk.ORDINAL_POSITION = fk.POSITION_IN_UNIQUE_CONSTRAINT
+ORDER BY fk.TABLE_SCHEMA, fk.TABLE_NAME, rc.CONSTRAINT_NAME, fk.ORDINAL_POSITION`))
+	if err != nil {
+		return exportDBMLOutput{}, err
+	}
+	return renderDBML(target, tables, columns, indexColumns, fks), nil
+}
+
+// renderDBML renders rows of INFORMATION_SCHEMA read by databaseDBML.
+func renderDBML(target *profile, tables, columns, indexColumns, fks []map[string]any) exportDBMLOutput {
+	str := func(row map[string]any, key string) string {
+		s, _ := row[key].(string)
+		return s
+	}
+	tableKey := func(row map[string]any) string {
+		return dbmlTableName(str(row, "TABLE_SCHEMA"), str(row, "TABLE_NAME"))
+	}
+	columnsByTable := lo.GroupBy(columns, tableKey)
+	indexesByTable := lo.GroupBy(indexColumns, tableKey)
+	primaryKeys := make(map[string][]string)
+	for _, row := range indexColumns {
+		if str(row, "INDEX_TYPE") == "PRIMARY_KEY" {
+			primaryKeys[tableKey(row)] = append(primaryKeys[tableKey(row)], str(row, "COLUMN_NAME"))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Project %s {\n  database_type: 'Spanner'\n  Note: %s\n}\n", dbmlName(target.Database), dbmlString(target.databasePath()))
+
+	out := exportDBMLOutput{Tables: len(tables)}
+	var refs []string
+	for _, t := range tables {
+		name := tableKey(t)
+		keys := primaryKeys[name]
+		fmt.Fprintf(&b, "\nTable %s {\n", name)
+		for _, c := range columnsByTable[name] {
+			column := str(c, "COLUMN_NAME")
+			var settings []string
+			if len(keys) == 1 && keys[0] == column {
+				settings = append(settings, "pk")
+			}
+			if c["NULLABLE"] != true {
+				settings = append(settings, "not null")
+			}
+			if def := str(c, "COLUMN_DEFAULT"); def != "" {
+				settings = append(settings, fmt.Sprintf("default: `%s`", def))
+			}
+			if gen := str(c, "GENERATION_EXPRESSION"); gen != "" {
+				note := fmt.Sprintf("AS (%s)", gen)
+				if c["STORED"] == true {
+					note += " STORED"
+				}
+				settings = append(settings, "note: "+dbmlString(note))
+			}
+			fmt.Fprintf(&b, "  %s %s", dbmlName(column), dbmlName(str(c, "SPANNER_TYPE")))
+			if len(settings) > 0 {
+				fmt.Fprintf(&b, " [%s]", strings.Join(settings, ", "))
+			}
+			b.WriteString("\n")
+		}
+
+		var indexes []string
+		for _, group := range lo.PartitionBy(indexesByTable[name], func(row map[string]any) string { return str(row, "INDEX_NAME") }) {
+			index := group[0]
+			columns := lo.Map(group, func(row map[string]any, _ int) string { return dbmlName(str(row, "COLUMN_NAME")) })
+			cols := strings.Join(columns, ", ")
+			if len(columns) > 1 {
+				cols = "(" + cols + ")"
+			}
+			if str(index, "INDEX_TYPE") == "PRIMARY_KEY" {
+				if len(columns) > 1 {
+					indexes = append(indexes, cols+" [pk]")
+				}
+				continue
+			}
+			settings := []string{"name: " + dbmlString(str(index, "INDEX_NAME"))}
+			if index["IS_UNIQUE"] == true {
+				settings = append(settings, "unique")
+			}
+			indexes = append(indexes, fmt.Sprintf("%s [%s]", cols, strings.Join(settings, ", ")))
+		}
+		if len(indexes) > 0 {
+			b.WriteString("\n  Indexes {\n")
+			for _, index := range indexes {
+				fmt.Fprintf(&b, "    %s\n", index)
+			}
+			b.WriteString("  }\n")
+		}
+
+		if parent := str(t, "PARENT_TABLE_NAME"); parent != "" {
+			parentName := dbmlTableName(str(t, "TABLE_SCHEMA"), parent)
+			clause := "INTERLEAVE IN " + parent
+			if str(t, "INTERLEAVE_TYPE") != "IN" {
+				clause = "INTERLEAVE IN PARENT " + parent
+				if action := str(t, "ON_DELETE_ACTION"); action != "" {
+					clause += " ON DELETE " + action
+				}
+			}
+			fmt.Fprintf(&b, "\n  Note: %s\n", dbmlString(clause))
+
+			parentKeys := primaryKeys[parentName]
+			ref := fmt.Sprintf("Ref: %s > %s", dbmlColumns(name, parentKeys), dbmlColumns(parentName, parentKeys))
+			if str(t, "ON_DELETE_ACTION") == "CASCADE" {
+				ref += " [delete: cascade]"
+			}
+			refs = append(refs, ref+" // "+clause)
+		}
+		b.WriteString("}\n")
+	}
+
+	for _, group := range lo.PartitionBy(fks, func(row map[string]any) string { return tableKey(row) + "." + str(row, "CONSTRAINT_NAME") }) {
+		fk := group[0]
+		ref := fmt.Sprintf("Ref %s: %s > %s", dbmlName(str(fk, "CONSTRAINT_NAME")),
+			dbmlColumns(tableKey(fk), lo.Map(group, func(row map[string]any, _ int) string { return str(row, "COLUMN_NAME") })),
+			dbmlColumns(dbmlTableName(str(fk, "REF_SCHEMA"), str(fk, "REF_TABLE")), lo.Map(group, func(row map[string]any, _ int) string { return str(row, "REF_COLUMN") })))
+		if str(fk, "DELETE_RULE") == "CASCADE" {
+			ref += " [delete: cascade]"
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) > 0 {
+		b.WriteString("\n" + strings.Join(refs, "\n") + "\n")
+	}
+
+	out.DBML = b.String()
+	out.Refs = len(refs)
+	return out
+}
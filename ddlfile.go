@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// ddlDir is the directory of schema files which update_ddl can read in addition to the roots of the client.
+// It is set by --ddl-dir.
+var ddlDir string
+
+// maxDDLFileBytes is the maximum size of a schema file read by update_ddl.
+const maxDDLFileBytes = 10 << 20
+
+// ddlKeywords are the first keywords of statements accepted by UpdateDatabaseDdl.
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "GRANT", "REVOKE", "ANALYZE"}
+
+// readDDLFile reads and splits the statements of a schema file. The file is a gs:// object, or a path or a file:// URI
+// within the roots of the client or ddlDir. Relative paths are resolved against the directories in order.
+func readDDLFile(ctx context.Context, file string) ([]string, error) {
+	if strings.HasPrefix(file, "gs://") {
+		b, err := readGCSObject(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		return parseDDLFile(file, b)
+	}
+
+	dirs, err := ddlFileDirs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, &validationError{Field: "file", Message: "no directories are readable (the client provides no roots and the server is started without --ddl-dir)"}
+	}
+
+	if strings.HasPrefix(file, "file://") {
+		u, err := url.Parse(file)
+		if err != nil {
+			return nil, &validationError{Field: "file", Message: err.Error()}
+		}
+		file = u.Path
+	}
+	candidates := []string{file}
+	if !filepath.IsAbs(file) {
+		candidates = lo.Map(dirs, func(dir string, _ int) string { return filepath.Join(dir, file) })
+	}
+
+	for _, candidate := range candidates {
+		path, err := filepath.EvalSymlinks(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !lo.ContainsBy(dirs, func(dir string) bool { return isWithinDir(dir, path) }) {
+			return nil, &validationError{Field: "file", Message: fmt.Sprintf("%s is not in the roots of the client or the DDL directory", file)}
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		b, err := readDDLContent(f, file)
+		if err != nil {
+			return nil, err
+		}
+		return parseDDLFile(file, b)
+	}
+	return nil, &validationError{Field: "file", Message: fmt.Sprintf("%s is not found", file)}
+}
+
+func readGCSObject(ctx context.Context, uri string) ([]byte, error) {
+	bucket, object, err := parseGCSURI(uri)
+	if err == nil && object == "" {
+		err = fmt.Errorf("%q has no object name", uri)
+	}
+	if err != nil {
+		return nil, &validationError{Field: "file", Message: err.Error()}
+	}
+	storageClient, err := clients.storageClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := storageClient.Bucket(bucket).Object(object).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, &validationError{Field: "file", Message: fmt.Sprintf("%s is not found", uri)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDDLContent(r, uri)
+}
+
+// readDDLContent reads a schema file up to maxDDLFileBytes.
+func readDDLContent(r io.Reader, file string) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxDDLFileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxDDLFileBytes {
+		return nil, &validationError{Field: "file", Message: fmt.Sprintf("%s is larger than %d bytes", file, maxDDLFileBytes)}
+	}
+	return b, nil
+}
+
+// parseDDLFile splits the content of a schema file into statements and checks that all of them are DDL
+// before any statement is submitted.
+func parseDDLFile(file string, b []byte) ([]string, error) {
+	statements := splitStatements(string(b))
+	if len(statements) == 0 {
+		return nil, &validationError{Field: "file", Message: fmt.Sprintf("%s has no statements", file)}
+	}
+	for i, stmt := range statements {
+		words := topLevelWords(stmt)
+		if len(words) == 0 || !lo.ContainsBy(ddlKeywords, words[0].isKeyword) {
+			return nil, &validationError{Field: "file", Message: fmt.Sprintf("statement %d of %s is not a DDL statement: %s", i+1, file, stmt)}
+		}
+	}
+	return statements, nil
+}
+
+// ddlFileDirs returns the local directories of the roots of the client and ddlDir.
+func ddlFileDirs(ctx context.Context) ([]string, error) {
+	var dirs []string
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if s := server.ServerFromContext(ctx); s != nil && ok && session.GetClientCapabilities().Roots != nil {
+		result, err := s.RequestRoots(ctx, mcp.ListRootsRequest{})
+		switch {
+		case errors.Is(err, server.ErrRootsNotSupported):
+		case err != nil:
+			return nil, fmt.Errorf("failed to list roots of the client: %w", err)
+		default:
+			for _, root := range result.Roots {
+				u, err := url.Parse(root.URI)
+				if err != nil || u.Scheme != "file" {
+					continue
+				}
+				if dir, err := filepath.EvalSymlinks(u.Path); err == nil {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+	}
+	if ddlDir != "" {
+		dirs = append(dirs, ddlDir)
+	}
+	return dirs, nil
+}
+
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
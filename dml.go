@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplanviz/plantree"
+	"github.com/apstndb/spannerplanviz/queryplan"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func executeDMLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs      `mapstructure:",squash"`
+		Statement      string `mapstructure:"statement"`
+		DryRun         bool   `mapstructure:"dry_run"`
+		AllowFullTable bool   `mapstructure:"allow_full_table"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Access.checkStatement(ctx, target, "statement", req.Statement); err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		return dryRunDML(ctx, target, req.Statement)
+	}
+
+	if err := checkBoundedDML(req.Statement, req.AllowFullTable); err != nil {
+		return nil, err
+	}
+	if err := confirmStatements(ctx, target, []string{req.Statement}); err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// ReadWriteTransaction retries aborted transactions, and the function is re-run from scratch.
+	txnCtx, aborts := recordAborts(ctx)
+	var count int64
+	ts, err := client.ReadWriteTransaction(txnCtx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		count, err = txn.Update(ctx, spanner.NewStatement(req.Statement))
+		return err
+	})
+	diagnostics := aborts.diagnostics(ctx, client, err)
+	if err != nil {
+		return nil, withAbortDiagnostics(err, diagnostics)
+	}
+	auditCommitTimestamps(ctx, ts)
+	auditAffectedRows(ctx, count)
+
+	out := executeDMLOutput{RowCount: &count, CommitTimestamp: &ts, Transaction: diagnostics}
+	return mcp.NewToolResultStructured(out, fmt.Sprintf("%d rows affected at %s", count, ts.Format(time.RFC3339Nano))+renderTransactionDiagnostics(diagnostics)), nil
+}
+
+// dryRunDML plans the statement in a read-write transaction which is rolled back,
+// and estimates the affected rows by COUNT(*) of the WHERE clause in a read-only transaction.
+func dryRunDML(ctx context.Context, target *profile, statement string) (*mcp.CallToolResult, error) {
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	qp, err := analyzeDML(ctx, client, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := plantree.ProcessPlan(queryplan.New(qp.GetPlanNodes()))
+	if err != nil {
+		return nil, err
+	}
+	plan, err := printResult(processed)
+	if err != nil {
+		return nil, err
+	}
+
+	out := executeDMLOutput{DryRun: true, Operators: planOperators(processed)}
+	if countSQL, ok := dmlCountStatement(statement); ok {
+		count, err := queryInt64(ctx, client, spanner.NewStatement(countSQL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count matching rows by %s: %w", countSQL, err)
+		}
+		out.EstimatedRows = &count
+		out.Note = "The estimate is the number of rows matching the WHERE clause at the current time, which may change before the statement is executed"
+	} else {
+		out.Note = "Affected rows are only estimated for UPDATE and DELETE"
+	}
+
+	var b strings.Builder
+	b.WriteString(plan)
+	if out.EstimatedRows != nil {
+		fmt.Fprintf(&b, "Estimated rows affected: %d\n", *out.EstimatedRows)
+	}
+	fmt.Fprintf(&b, "Note: %s\n", out.Note)
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// analyzeDML returns the query plan of the DML statement, which requires a read-write transaction.
+// PLAN mode doesn't execute the statement, and the transaction is rolled back.
+func analyzeDML(ctx context.Context, client *spanner.Client, statement string) (*sppb.QueryPlan, error) {
+	var qp *sppb.QueryPlan
+	err := retry(ctx, func(ctx context.Context) error {
+		txn, err := spanner.NewReadWriteStmtBasedTransaction(ctx, client)
+		if err != nil {
+			return err
+		}
+		defer txn.Rollback(context.WithoutCancel(ctx))
+		qp, err = txn.AnalyzeQuery(ctx, spanner.NewStatement(statement))
+		return err
+	})
+	return qp, err
+}
+
+// analyzeStatement returns the query plan of the query or the DML statement.
+func analyzeStatement(ctx context.Context, target *profile, client *spanner.Client, statement string) (*sppb.QueryPlan, error) {
+	if isDML(statement) {
+		return analyzeDML(ctx, client, statement)
+	}
+	qp, _, err := analyzeQuery(ctx, target, statement)
+	return qp, err
+}
+
+// dmlCountStatement returns SELECT COUNT(*) of the rows matched by UPDATE or DELETE.
+func dmlCountStatement(statement string) (string, bool) {
+	t, ok := parseDMLTarget(statement)
+	if !ok {
+		return "", false
+	}
+	if t.where == "" {
+		return "SELECT COUNT(*) FROM " + t.table, true
+	}
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", t.table, t.where), true
+}
+
+// dmlTarget is the target table and the WHERE clause of UPDATE or DELETE.
+// They are copied as is after the statement hint, so statements with WITH clauses are not parsed.
+type dmlTarget struct {
+	table string
+
+	// where is the condition, or empty if the statement has no WHERE clause.
+	where string
+}
+
+func parseDMLTarget(statement string) (dmlTarget, bool) {
+	words := statementWords(statement)
+	if len(words) == 0 {
+		return dmlTarget{}, false
+	}
+
+	var from, where int
+	switch strings.ToUpper(words[0].text) {
+	case "DELETE":
+		from = words[0].end
+		if len(words) > 1 && strings.EqualFold(words[1].text, "FROM") {
+			from = words[1].end
+		}
+	case "UPDATE":
+		from = words[0].end
+	default:
+		return dmlTarget{}, false
+	}
+
+	fromEnd, end := 0, len(statement)
+	for i, w := range words[1:] {
+		switch strings.ToUpper(w.text) {
+		case "SET":
+			if fromEnd == 0 && strings.EqualFold(words[0].text, "UPDATE") {
+				fromEnd = w.start
+			}
+		case "WHERE":
+			if where == 0 {
+				where = w.end
+				if fromEnd == 0 {
+					fromEnd = w.start
+				}
+			}
+		case "THEN":
+			// THEN RETURN in GoogleSQL.
+			if i+2 < len(words) && strings.EqualFold(words[i+2].text, "RETURN") {
+				end = min(end, w.start)
+			}
+		case "RETURNING":
+			// RETURNING in PostgreSQL.
+			end = min(end, w.start)
+		}
+	}
+	if fromEnd == 0 {
+		fromEnd = end
+	}
+	if fromEnd <= from {
+		return dmlTarget{}, false
+	}
+
+	t := dmlTarget{table: strings.TrimSpace(statement[from:fromEnd])}
+	if where != 0 && where < end {
+		t.where = strings.TrimSpace(statement[where:end])
+	}
+	return t, true
+}
+
+// rejectUnboundedDML rejects UPDATE and DELETE of all rows unless allow_full_table is passed. It is set by --reject-unbounded-dml.
+var rejectUnboundedDML = true
+
+// trivialConditionRe matches WHERE clauses which are always true. GoogleSQL requires WHERE, so WHERE true is the common way to update all rows.
+var trivialConditionRe = regexp.MustCompile(`(?i)^\(*\s*(?:true|1\s*=\s*1)\s*\)*$`)
+
+// checkBoundedDML returns an error if the statement is UPDATE or DELETE without a WHERE clause or with an always true condition.
+// Statements other than INSERT which can't be parsed are also rejected, since they may update or delete all rows.
+func checkBoundedDML(statement string, allowFullTable bool) error {
+	if !rejectUnboundedDML || allowFullTable {
+		return nil
+	}
+	t, ok := parseDMLTarget(statement)
+	if !ok {
+		if words := statementWords(statement); len(words) > 0 && words[0].isKeyword("INSERT") {
+			return nil
+		}
+		return &validationError{Field: "statement", Message: "the statement can't be parsed to check whether it updates or deletes all rows, so pass allow_full_table: true if it is intended"}
+	}
+	if t.where != "" && !trivialConditionRe.MatchString(t.where) {
+		return nil
+	}
+	return &validationError{Field: "statement", Message: fmt.Sprintf("UPDATE or DELETE of all rows of %s is rejected, add a WHERE clause to limit the rows or pass allow_full_table: true (use truncate_table to delete all rows)", t.table)}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// databaseFeatures are the features of a database which specialized tools depend on.
+type databaseFeatures struct {
+	Dialect        databasepb.DatabaseDialect
+	PropertyGraphs bool
+	ChangeStreams  bool
+	ProtoBundles   bool
+}
+
+// graphs returns true if the database can run GQL, which requires property graphs and GoogleSQL.
+func (f databaseFeatures) graphs() bool {
+	return f.PropertyGraphs && f.Dialect != databasepb.DatabaseDialect_POSTGRESQL
+}
+
+// changeStreams returns true if the database can be read by change stream tools, which require change streams and GoogleSQL.
+func (f databaseFeatures) changeStreams() bool {
+	return f.ChangeStreams && f.Dialect != databasepb.DatabaseDialect_POSTGRESQL
+}
+
+// featureTools are the specialized tools and the features they require. PROTO values are decoded by execute_query,
+// so no tools are specialized for proto bundles.
+var featureTools = map[string]func(databaseFeatures) bool{
+	"execute_gql":                      databaseFeatures.graphs,
+	"list_property_graphs":             databaseFeatures.graphs,
+	"graph_schema_diagram":             databaseFeatures.graphs,
+	"tail_change_stream":               databaseFeatures.changeStreams,
+	"inspect_change_stream_partitions": databaseFeatures.changeStreams,
+}
+
+// dynamicTools are the specialized tools registered only while the session uses databases with their features.
+// It is set in main if --dynamic-tools is enabled.
+var dynamicTools []toolEntry
+
+var (
+	// detectedFeatures are the features of databases keyed by database paths.
+	detectedFeatures sync.Map
+
+	// sessionToolDatabases are the databases whose features decide the tools of sessions, keyed by session IDs.
+	sessionToolDatabases sync.Map
+
+	// sessionToolsMu serializes updates of tools so tools of an older database don't overwrite a newer one.
+	sessionToolsMu sync.Mutex
+)
+
+// featureStatementRe matches DDL statements creating schema objects which specialized tools depend on.
+var featureStatementRe = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(PROPERTY\s+GRAPH|CHANGE\s+STREAM|PROTO\s+BUNDLE)\s`)
+
+// detectFeatures returns the features of the database, which are cached until its DDL is updated by this server.
+func detectFeatures(ctx context.Context, target *profile) (databaseFeatures, error) {
+	if f, ok := detectedFeatures.Load(target.databasePath()); ok {
+		return f.(databaseFeatures), nil
+	}
+
+	admin, err := clients.adminClient(ctx)
+	if err != nil {
+		return databaseFeatures{}, err
+	}
+	var db *databasepb.Database
+	err = retry(ctx, func(ctx context.Context) error {
+		db, err = admin.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: target.databasePath()})
+		return err
+	})
+	if err != nil {
+		return databaseFeatures{}, err
+	}
+	statements, err := databaseStatements(ctx, target)
+	if err != nil {
+		return databaseFeatures{}, err
+	}
+
+	f := databaseFeatures{Dialect: db.GetDatabaseDialect()}
+	for _, stmt := range statements {
+		m := featureStatementRe.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		switch strings.Join(strings.Fields(strings.ToUpper(m[1])), " ") {
+		case "PROPERTY GRAPH":
+			f.PropertyGraphs = true
+		case "CHANGE STREAM":
+			f.ChangeStreams = true
+		case "PROTO BUNDLE":
+			f.ProtoBundles = true
+		}
+	}
+	detectedFeatures.Store(target.databasePath(), f)
+	return f, nil
+}
+
+// forgetDatabaseFeatures removes the cached features of the database after its DDL is updated,
+// so they are detected again by the next tool call of each session using it.
+func forgetDatabaseFeatures(target *profile) {
+	detectedFeatures.Delete(target.databasePath())
+	sessionToolDatabases.Range(func(id, path any) bool {
+		if path == target.databasePath() {
+			sessionToolDatabases.Delete(id)
+		}
+		return true
+	})
+}
+
+func forgetSessionToolDatabase(_ context.Context, session server.ClientSession) {
+	sessionToolDatabases.Delete(session.SessionID())
+}
+
+// updateSessionTools registers the specialized tools which the target database supports and unregisters the others
+// on first use of the database in the session. Features are detected in background so the tool call is not delayed,
+// and clients are notified of the changes by notifications/tools/list_changed. Sessions without session-specific tools,
+// i.e. stdio, update the tools of the server.
+func updateSessionTools(ctx context.Context, target *profile) {
+	s := server.ServerFromContext(ctx)
+	session := server.ClientSessionFromContext(ctx)
+	if len(dynamicTools) == 0 || s == nil || session == nil {
+		return
+	}
+	id, path := session.SessionID(), target.databasePath()
+	if prev, loaded := sessionToolDatabases.Swap(id, path); loaded && prev == path {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+
+		f, err := detectFeatures(ctx, target)
+		if err != nil {
+			slog.Warn("failed to detect features of the database", "database", path, "error", err)
+			// Detect again on the next tool call.
+			sessionToolDatabases.CompareAndDelete(id, path)
+			return
+		}
+
+		sessionToolsMu.Lock()
+		defer sessionToolsMu.Unlock()
+		if current, _ := sessionToolDatabases.Load(id); current != path {
+			return
+		}
+
+		registered := func(name string) bool { return s.GetTool(name) != nil }
+		sessionTools, ok := session.(server.SessionWithTools)
+		if ok {
+			registered = func(name string) bool { _, ok := sessionTools.GetSessionTools()[name]; return ok }
+		}
+		var add []server.ServerTool
+		var remove []string
+		for _, t := range dynamicTools {
+			switch supported := featureTools[t.tool.Name](f); {
+			case supported && !registered(t.tool.Name):
+				add = append(add, server.ServerTool{Tool: t.tool, Handler: t.handler})
+			case !supported && registered(t.tool.Name):
+				remove = append(remove, t.tool.Name)
+			}
+		}
+		if len(add) == 0 && len(remove) == 0 {
+			return
+		}
+		slog.Info("updating tools by features of the database", "database", path, "session", id,
+			"dialect", f.Dialect.String(), "property_graphs", f.PropertyGraphs, "change_streams", f.ChangeStreams, "proto_bundles", f.ProtoBundles,
+			"added", lo.Map(add, func(t server.ServerTool, _ int) string { return t.Tool.Name }), "removed", remove)
+
+		switch {
+		case ok && len(add) > 0:
+			err = s.AddSessionTools(id, add...)
+			if err == nil && len(remove) > 0 {
+				err = s.DeleteSessionTools(id, remove...)
+			}
+		case ok:
+			err = s.DeleteSessionTools(id, remove...)
+		case len(add) > 0:
+			s.AddTools(add...)
+			s.DeleteTools(remove...)
+		default:
+			s.DeleteTools(remove...)
+		}
+		if err != nil {
+			slog.Warn("failed to update tools of the session", "session", id, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"google.golang.org/grpc/codes"
+)
+
+// missingPermissionRe extracts the IAM permission from PERMISSION_DENIED errors.
+var missingPermissionRe = regexp.MustCompile(`spanner\.[A-Za-z]+\.[A-Za-z]+`)
+
+// permissionRoles are predefined roles which include the permissions.
+var permissionRoles = map[string]string{
+	"spanner.databases.select":                              "roles/spanner.databaseReader",
+	"spanner.databases.read":                                "roles/spanner.databaseReader",
+	"spanner.databases.getDdl":                              "roles/spanner.databaseReader",
+	"spanner.databases.beginReadOnlyTransaction":            "roles/spanner.databaseReader",
+	"spanner.databases.write":                               "roles/spanner.databaseUser",
+	"spanner.databases.beginOrRollbackReadWriteTransaction": "roles/spanner.databaseUser",
+	"spanner.databases.updateDdl":                           "roles/spanner.databaseAdmin",
+	"spanner.databases.list":                                "roles/spanner.viewer",
+	"spanner.databases.get":                                 "roles/spanner.viewer",
+	"spanner.sessions.create":                               "roles/spanner.databaseReader",
+}
+
+// friendlyError adds an actionable hint to common Spanner errors. The original error is wrapped.
+func friendlyError(err error) error {
+	msg := err.Error()
+	var hint string
+	switch spanner.ErrCode(err) {
+	case codes.NotFound:
+		switch {
+		case strings.Contains(msg, "Database not found"):
+			hint = "the database does not exist; call list_databases to see the databases in the instance"
+		case strings.Contains(msg, "Instance not found"):
+			hint = "the instance does not exist; check the project and instance IDs"
+		case strings.Contains(msg, "Table not found"):
+			hint = "the table does not exist; call get_ddl to see the schema"
+		}
+	case codes.PermissionDenied:
+		if permission := missingPermissionRe.FindString(msg); permission != "" {
+			hint = fmt.Sprintf("the principal is missing IAM permission %s", permission)
+			if role, ok := permissionRoles[permission]; ok {
+				hint += fmt.Sprintf("; grant a role which includes it, e.g. %s", role)
+			}
+		} else {
+			hint = "the principal is not allowed; call server_info to see the principal"
+		}
+	case codes.Unauthenticated:
+		hint = "credentials are invalid or expired; run gcloud auth application-default login or check --credentials-file"
+	case codes.DeadlineExceeded:
+		hint = "the call took too long; narrow the query or retry later"
+	case codes.ResourceExhausted:
+		hint = "the instance is overloaded or a quota is exceeded even after retries; retry later or reduce concurrency"
+	}
+
+	if hint == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", hint, err)
+}
+
+// errorMiddleware translates errors of tool calls into actionable messages.
+func errorMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			err = friendlyError(err)
+		}
+		return result, err
+	}
+}
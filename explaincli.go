@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cliArgs reads arguments of a tool call without decoding them into the arguments of the tool.
+type cliArgs map[string]any
+
+func (a cliArgs) string(key string) string {
+	s, _ := a[key].(string)
+	return s
+}
+
+func (a cliArgs) strings(key string) []string {
+	values, _ := a[key].([]any)
+	return lo.Map(values, func(v any, _ int) string { return fmt.Sprint(v) })
+}
+
+func (a cliArgs) bool(key string) bool {
+	b, _ := a[key].(bool)
+	return b
+}
+
+// cliCommands returns the gcloud and spanner-cli commands equivalent to the tool call, and a note on differences.
+// The target is resolved like the tool call, but it is not checked because the commands are not run.
+func cliCommands(ctx context.Context, tool string, args cliArgs) ([]cliCommand, string, error) {
+	t, err := databaseArgs{
+		Profile:  args.string("profile"),
+		Project:  args.string("project"),
+		Instance: args.string("instance"),
+		Database: args.string("database"),
+	}.resolve(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	role := lo.CoalesceOrEmpty(args.string("database_role"), t.DatabaseRole)
+
+	gcloud := func(command string, flags ...string) cliCommand {
+		flags = append(flags, "--instance="+t.Instance, "--project="+t.Project)
+		return cliCommand{Tool: "gcloud", Command: "gcloud spanner " + command + " " + strings.Join(flags, " ")}
+	}
+	executeSQL := func(sql string, flags ...string) cliCommand {
+		flags = append([]string{t.Database, "--sql=" + shellQuote(sql)}, flags...)
+		if role != "" {
+			flags = append(flags, "--database-role="+role)
+		}
+		return gcloud("databases execute-sql", flags...)
+	}
+	updateDDL := func(statements ...string) cliCommand {
+		return gcloud("databases ddl update", t.Database, "--ddl="+shellQuote(strings.Join(statements, ";")))
+	}
+	// input is --execute or --file of spanner-cli.
+	spannerCLIInput := func(input string) cliCommand {
+		flags := []string{"--project=" + t.Project, "--instance=" + t.Instance, "--database=" + t.Database}
+		if role != "" {
+			flags = append(flags, "--role="+role)
+		}
+		if cfg.Client.Endpoint != "" {
+			flags = append(flags, "--endpoint="+cfg.Client.Endpoint)
+		}
+		flags = append(flags, input)
+		return cliCommand{Tool: "spanner-cli", Command: "spanner-cli " + strings.Join(flags, " ")}
+	}
+	spannerCLI := func(statements ...string) cliCommand {
+		return spannerCLIInput("--execute=" + shellQuote(strings.Join(statements, ";\n")))
+	}
+
+	switch tool {
+	case "execute_query", "execute_gql", "execute_partitioned_query":
+		query := args.string("query")
+		var note string
+		if tool == "execute_partitioned_query" {
+			note = "gcloud and spanner-cli don't partition the query, so it runs as a single query"
+		}
+		if args.string("query_mode") == "PROFILE" {
+			return []cliCommand{executeSQL(query, "--query-mode=PROFILE"), spannerCLI("EXPLAIN ANALYZE " + query)}, note, nil
+		}
+		return []cliCommand{executeSQL(query), spannerCLI(query)}, note, nil
+	case "plan":
+		query := args.string("query")
+		return []cliCommand{executeSQL(query, "--query-mode=PLAN"), spannerCLI("EXPLAIN " + query)}, "", nil
+	case "execute_dml":
+		statement := args.string("statement")
+		if args.bool("dry_run") {
+			return []cliCommand{executeSQL(statement, "--query-mode=PLAN"), spannerCLI("EXPLAIN " + statement)},
+				"The dry run of execute_dml also counts rows matching the WHERE clause, which is not included", nil
+		}
+		return []cliCommand{executeSQL(statement), spannerCLI(statement)}, "", nil
+	case "truncate_table":
+		table, err := quoteTableName(args.string("table"))
+		if err != nil {
+			return nil, "", err
+		}
+		return []cliCommand{executeSQL(fmt.Sprintf("DELETE FROM %s WHERE true", table), "--enable-partitioned-dml")},
+			"spanner-cli doesn't support Partitioned DML", nil
+	case "update_ddl":
+		if file := args.string("file"); strings.HasPrefix(file, "gs://") {
+			content := fmt.Sprintf(`"$(gcloud storage cat %s)"`, shellQuote(file))
+			return []cliCommand{gcloud("databases ddl update", t.Database, "--ddl="+content), spannerCLIInput("--execute=" + content)}, "", nil
+		} else if file != "" {
+			file = shellQuote(strings.TrimPrefix(file, "file://"))
+			return []cliCommand{gcloud("databases ddl update", t.Database, "--ddl-file="+file), spannerCLIInput("--file=" + file)},
+				"Relative paths of the file are resolved against the roots of the client, and the commands resolve them against the working directory", nil
+		}
+		statements := args.strings("statements")
+		return []cliCommand{updateDDL(statements...), spannerCLI(statements...)}, "", nil
+	case "analyze_database":
+		return []cliCommand{updateDDL("ANALYZE"), spannerCLI("ANALYZE")}, "", nil
+	case "set_statistics_package":
+		value := "NULL"
+		if p := args.string("package"); p != "" {
+			value = fmt.Sprintf("'%s'", p)
+		}
+		statements := []string{fmt.Sprintf("ALTER DATABASE `%s` SET OPTIONS (optimizer_statistics_package = %s)", t.Database, value)}
+		if allowGC, ok := args["allow_gc"].(bool); ok {
+			statements = append(statements, fmt.Sprintf("ALTER STATISTICS %s SET OPTIONS (allow_gc = %t)", args.string("package"), allowGC))
+		}
+		return []cliCommand{updateDDL(statements...), spannerCLI(statements...)}, "", nil
+	case "get_ddl":
+		return []cliCommand{gcloud("databases ddl describe", t.Database)}, "", nil
+	case "ping":
+		return []cliCommand{gcloud("databases describe", t.Database), spannerCLI("SELECT 1")}, "", nil
+	case "list_databases":
+		return []cliCommand{gcloud("databases list")}, "", nil
+	case "list_backups":
+		var flags []string
+		if t.Database != "" {
+			flags = append(flags, "--database="+t.Database)
+		}
+		return []cliCommand{gcloud("backups list", flags...)}, "", nil
+	case "create_backup":
+		flags := []string{args.string("backup_id"), "--database=" + t.Database}
+		if expireTime := args.string("expire_time"); expireTime != "" {
+			flags = append(flags, "--expiration-date="+expireTime)
+		} else {
+			flags = append(flags, fmt.Sprintf("--retention-period=%dd", int(defaultBackupRetention.Hours()/24)))
+		}
+		if versionTime := args.string("version_time"); versionTime != "" {
+			flags = append(flags, "--version-time="+versionTime)
+		}
+		if encryptionType := args.string("encryption_type"); encryptionType != "" {
+			flags = append(flags, "--encryption-type="+encryptionType)
+		}
+		if keys := args.strings("kms_key_names"); len(keys) > 0 {
+			flags = append(flags, "--kms-keys="+strings.Join(keys, ","))
+		}
+		return []cliCommand{gcloud("backups create", flags...)}, "", nil
+	default:
+		return []cliCommand{}, fmt.Sprintf("%s has no equivalent gcloud or spanner-cli command", tool), nil
+	}
+}
+
+func explainAsCLIHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		Tool      string         `mapstructure:"tool"`
+		Arguments map[string]any `mapstructure:"arguments"`
+		Index     int            `mapstructure:"index"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Index > 0:
+		e, ok := lo.Find(audit.history(sessionID(ctx)), func(e *auditEntry) bool { return e.Index == req.Index })
+		if !ok {
+			return nil, &validationError{Field: "index", Message: fmt.Sprintf("#%d is not in the history of this session", req.Index)}
+		}
+		req.Tool, req.Arguments = e.Tool, e.Arguments
+	case req.Tool == "":
+		return nil, &validationError{Field: "tool", Message: "tool or index is required"}
+	}
+
+	commands, note, err := cliCommands(ctx, req.Tool, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	out := explainAsCLIOutput{Tool: req.Tool, Commands: commands, Note: note}
+	var b strings.Builder
+	for _, c := range out.Commands {
+		fmt.Fprintf(&b, "# %s\n%s\n\n", c.Tool, c.Command)
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "Note: %s\n", out.Note)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
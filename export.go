@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"cloud.google.com/go/storage"
+	"github.com/linkedin/goavro/v2"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+// exportParallelism is the number of partitions exported concurrently.
+const exportParallelism = 4
+
+var exportFormats = []string{"csv", "jsonl", "avro"}
+
+// gcsURIRe matches gs://{bucket}/{prefix}.
+var gcsURIRe = regexp.MustCompile(`^gs://([^/]+)/?(.*)$`)
+
+func parseGCSURI(uri string) (bucket, object string, err error) {
+	m := gcsURIRe.FindStringSubmatch(uri)
+	if m == nil {
+		return "", "", fmt.Errorf("%q is not a gs://{bucket}/{object} URI", uri)
+	}
+	return m[1], m[2], nil
+}
+
+func exportToGCSHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs   `mapstructure:",squash"`
+		Query       string `mapstructure:"query"`
+		Destination string `mapstructure:"destination"`
+		Format      string `mapstructure:"format"`
+		DataBoost   bool   `mapstructure:"data_boost"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+
+	bucket, prefix, err := parseGCSURI(req.Destination)
+	if err != nil {
+		return nil, &validationError{Field: "destination", Message: err.Error()}
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkStatement(ctx, target, "query", req.Query); err != nil {
+		return nil, err
+	}
+	masks, err := cfg.Masking.queryMasks(ctx, target, "query", req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	storageClient, err := clients.storageClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txn, err := client.BatchReadOnlyTransaction(ctx, spanner.StrongRead())
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	var partitions []*spanner.Partition
+	err = retry(ctx, func(ctx context.Context) error {
+		partitions, err = txn.PartitionQueryWithOptions(ctx, spanner.NewStatement(req.Query), spanner.PartitionOptions{},
+			spanner.QueryOptions{DataBoostEnabled: req.DataBoost})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := exportToGCSOutput{Objects: make([]exportedObject, len(partitions))}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(exportParallelism)
+	for i, p := range partitions {
+		name := fmt.Sprintf("%spart-%05d.%s", prefix, i, req.Format)
+		out.Objects[i].URI = fmt.Sprintf("gs://%s/%s", bucket, name)
+		g.Go(func() error {
+			// Existing objects are not overwritten.
+			w := storageClient.Bucket(bucket).Object(name).If(storage.Conditions{DoesNotExist: true}).NewWriter(gctx)
+			rows, err := exportPartition(gctx, txn, p, req.Format, masks, w)
+			if err != nil {
+				// The object is not created if the context is cancelled before Close.
+				return fmt.Errorf("failed to export %s: %w", out.Objects[i].URI, err)
+			}
+			if err := w.Close(); err != nil {
+				return fmt.Errorf("failed to write %s: %w", out.Objects[i].URI, err)
+			}
+			out.Objects[i].Rows = rows
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	out.Rows = lo.SumBy(out.Objects, func(o exportedObject) int64 { return o.Rows })
+	text := fmt.Sprintf("Exported %d rows to %d objects:\n%s\n", out.Rows, len(out.Objects),
+		strings.Join(lo.Map(out.Objects, func(o exportedObject, _ int) string { return o.URI }), "\n"))
+	return mcp.NewToolResultStructured(out, text), nil
+}
+
+// exportPartition writes rows of the partition to w in the format and returns the number of rows.
+// Columns are masked by masks returned by queryMasks, and masked columns are written as STRING.
+func exportPartition(ctx context.Context, txn *spanner.BatchReadOnlyTransaction, p *spanner.Partition, format string, masks map[string]string, w io.Writer) (int64, error) {
+	it := txn.Execute(ctx, p)
+	defer it.Stop()
+
+	var (
+		enc     rowEncoder
+		rowType *sppb.StructType
+		rows    int64
+	)
+	for {
+		row, err := it.Next()
+		if err != nil && err != iterator.Done {
+			return 0, err
+		}
+
+		// The row type is available after the first Next.
+		if enc == nil {
+			rowType = it.Metadata.GetRowType()
+			if enc, err = newRowEncoder(format, cfg.Masking.maskQueryResult(masks, rowType, nil), w); err != nil {
+				return 0, err
+			}
+		}
+		if row == nil {
+			break
+		}
+
+		values, err := decodeRowValues(row)
+		if err != nil {
+			return 0, err
+		}
+		cfg.Masking.maskQueryResult(masks, rowType, [][]any{values})
+		if err := enc.encode(values); err != nil {
+			return 0, err
+		}
+		rows++
+	}
+	return rows, enc.flush()
+}
+
+// rowEncoder encodes rows decoded by decodeRowValues.
+type rowEncoder interface {
+	encode(values []any) error
+	flush() error
+}
+
+func newRowEncoder(format string, rowType *sppb.StructType, w io.Writer) (rowEncoder, error) {
+	switch format {
+	case "csv":
+		return newCSVEncoder(rowType, w)
+	case "jsonl":
+		return &jsonlEncoder{rowType: rowType, enc: json.NewEncoder(w)}, nil
+	case "avro":
+		return newAvroEncoder(rowType, w)
+	default:
+		return nil, &validationError{Field: "format", Message: fmt.Sprintf("must be one of %v", exportFormats)}
+	}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(rowType *sppb.StructType, w io.Writer) (*csvEncoder, error) {
+	cw := csv.NewWriter(w)
+	header := lo.Map(rowType.GetFields(), func(f *sppb.StructType_Field, _ int) string { return f.GetName() })
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvEncoder{w: cw}, nil
+}
+
+func (e *csvEncoder) encode(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			// NULL is an empty field.
+		case string:
+			record[i] = v
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			record[i] = string(b)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder struct {
+	rowType *sppb.StructType
+	enc     *json.Encoder
+}
+
+func (e *jsonlEncoder) encode(values []any) error {
+	m := make(map[string]any, len(values))
+	for i, v := range values {
+		m[e.rowType.GetFields()[i].GetName()] = v
+	}
+	return e.enc.Encode(m)
+}
+
+func (e *jsonlEncoder) flush() error {
+	return nil
+}
+
+// avroEncoder writes an Avro object container file. Every field is nullable.
+// NUMERIC, DATE, TIMESTAMP, JSON, INTERVAL and UUID are strings, PROTO is bytes and ENUM is long.
+// STRUCT is not supported because it can't be stored in tables.
+type avroEncoder struct {
+	rowType *sppb.StructType
+	w       *goavro.OCFWriter
+
+	// pending records are appended to w as a block.
+	pending []any
+}
+
+// avroBlockRows is the number of rows in each block of Avro files.
+const avroBlockRows = 1000
+
+func newAvroEncoder(rowType *sppb.StructType, w io.Writer) (*avroEncoder, error) {
+	fields := make([]map[string]any, len(rowType.GetFields()))
+	for i, f := range rowType.GetFields() {
+		typ, err := avroType(f.GetType())
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", f.GetName(), err)
+		}
+		fields[i] = map[string]any{"name": avroFieldName(f.GetName(), i), "type": []any{"null", typ}, "default": nil}
+	}
+	schema, err := json.Marshal(map[string]any{"type": "record", "name": "Row", "fields": fields})
+	if err != nil {
+		return nil, err
+	}
+	ocf, err := goavro.NewOCFWriter(goavro.OCFConfig{W: w, Schema: string(schema)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Avro schema: %w", err)
+	}
+	return &avroEncoder{rowType: rowType, w: ocf}, nil
+}
+
+func (e *avroEncoder) encode(values []any) error {
+	record := make(map[string]any, len(values))
+	for i, f := range e.rowType.GetFields() {
+		v, err := avroValue(f.GetType(), values[i])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", f.GetName(), err)
+		}
+		record[avroFieldName(f.GetName(), i)] = v
+	}
+
+	e.pending = append(e.pending, record)
+	if len(e.pending) < avroBlockRows {
+		return nil
+	}
+	return e.flush()
+}
+
+func (e *avroEncoder) flush() error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	err := e.w.Append(e.pending)
+	e.pending = e.pending[:0]
+	return err
+}
+
+// avroFieldName returns the field name of the column. Columns without names are named by their positions.
+func avroFieldName(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("_%d", i)
+	}
+	return name
+}
+
+func avroType(t *sppb.Type) (any, error) {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return "boolean", nil
+	case sppb.TypeCode_INT64, sppb.TypeCode_ENUM:
+		return "long", nil
+	case sppb.TypeCode_FLOAT32:
+		return "float", nil
+	case sppb.TypeCode_FLOAT64:
+		return "double", nil
+	case sppb.TypeCode_BYTES, sppb.TypeCode_PROTO:
+		return "bytes", nil
+	case sppb.TypeCode_ARRAY:
+		items, err := avroType(t.GetArrayElementType())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": []any{"null", items}}, nil
+	case sppb.TypeCode_STRUCT:
+		return nil, fmt.Errorf("STRUCT is not supported in avro, use jsonl instead")
+	default:
+		return "string", nil
+	}
+}
+
+// avroValue converts a value decoded by decodeValue into a nullable Avro value of goavro.
+func avroValue(t *sppb.Type, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var native any
+	switch t.GetCode() {
+	case sppb.TypeCode_ARRAY:
+		elems, _ := v.([]any)
+		items := make([]any, len(elems))
+		for i, elem := range elems {
+			item, err := avroValue(t.GetArrayElementType(), elem)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return goavro.Union("array", items), nil
+	case sppb.TypeCode_ENUM:
+		n, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		native = n
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64:
+		f, ok := v.(float64)
+		if s, isString := v.(string); isString {
+			// NaN and Infinity are strings.
+			parsed, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, err
+			}
+			f, ok = parsed, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("unexpected value %v", v)
+		}
+		if t.GetCode() == sppb.TypeCode_FLOAT32 {
+			native = float32(f)
+		} else {
+			native = f
+		}
+	case sppb.TypeCode_BYTES, sppb.TypeCode_PROTO:
+		b, err := base64.StdEncoding.DecodeString(fmt.Sprint(v))
+		if err != nil {
+			return nil, err
+		}
+		native = b
+	case sppb.TypeCode_JSON:
+		native = fmt.Sprintf("%s", v)
+	default:
+		native = v
+	}
+
+	typ, err := avroType(t)
+	if err != nil {
+		return nil, err
+	}
+	return goavro.Union(typ.(string), native), nil
+}
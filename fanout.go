@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+)
+
+// Parallelism of execute_fanout_query, which is the number of databases queried concurrently.
+const (
+	defaultFanoutParallelism = 4
+	maxFanoutParallelism     = 32
+)
+
+// sourceDatabaseColumn is the column of merged rows of execute_fanout_query containing the database ID of the row.
+const sourceDatabaseColumn = "source_database"
+
+func executeFanoutQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs       `mapstructure:",squash"`
+		renderOptions   `mapstructure:",squash"`
+		Query           string   `mapstructure:"query"`
+		Databases       []string `mapstructure:"databases"`
+		DatabasePattern string   `mapstructure:"database_pattern"`
+		MaxRows         int      `mapstructure:"max_rows"`
+		Parallelism     int      `mapstructure:"parallelism"`
+		ProtoFormat     string   `mapstructure:"proto_format"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Database != "" {
+		return nil, &validationError{Field: "database", Message: "use databases to specify the databases of execute_fanout_query"}
+	}
+	if req.MaxRows <= 0 {
+		req.MaxRows = defaultMaxRows
+	}
+	if req.Parallelism == 0 {
+		req.Parallelism = defaultFanoutParallelism
+	}
+	if req.Parallelism < 1 || req.Parallelism > maxFanoutParallelism {
+		return nil, &validationError{Field: "parallelism", Message: fmt.Sprintf("must be between 1 and %d", maxFanoutParallelism)}
+	}
+	var pattern *regexp.Regexp
+	if req.DatabasePattern != "" {
+		if pattern, err = regexp.Compile(req.DatabasePattern); err != nil {
+			return nil, &validationError{Field: "database_pattern", Message: err.Error()}
+		}
+	}
+
+	format := protoFormat(req.ProtoFormat)
+	r, err := newRenderer(cfg.Output.Render.override(req.renderOptions), format)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.instanceTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.DatabaseRole != "" {
+		target.DatabaseRole = req.DatabaseRole
+	}
+	if req.RouteToLeader != nil {
+		target.routeToLeader = strconv.FormatBool(*req.RouteToLeader)
+	}
+
+	databases := req.Databases
+	if len(databases) == 0 {
+		listed, err := listInstanceDatabases(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		for _, db := range listed {
+			if db.GetState() == databasepb.Database_READY {
+				databases = append(databases, path.Base(db.GetName()))
+			}
+		}
+	}
+	if pattern != nil {
+		databases = lo.Filter(databases, func(db string, _ int) bool { return pattern.MatchString(db) })
+	}
+	databases = lo.Uniq(databases)
+	if len(databases) == 0 {
+		return nil, &validationError{Field: "databases", Message: "no databases to query"}
+	}
+
+	// Errors of databases are isolated in their results so the other databases are merged.
+	results := make([]fanoutDatabaseResult, len(databases))
+	outputs := make([]executeQueryOutput, len(databases))
+	rowTypes := make([]*sppb.StructType, len(databases))
+	var (
+		mu        sync.Mutex
+		completed int
+	)
+	var g errgroup.Group
+	g.SetLimit(req.Parallelism)
+	for i, db := range databases {
+		g.Go(func() error {
+			shard := *target
+			shard.Database = db
+			results[i] = fanoutDatabaseResult{Database: db}
+
+			start := time.Now()
+			err := validateTarget(&shard)
+			if err == nil {
+				err = checkTier(ctx, &shard)
+			}
+			if err == nil {
+				outputs[i], rowTypes[i], err = runQuery(ctx, &shard, spanner.NewStatement(req.Query), req.MaxRows, format, false)
+			}
+			results[i].DurationMillis = time.Since(start).Milliseconds()
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+
+			mu.Lock()
+			completed++
+			n := completed
+			mu.Unlock()
+			sendProgressNotification(ctx, request, n, len(databases))
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	out, rowType := mergeFanoutResults(results, outputs, rowTypes, req.MaxRows)
+	if rowType == nil {
+		var b strings.Builder
+		for _, res := range out.Databases {
+			fmt.Fprintf(&b, "%s: %s\n", res.Database, res.Error)
+		}
+		return nil, fmt.Errorf("the query failed in all databases:\n%s", b.String())
+	}
+
+	text, err := renderQueryResult(executeQueryOutput{Columns: out.Columns, Rows: out.Rows, HasMoreRows: out.HasMoreRows}, rowType, r)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	for _, res := range out.Databases {
+		if res.Error != "" {
+			fmt.Fprintf(&b, "%s: error: %s\n", res.Database, res.Error)
+		} else {
+			fmt.Fprintf(&b, "%s: %d rows (%dms)\n", res.Database, res.Rows, res.DurationMillis)
+		}
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// mergeFanoutResults merges rows of the databases in their order with the source_database column, and returns the row type of
+// the merged rows. The schema of the result is the columns of the first successful database, and databases returning
+// different column names or types are errors instead of being merged. The row type is nil if all databases failed.
+func mergeFanoutResults(results []fanoutDatabaseResult, outputs []executeQueryOutput, rowTypes []*sppb.StructType, maxRows int) (executeFanoutQueryOutput, *sppb.StructType) {
+	out := executeFanoutQueryOutput{Rows: [][]any{}, Databases: results}
+
+	i := slices.IndexFunc(results, func(res fanoutDatabaseResult) bool { return res.Error == "" })
+	if i < 0 {
+		return out, nil
+	}
+	schema := outputs[i].Columns
+	rowType := &sppb.StructType{Fields: append([]*sppb.StructType_Field{{
+		Name: sourceDatabaseColumn,
+		Type: &sppb.Type{Code: sppb.TypeCode_STRING},
+	}}, rowTypes[i].GetFields()...)}
+	out.Columns = queryColumns(rowType)
+
+	for i := range results {
+		res := &results[i]
+		if res.Error != "" {
+			continue
+		}
+		if !slices.Equal(outputs[i].Columns, schema) {
+			res.Error = fmt.Sprintf("incompatible schema: columns %s differ from %s", formatColumns(outputs[i].Columns), formatColumns(schema))
+			continue
+		}
+
+		res.HasMoreRows = outputs[i].HasMoreRows
+		for _, row := range outputs[i].Rows {
+			if len(out.Rows) == maxRows {
+				res.HasMoreRows = true
+				break
+			}
+			out.Rows = append(out.Rows, append([]any{res.Database}, row...))
+			res.Rows++
+		}
+		out.HasMoreRows = out.HasMoreRows || res.HasMoreRows
+	}
+	out.FailedDatabases = lo.CountBy(results, func(res fanoutDatabaseResult) bool { return res.Error != "" })
+	return out, rowType
+}
+
+func formatColumns(columns []queryColumn) string {
+	return "(" + strings.Join(lo.Map(columns, func(c queryColumn, _ int) string { return c.Name + " " + c.Type }), ", ") + ")"
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+const maxGenerateRows = 100000
+
+// referenceSampleRows is the number of rows sampled from referenced tables for values of foreign keys.
+const referenceSampleRows = 1000
+
+// generatePreviewRows is the number of rows returned by generate_data in dry run.
+const generatePreviewRows = 5
+
+func generateDataHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs  `mapstructure:",squash"`
+		Table      string            `mapstructure:"table"`
+		Rows       int               `mapstructure:"rows"`
+		Generators map[string]string `mapstructure:"generators"`
+		Seed       int64             `mapstructure:"seed"`
+		BatchSize  int               `mapstructure:"batch_size"`
+		DryRun     bool              `mapstructure:"dry_run"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := quoteTableName(req.Table); err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+	if req.Rows <= 0 || req.Rows > maxGenerateRows {
+		return nil, &validationError{Field: "rows", Message: fmt.Sprintf("must be between 1 and %d", maxGenerateRows)}
+	}
+	if req.BatchSize <= 0 {
+		req.BatchSize = defaultImportBatchSize
+	}
+	if req.BatchSize > maxImportBatchSize {
+		return nil, &validationError{Field: "batch_size", Message: fmt.Sprintf("must not be greater than %d", maxImportBatchSize)}
+	}
+	if req.Seed == 0 {
+		req.Seed = rand.Int64()
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	schema, err := generationSchema(ctx, client, req.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	r := rand.New(rand.NewPCG(uint64(req.Seed), 0))
+	g, err := newRowGenerator(ctx, client, req.Table, schema, req.Generators, r)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]*sppb.Type, len(schema.columns))
+	for _, c := range schema.columns {
+		columns[c.name] = c.typ
+	}
+
+	out := generateDataOutput{Table: req.Table, Seed: req.Seed, DryRun: req.DryRun}
+	batcher := &mutationBatcher{client: client, size: req.BatchSize, dryRun: req.DryRun}
+	for i := range req.Rows {
+		row, err := g.next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate row %d: %w", i+1, err)
+		}
+		if req.DryRun && len(out.Preview) < generatePreviewRows {
+			out.Preview = append(out.Preview, g.preview(row))
+		}
+
+		m, err := importMutation(spanner.Insert, req.Table, columns, row)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row %d: %w", i+1, err)
+		}
+		if err := batcher.add(ctx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := batcher.flush(ctx); err != nil {
+		return nil, err
+	}
+	out.Rows, out.Batches = batcher.rows, batcher.batches
+
+	if req.DryRun {
+		return mcp.NewToolResultStructured(out, fmt.Sprintf("Dry run: %d rows would be inserted into %s in %d batches (seed %d)", out.Rows, req.Table, out.Batches, out.Seed)), nil
+	}
+	auditAffectedRows(ctx, out.Rows)
+	out.Transaction = batcher.diagnostics(ctx)
+	text := fmt.Sprintf("Inserted %d rows into %s in %d batches (seed %d)", out.Rows, req.Table, out.Batches, out.Seed)
+	return mcp.NewToolResultStructured(out, text+renderTransactionDiagnostics(out.Transaction)), nil
+}
+
+// generationTable is the schema of a table used to generate rows.
+type generationTable struct {
+	columns []generationColumn
+
+	// references are foreign keys and the interleaved parent table, whose rows must exist.
+	references []tableReference
+}
+
+type generationColumn struct {
+	name string
+
+	// typ is nil if the type is not supported by generators.
+	typ *sppb.Type
+
+	// length is the maximum length of STRING and BYTES, 0 means MAX.
+	length     int
+	nullable   bool
+	hasDefault bool
+	key        bool
+}
+
+// tableReference is a reference from columns to the key columns of the table.
+type tableReference struct {
+	table      string
+	columns    []string
+	refColumns []string
+}
+
+// generationSchema reads the columns, the primary key, foreign keys and the interleaved parent of the table from INFORMATION_SCHEMA.
+func generationSchema(ctx context.Context, client *spanner.Client, table string) (*generationTable, error) {
+	schema, name, ok := strings.Cut(strings.ReplaceAll(table, "`", ""), ".")
+	if !ok {
+		schema, name = "", schema
+	}
+	params := map[string]any{"schema": schema, "table": name}
+
+	rows, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT COLUMN_NAME, SPANNER_TYPE, IS_NULLABLE = 'YES' AS NULLABLE, COLUMN_DEFAULT IS NOT NULL AS HAS_DEFAULT
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND IS_GENERATED = 'NEVER'
+ORDER BY ORDINAL_POSITION`,
+		Params: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, &validationError{Field: "table", Message: fmt.Sprintf("table %s is not found", table)}
+	}
+
+	keys, err := primaryKeyColumns(ctx, client, schema, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var t generationTable
+	for _, row := range rows {
+		spannerType, _ := row["SPANNER_TYPE"].(string)
+		c := generationColumn{
+			length:     typeLength(spannerType),
+			nullable:   row["NULLABLE"] == true,
+			hasDefault: row["HAS_DEFAULT"] == true,
+		}
+		c.name, _ = row["COLUMN_NAME"].(string)
+		c.key = slices.Contains(keys, c.name)
+		if typ, err := parseSpannerType(spannerType); err == nil {
+			c.typ = typ
+		}
+		t.columns = append(t.columns, c)
+	}
+
+	parents, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND PARENT_TABLE_NAME IS NOT NULL AND INTERLEAVE_TYPE = 'IN PARENT'`,
+		Params: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range parents {
+		parent, _ := row["PARENT_TABLE_NAME"].(string)
+		// Child tables have the key columns of the parent table.
+		parentKeys, err := primaryKeyColumns(ctx, client, schema, parent)
+		if err != nil {
+			return nil, err
+		}
+		t.references = append(t.references, tableReference{table: qualifiedTableName(schema, parent), columns: parentKeys, refColumns: parentKeys})
+	}
+
+	fks, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT rc.CONSTRAINT_NAME, fk.COLUMN_NAME, pk.TABLE_SCHEMA AS REF_SCHEMA, pk.TABLE_NAME AS REF_TABLE, pk.COLUMN_NAME AS REF_COLUMN
+FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS fk
+  ON fk.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND fk.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS pk
+  ON pk.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND pk.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME
+  AND pk.ORDINAL_POSITION = fk.POSITION_IN_UNIQUE_CONSTRAINT
+WHERE fk.TABLE_SCHEMA = @schema AND fk.TABLE_NAME = @table
+ORDER BY rc.CONSTRAINT_NAME, fk.ORDINAL_POSITION`,
+		Params: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var constraint string
+	for _, row := range fks {
+		name, _ := row["CONSTRAINT_NAME"].(string)
+		if name != constraint {
+			refSchema, _ := row["REF_SCHEMA"].(string)
+			refTable, _ := row["REF_TABLE"].(string)
+			t.references = append(t.references, tableReference{table: qualifiedTableName(refSchema, refTable)})
+			constraint = name
+		}
+		ref := &t.references[len(t.references)-1]
+		column, _ := row["COLUMN_NAME"].(string)
+		refColumn, _ := row["REF_COLUMN"].(string)
+		ref.columns = append(ref.columns, column)
+		ref.refColumns = append(ref.refColumns, refColumn)
+	}
+	return &t, nil
+}
+
+func primaryKeyColumns(ctx context.Context, client *spanner.Client, schema, table string) ([]string, error) {
+	rows, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.INDEX_COLUMNS
+WHERE TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND INDEX_TYPE = 'PRIMARY_KEY'
+ORDER BY ORDINAL_POSITION`,
+		Params: map[string]any{"schema": schema, "table": table},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(rows, func(row map[string]any, _ int) string {
+		name, _ := row["COLUMN_NAME"].(string)
+		return name
+	}), nil
+}
+
+func qualifiedTableName(schema, table string) string {
+	if schema == "" {
+		return table
+	}
+	return schema + "." + table
+}
+
+// typeLength returns the length of STRING(N) and BYTES(N), or 0 for MAX and other types.
+func typeLength(spannerType string) int {
+	_, length, ok := strings.Cut(strings.TrimSuffix(spannerType, ")"), "(")
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(length)
+	return n
+}
+
+// rowGenerator generates rows keyed by column names in values accepted by importValue.
+type rowGenerator struct {
+	r          *rand.Rand
+	generators map[string]valueGenerator
+	references []sampledReference
+
+	// masks are the masking methods of columns taking values from sampled rows of masked columns.
+	masks map[string]string
+}
+
+// valueGenerator generates a value of a column. Values are strings except ARRAY and NULL.
+type valueGenerator func() any
+
+type sampledReference struct {
+	tableReference
+	rows [][]any
+}
+
+// newRowGenerator returns a generator of rows of the table. Referenced tables and columns denied by the access config are not sampled,
+// so their columns need generators.
+func newRowGenerator(ctx context.Context, client *spanner.Client, table string, schema *generationTable, specs map[string]string, r *rand.Rand) (*rowGenerator, error) {
+	g := &rowGenerator{r: r, generators: make(map[string]valueGenerator), masks: make(map[string]string)}
+
+	for name := range specs {
+		if !slices.ContainsFunc(schema.columns, func(c generationColumn) bool { return c.name == name }) {
+			return nil, &validationError{Field: "generators", Message: fmt.Sprintf("unknown or generated column %s", name)}
+		}
+	}
+
+	// Columns referencing other tables take values from the referenced rows unless generators are given.
+	referenced := make(map[string]bool)
+	for _, ref := range schema.references {
+		if lo.EveryBy(ref.columns, func(c string) bool { return specs[c] != "" }) {
+			continue
+		}
+
+		if p, ok := cfg.Access.deniedTable(ref.table); ok {
+			return nil, &validationError{Field: "generators", Message: fmt.Sprintf("%s references %s which is denied by access.deny_tables %q of the server config, so give generators of the columns", strings.Join(ref.columns, ", "), ref.table, p)}
+		}
+		matchRefTable := func(pattern string) bool { return matchPattern(pattern, ref.table) }
+		matchTable := func(pattern string) bool { return matchPattern(pattern, strings.ReplaceAll(table, "`", "")) }
+		for i, c := range ref.refColumns {
+			if p, ok := cfg.Access.deniedColumn(c, matchRefTable); ok {
+				return nil, &validationError{Field: "generators", Message: fmt.Sprintf("%s references %s.%s which is denied by access.deny_columns %q of the server config, so give generators of the columns", strings.Join(ref.columns, ", "), ref.table, c, p)}
+			}
+			if method := lo.CoalesceOrEmpty(cfg.Masking.method(c, matchRefTable), cfg.Masking.method(ref.columns[i], matchTable)); method != "" {
+				g.masks[ref.columns[i]] = method
+			}
+		}
+
+		quoted, err := quoteTableName(ref.table)
+		if err != nil {
+			return nil, err
+		}
+		sql := fmt.Sprintf("SELECT %s FROM %s TABLESAMPLE RESERVOIR (%d ROWS)",
+			strings.Join(lo.Map(ref.refColumns, func(c string, _ int) string { return "`" + c + "`" }), ", "), quoted, referenceSampleRows)
+		rows, err := queryRows(ctx, client, spanner.NewStatement(sql))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample rows of %s: %w", ref.table, err)
+		}
+
+		sampled := sampledReference{tableReference: ref}
+		for _, row := range rows {
+			sampled.rows = append(sampled.rows, lo.Map(ref.refColumns, func(c string, _ int) any { return row[c] }))
+		}
+		if len(sampled.rows) == 0 {
+			// NULL in any column of a foreign key is not checked, but key columns and NOT NULL columns need referenced rows.
+			if !lo.SomeBy(ref.columns, func(name string) bool {
+				c, _ := lo.Find(schema.columns, func(c generationColumn) bool { return c.name == name })
+				return c.nullable
+			}) {
+				return nil, &validationError{Field: "table", Message: fmt.Sprintf("%s references %s which has no rows, generate rows of %s first", strings.Join(ref.columns, ", "), ref.table, ref.table)}
+			}
+		}
+		g.references = append(g.references, sampled)
+		for _, c := range ref.columns {
+			referenced[c] = true
+		}
+	}
+
+	for _, c := range schema.columns {
+		spec := specs[c.name]
+		switch {
+		case spec != "":
+			gen, err := newValueGenerator(spec, c, r)
+			if err != nil {
+				return nil, &validationError{Field: "generators", Message: fmt.Sprintf("column %s: %v", c.name, err)}
+			}
+			g.generators[c.name] = gen
+		case referenced[c.name]:
+		case c.hasDefault:
+			// The default value is used.
+		case c.typ == nil:
+			if !c.nullable {
+				return nil, &validationError{Field: "generators", Message: fmt.Sprintf("column %s has a type which is not supported by generators", c.name)}
+			}
+		default:
+			g.generators[c.name] = defaultGenerator(c, c.typ, r)
+		}
+	}
+	return g, nil
+}
+
+// preview returns a copy of the row whose values sampled from masked columns are masked.
+func (g *rowGenerator) preview(row map[string]any) map[string]any {
+	preview := maps.Clone(row)
+	for c, method := range g.masks {
+		if v, ok := preview[c]; ok {
+			preview[c] = cfg.Masking.mask(method, v)
+		}
+	}
+	return preview
+}
+
+func (g *rowGenerator) next() (map[string]any, error) {
+	row := make(map[string]any, len(g.generators))
+	for name, gen := range g.generators {
+		row[name] = gen()
+	}
+
+	for _, ref := range g.references {
+		// Referenced rows must be consistent with the columns given by other references, e.g. a foreign key to the parent.
+		candidates := lo.Filter(ref.rows, func(values []any, _ int) bool {
+			for i, c := range ref.columns {
+				if v, ok := row[c]; ok && fmt.Sprint(v) != fmt.Sprint(values[i]) {
+					return false
+				}
+			}
+			return true
+		})
+		if len(candidates) == 0 {
+			if len(ref.rows) > 0 {
+				return nil, fmt.Errorf("no sampled rows of %s match the other columns", ref.table)
+			}
+			for _, c := range ref.columns {
+				if _, ok := row[c]; !ok {
+					row[c] = nil
+				}
+			}
+			continue
+		}
+		values := candidates[g.r.IntN(len(candidates))]
+		for i, c := range ref.columns {
+			row[c] = values[i]
+		}
+	}
+	return row, nil
+}
+
+var numericTypes = []sppb.TypeCode{sppb.TypeCode_INT64, sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64, sppb.TypeCode_NUMERIC}
+
+// generatorTypes are the types supported by generators. Other generators support all types.
+var generatorTypes = map[string][]sppb.TypeCode{
+	"uuid":      {sppb.TypeCode_STRING, sppb.TypeCode_UUID},
+	"sequence":  {sppb.TypeCode_INT64, sppb.TypeCode_STRING},
+	"uniform":   numericTypes,
+	"zipf":      slices.Concat(numericTypes, []sppb.TypeCode{sppb.TypeCode_STRING}),
+	"timestamp": {sppb.TypeCode_TIMESTAMP, sppb.TypeCode_DATE},
+	"string":    {sppb.TypeCode_STRING},
+}
+
+// newValueGenerator parses the generator spec for the column. For ARRAY columns, the spec generates elements.
+func newValueGenerator(spec string, c generationColumn, r *rand.Rand) (valueGenerator, error) {
+	if c.typ == nil {
+		return nil, fmt.Errorf("the type is not supported by generators")
+	}
+	typ := c.typ
+	if typ.GetCode() == sppb.TypeCode_ARRAY {
+		typ = typ.GetArrayElementType()
+	}
+
+	name, arg, _ := strings.Cut(spec, ":")
+	if codes, ok := generatorTypes[name]; ok && !slices.Contains(codes, typ.GetCode()) {
+		return nil, fmt.Errorf("%s is not supported for %s", name, formatType(c.typ))
+	}
+	var gen valueGenerator
+	switch name {
+	case "null":
+		if !c.nullable {
+			return nil, fmt.Errorf("NOT NULL column can't be null")
+		}
+		return func() any { return nil }, nil
+	case "const":
+		gen = func() any { return arg }
+	case "choice":
+		choices := strings.Split(arg, "|")
+		gen = func() any { return choices[r.IntN(len(choices))] }
+	case "uuid":
+		gen = func() any { return randomUUID(r) }
+	case "sequence":
+		next := int64(1)
+		if arg != "" {
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid start of sequence: %w", err)
+			}
+			next = n
+		}
+		gen = func() any {
+			n := next
+			next++
+			return strconv.FormatInt(n, 10)
+		}
+	case "uniform":
+		low, high, err := parseFloatRange(arg)
+		if err != nil {
+			return nil, err
+		}
+		gen = uniformGenerator(typ, low, high, r)
+	case "zipf":
+		rangeArg, exponentArg, _ := strings.Cut(arg, ":")
+		low, high, err := parseIntRange(rangeArg)
+		if err != nil {
+			return nil, err
+		}
+		s := 1.1
+		if exponentArg != "" {
+			if s, err = strconv.ParseFloat(exponentArg, 64); err != nil || s <= 1 {
+				return nil, fmt.Errorf("exponent of zipf must be greater than 1")
+			}
+		}
+		// Smaller values are more frequent.
+		z := rand.NewZipf(r, s, 1, uint64(high-low))
+		gen = func() any { return strconv.FormatInt(low+int64(z.Uint64()), 10) }
+	case "timestamp":
+		start, end, ok := strings.Cut(arg, "..")
+		if !ok {
+			return nil, fmt.Errorf("range must be {start}..{end}")
+		}
+		startTime, err := parseTimeBound(start)
+		if err != nil {
+			return nil, err
+		}
+		endTime, err := parseTimeBound(end)
+		if err != nil {
+			return nil, err
+		}
+		if !endTime.After(startTime) {
+			return nil, fmt.Errorf("end must be after start")
+		}
+		gen = timeGenerator(typ, startTime, endTime, r)
+	case "string":
+		minLen, maxLen := int64(8), int64(16)
+		if arg != "" {
+			var err error
+			if minLen, maxLen, err = parseIntRange(arg); err != nil {
+				return nil, err
+			}
+		}
+		gen = func() any { return randomString(r, int(minLen+r.Int64N(maxLen-minLen+1))) }
+	default:
+		return nil, fmt.Errorf("unknown generator %q", name)
+	}
+
+	if c.typ.GetCode() == sppb.TypeCode_ARRAY {
+		return arrayGenerator(gen, r), nil
+	}
+	return gen, nil
+}
+
+// defaultGenerator returns the generator for the type. Random keys avoid hotspots.
+func defaultGenerator(c generationColumn, typ *sppb.Type, r *rand.Rand) valueGenerator {
+	switch typ.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return func() any { return strconv.FormatBool(r.IntN(2) == 0) }
+	case sppb.TypeCode_INT64:
+		if c.key {
+			return func() any { return strconv.FormatInt(r.Int64N(math.MaxInt64-1)+1, 10) }
+		}
+		return func() any { return strconv.FormatInt(r.Int64N(1000000), 10) }
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64, sppb.TypeCode_NUMERIC:
+		return uniformGenerator(typ, 0, 1000, r)
+	case sppb.TypeCode_STRING:
+		if c.key && (c.length == 0 || c.length >= 36) {
+			return func() any { return randomUUID(r) }
+		}
+		length := 16
+		if c.length > 0 {
+			length = min(c.length, length)
+		}
+		return func() any { return randomString(r, length) }
+	case sppb.TypeCode_BYTES:
+		length := 16
+		if c.length > 0 {
+			length = min(c.length, length)
+		}
+		return func() any {
+			b := make([]byte, length)
+			for i := range b {
+				b[i] = byte(r.UintN(256))
+			}
+			return base64.StdEncoding.EncodeToString(b)
+		}
+	case sppb.TypeCode_DATE:
+		now := time.Now().UTC()
+		return timeGenerator(typ, now.AddDate(-1, 0, 0), now, r)
+	case sppb.TypeCode_TIMESTAMP:
+		// Values in the past are also valid for commit timestamp columns.
+		now := time.Now().UTC()
+		return timeGenerator(typ, now.AddDate(0, 0, -30), now, r)
+	case sppb.TypeCode_JSON:
+		return func() any { return fmt.Sprintf(`{"value":%d}`, r.IntN(1000)) }
+	case sppb.TypeCode_UUID:
+		return func() any { return randomUUID(r) }
+	case sppb.TypeCode_INTERVAL:
+		return func() any { return fmt.Sprintf("P%dD", r.IntN(365)) }
+	case sppb.TypeCode_ARRAY:
+		return arrayGenerator(defaultGenerator(generationColumn{}, typ.GetArrayElementType(), r), r)
+	default:
+		return func() any { return nil }
+	}
+}
+
+// arrayGenerator generates arrays of up to 3 elements.
+func arrayGenerator(elem valueGenerator, r *rand.Rand) valueGenerator {
+	return func() any {
+		values := make([]any, r.IntN(4))
+		for i := range values {
+			values[i] = elem()
+		}
+		return values
+	}
+}
+
+func uniformGenerator(typ *sppb.Type, low, high float64, r *rand.Rand) valueGenerator {
+	if typ.GetCode() == sppb.TypeCode_INT64 {
+		n := int64(high-low) + 1
+		return func() any { return strconv.FormatInt(int64(low)+r.Int64N(n), 10) }
+	}
+	return func() any {
+		f := low + r.Float64()*(high-low)
+		if typ.GetCode() == sppb.TypeCode_NUMERIC {
+			// NUMERIC has 9 digits after the decimal point.
+			return strconv.FormatFloat(f, 'f', 9, 64)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+func timeGenerator(typ *sppb.Type, start, end time.Time, r *rand.Rand) valueGenerator {
+	d := end.Sub(start)
+	return func() any {
+		t := start.Add(time.Duration(r.Int64N(int64(d))))
+		if typ.GetCode() == sppb.TypeCode_DATE {
+			return t.Format(time.DateOnly)
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+}
+
+func parseFloatRange(s string) (float64, float64, error) {
+	lowArg, highArg, ok := strings.Cut(s, "..")
+	if !ok {
+		return 0, 0, fmt.Errorf("range must be {min}..{max}")
+	}
+	low, err := strconv.ParseFloat(lowArg, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid min: %w", err)
+	}
+	high, err := strconv.ParseFloat(highArg, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max: %w", err)
+	}
+	if high < low {
+		return 0, 0, fmt.Errorf("max must not be less than min")
+	}
+	return low, high, nil
+}
+
+func parseIntRange(s string) (int64, int64, error) {
+	lowArg, highArg, ok := strings.Cut(s, "..")
+	if !ok {
+		return 0, 0, fmt.Errorf("range must be {min}..{max}")
+	}
+	low, err := strconv.ParseInt(lowArg, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid min: %w", err)
+	}
+	high, err := strconv.ParseInt(highArg, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max: %w", err)
+	}
+	if high < low {
+		return 0, 0, fmt.Errorf("max must not be less than min")
+	}
+	return low, high, nil
+}
+
+// parseTimeBound parses an RFC 3339 timestamp, a date or now.
+func parseTimeBound(s string) (time.Time, error) {
+	if s == "now" {
+		return time.Now().UTC(), nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphanumeric[r.IntN(len(alphanumeric))]
+	}
+	return string(b)
+}
+
+// randomUUID returns a UUID version 4 from r, so it is reproducible by the seed.
+func randomUUID(r *rand.Rand) string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(r.UintN(256))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+func executeGQLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs     `mapstructure:",squash"`
+		renderOptions `mapstructure:",squash"`
+		Query         string `mapstructure:"query"`
+		MaxRows       int    `mapstructure:"max_rows"`
+		Mermaid       bool   `mapstructure:"mermaid"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxRows <= 0 {
+		req.MaxRows = defaultMaxRows
+	}
+	if _, ok := gqlGraphName(req.Query); !ok {
+		return nil, &validationError{Field: "query", Message: "must be a GQL query starting with GRAPH {name}"}
+	}
+
+	r, err := newRenderer(cfg.Output.Render.override(req.renderOptions), protoFormat(""))
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, rowType, err := runQuery(ctx, target, spanner.NewStatement(req.Query), req.MaxRows, protoFormat(""), false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Graph elements are JSON values returned by TO_JSON or SAFE_TO_JSON of nodes, edges and paths.
+	out := executeGQLOutput{Columns: result.Columns, Rows: make([][]any, len(result.Rows)), HasMoreRows: result.HasMoreRows, Stats: result.Stats}
+	var b strings.Builder
+	table := newTable(&b)
+	table.SetHeader(lo.Map(out.Columns, func(c queryColumn, _ int) string {
+		return strings.TrimSpace(c.Name + " " + c.Type)
+	}))
+	var elements []graphElement
+	for i, row := range result.Rows {
+		out.Rows[i] = make([]any, len(row))
+		cells := make([]string, len(row))
+		for j, v := range row {
+			if value, ok := parseGraphValue(v); ok {
+				out.Rows[i][j] = value
+				cells[j] = r.truncate(value.pattern())
+				elements = append(elements, value.elements()...)
+				continue
+			}
+			out.Rows[i][j] = v
+			s, err := r.render(rowType.GetFields()[j].GetType(), v)
+			if err != nil {
+				return nil, err
+			}
+			cells[j] = s
+		}
+		table.Append(cells)
+	}
+	if len(out.Columns) > 0 {
+		table.Render()
+	}
+	fmt.Fprintf(&b, "%d rows", len(out.Rows))
+	if out.HasMoreRows {
+		b.WriteString(" (more rows are omitted by max_rows)")
+	}
+	b.WriteString("\n")
+	b.WriteString(renderQueryStats(out.Stats))
+
+	if req.Mermaid {
+		out.Mermaid = mermaidGraphElements(elements)
+		fmt.Fprintf(&b, "```mermaid\n%s```\n", out.Mermaid)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// graphElement is a node or an edge in the JSON format of graph elements.
+type graphElement struct {
+	Kind                      string         `json:"kind" jsonschema:"node or edge"`
+	Identifier                string         `json:"identifier"`
+	Labels                    []string       `json:"labels"`
+	Properties                map[string]any `json:"properties"`
+	SourceNodeIdentifier      string         `json:"source_node_identifier,omitempty"`
+	DestinationNodeIdentifier string         `json:"destination_node_identifier,omitempty"`
+}
+
+// graphValue is a graph element or a path, which is an array of alternating nodes and edges.
+type graphValue struct {
+	Element *graphElement  `json:"element,omitempty"`
+	Path    []graphElement `json:"path,omitempty"`
+}
+
+// parseGraphValue parses a JSON value decoded by decodeValue as a graph element or a path.
+func parseGraphValue(v any) (*graphValue, bool) {
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		return nil, false
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	// Numbers are kept as they are because INT64 properties may exceed the precision of float64.
+	d.UseNumber()
+
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) > 0 && raw[0] == '{':
+		var e graphElement
+		if err := d.Decode(&e); err != nil || !e.valid() {
+			return nil, false
+		}
+		return &graphValue{Element: &e}, true
+	case len(raw) > 0 && raw[0] == '[':
+		var path []graphElement
+		if err := d.Decode(&path); err != nil || len(path) == 0 {
+			return nil, false
+		}
+		for i, e := range path {
+			if !e.valid() || e.Kind != lo.Ternary(i%2 == 0, "node", "edge") {
+				return nil, false
+			}
+		}
+		return &graphValue{Path: path}, true
+	}
+	return nil, false
+}
+
+func (e graphElement) valid() bool {
+	return e.Identifier != "" && (e.Kind == "node" || e.Kind == "edge")
+}
+
+func (v *graphValue) elements() []graphElement {
+	if v.Element != nil {
+		return []graphElement{*v.Element}
+	}
+	return v.Path
+}
+
+// pattern formats the value like a GQL path pattern, e.g. (:Person {id: 1})-[:Owns]->(:Account {id: 7}).
+func (v *graphValue) pattern() string {
+	if e := v.Element; e != nil {
+		if e.Kind == "node" {
+			return "(" + e.body() + ")"
+		}
+		return "-[" + e.body() + "]->"
+	}
+
+	var b strings.Builder
+	for i, e := range v.Path {
+		if e.Kind == "node" {
+			b.WriteString("(" + e.body() + ")")
+			continue
+		}
+		// Edges in paths may be traversed from the destination to the source.
+		if i > 0 && v.Path[i-1].Identifier == e.DestinationNodeIdentifier && e.SourceNodeIdentifier != e.DestinationNodeIdentifier {
+			b.WriteString("<-[" + e.body() + "]-")
+		} else {
+			b.WriteString("-[" + e.body() + "]->")
+		}
+	}
+	return b.String()
+}
+
+// body returns the labels and the properties of the element, e.g. :Person {id: 1, name: "Alex"}.
+func (e graphElement) body() string {
+	var parts []string
+	if len(e.Labels) > 0 {
+		parts = append(parts, ":"+strings.Join(e.Labels, "&"))
+	}
+	if props := e.properties(); len(props) > 0 {
+		parts = append(parts, "{"+strings.Join(props, ", ")+"}")
+	}
+	return strings.Join(parts, " ")
+}
+
+// properties returns name: value of the properties in the order of names.
+func (e graphElement) properties() []string {
+	names := lo.Keys(e.Properties)
+	slices.Sort(names)
+	return lo.Map(names, func(name string, _ int) string {
+		b, err := json.Marshal(e.Properties[name])
+		if err != nil {
+			return name + ": ?"
+		}
+		return fmt.Sprintf("%s: %s", name, b)
+	})
+}
+
+// mermaidGraphElements renders the nodes and the edges as a mermaid flowchart. Elements which appear multiple times are rendered once.
+// Edges whose nodes are not returned are omitted.
+func mermaidGraphElements(elements []graphElement) string {
+	ids := make(map[string]string)
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, e := range elements {
+		if e.Kind != "node" || ids[e.Identifier] != "" {
+			continue
+		}
+		ids[e.Identifier] = fmt.Sprintf("n%d", len(ids))
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[e.Identifier], mermaidLabel(append([]string{":" + strings.Join(e.Labels, "&")}, e.properties()...)))
+	}
+	edges := make(map[string]bool)
+	for _, e := range elements {
+		from, to := ids[e.SourceNodeIdentifier], ids[e.DestinationNodeIdentifier]
+		if e.Kind != "edge" || edges[e.Identifier] || from == "" || to == "" {
+			continue
+		}
+		edges[e.Identifier] = true
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", from, mermaidLabel(append([]string{":" + strings.Join(e.Labels, "&")}, e.properties()...)), to)
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// propertyGraphMetadata is PROPERTY_GRAPH_METADATA_JSON of INFORMATION_SCHEMA.PROPERTY_GRAPHS.
+type propertyGraphMetadata struct {
+	NodeTables []graphElementMetadata `json:"nodeTables"`
+	EdgeTables []graphElementMetadata `json:"edgeTables"`
+	Labels     []struct {
+		Name                     string   `json:"name"`
+		PropertyDeclarationNames []string `json:"propertyDeclarationNames"`
+	} `json:"labels"`
+	PropertyDeclarations []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"propertyDeclarations"`
+}
+
+type graphElementMetadata struct {
+	Name                string   `json:"name"`
+	BaseSchemaName      string   `json:"baseSchemaName"`
+	BaseTableName       string   `json:"baseTableName"`
+	KeyColumns          []string `json:"keyColumns"`
+	LabelNames          []string `json:"labelNames"`
+	PropertyDefinitions []struct {
+		PropertyDeclarationName string `json:"propertyDeclarationName"`
+		ValueExpressionSQL      string `json:"valueExpressionSql"`
+	} `json:"propertyDefinitions"`
+	SourceNodeTable      *graphNodeReferenceMetadata `json:"sourceNodeTable"`
+	DestinationNodeTable *graphNodeReferenceMetadata `json:"destinationNodeTable"`
+}
+
+type graphNodeReferenceMetadata struct {
+	NodeTableName    string   `json:"nodeTableName"`
+	EdgeTableColumns []string `json:"edgeTableColumns"`
+	NodeTableColumns []string `json:"nodeTableColumns"`
+}
+
+func listPropertyGraphsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Graph     string `mapstructure:"graph"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	graphs, err := propertyGraphs(ctx, client, req.Graph)
+	if err != nil {
+		return nil, err
+	}
+
+	out := listPropertyGraphsOutput{Graphs: graphs}
+	if len(out.Graphs) == 0 {
+		if req.Graph != "" {
+			return nil, &validationError{Field: "graph", Message: fmt.Sprintf("property graph %s is not found", req.Graph)}
+		}
+		return mcp.NewToolResultStructured(out, "No property graphs are defined"), nil
+	}
+
+	var b strings.Builder
+	for _, g := range out.Graphs {
+		fmt.Fprintf(&b, "GRAPH %s\n", g.Name)
+		for _, n := range g.NodeTables {
+			fmt.Fprintf(&b, "  NODE %s (table %s, key %s) labels %s\n", n.Name, n.BaseTable, strings.Join(n.KeyColumns, ", "), strings.Join(n.Labels, ", "))
+		}
+		for _, e := range g.EdgeTables {
+			fmt.Fprintf(&b, "  EDGE %s (table %s, key %s) labels %s: (%s)-[%s]->(%s)\n", e.Name, e.BaseTable, strings.Join(e.KeyColumns, ", "), strings.Join(e.Labels, ", "),
+				lo.FromPtr(e.Source).NodeTable, e.Name, lo.FromPtr(e.Destination).NodeTable)
+		}
+		for _, l := range g.Labels {
+			fmt.Fprintf(&b, "  LABEL %s PROPERTIES (%s)\n", l.Name, strings.Join(l.Properties, ", "))
+		}
+		for _, p := range g.Properties {
+			fmt.Fprintf(&b, "  PROPERTY %s %s\n", p.Name, p.Type)
+		}
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// propertyGraphs returns the property graph of the name, or all property graphs if the name is empty.
+func propertyGraphs(ctx context.Context, client *spanner.Client, graph string) ([]propertyGraph, error) {
+	// Graphs in named schemas are referred as schema.graph like tables.
+	stmt := spanner.NewStatement(`SELECT PROPERTY_GRAPH_SCHEMA, PROPERTY_GRAPH_NAME, TO_JSON_STRING(PROPERTY_GRAPH_METADATA_JSON)
+FROM INFORMATION_SCHEMA.PROPERTY_GRAPHS`)
+	if graph != "" {
+		schema, name, ok := strings.Cut(strings.ReplaceAll(graph, "`", ""), ".")
+		if !ok {
+			schema, name = "", schema
+		}
+		stmt.SQL += "\nWHERE PROPERTY_GRAPH_SCHEMA = @schema AND PROPERTY_GRAPH_NAME = @graph"
+		stmt.Params = map[string]any{"schema": schema, "graph": name}
+	}
+	stmt.SQL += "\nORDER BY PROPERTY_GRAPH_SCHEMA, PROPERTY_GRAPH_NAME"
+
+	var graphs []propertyGraph
+	err := retry(ctx, func(ctx context.Context) error {
+		graphs = nil
+		return client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			var schema, name, metadataJSON string
+			if err := row.Columns(&schema, &name, &metadataJSON); err != nil {
+				return err
+			}
+			var metadata propertyGraphMetadata
+			if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+				return fmt.Errorf("failed to parse the metadata of property graph %s: %w", name, err)
+			}
+			graphs = append(graphs, newPropertyGraph(qualifiedTableName(schema, name), &metadata))
+			return nil
+		})
+	})
+	return graphs, err
+}
+
+func newPropertyGraph(name string, metadata *propertyGraphMetadata) propertyGraph {
+	g := propertyGraph{
+		Name:       name,
+		NodeTables: lo.Map(metadata.NodeTables, func(e graphElementMetadata, _ int) graphElementTable { return newGraphElementTable(e) }),
+		EdgeTables: lo.Map(metadata.EdgeTables, func(e graphElementMetadata, _ int) graphElementTable { return newGraphElementTable(e) }),
+	}
+	for _, l := range metadata.Labels {
+		g.Labels = append(g.Labels, graphLabel{Name: l.Name, Properties: l.PropertyDeclarationNames})
+	}
+	for _, p := range metadata.PropertyDeclarations {
+		g.Properties = append(g.Properties, graphProperty{Name: p.Name, Type: p.Type})
+	}
+	return g
+}
+
+func newGraphElementTable(e graphElementMetadata) graphElementTable {
+	t := graphElementTable{
+		Name:       e.Name,
+		BaseTable:  qualifiedTableName(e.BaseSchemaName, e.BaseTableName),
+		KeyColumns: e.KeyColumns,
+		Labels:     e.LabelNames,
+	}
+	for _, p := range e.PropertyDefinitions {
+		t.Properties = append(t.Properties, graphPropertyDefinition{Name: p.PropertyDeclarationName, Expression: p.ValueExpressionSQL})
+	}
+	if e.SourceNodeTable != nil {
+		t.Source = &graphNodeReference{NodeTable: e.SourceNodeTable.NodeTableName, EdgeColumns: e.SourceNodeTable.EdgeTableColumns, NodeColumns: e.SourceNodeTable.NodeTableColumns}
+	}
+	if e.DestinationNodeTable != nil {
+		t.Destination = &graphNodeReference{NodeTable: e.DestinationNodeTable.NodeTableName, EdgeColumns: e.DestinationNodeTable.EdgeTableColumns, NodeColumns: e.DestinationNodeTable.NodeTableColumns}
+	}
+	return t
+}
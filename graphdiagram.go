@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+var diagramFormats = []string{"mermaid", "dot"}
+
+func graphSchemaDiagramHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Graph     string `mapstructure:"graph"`
+		Format    string `mapstructure:"format"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Graph == "" {
+		return nil, &validationError{Field: "graph", Message: "is required"}
+	}
+	format := lo.CoalesceOrEmpty(req.Format, "mermaid")
+	if !lo.Contains(diagramFormats, format) {
+		return nil, &validationError{Field: "format", Message: "must be mermaid or dot"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	graphs, err := propertyGraphs(ctx, client, req.Graph)
+	if err != nil {
+		return nil, err
+	}
+	if len(graphs) == 0 {
+		return nil, &validationError{Field: "graph", Message: fmt.Sprintf("property graph %s is not found", req.Graph)}
+	}
+
+	g := graphs[0]
+	diagram := mermaidGraphDiagram(g)
+	if format == "dot" {
+		diagram = dotGraphDiagram(g)
+	}
+	return mcp.NewToolResultStructured(graphSchemaDiagramOutput{Graph: g.Name, Format: format, Diagram: diagram}, diagram), nil
+}
+
+// graphElementLines returns the lines describing the element table: the name with the labels, and the properties with their types.
+func graphElementLines(g propertyGraph, t graphElementTable) []string {
+	types := lo.SliceToMap(g.Properties, func(p graphProperty) (string, string) { return p.Name, p.Type })
+	name := t.Name
+	// Labels default to the name of the element table.
+	if len(t.Labels) > 0 && !(len(t.Labels) == 1 && t.Labels[0] == t.Name) {
+		name += " :" + strings.Join(t.Labels, " :")
+	}
+	lines := []string{name}
+	for _, p := range t.Properties {
+		lines = append(lines, strings.TrimSpace(p.Name+" "+types[p.Name]))
+	}
+	return lines
+}
+
+// mermaidGraphDiagram renders node tables as boxes and edge tables as arrows from the source to the destination in a mermaid flowchart.
+// Nodes have generated IDs because names may contain characters which mermaid doesn't allow in IDs.
+func mermaidGraphDiagram(g propertyGraph) string {
+	ids := make(map[string]string, len(g.NodeTables))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range g.NodeTables {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[n.Name], mermaidLabel(graphElementLines(g, n)))
+	}
+	for _, e := range g.EdgeTables {
+		from, to := ids[lo.FromPtr(e.Source).NodeTable], ids[lo.FromPtr(e.Destination).NodeTable]
+		if from == "" || to == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", from, mermaidLabel(graphElementLines(g, e)), to)
+	}
+	return b.String()
+}
+
+// mermaidLabel joins the lines by <br/> with escaping characters which break quoted labels.
+func mermaidLabel(lines []string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	return strings.Join(lo.Map(lines, func(line string, _ int) string { return r.Replace(line) }), "<br/>")
+}
+
+// dotGraphDiagram renders the graph like mermaidGraphDiagram in the DOT language of Graphviz.
+func dotGraphDiagram(g propertyGraph) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(g.Name))
+	b.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+	for _, n := range g.NodeTables {
+		fmt.Fprintf(&b, "  %s [label=%s];\n", strconv.Quote(n.Name), dotLabel(graphElementLines(g, n)))
+	}
+	for _, e := range g.EdgeTables {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(lo.FromPtr(e.Source).NodeTable), strconv.Quote(lo.FromPtr(e.Destination).NodeTable), dotLabel(graphElementLines(g, e)))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotLabel returns a quoted label whose lines are left-justified by \l.
+func dotLabel(lines []string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	escaped := lo.Map(lines, func(line string, _ int) string { return r.Replace(line) })
+	return `"` + strings.Join(escaped, `\l`) + `\l"`
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/samber/lo"
+)
+
+// gqlGraphName returns the graph name of GRAPH {name} at the start of a GQL query.
+func gqlGraphName(query string) (string, bool) {
+	tokens := scanSQL(query)
+	if len(tokens) < 2 || !tokens[0].isKeyword("GRAPH") || tokens[1].kind != sqlWord {
+		return "", false
+	}
+	name := tokens[1].text
+	// The name may be qualified by the named schema.
+	for i := 2; i+1 < len(tokens) && tokens[i].text == "." && tokens[i+1].kind == sqlWord; i += 2 {
+		name += "." + tokens[i+1].text
+	}
+	return strings.ReplaceAll(name, "`", ""), true
+}
+
+// graphPlanOperators explains graph-specific roles of plan nodes of a GQL query.
+// GQL is compiled to relational operators, so scans of element tables are mapped back to nodes and edges of the graph,
+// the nearest join above a scan of an edge table is the edge expansion, and recursive operators evaluate quantified paths.
+func graphPlanOperators(qp *sppb.QueryPlan, g propertyGraph) []graphPlanOperator {
+	nodes := qp.GetPlanNodes()
+	parents := make(map[int32]int32)
+	for _, node := range nodes {
+		for _, link := range node.GetChildLinks() {
+			parents[link.GetChildIndex()] = node.GetIndex()
+		}
+	}
+
+	nodeTables := lo.SliceToMap(g.NodeTables, func(t graphElementTable) (string, graphElementTable) { return t.BaseTable, t })
+	edgeTables := lo.SliceToMap(g.EdgeTables, func(t graphElementTable) (string, graphElementTable) { return t.BaseTable, t })
+
+	var ops []graphPlanOperator
+	expansions := make(map[int32][]string)
+	for _, node := range nodes {
+		name := node.GetDisplayName()
+		switch {
+		case name == "Scan":
+			target := node.GetMetadata().GetFields()["scan_target"].GetStringValue()
+			if t, ok := nodeTables[target]; ok {
+				ops = append(ops, graphPlanOperator{ID: node.GetIndex(), Kind: "node-scan", Description: fmt.Sprintf("scans nodes %s", graphLabels(t))})
+			}
+			if t, ok := edgeTables[target]; ok {
+				ops = append(ops, graphPlanOperator{ID: node.GetIndex(), Kind: "edge-scan", Description: fmt.Sprintf("scans edges %s", graphEdgePattern(t))})
+				if join, ok := nearestJoin(nodes, parents, node.GetIndex()); ok {
+					expansions[join] = append(expansions[join], graphEdgePattern(t))
+				}
+			}
+		case strings.Contains(name, "Recursive"):
+			ops = append(ops, graphPlanOperator{ID: node.GetIndex(), Kind: "path", Description: "repeats its input to evaluate a quantified path pattern"})
+		}
+	}
+	for id, patterns := range expansions {
+		ops = append(ops, graphPlanOperator{ID: id, Kind: "edge-expansion", Description: fmt.Sprintf("expands %s", strings.Join(lo.Uniq(patterns), ", "))})
+	}
+
+	// Operators are listed in the order of the plan like predicates.
+	return lo.Flatten(lo.Map(nodes, func(node *sppb.PlanNode, _ int) []graphPlanOperator {
+		return lo.Filter(ops, func(op graphPlanOperator, _ int) bool { return op.ID == node.GetIndex() })
+	}))
+}
+
+// nearestJoin returns the nearest ancestor of the node which joins its inputs.
+func nearestJoin(nodes []*sppb.PlanNode, parents map[int32]int32, index int32) (int32, bool) {
+	for {
+		parent, ok := parents[index]
+		if !ok || int(parent) >= len(nodes) {
+			return 0, false
+		}
+		name := nodes[parent].GetDisplayName()
+		if strings.Contains(name, "Apply") || strings.Contains(name, "Join") {
+			return parent, true
+		}
+		index = parent
+	}
+}
+
+func graphLabels(t graphElementTable) string {
+	if len(t.Labels) == 0 {
+		return t.Name
+	}
+	return ":" + strings.Join(t.Labels, "|")
+}
+
+// graphEdgePattern returns the edge table in the syntax of GQL path patterns, e.g. (Person)-[:Owns]->(Account).
+func graphEdgePattern(t graphElementTable) string {
+	return fmt.Sprintf("(%s)-[%s]->(%s)", lo.FromPtr(t.Source).NodeTable, graphLabels(t), lo.FromPtr(t.Destination).NodeTable)
+}
+
+// renderGraphPlanOperators renders the operators like predicates of the rendered plan.
+func renderGraphPlanOperators(ops []graphPlanOperator) string {
+	if len(ops) == 0 {
+		return ""
+	}
+	width := len(fmt.Sprint(lo.MaxBy(ops, func(a, b graphPlanOperator) bool { return a.ID > b.ID }).ID))
+	var b strings.Builder
+	b.WriteString("Graph operators(identified by ID):\n")
+	for _, op := range ops {
+		fmt.Fprintf(&b, " %*d: %s %s\n", width, op.ID, op.Kind, op.Description)
+	}
+	return b.String()
+}
+
+// gqlPlanOperators returns graph operators of the plan if the query is GQL.
+// The plan is still useful without them, so failures to read the graph are only logged.
+func gqlPlanOperators(ctx context.Context, target *profile, query string, qp *sppb.QueryPlan) []graphPlanOperator {
+	name, ok := gqlGraphName(query)
+	if !ok {
+		return nil
+	}
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil
+	}
+	defer release()
+
+	graphs, err := propertyGraphs(ctx, client, name)
+	if err != nil {
+		slog.Warn("failed to read the property graph of the plan", "graph", name, "error", err)
+		return nil
+	}
+	if len(graphs) == 0 {
+		return nil
+	}
+	return graphPlanOperators(qp, graphs[0])
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// historyTools are the tools running queries, DML and DDL listed by history and replayed by replay.
+var historyTools = []string{
+	"plan",
+	"execute_query",
+	"execute_partitioned_query",
+	"execute_fanout_query",
+	"execute_gql",
+	"execute_dml",
+	"update_ddl",
+}
+
+// toolMiddlewares are the middlewares of tool calls, set in main. replay applies them to the replayed call
+// so it is logged, audited, limited and checked like a tool call from the client.
+var toolMiddlewares []server.ToolHandlerMiddleware
+
+// historyStatement returns the SQL, GQL or DDL of the audited tool call.
+func historyStatement(e *auditEntry) string {
+	for _, key := range []string{"query", "statement"} {
+		if s, ok := e.Arguments[key].(string); ok {
+			return s
+		}
+	}
+	statements, _ := e.Arguments["statements"].([]any)
+	return strings.Join(lo.Map(statements, func(s any, _ int) string { return fmt.Sprint(s) }), ";\n")
+}
+
+// queryHistory returns the audited calls of historyTools in the session.
+func queryHistory(session string) []*auditEntry {
+	return lo.Filter(audit.history(session), func(e *auditEntry, _ int) bool { return lo.Contains(historyTools, e.Tool) })
+}
+
+func historyHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, err := mapToStruct[struct{}](request.GetArguments()); err != nil {
+		return nil, err
+	}
+
+	id := sessionID(ctx)
+	if id == "" {
+		return nil, fmt.Errorf("history requires a stateful MCP session")
+	}
+
+	out := historyOutput{Entries: lo.Map(queryHistory(id), func(e *auditEntry, _ int) historyEntry {
+		return historyEntry{
+			Index:          e.Index,
+			Time:           e.Time,
+			Tool:           e.Tool,
+			Statement:      historyStatement(e),
+			Arguments:      e.Arguments,
+			DurationMillis: e.DurationMillis,
+			Error:          e.Error,
+		}
+	})}
+
+	var b strings.Builder
+	for _, e := range out.Entries {
+		fmt.Fprintf(&b, "#%d %s %s (%dms)", e.Index, e.Time.Format("15:04:05"), e.Tool, e.DurationMillis)
+		if e.Error != "" {
+			fmt.Fprintf(&b, " error: %s", e.Error)
+		}
+		fmt.Fprintf(&b, "\n%s\n\n", e.Statement)
+	}
+	if len(out.Entries) == 0 {
+		b.WriteString("No queries, DML or DDL in this session\n")
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+func replayHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		Index     int            `mapstructure:"index"`
+		Arguments map[string]any `mapstructure:"arguments"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	id := sessionID(ctx)
+	if id == "" {
+		return nil, fmt.Errorf("replay requires a stateful MCP session")
+	}
+
+	e, ok := lo.Find(queryHistory(id), func(e *auditEntry) bool { return e.Index == req.Index })
+	if !ok {
+		return nil, &validationError{Field: "index", Message: fmt.Sprintf("#%d is not a query, DML or DDL in the history of this session", req.Index)}
+	}
+
+	var tool *server.ServerTool
+	if s := server.ServerFromContext(ctx); s != nil {
+		tool = s.GetTool(e.Tool)
+	}
+	// Tools registered by --dynamic-tools are session-specific.
+	if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithTools); ok && tool == nil {
+		if t, ok := session.GetSessionTools()[e.Tool]; ok {
+			tool = &t
+		}
+	}
+	if tool == nil {
+		return nil, fmt.Errorf("tool %s is not available", e.Tool)
+	}
+
+	// null removes the argument of the replayed call.
+	args := maps.Clone(e.Arguments)
+	if args == nil {
+		args = make(map[string]any)
+	}
+	for k, v := range req.Arguments {
+		if v == nil {
+			delete(args, k)
+		} else {
+			args[k] = v
+		}
+	}
+	if lo.Contains(lo.Values(args), any(redactedArgument)) {
+		return nil, &validationError{Field: "index", Message: fmt.Sprintf("#%d has redacted arguments, which must be given in arguments", req.Index)}
+	}
+
+	handler := tool.Handler
+	for i := len(toolMiddlewares) - 1; i >= 0; i-- {
+		handler = toolMiddlewares[i](handler)
+	}
+	replayed := request
+	replayed.Params.Name = e.Tool
+	replayed.Params.Arguments = args
+	return handler(ctx, replayed)
+}
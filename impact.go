@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/apstndb/spannerplanviz/plantree"
+	"github.com/apstndb/spannerplanviz/queryplan"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// maxImpactQueries is the number of queries in a baseline of index_impact.
+const maxImpactQueries = 20
+
+// indexBaselines holds the baseline captured by index_impact, keyed by MCP session ID.
+var indexBaselines sync.Map
+
+type indexBaseline struct {
+	database   string
+	capturedAt time.Time
+	profiled   bool
+	queries    []queryCapture
+}
+
+// queryCapture is the plan of a query and, if profiled, the statistics of its execution.
+type queryCapture struct {
+	query string
+	plan  string
+	scans []planScan
+	cost  string
+
+	// rowsScanned is the estimate of estimate_cost, or the actual value if profiled.
+	rowsScanned *int64
+	cpuTime     string
+	elapsedTime string
+}
+
+func forgetIndexBaseline(_ context.Context, session server.ClientSession) {
+	indexBaselines.Delete(session.SessionID())
+}
+
+func indexImpactHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs      `mapstructure:",squash"`
+		Action         string   `mapstructure:"action"`
+		Queries        []string `mapstructure:"queries"`
+		Index          string   `mapstructure:"index"`
+		ProfileQueries bool     `mapstructure:"profile_queries"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	id := sessionID(ctx)
+	if id == "" {
+		return nil, fmt.Errorf("index_impact requires a stateful MCP session")
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Action {
+	case "baseline":
+		if len(req.Queries) == 0 {
+			return nil, &validationError{Field: "queries", Message: "is required for baseline"}
+		}
+		if len(req.Queries) > maxImpactQueries {
+			return nil, &validationError{Field: "queries", Message: fmt.Sprintf("must have at most %d queries", maxImpactQueries)}
+		}
+		if req.ProfileQueries {
+			if i := slices.IndexFunc(req.Queries, isDML); i >= 0 {
+				return nil, &validationError{Field: "queries", Message: fmt.Sprintf("query %d is DML, which can't be profiled because profiling executes the query", i+1)}
+			}
+		}
+	case "compare":
+		if req.Index == "" {
+			return nil, &validationError{Field: "index", Message: "is required for compare"}
+		}
+		if len(req.Queries) > 0 || req.ProfileQueries {
+			return nil, &validationError{Field: "action", Message: "compare re-runs the queries of the baseline, so queries and profile_queries are only for baseline"}
+		}
+	default:
+		return nil, &validationError{Field: "action", Message: "must be baseline or compare"}
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if req.Action == "baseline" {
+		baseline := &indexBaseline{database: target.databasePath(), capturedAt: time.Now(), profiled: req.ProfileQueries}
+		for i, query := range req.Queries {
+			c, err := captureQuery(ctx, target, client, query, req.ProfileQueries)
+			if err != nil {
+				return nil, fmt.Errorf("failed to capture query %d: %w", i+1, err)
+			}
+			baseline.queries = append(baseline.queries, *c)
+		}
+		indexBaselines.Store(id, baseline)
+
+		out := indexImpactOutput{Action: req.Action, Database: baseline.database}
+		for _, c := range baseline.queries {
+			out.Queries = append(out.Queries, indexImpactQuery{Query: c.query, BaselineCost: c.cost, BaselineRowsScanned: c.rowsScanned})
+		}
+		text := fmt.Sprintf("Captured the baseline of %d queries on %s. Create the index by update_ddl, then call index_impact with action compare and the index name\n", len(baseline.queries), baseline.database)
+		return mcp.NewToolResultStructured(out, text), nil
+	}
+
+	v, ok := indexBaselines.Load(id)
+	if !ok {
+		return nil, &validationError{Field: "action", Message: "no baseline is captured in this session, call index_impact with action baseline first"}
+	}
+	baseline := v.(*indexBaseline)
+	if baseline.database != target.databasePath() {
+		return nil, &validationError{Field: "database", Message: fmt.Sprintf("the baseline is captured on %s", baseline.database)}
+	}
+
+	state, err := indexState(ctx, client, req.Index)
+	if err != nil {
+		return nil, err
+	}
+	switch state {
+	case "":
+		return nil, &validationError{Field: "index", Message: fmt.Sprintf("index %s is not found", req.Index)}
+	case "READ_WRITE":
+	default:
+		return nil, &validationError{Field: "index", Message: fmt.Sprintf("index %s is %s, wait until the backfill completes", req.Index, state)}
+	}
+
+	out := indexImpactOutput{Action: req.Action, Database: baseline.database, Index: req.Index}
+	for i, before := range baseline.queries {
+		after, err := captureQuery(ctx, target, client, before.query, baseline.profiled)
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture query %d: %w", i+1, err)
+		}
+		out.Queries = append(out.Queries, compareCaptures(req.Index, before, *after))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Impact of index %s on %d queries captured at %s:\n", req.Index, len(out.Queries), baseline.capturedAt.Format(time.RFC3339))
+	for i, q := range out.Queries {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, q.Query)
+		fmt.Fprintf(&b, "   plan changed: %t, uses index: %t, cost: %s -> %s\n", q.PlanChanged, q.UsesIndex, q.BaselineCost, q.Cost)
+		if q.Improvement != "" {
+			fmt.Fprintf(&b, "   %s\n", q.Improvement)
+		}
+	}
+	if !baseline.profiled {
+		b.WriteString("Note: Rows scanned are estimated by the plans and table size statistics. Capture the baseline with profile_queries: true for actual rows scanned\n")
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// captureQuery plans the query, and executes it in PROFILE mode if profile is true.
+func captureQuery(ctx context.Context, target *profile, client *spanner.Client, query string, profile bool) (*queryCapture, error) {
+	qp, err := analyzeStatement(ctx, target, client, query)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := plantree.ProcessPlan(queryplan.New(qp.GetPlanNodes()))
+	if err != nil {
+		return nil, err
+	}
+	plan, err := printResult(processed)
+	if err != nil {
+		return nil, err
+	}
+	cost, err := estimateCost(ctx, client, qp)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &queryCapture{query: query, plan: plan, scans: planScans(qp), cost: cost.Cost, rowsScanned: cost.EstimatedRowsScanned}
+	if !profile {
+		return c, nil
+	}
+
+	var stats map[string]any
+	err = retry(ctx, func(ctx context.Context) error {
+		iter := client.Single().QueryWithStats(ctx, spanner.NewStatement(query))
+		// Rows are discarded because only the statistics are compared.
+		if err := iter.Do(func(*spanner.Row) error { return nil }); err != nil {
+			return err
+		}
+		stats = iter.QueryStats
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to profile the query: %w", err)
+	}
+	s := lo.FromPtr(queryStatsOf(stats))
+	c.rowsScanned, c.cpuTime, c.elapsedTime = s.RowsScanned, s.CPUTime, s.ElapsedTime
+	return c, nil
+}
+
+func compareCaptures(index string, before, after queryCapture) indexImpactQuery {
+	// Scan targets of indexes are not qualified by schemas.
+	index = strings.ReplaceAll(index, "`", "")
+	if i := strings.LastIndex(index, "."); i >= 0 {
+		index = index[i+1:]
+	}
+	q := indexImpactQuery{
+		Query:               before.query,
+		PlanChanged:         before.plan != after.plan,
+		UsesIndex:           slices.ContainsFunc(after.scans, func(s planScan) bool { return s.kind == "index" && strings.EqualFold(s.target, index) }),
+		BaselineCost:        before.cost,
+		Cost:                after.cost,
+		BaselineRowsScanned: before.rowsScanned,
+		RowsScanned:         after.rowsScanned,
+		BaselineCPUTime:     before.cpuTime,
+		CPUTime:             after.cpuTime,
+		BaselineElapsedTime: before.elapsedTime,
+		ElapsedTime:         after.elapsedTime,
+	}
+
+	var improvements []string
+	if before.rowsScanned != nil && after.rowsScanned != nil && *before.rowsScanned != *after.rowsScanned {
+		improvements = append(improvements, fmt.Sprintf("rows scanned %d -> %d%s", *before.rowsScanned, *after.rowsScanned, percentChange(*before.rowsScanned, *after.rowsScanned)))
+	}
+	beforeFull := lo.CountBy(before.scans, func(s planScan) bool { return s.full })
+	afterFull := lo.CountBy(after.scans, func(s planScan) bool { return s.full })
+	if beforeFull != afterFull {
+		improvements = append(improvements, fmt.Sprintf("full scans %d -> %d", beforeFull, afterFull))
+	}
+	if before.elapsedTime != "" && after.elapsedTime != "" {
+		improvements = append(improvements, fmt.Sprintf("elapsed time %s -> %s", before.elapsedTime, after.elapsedTime))
+	}
+	if before.cpuTime != "" && after.cpuTime != "" {
+		improvements = append(improvements, fmt.Sprintf("CPU time %s -> %s", before.cpuTime, after.cpuTime))
+	}
+	q.Improvement = strings.Join(improvements, ", ")
+	return q
+}
+
+func percentChange(before, after int64) string {
+	if before == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.0f%%)", float64(after-before)*100/float64(before))
+}
+
+// indexState returns INDEX_STATE of the index, or an empty string if it doesn't exist.
+func indexState(ctx context.Context, client *spanner.Client, index string) (string, error) {
+	schema, name, ok := strings.Cut(strings.ReplaceAll(index, "`", ""), ".")
+	if !ok {
+		schema, name = "", schema
+	}
+
+	rows, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT INDEX_STATE FROM INFORMATION_SCHEMA.INDEXES
+WHERE TABLE_SCHEMA = @schema AND INDEX_NAME = @index AND INDEX_TYPE = 'INDEX'`,
+		Params: map[string]any{"schema": schema, "index": name},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	state, _ := rows[0]["INDEX_STATE"].(string)
+	return state, nil
+}
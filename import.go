@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// importDir is the directory of local files which import_data can read. It is set by --import-dir.
+// Local files are not readable if it is empty.
+var importDir string
+
+const (
+	defaultImportBatchSize = 500
+	maxImportBatchSize     = 5000
+)
+
+var importFormats = []string{"csv", "jsonl"}
+
+// conflictMutations are the mutations for the on_conflict argument of import_data.
+var conflictMutations = map[string]func(table string, columns []string, values []any) *spanner.Mutation{
+	"error":   spanner.Insert,
+	"update":  spanner.InsertOrUpdate,
+	"replace": spanner.Replace,
+}
+
+func importDataHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs  `mapstructure:",squash"`
+		Table      string `mapstructure:"table"`
+		Source     string `mapstructure:"source"`
+		Format     string `mapstructure:"format"`
+		BatchSize  int    `mapstructure:"batch_size"`
+		OnConflict string `mapstructure:"on_conflict"`
+		DryRun     bool   `mapstructure:"dry_run"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := quoteTableName(req.Table); err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+	if req.BatchSize <= 0 {
+		req.BatchSize = defaultImportBatchSize
+	}
+	if req.BatchSize > maxImportBatchSize {
+		return nil, &validationError{Field: "batch_size", Message: fmt.Sprintf("must not be greater than %d", maxImportBatchSize)}
+	}
+	if req.OnConflict == "" {
+		req.OnConflict = "error"
+	}
+	newMutation, ok := conflictMutations[req.OnConflict]
+	if !ok {
+		return nil, &validationError{Field: "on_conflict", Message: "must be error, update or replace"}
+	}
+	if req.Format == "" {
+		req.Format = strings.TrimPrefix(filepath.Ext(req.Source), ".")
+	}
+	if !slices.Contains(importFormats, req.Format) {
+		return nil, &validationError{Field: "format", Message: fmt.Sprintf("must be one of %v, or the source must have the extension", importFormats)}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	columns, err := tableColumnTypes(ctx, client, req.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := openImportSource(ctx, req.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	rows, err := newImportReader(req.Format, src)
+	if err != nil {
+		return nil, err
+	}
+
+	out := importDataOutput{Table: req.Table, DryRun: req.DryRun}
+	batcher := &mutationBatcher{client: client, size: req.BatchSize, dryRun: req.DryRun}
+	// Columns are checked as they appear, since rows of JSON Lines may have different columns.
+	matchTable := func(pattern string) bool { return matchPattern(pattern, strings.ReplaceAll(req.Table, "`", "")) }
+	checked := make(map[string]bool)
+	var read int64
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row %d: %w", read+1, err)
+		}
+		read++
+
+		for column := range row {
+			if checked[column] {
+				continue
+			}
+			if p, ok := cfg.Access.deniedColumn(column, matchTable); ok {
+				return nil, &validationError{Field: "source", Message: fmt.Sprintf("column %s is denied by access.deny_columns %q of the server config", column, p)}
+			}
+			checked[column] = true
+		}
+
+		m, err := importMutation(newMutation, req.Table, columns, row)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row %d: %w", read, err)
+		}
+		if err := batcher.add(ctx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := batcher.flush(ctx); err != nil {
+		return nil, err
+	}
+	out.Rows, out.Batches = batcher.rows, batcher.batches
+
+	if req.DryRun {
+		return mcp.NewToolResultStructured(out, fmt.Sprintf("Dry run: %d rows are valid and would be imported into %s in %d batches", out.Rows, req.Table, out.Batches)), nil
+	}
+	auditAffectedRows(ctx, out.Rows)
+	out.Transaction = batcher.diagnostics(ctx)
+	text := fmt.Sprintf("Imported %d rows into %s in %d batches", out.Rows, req.Table, out.Batches)
+	return mcp.NewToolResultStructured(out, text+renderTransactionDiagnostics(out.Transaction)), nil
+}
+
+// mutationBatcher commits mutations in batches of size rows, each in a separate transaction.
+// In dry run, batches are only counted.
+type mutationBatcher struct {
+	client *spanner.Client
+	size   int
+	dryRun bool
+
+	batch   []*spanner.Mutation
+	rows    int64
+	batches int
+	aborts  abortRecorder
+}
+
+func (b *mutationBatcher) add(ctx context.Context, m *spanner.Mutation) error {
+	b.batch = append(b.batch, m)
+	if len(b.batch) < b.size {
+		return nil
+	}
+	return b.flush(ctx)
+}
+
+// flush commits the pending mutations.
+func (b *mutationBatcher) flush(ctx context.Context) error {
+	if len(b.batch) == 0 {
+		return nil
+	}
+	if !b.dryRun {
+		// Apply retries aborted transactions in the client library.
+		ts, err := b.client.Apply(b.aborts.context(ctx), b.batch)
+		if err != nil {
+			err = fmt.Errorf("failed to apply batch %d after %d rows are written: %w", b.batches+1, b.rows, err)
+			return withAbortDiagnostics(err, b.aborts.diagnostics(ctx, b.client, err))
+		}
+		auditCommitTimestamps(ctx, ts)
+	}
+	b.rows += int64(len(b.batch))
+	b.batches++
+	b.batch = b.batch[:0]
+	return nil
+}
+
+// diagnostics returns the aborts of the transactions of all batches, or nil if none is aborted.
+func (b *mutationBatcher) diagnostics(ctx context.Context) *transactionDiagnostics {
+	d := b.aborts.diagnostics(ctx, b.client, nil)
+	if d != nil {
+		// Each committed batch is the last attempt of its transaction.
+		d.Attempts = len(d.Aborts) + b.batches
+	}
+	return d
+}
+
+// openImportSource opens a gs:// object or a local file in importDir.
+func openImportSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "gs://") {
+		bucket, object, err := parseGCSURI(source)
+		if err != nil {
+			return nil, &validationError{Field: "source", Message: err.Error()}
+		}
+		storageClient, err := clients.storageClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return storageClient.Bucket(bucket).Object(object).NewReader(ctx)
+	}
+
+	if importDir == "" {
+		return nil, &validationError{Field: "source", Message: "local files are disabled (start the server with --import-dir to import them)"}
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(importDir, filepath.Clean("/"+source)))
+	if err != nil {
+		return nil, err
+	}
+	if !isWithinDir(importDir, path) {
+		return nil, &validationError{Field: "source", Message: fmt.Sprintf("%s is not in the import directory", source)}
+	}
+	return os.Open(path)
+}
+
+// importReader reads rows keyed by column names. Values are strings in CSV and JSON values in JSON Lines.
+type importReader interface {
+	next() (map[string]any, error)
+}
+
+func newImportReader(format string, r io.Reader) (importReader, error) {
+	switch format {
+	case "csv":
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the header: %w", err)
+		}
+		return &csvImportReader{r: cr, header: header}, nil
+	default:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		return &jsonlImportReader{dec: dec}, nil
+	}
+}
+
+type csvImportReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func (r *csvImportReader) next() (map[string]any, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]any, len(record))
+	for i, s := range record {
+		// An empty field is NULL like export_to_gcs.
+		if s != "" {
+			row[r.header[i]] = s
+		} else {
+			row[r.header[i]] = nil
+		}
+	}
+	return row, nil
+}
+
+type jsonlImportReader struct {
+	dec *json.Decoder
+}
+
+func (r *jsonlImportReader) next() (map[string]any, error) {
+	var row map[string]any
+	if err := r.dec.Decode(&row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// tableColumnTypes returns the types of the writable columns of the table from INFORMATION_SCHEMA.
+func tableColumnTypes(ctx context.Context, client *spanner.Client, table string) (map[string]*sppb.Type, error) {
+	schema, name, ok := strings.Cut(strings.ReplaceAll(table, "`", ""), ".")
+	if !ok {
+		schema, name = "", schema
+	}
+
+	rows, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT COLUMN_NAME, SPANNER_TYPE FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND IS_GENERATED = 'NEVER'`,
+		Params: map[string]any{"schema": schema, "table": name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, &validationError{Field: "table", Message: fmt.Sprintf("table %s is not found", table)}
+	}
+
+	columns := make(map[string]*sppb.Type, len(rows))
+	for _, row := range rows {
+		column, _ := row["COLUMN_NAME"].(string)
+		spannerType, _ := row["SPANNER_TYPE"].(string)
+		typ, err := parseSpannerType(spannerType)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		columns[column] = typ
+	}
+	return columns, nil
+}
+
+// parseSpannerType parses SPANNER_TYPE of INFORMATION_SCHEMA.COLUMNS in GoogleSQL, e.g. ARRAY<STRING(MAX)>.
+func parseSpannerType(s string) (*sppb.Type, error) {
+	if elem, ok := strings.CutPrefix(s, "ARRAY<"); ok {
+		// Trailing options like ARRAY<FLOAT32>(vector_length=>128) are ignored.
+		i := strings.LastIndex(elem, ">")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid type %q", s)
+		}
+		elemType, err := parseSpannerType(elem[:i])
+		if err != nil {
+			return nil, err
+		}
+		return &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: elemType}, nil
+	}
+
+	name, _, _ := strings.Cut(s, "(")
+	code, ok := sppb.TypeCode_value[name]
+	if !ok || code == int32(sppb.TypeCode_STRUCT) {
+		// PROTO and ENUM columns are their fully qualified names.
+		return nil, fmt.Errorf("unsupported type %q", s)
+	}
+	return &sppb.Type{Code: sppb.TypeCode(code)}, nil
+}
+
+func importMutation(newMutation func(string, []string, []any) *spanner.Mutation, table string, columns map[string]*sppb.Type, row map[string]any) (*spanner.Mutation, error) {
+	names := lo.Keys(row)
+	slices.Sort(names)
+
+	values := make([]any, len(names))
+	for i, name := range names {
+		typ, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or generated column %s", name)
+		}
+		if typ.GetCode() == sppb.TypeCode_TIMESTAMP && isCommitTimestampPlaceholder(row[name]) {
+			values[i] = spanner.CommitTimestamp
+			continue
+		}
+		v, err := importValue(typ, row[name])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		values[i] = spanner.GenericColumnValue{Type: typ, Value: v}
+	}
+	return newMutation(table, names, values), nil
+}
+
+// importValue converts a value read by importReader into the Spanner value of the type.
+// In CSV, ARRAY is JSON and BYTES is base64 like export_to_gcs.
+func importValue(typ *sppb.Type, v any) (*structpb.Value, error) {
+	if v == nil {
+		return structpb.NewNullValue(), nil
+	}
+
+	switch typ.GetCode() {
+	case sppb.TypeCode_BOOL:
+		switch v := v.(type) {
+		case bool:
+			return structpb.NewBoolValue(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, err
+			}
+			return structpb.NewBoolValue(b), nil
+		}
+	case sppb.TypeCode_INT64:
+		s := fmt.Sprint(v)
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, err
+		}
+		return structpb.NewStringValue(s), nil
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64:
+		f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case math.IsNaN(f):
+			return structpb.NewStringValue("NaN"), nil
+		case math.IsInf(f, 1):
+			return structpb.NewStringValue("Infinity"), nil
+		case math.IsInf(f, -1):
+			return structpb.NewStringValue("-Infinity"), nil
+		}
+		return structpb.NewNumberValue(f), nil
+	case sppb.TypeCode_ARRAY:
+		elems, ok := v.([]any)
+		if s, isString := v.(string); isString {
+			dec := json.NewDecoder(strings.NewReader(s))
+			dec.UseNumber()
+			if err := dec.Decode(&elems); err != nil {
+				return nil, fmt.Errorf("ARRAY must be a JSON array: %w", err)
+			}
+			ok = true
+		}
+		if !ok {
+			break
+		}
+		values := make([]*structpb.Value, len(elems))
+		for i, elem := range elems {
+			value, err := importValue(typ.GetArrayElementType(), elem)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values}), nil
+	case sppb.TypeCode_JSON:
+		if s, ok := v.(string); ok {
+			if !json.Valid([]byte(s)) {
+				return nil, fmt.Errorf("invalid JSON")
+			}
+			return structpb.NewStringValue(s), nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStringValue(string(b)), nil
+	default:
+		// STRING, BYTES(base64), DATE, TIMESTAMP, NUMERIC, INTERVAL and UUID are strings.
+		switch v := v.(type) {
+		case string:
+			return structpb.NewStringValue(v), nil
+		case json.Number:
+			return structpb.NewStringValue(v.String()), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value %v for %s", v, formatType(typ))
+}
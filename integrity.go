@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// columnNameRe matches an unquoted column name.
+var columnNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// informalReference is a reference between tables without a foreign key, e.g. by the naming convention of the application.
+type informalReference struct {
+	Table             string   `mapstructure:"table"`
+	Columns           []string `mapstructure:"columns"`
+	ReferencedTable   string   `mapstructure:"referenced_table"`
+	ReferencedColumns []string `mapstructure:"referenced_columns"`
+}
+
+func checkReferentialIntegrityHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs       `mapstructure:",squash"`
+		Table           string              `mapstructure:"table"`
+		IncludeEnforced bool                `mapstructure:"include_enforced"`
+		References      []informalReference `mapstructure:"references"`
+		Samples         int                 `mapstructure:"samples"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Samples <= 0 {
+		req.Samples = defaultSampleRows
+	}
+	if req.Samples > maxSampleRows {
+		return nil, &validationError{Field: "samples", Message: fmt.Sprintf("must be at most %d", maxSampleRows)}
+	}
+
+	var checks []foreignKeyConstraint
+	for i, ref := range req.References {
+		if len(ref.Columns) == 0 || len(ref.Columns) != len(ref.ReferencedColumns) {
+			return nil, &validationError{Field: "references", Message: fmt.Sprintf("reference %d must have the same number of columns and referenced_columns", i+1)}
+		}
+		for _, c := range slices.Concat(ref.Columns, ref.ReferencedColumns) {
+			if !columnNameRe.MatchString(c) {
+				return nil, &validationError{Field: "references", Message: fmt.Sprintf("reference %d: %q is not a valid column name", i+1, c)}
+			}
+		}
+		for _, t := range []string{ref.Table, ref.ReferencedTable} {
+			if _, err := quoteTableName(t); err != nil {
+				return nil, &validationError{Field: "references", Message: fmt.Sprintf("reference %d: %q is not a valid table name", i+1, t)}
+			}
+			if err := cfg.Access.checkTable("references", t); err != nil {
+				return nil, err
+			}
+		}
+		check := foreignKeyConstraint{
+			Table:             strings.ReplaceAll(ref.Table, "`", ""),
+			Columns:           ref.Columns,
+			ReferencedTable:   strings.ReplaceAll(ref.ReferencedTable, "`", ""),
+			ReferencedColumns: ref.ReferencedColumns,
+		}
+		if column, p, ok := deniedReferenceColumn(check); ok {
+			return nil, &validationError{Field: "references", Message: fmt.Sprintf("reference %d: column %s is denied by access.deny_columns %q of the server config", i+1, column, p)}
+		}
+		checks = append(checks, check)
+	}
+
+	params := map[string]any{}
+	fkFilter := ""
+	if req.Table != "" {
+		if err := cfg.Access.checkTable("table", req.Table); err != nil {
+			return nil, err
+		}
+		schema, name, ok := strings.Cut(strings.ReplaceAll(req.Table, "`", ""), ".")
+		if !ok {
+			schema, name = "", schema
+		}
+		params["schema"], params["table"] = schema, name
+		fkFilter = " AND tc.TABLE_SCHEMA = @schema AND tc.TABLE_NAME = @table"
+	}
+	if !req.IncludeEnforced {
+		fkFilter += " AND tc.ENFORCED = 'NO'"
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	fks, err := queryRows(ctx, client, spanner.Statement{
+		SQL:    foreignKeysSQL + fkFilter + foreignKeysOrder,
+		Params: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := checkReferentialIntegrityOutput{References: []referenceCheck{}}
+	for _, fk := range constraintsOf(nil, fks).ForeignKeys {
+		if _, _, denied := deniedReferenceColumn(fk); denied || cfg.Access.checkTable("table", fk.Table) != nil || cfg.Access.checkTable("table", fk.ReferencedTable) != nil {
+			out.Skipped = append(out.Skipped, fk.Table+"."+fk.Name)
+			continue
+		}
+		checks = append(checks, fk)
+	}
+	if len(checks) == 0 {
+		text := lo.Ternary(req.IncludeEnforced, "No foreign keys", "No NOT ENFORCED foreign keys") + " to check; pass references to check informal references\n"
+		return mcp.NewToolResultStructured(out, text), nil
+	}
+
+	for _, fk := range checks {
+		c, err := checkReference(ctx, client, fk, req.Samples)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", referenceName(fk), err)
+		}
+		out.References = append(out.References, *c)
+	}
+	return mcp.NewToolResultStructured(out, renderReferentialIntegrity(out)), nil
+}
+
+// referenceName returns the name of the foreign key, or the columns of the informal reference.
+func referenceName(fk foreignKeyConstraint) string {
+	if fk.Name != "" {
+		return fk.Table + "." + fk.Name
+	}
+	return fmt.Sprintf("%s(%s) -> %s(%s)", fk.Table, strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "))
+}
+
+// deniedReferenceColumn returns the first referencing or referenced column of the reference denied by access.deny_columns with the pattern.
+func deniedReferenceColumn(fk foreignKeyConstraint) (column, pattern string, denied bool) {
+	check := func(table string, columns []string) (string, string, bool) {
+		for _, c := range columns {
+			if p, ok := cfg.Access.deniedColumn(c, func(pattern string) bool { return matchPattern(pattern, table) }); ok {
+				return table + "." + c, p, true
+			}
+		}
+		return "", "", false
+	}
+	if column, pattern, denied = check(fk.Table, fk.Columns); denied {
+		return column, pattern, denied
+	}
+	return check(fk.ReferencedTable, fk.ReferencedColumns)
+}
+
+// checkReference counts the rows whose referencing columns have no referenced rows by an anti-join, and samples them with
+// their primary keys. Rows with NULL in any referencing column are not orphans, as foreign keys don't check them.
+func checkReference(ctx context.Context, client *spanner.Client, fk foreignKeyConstraint, samples int) (*referenceCheck, error) {
+	table, err := quoteTableName(fk.Table)
+	if err != nil {
+		return nil, err
+	}
+	referenced, err := quoteTableName(fk.ReferencedTable)
+	if err != nil {
+		return nil, err
+	}
+	primaryKey, _, err := primaryKeyTypes(ctx, client, fk.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := func(alias string) func(string, int) string {
+		return func(column string, _ int) string { return alias + ".`" + column + "`" }
+	}
+	columns := lo.Map(fk.Columns, quote("c"))
+	conditions := lo.Map(columns, func(c string, _ int) string { return c + " IS NOT NULL" })
+	joins := lo.Map(fk.ReferencedColumns, func(column string, i int) string { return quote("p")(column, i) + " = " + columns[i] })
+	orphans := fmt.Sprintf("FROM %s AS c\nWHERE %s\n  AND NOT EXISTS (SELECT 1 FROM %s AS p WHERE %s)",
+		table, strings.Join(conditions, " AND "), referenced, strings.Join(joins, " AND "))
+
+	count := fmt.Sprintf("SELECT COUNT(*) AS ORPHANED_ROWS, COUNT(DISTINCT TO_JSON_STRING(STRUCT(%s))) AS MISSING_KEYS\n%s", strings.Join(columns, ", "), orphans)
+	rows, err := queryRows(ctx, client, spanner.NewStatement(count))
+	if err != nil {
+		return nil, err
+	}
+	c := &referenceCheck{foreignKeyConstraint: fk, Informal: fk.Name == "", SQL: count}
+	c.OrphanedRows, _ = rows[0]["ORPHANED_ROWS"].(int64)
+	c.MissingKeys, _ = rows[0]["MISSING_KEYS"].(int64)
+	if c.OrphanedRows == 0 {
+		return c, nil
+	}
+
+	// Samples have the primary key and the referencing columns. Denied columns are omitted and masked columns are masked.
+	matchTable := func(pattern string) bool { return matchPattern(pattern, fk.Table) }
+	c.SampleColumns = lo.Reject(lo.Uniq(append(primaryKey, fk.Columns...)), func(column string, _ int) bool {
+		_, denied := cfg.Access.deniedColumn(column, matchTable)
+		return denied
+	})
+	if len(c.SampleColumns) == 0 {
+		return c, nil
+	}
+	c.Samples, err = queryRows(ctx, client, spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s\n%s\nLIMIT @limit", strings.Join(lo.Map(c.SampleColumns, quote("c")), ", "), orphans),
+		Params: map[string]any{"limit": samples},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range c.SampleColumns {
+		if method := cfg.Masking.method(column, matchTable); method != "" {
+			for _, row := range c.Samples {
+				row[column] = cfg.Masking.mask(method, row[column])
+			}
+		}
+	}
+	return c, nil
+}
+
+func renderReferentialIntegrity(out checkReferentialIntegrityOutput) string {
+	var b strings.Builder
+	table := newTable(&b)
+	table.SetHeader([]string{"Reference", "Enforcement", "Orphaned rows", "Missing keys"})
+	for _, c := range out.References {
+		enforcement := lo.Ternary(c.Informal, "informal", lo.Ternary(c.Enforced, "ENFORCED", "NOT ENFORCED"))
+		table.Append([]string{referenceName(c.foreignKeyConstraint), enforcement, fmt.Sprint(c.OrphanedRows), fmt.Sprint(c.MissingKeys)})
+	}
+	table.Render()
+
+	for _, c := range out.References {
+		if len(c.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\nSamples of orphaned rows of %s:\n", referenceName(c.foreignKeyConstraint))
+		sample := newTable(&b)
+		sample.SetHeader(c.SampleColumns)
+		for _, row := range c.Samples {
+			sample.Append(lo.Map(c.SampleColumns, func(column string, _ int) string { return fmt.Sprint(row[column]) }))
+		}
+		sample.Render()
+	}
+	if len(out.Skipped) > 0 {
+		fmt.Fprintf(&b, "\nSkipped foreign keys of denied tables: %s\n", strings.Join(out.Skipped, ", "))
+	}
+	if lo.SomeBy(out.References, func(c referenceCheck) bool { return c.OrphanedRows > 0 }) {
+		b.WriteString("\nDelete or fix the orphaned rows before enforcing the foreign keys, or adding foreign keys for informal references, because Spanner validates existing rows when they are added.\n")
+	}
+	return b.String()
+}
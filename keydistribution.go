@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// Limits of key_distribution. Each bucket is counted by a key range scan which reads at most max_rows_per_bucket rows.
+const (
+	defaultKeyBuckets           = 10
+	maxKeyBuckets               = 100
+	defaultMaxRowsPerKeyBucket  = 10000
+	maxMaxRowsPerKeyBucket      = 1000000
+	keyDistributionSkewRatio    = 3
+	keyDistributionSequentialBy = 2
+)
+
+// keySuffixDigits is the number of characters after the common prefix of the minimum and maximum keys
+// used to interpolate boundaries of STRING and BYTES keys.
+const keySuffixDigits = 8
+
+// monotonicStringRe matches STRING keys which look like timestamps, dates or zero-padded numbers.
+var monotonicStringRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|\d{8,})`)
+
+func keyDistributionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs        `mapstructure:",squash"`
+		Table            string `mapstructure:"table"`
+		Buckets          int    `mapstructure:"buckets"`
+		MaxRowsPerBucket int64  `mapstructure:"max_rows_per_bucket"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.Buckets == 0 {
+		req.Buckets = defaultKeyBuckets
+	}
+	if req.Buckets < 1 || req.Buckets > maxKeyBuckets {
+		return nil, &validationError{Field: "buckets", Message: fmt.Sprintf("must be between 1 and %d", maxKeyBuckets)}
+	}
+	if req.MaxRowsPerBucket == 0 {
+		req.MaxRowsPerBucket = defaultMaxRowsPerKeyBucket
+	}
+	if req.MaxRowsPerBucket < 1 || req.MaxRowsPerBucket > maxMaxRowsPerKeyBucket {
+		return nil, &validationError{Field: "max_rows_per_bucket", Message: fmt.Sprintf("must be between 1 and %d", maxMaxRowsPerKeyBucket)}
+	}
+
+	table, err := quoteTableName(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	name := strings.ReplaceAll(req.Table, "`", "")
+	schema, tableName, ok := strings.Cut(name, ".")
+	if !ok {
+		schema, tableName = "", schema
+	}
+	keys, err := primaryKeyColumns(ctx, client, schema, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, &validationError{Field: "table", Message: fmt.Sprintf("table %s is not found or has no primary key", name)}
+	}
+	matchTable := func(pattern string) bool { return matchPattern(pattern, name) }
+	if p, ok := cfg.Access.deniedColumn(keys[0], matchTable); ok {
+		return nil, &validationError{Field: "table", Message: fmt.Sprintf("the first key column %s of %s is denied by access.deny_columns %q of the server config", keys[0], name, p)}
+	}
+	// Boundaries of a masked key are masked, so only the distribution is returned.
+	formatKey := formatKeyValue
+	if method := cfg.Masking.method(keys[0], matchTable); method != "" {
+		formatKey = func(v any) string { return fmt.Sprint(cfg.Masking.mask(method, v)) }
+	}
+
+	// All ranges are read in the same snapshot, so the buckets add up to the table at a timestamp.
+	txn := client.ReadOnlyTransaction()
+	defer txn.Close()
+
+	key := "`" + keys[0] + "`"
+	out := keyDistributionOutput{Table: name, KeyColumns: keys, Buckets: []keyBucket{}, Findings: []lintFinding{}}
+	minKey, maxKey, err := keyBounds(ctx, txn, table, key)
+	if err != nil {
+		return nil, err
+	}
+	if minKey == nil {
+		out.Note = fmt.Sprintf("%s has no rows with non-NULL %s", name, keys[0])
+		return mcp.NewToolResultStructured(out, out.Note+"\n"), nil
+	}
+	code := minKey.Type.GetCode()
+	out.KeyType = formatType(minKey.Type)
+
+	boundaries, err := keyBoundaries(minKey, maxKey, req.Buckets)
+	if err != nil {
+		return nil, &validationError{Field: "table", Message: err.Error()}
+	}
+	out.Min, out.Max = formatKey(boundaries[0]), formatKey(boundaries[len(boundaries)-1])
+	for i := range len(boundaries) - 1 {
+		// The last bucket includes the maximum key.
+		op := "<"
+		if i == len(boundaries)-2 {
+			op = "<="
+		}
+		count, err := countKeyRange(ctx, txn, spanner.Statement{
+			SQL:    fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM %s WHERE %s >= @start AND %s %s @end LIMIT @limit)", table, key, key, op),
+			Params: map[string]any{"start": boundaries[i], "end": boundaries[i+1], "limit": req.MaxRowsPerBucket},
+		})
+		if err != nil {
+			return nil, err
+		}
+		out.Buckets = append(out.Buckets, keyBucket{
+			Start:     formatKey(boundaries[i]),
+			End:       formatKey(boundaries[i+1]),
+			Rows:      count,
+			Saturated: count >= req.MaxRowsPerBucket,
+		})
+		out.SampledRows += count
+		sendProgressNotification(ctx, request, i+1, len(boundaries)-1)
+	}
+	if out.SampledRows > 0 {
+		for i := range out.Buckets {
+			out.Buckets[i].Percent = float64(out.Buckets[i].Rows) * 100 / float64(out.SampledRows)
+		}
+	}
+	out.SkewRatio, out.Findings = keyDistributionFindings(name, keys[0], code, boundaries, out.Buckets, formatKey)
+	if code == sppb.TypeCode_STRING {
+		out.Note = "Boundaries of STRING keys are interpolated over ASCII characters, so buckets of non-ASCII keys are uneven"
+	}
+	return mcp.NewToolResultStructured(out, renderKeyDistribution(out)), nil
+}
+
+// keyBounds returns the minimum and maximum non-NULL values of the first key column, or nil if there are no such rows.
+func keyBounds(ctx context.Context, txn *spanner.ReadOnlyTransaction, table, key string) (minKey, maxKey *spanner.GenericColumnValue, err error) {
+	bound := func(order string) (*spanner.GenericColumnValue, error) {
+		var v *spanner.GenericColumnValue
+		err := retry(ctx, func(ctx context.Context) error {
+			v = nil
+			return txn.Query(ctx, spanner.NewStatement(fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL ORDER BY %s %s LIMIT 1", key, table, key, key, order))).Do(func(row *spanner.Row) error {
+				var gcv spanner.GenericColumnValue
+				if err := row.Column(0, &gcv); err != nil {
+					return err
+				}
+				v = &gcv
+				return nil
+			})
+		})
+		return v, err
+	}
+	if minKey, err = bound("ASC"); err != nil || minKey == nil {
+		return nil, nil, err
+	}
+	if maxKey, err = bound("DESC"); err != nil {
+		return nil, nil, err
+	}
+	return minKey, maxKey, nil
+}
+
+func countKeyRange(ctx context.Context, txn *spanner.ReadOnlyTransaction, stmt spanner.Statement) (int64, error) {
+	var n int64
+	err := retry(ctx, func(ctx context.Context) error {
+		return txn.Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			return row.Column(0, &n)
+		})
+	})
+	return n, err
+}
+
+// keyBoundaries divides the range between the minimum and maximum keys into n buckets of equal width, and returns
+// n+1 boundaries as query parameters. Buckets are fewer than n if the range of INT64 or DATE keys is narrower.
+func keyBoundaries(minKey, maxKey *spanner.GenericColumnValue, n int) ([]any, error) {
+	switch minKey.Type.GetCode() {
+	case sppb.TypeCode_INT64:
+		var a, b int64
+		if err := decodeKeys(minKey, maxKey, &a, &b); err != nil {
+			return nil, err
+		}
+		return integerBoundaries(big.NewInt(a), big.NewInt(b), n, func(v *big.Int) any { return v.Int64() }), nil
+	case sppb.TypeCode_FLOAT64:
+		var a, b float64
+		if err := decodeKeys(minKey, maxKey, &a, &b); err != nil {
+			return nil, err
+		}
+		if a == b || math.IsInf(a, 0) || math.IsInf(b, 0) || math.IsNaN(a) || math.IsNaN(b) {
+			return []any{a, b}, nil
+		}
+		return lo.Map(lo.Range(n+1), func(i int, _ int) any {
+			if i == n {
+				return b
+			}
+			f := float64(i) / float64(n)
+			return a*(1-f) + b*f
+		}), nil
+	case sppb.TypeCode_TIMESTAMP:
+		var a, b time.Time
+		if err := decodeKeys(minKey, maxKey, &a, &b); err != nil {
+			return nil, err
+		}
+		bounds := integerBoundaries(big.NewInt(a.UnixMicro()), big.NewInt(b.UnixMicro()), n, func(v *big.Int) any { return time.UnixMicro(v.Int64()).UTC() })
+		// The boundaries of the microseconds truncate nanoseconds of the keys.
+		bounds[0], bounds[len(bounds)-1] = a, b
+		return bounds, nil
+	case sppb.TypeCode_DATE:
+		var a, b civil.Date
+		if err := decodeKeys(minKey, maxKey, &a, &b); err != nil {
+			return nil, err
+		}
+		return integerBoundaries(big.NewInt(0), big.NewInt(int64(b.DaysSince(a))), n, func(v *big.Int) any { return a.AddDays(int(v.Int64())) }), nil
+	case sppb.TypeCode_STRING:
+		var a, b string
+		if err := decodeKeys(minKey, maxKey, &a, &b); err != nil {
+			return nil, err
+		}
+		return suffixBoundaries([]byte(a), []byte(b), n, 128, func(s []byte) any { return string(s) }), nil
+	case sppb.TypeCode_BYTES:
+		var a, b []byte
+		if err := decodeKeys(minKey, maxKey, &a, &b); err != nil {
+			return nil, err
+		}
+		return suffixBoundaries(a, b, n, 256, func(s []byte) any { return s }), nil
+	default:
+		return nil, fmt.Errorf("key distribution of the first key column of %s is not supported", formatType(minKey.Type))
+	}
+}
+
+func decodeKeys(minKey, maxKey *spanner.GenericColumnValue, a, b any) error {
+	if err := minKey.Decode(a); err != nil {
+		return err
+	}
+	return maxKey.Decode(b)
+}
+
+// integerBoundaries returns boundaries of equal width between a and b, removing duplicates of narrow ranges.
+func integerBoundaries(a, b *big.Int, n int, value func(*big.Int) any) []any {
+	span := new(big.Int).Sub(b, a)
+	var bounds []any
+	var prev *big.Int
+	for i := range n + 1 {
+		v := new(big.Int).Mul(span, big.NewInt(int64(i)))
+		v.Quo(v, big.NewInt(int64(n))).Add(v, a)
+		if prev != nil && v.Cmp(prev) == 0 {
+			continue
+		}
+		bounds = append(bounds, value(v))
+		prev = v
+	}
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	return bounds
+}
+
+// suffixBoundaries interpolates keySuffixDigits characters after the common prefix of a and b as digits of the base.
+// Bytes over the base are clamped, so boundaries of STRING keys are valid UTF-8.
+func suffixBoundaries(a, b []byte, n, base int, value func([]byte) any) []any {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	digits := func(s []byte) *big.Int {
+		v := new(big.Int)
+		for i := range keySuffixDigits {
+			d := 0
+			if prefix+i < len(s) {
+				d = min(int(s[prefix+i]), base-1)
+			}
+			v.Mul(v, big.NewInt(int64(base))).Add(v, big.NewInt(int64(d)))
+		}
+		return v
+	}
+	bounds := integerBoundaries(digits(a), digits(b), n, func(v *big.Int) any {
+		suffix := make([]byte, keySuffixDigits)
+		for i := keySuffixDigits - 1; i >= 0; i-- {
+			var d big.Int
+			v, _ = new(big.Int).QuoRem(v, big.NewInt(int64(base)), &d)
+			suffix[i] = byte(d.Int64())
+		}
+		// Trailing zeros don't change the order of boundaries.
+		return value(append(bytes.Clone(a[:prefix]), bytes.TrimRight(suffix, "\x00")...))
+	})
+	bounds[0], bounds[len(bounds)-1] = value(a), value(b)
+	return bounds
+}
+
+// formatKeyValue formats a boundary like a GoogleSQL literal.
+func formatKeyValue(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case civil.Date:
+		return v.String()
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return "b" + strconv.Quote(string(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// keyDistributionFindings reports hotspot-prone patterns of the first key column and skew of the buckets.
+// The skew ratio is the rows of the largest bucket divided by the average of buckets. Boundaries in messages are formatted by formatKey.
+func keyDistributionFindings(table, key string, code sppb.TypeCode, boundaries []any, buckets []keyBucket, formatKey func(any) string) (float64, []lintFinding) {
+	findings := []lintFinding{}
+	const suggestion = "Use a UUID, a bit-reversed sequence or a hash-based shard column as the first key column to distribute writes across splits"
+	monotonic := func(message string) {
+		findings = append(findings, lintFinding{Rule: "monotonic-key", Severity: "warning", Message: message, Suggestion: suggestion})
+	}
+
+	var rows int64
+	var saturated bool
+	for _, b := range buckets {
+		rows += b.Rows
+		saturated = saturated || b.Saturated
+	}
+
+	switch code {
+	case sppb.TypeCode_TIMESTAMP, sppb.TypeCode_DATE:
+		monotonic(fmt.Sprintf("The first key column %s of %s is %s, so inserts of recent values concentrate on the last split (hotspot)", key, table, code.String()))
+	case sppb.TypeCode_INT64:
+		// Sequential IDs fill the key range densely, while bit-reversed sequences and hashes spread over the whole INT64 range.
+		first, last := boundaries[0].(int64), boundaries[len(boundaries)-1].(int64)
+		width := new(big.Int).Sub(big.NewInt(last), big.NewInt(first))
+		if !saturated && rows >= 2 && width.Cmp(big.NewInt(rows*keyDistributionSequentialBy)) < 0 {
+			monotonic(fmt.Sprintf("%d rows of %s have keys %s between %s and %s, which look like sequential IDs and concentrate inserts on the last split (hotspot)", rows, table, key, formatKey(first), formatKey(last)))
+		}
+	case sppb.TypeCode_STRING:
+		first, last := boundaries[0].(string), boundaries[len(boundaries)-1].(string)
+		if monotonicStringRe.MatchString(first) && monotonicStringRe.MatchString(last) {
+			monotonic(fmt.Sprintf("Keys %s of %s start with dates or numbers like %s, which often increase monotonically and concentrate inserts on the last split (hotspot)", key, table, formatKey(last)))
+		}
+	}
+
+	if rows == 0 || len(buckets) < 2 {
+		return 0, findings
+	}
+	largest := lo.MaxBy(buckets, func(a, b keyBucket) bool { return a.Rows > b.Rows })
+	ratio := float64(largest.Rows) * float64(len(buckets)) / float64(rows)
+	if ratio >= keyDistributionSkewRatio {
+		findings = append(findings, lintFinding{
+			Rule:       "skew",
+			Severity:   "warning",
+			Message:    fmt.Sprintf("%.0f%% of sampled rows of %s are in the key range [%s, %s], %.1f times the average of %d buckets", largest.Percent, table, largest.Start, largest.End, ratio, len(buckets)),
+			Suggestion: "Skewed keys concentrate reads and writes of the range on a few splits. Check whether the first key column has a dominant value or prefix",
+		})
+	}
+	if empty := lo.CountBy(buckets, func(b keyBucket) bool { return b.Rows == 0 }); empty*2 >= len(buckets) {
+		findings = append(findings, lintFinding{
+			Rule:     "sparse",
+			Severity: "info",
+			Message:  fmt.Sprintf("%d of %d key ranges of %s are empty, so keys are clustered in a few ranges", empty, len(buckets), table),
+		})
+	}
+	if saturated {
+		findings = append(findings, lintFinding{
+			Rule:     "saturated",
+			Severity: "info",
+			Message:  "Some buckets reached max_rows_per_bucket, so their counts and the skew are lower bounds",
+		})
+	}
+	return ratio, findings
+}
+
+func renderKeyDistribution(out keyDistributionOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Key distribution of %s by %s (%s) from %s to %s\n", out.Table, out.KeyColumns[0], out.KeyType, out.Min, out.Max)
+	largest := lo.Max(lo.Map(out.Buckets, func(bucket keyBucket, _ int) int64 { return bucket.Rows }))
+	for _, bucket := range out.Buckets {
+		bar := 0
+		if largest > 0 {
+			bar = int(bucket.Rows * 40 / largest)
+		}
+		count := strconv.FormatInt(bucket.Rows, 10)
+		if bucket.Saturated {
+			count += "+"
+		}
+		fmt.Fprintf(&b, "  [%s, %s]\t%s\t%5.1f%%\t%s\n", bucket.Start, bucket.End, count, bucket.Percent, strings.Repeat("#", bar))
+	}
+	fmt.Fprintf(&b, "%d sampled rows, skew ratio %.1f\n", out.SampledRows, out.SkewRatio)
+	for _, f := range out.Findings {
+		fmt.Fprintf(&b, "%s [%s]: %s\n", strings.ToUpper(f.Severity), f.Rule, f.Message)
+		if f.Suggestion != "" {
+			fmt.Fprintf(&b, "  Suggestion: %s\n", f.Suggestion)
+		}
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "Note: %s\n", out.Note)
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+)
+
+// limitOptions configures limits of tool calls. Zero values mean unlimited.
+//
+//	limits:
+//	  max_concurrent_calls: 20
+//	  tools:
+//	    update_ddl:
+//	      max_concurrent_calls: 2
+//	    plan:
+//	      qps: 10
+//	      burst: 20
+type limitOptions struct {
+	MaxConcurrentCalls int64                  `yaml:"max_concurrent_calls"`
+	Tools              map[string]*toolLimits `yaml:"tools"`
+}
+
+type toolLimits struct {
+	MaxConcurrentCalls int64   `yaml:"max_concurrent_calls"`
+	QPS                float64 `yaml:"qps"`
+	// Burst is the number of calls allowed at once above qps. It defaults to 1.
+	Burst int `yaml:"burst"`
+}
+
+// limiter is a concurrency cap and a rate limit.
+type limiter struct {
+	sem  *semaphore.Weighted
+	rate *rate.Limiter
+}
+
+func newLimiter(maxConcurrent int64, qps float64, burst int) *limiter {
+	var l limiter
+	if maxConcurrent > 0 {
+		l.sem = semaphore.NewWeighted(maxConcurrent)
+	}
+	if qps > 0 {
+		l.rate = rate.NewLimiter(rate.Limit(qps), max(burst, 1))
+	}
+	return &l
+}
+
+// acquire waits until the call is allowed and returns the function to release it.
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	if err := l.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	return func() { l.sem.Release(1) }, nil
+}
+
+// limitMiddleware returns a middleware which applies the global and per-tool limits.
+// Calls exceeding the limits wait until they are allowed or the tool call is cancelled.
+func limitMiddleware(opts limitOptions) server.ToolHandlerMiddleware {
+	global := newLimiter(opts.MaxConcurrentCalls, 0, 0)
+	tools := make(map[string]*limiter, len(opts.Tools))
+	for name, t := range opts.Tools {
+		tools[name] = newLimiter(t.MaxConcurrentCalls, t.QPS, t.Burst)
+	}
+
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			name := request.Params.Name
+			if name == "replay" {
+				// The replayed call is limited by itself.
+				return next(ctx, request)
+			}
+			for _, l := range []*limiter{tools[name], global} {
+				if l == nil {
+					continue
+				}
+				release, err := l.acquire(ctx)
+				if err != nil {
+					slog.WarnContext(ctx, "tool call is not allowed by limits", "tool", name, "error", err)
+					return nil, fmt.Errorf("tool call %s is limited: %w", name, err)
+				}
+				defer release()
+			}
+			return next(ctx, request)
+		}
+	}
+}
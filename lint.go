@@ -0,0 +1,401 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// Severities of lint findings in descending order.
+var lintSeverities = []string{"error", "warning", "info"}
+
+// maxLintExamples is the number of examples in a finding which is found many times.
+const maxLintExamples = 3
+
+func lintQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Query     string `mapstructure:"query"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := scanSQL(req.Query)
+	var findings []lintFinding
+	for _, rule := range []func([]sqlToken) []lintFinding{lintParameterization, lintSelectStar, lintNonSargable, lintLeadingWildcard} {
+		findings = append(findings, rule(tokens)...)
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// The plan reflects the schema, e.g. keys and indexes, so full scans are found by the plan.
+	qp, err := analyzeStatement(ctx, target, client, req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan the query: %w", err)
+	}
+	findings = append(findings, lintFullScans(qp)...)
+
+	monotonic, err := lintMonotonicKey(ctx, client, tokens)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, monotonic...)
+
+	slices.SortStableFunc(findings, func(a, b lintFinding) int {
+		return cmp.Compare(slices.Index(lintSeverities, a.Severity), slices.Index(lintSeverities, b.Severity))
+	})
+
+	out := lintQueryOutput{Findings: findings}
+	if len(findings) == 0 {
+		return mcp.NewToolResultStructured(out, "No findings"), nil
+	}
+	var b strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n  Suggestion: %s\n", f.Severity, f.Rule, f.Message, f.Suggestion)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+func isDML(statement string) bool {
+	words := statementWords(statement)
+	return len(words) > 0 && slices.ContainsFunc([]string{"INSERT", "UPDATE", "DELETE"}, words[0].isKeyword)
+}
+
+var comparisonSymbols = []string{"=", "!=", "<>", "<", ">", "<=", ">="}
+
+// isComparison reports whether the token compares its operands.
+func (t sqlToken) isComparison() bool {
+	if t.kind == sqlSymbol {
+		return slices.Contains(comparisonSymbols, t.text)
+	}
+	return t.isKeyword("LIKE") || t.isKeyword("IN") || t.isKeyword("BETWEEN")
+}
+
+// lintParameterization finds literals compared in predicates and values of IN lists and VALUES.
+func lintParameterization(tokens []sqlToken) []lintFinding {
+	var literals []string
+	// lists are the depths of parentheses opened by IN or VALUES.
+	lists := make(map[int]bool)
+	for i, t := range tokens {
+		if t.kind == sqlSymbol && t.text == "(" {
+			// Following tuples of VALUES are preceded by commas.
+			if i == 0 || tokens[i-1].text != "," {
+				lists[t.depth+1] = i > 0 && (tokens[i-1].isKeyword("IN") || tokens[i-1].isKeyword("VALUES"))
+			}
+			continue
+		}
+		if t.kind != sqlString && t.kind != sqlNumber || i == 0 {
+			continue
+		}
+		prev := tokens[i-1]
+		if prev.isComparison() || prev.isKeyword("AND") && lo.ContainsBy(tokens[max(0, i-4):i], func(t sqlToken) bool { return t.isKeyword("BETWEEN") }) ||
+			lists[t.depth] && (prev.text == "(" || prev.text == ",") {
+			literals = append(literals, t.text)
+		}
+	}
+	if len(literals) == 0 {
+		return nil
+	}
+	return []lintFinding{{
+		Rule:       "parameterization",
+		Severity:   "warning",
+		Message:    fmt.Sprintf("%d literal values are in the query, e.g. %s", len(literals), strings.Join(lo.Slice(literals, 0, maxLintExamples), ", ")),
+		Suggestion: "Use query parameters like @name instead of literals, so Spanner reuses the cached query plan and values are not injected into SQL",
+	}}
+}
+
+// lintSelectStar finds * and t.* in select lists.
+func lintSelectStar(tokens []sqlToken) []lintFinding {
+	for i, t := range tokens {
+		if t.kind != sqlSymbol || t.text != "*" || i == 0 {
+			continue
+		}
+		prev := tokens[i-1]
+		if prev.isKeyword("SELECT") || prev.isKeyword("DISTINCT") || prev.isKeyword("ALL") || prev.isKeyword("RETURN") || prev.isKeyword("RETURNING") ||
+			prev.text == "," || prev.text == "." {
+			return []lintFinding{{
+				Rule:       "select-star",
+				Severity:   "info",
+				Message:    "SELECT * reads all columns including large ones, and the result changes when columns are added",
+				Suggestion: "List only the columns you need, which may also let secondary indexes cover the query without a back join",
+			}}
+		}
+	}
+	return nil
+}
+
+// nonSargableFunctions are functions which prevent seeks by keys and indexes when applied to columns in predicates.
+var nonSargableFunctions = []string{
+	"LOWER", "UPPER", "CAST", "SAFE_CAST", "EXTRACT", "DATE", "TIMESTAMP_TRUNC", "DATE_TRUNC", "FORMAT_TIMESTAMP", "FORMAT_DATE",
+	"SUBSTR", "SUBSTRING", "TRIM", "LTRIM", "RTRIM", "CONCAT", "COALESCE", "IFNULL", "ABS", "LENGTH", "CHAR_LENGTH",
+	"UNIX_SECONDS", "UNIX_MILLIS", "UNIX_MICROS",
+}
+
+// nonColumnWords are keywords which appear in arguments of nonSargableFunctions.
+var nonColumnWords = []string{
+	"AS", "FROM", "AT", "TIME", "ZONE", "TRUE", "FALSE", "NULL",
+	"MICROSECOND", "MILLISECOND", "SECOND", "MINUTE", "HOUR", "DAY", "DAYOFWEEK", "DAYOFYEAR", "WEEK", "ISOWEEK", "MONTH", "QUARTER", "YEAR", "ISOYEAR",
+	"BOOL", "INT64", "FLOAT32", "FLOAT64", "NUMERIC", "STRING", "BYTES", "DATE", "TIMESTAMP", "JSON", "INTERVAL", "UUID",
+}
+
+// lintNonSargable finds functions of columns compared in predicates, e.g. LOWER(Name) = @name.
+func lintNonSargable(tokens []sqlToken) []lintFinding {
+	var calls []string
+	for i := 0; i+1 < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != sqlWord || tokens[i+1].text != "(" || !slices.ContainsFunc(nonSargableFunctions, t.isKeyword) {
+			continue
+		}
+		end := slices.IndexFunc(tokens[i+2:], func(u sqlToken) bool { return u.text == ")" && u.depth == tokens[i+1].depth })
+		if end < 0 {
+			break
+		}
+		end += i + 2
+
+		args := tokens[i+2 : end]
+		hasColumn := slices.ContainsFunc(args, func(u sqlToken) bool {
+			return u.kind == sqlWord && !slices.ContainsFunc(nonColumnWords, u.isKeyword)
+		})
+		compared := i > 0 && tokens[i-1].isComparison() || end+1 < len(tokens) && tokens[end+1].isComparison()
+		if hasColumn && compared {
+			calls = append(calls, fmt.Sprintf("%s(...)", strings.ToUpper(t.text)))
+		}
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+	return []lintFinding{{
+		Rule:       "non-sargable",
+		Severity:   "warning",
+		Message:    fmt.Sprintf("Functions of columns are compared in predicates, e.g. %s, which can't seek by keys or indexes of the columns", strings.Join(lo.Slice(lo.Uniq(calls), 0, maxLintExamples), ", ")),
+		Suggestion: "Compare the column itself, e.g. a range of the column instead of a function of it, or index a stored generated column of the expression",
+	}}
+}
+
+// lintLeadingWildcard finds LIKE patterns starting with %.
+func lintLeadingWildcard(tokens []sqlToken) []lintFinding {
+	for i := 1; i < len(tokens); i++ {
+		t := tokens[i]
+		if tokens[i-1].isKeyword("LIKE") && t.kind == sqlString && strings.HasPrefix(strings.Trim(t.text, `'"`), "%") {
+			return []lintFinding{{
+				Rule:       "leading-wildcard",
+				Severity:   "warning",
+				Message:    fmt.Sprintf("LIKE %s has a leading wildcard, which scans all values instead of a prefix range", t.text),
+				Suggestion: "Use a prefix pattern or STARTS_WITH if possible, or a search index with SEARCH_SUBSTRING for substring search",
+			}}
+		}
+	}
+	return nil
+}
+
+// lintFullScans finds scans whose metadata has Full scan: true in the query plan.
+func lintFullScans(qp *sppb.QueryPlan) []lintFinding {
+	var findings []lintFinding
+	for _, scan := range planScans(qp) {
+		if !scan.full {
+			continue
+		}
+		findings = append(findings, lintFinding{
+			Rule:       "full-scan",
+			Severity:   "warning",
+			Message:    fmt.Sprintf("The query plan has a full scan of the %s %s (node %d)", scan.kind, scan.target, scan.index),
+			Suggestion: "Add predicates on the leading key columns, or create a secondary index on the filtered columns and check the plan uses it",
+		})
+	}
+	return findings
+}
+
+// planScan is a Scan node of a query plan.
+type planScan struct {
+	index  int32
+	target string
+
+	// kind is table, index or batch, which scans intermediate results.
+	kind string
+	full bool
+
+	// columns are the scanned columns, which are the variables of the child links.
+	columns []string
+}
+
+func planScans(qp *sppb.QueryPlan) []planScan {
+	var scans []planScan
+	for _, node := range qp.GetPlanNodes() {
+		if node.GetDisplayName() != "Scan" {
+			continue
+		}
+		fields := node.GetMetadata().GetFields()
+		kind := "table"
+		switch fields["scan_type"].GetStringValue() {
+		case "IndexScan":
+			kind = "index"
+		case "BatchScan":
+			kind = "batch"
+		}
+		scans = append(scans, planScan{
+			index:  node.GetIndex(),
+			target: fields["scan_target"].GetStringValue(),
+			kind:   kind,
+			full:   fields["Full scan"].GetStringValue() == "true",
+			columns: lo.FilterMap(node.GetChildLinks(), func(link *sppb.PlanNode_ChildLink, _ int) (string, bool) {
+				return link.GetVariable(), link.GetVariable() != ""
+			}),
+		})
+	}
+	return scans
+}
+
+// monotonicFunctions generate increasing values.
+var monotonicFunctions = []string{"CURRENT_TIMESTAMP", "PENDING_COMMIT_TIMESTAMP", "CURRENT_DATE", "UNIX_MICROS", "UNIX_MILLIS", "UNIX_SECONDS"}
+
+// lintMonotonicKey finds INSERT statements whose first key column is a timestamp, or whose values of the column increase.
+func lintMonotonicKey(ctx context.Context, client *spanner.Client, tokens []sqlToken) ([]lintFinding, error) {
+	table, columns, tuples, ok := parseInsert(tokens)
+	if !ok {
+		return nil, nil
+	}
+
+	schema, name, found := strings.Cut(table, ".")
+	if !found {
+		schema, name = "", schema
+	}
+	keys, err := primaryKeyColumns(ctx, client, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	types, err := tableColumnTypes(ctx, client, table)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys[0]
+	finding := lintFinding{
+		Rule:       "monotonic-key",
+		Severity:   "warning",
+		Suggestion: "Use a UUID, a bit-reversed sequence or a hash-based shard column as the first key column to distribute writes across splits",
+	}
+	switch types[key].GetCode() {
+	case sppb.TypeCode_TIMESTAMP, sppb.TypeCode_DATE:
+		finding.Message = fmt.Sprintf("The first key column %s of %s is %s, so inserts of recent values concentrate on a single split (hotspot)", key, table, formatType(types[key]))
+		return []lintFinding{finding}, nil
+	}
+
+	i := slices.IndexFunc(columns, func(c string) bool { return strings.EqualFold(c, key) })
+	if i < 0 {
+		return nil, nil
+	}
+
+	var numbers []int64
+	for _, tuple := range tuples {
+		if i >= len(tuple) {
+			return nil, nil
+		}
+		if f, ok := lo.Find(tuple[i], func(t sqlToken) bool { return slices.ContainsFunc(monotonicFunctions, t.isKeyword) }); ok {
+			finding.Message = fmt.Sprintf("The first key column %s of %s is a value of %s, which increases monotonically and causes a hotspot", key, table, strings.ToUpper(f.text))
+			return []lintFinding{finding}, nil
+		}
+		if len(tuple[i]) == 1 && tuple[i][0].kind == sqlNumber {
+			if n, err := strconv.ParseInt(tuple[i][0].text, 10, 64); err == nil {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	if len(numbers) >= 2 && len(numbers) == len(tuples) && slices.IsSorted(numbers) && numbers[0] != numbers[len(numbers)-1] {
+		finding.Message = fmt.Sprintf("Values of the first key column %s of %s are sequential, which concentrates inserts on a single split (hotspot)", key, table)
+		return []lintFinding{finding}, nil
+	}
+	return nil, nil
+}
+
+// parseInsert parses INSERT [OR UPDATE|OR IGNORE] [INTO] table (columns) VALUES (values), ... and returns tokens of each value.
+// columns and tuples are empty for INSERT ... SELECT.
+func parseInsert(tokens []sqlToken) (table string, columns []string, tuples [][][]sqlToken, ok bool) {
+	if len(tokens) == 0 || !tokens[0].isKeyword("INSERT") {
+		return "", nil, nil, false
+	}
+	i := 1
+	if i+1 < len(tokens) && tokens[i].isKeyword("OR") {
+		i += 2
+	}
+	if i < len(tokens) && tokens[i].isKeyword("INTO") {
+		i++
+	}
+
+	// The table name may be qualified by the named schema.
+	var name []string
+	for i < len(tokens) && tokens[i].kind == sqlWord {
+		name = append(name, strings.Trim(tokens[i].text, "`"))
+		i++
+		if i+1 >= len(tokens) || tokens[i].text != "." {
+			break
+		}
+		i++
+	}
+	if len(name) == 0 {
+		return "", nil, nil, false
+	}
+	table = strings.Join(name, ".")
+
+	groups := parenthesizedLists(tokens[i:])
+	if i < len(tokens) && tokens[i].isKeyword("VALUES") {
+		// Values without the column list are in the order of columns, which is unknown here.
+		return table, nil, groups, true
+	}
+	if len(groups) == 0 {
+		return table, nil, nil, true
+	}
+	for _, c := range groups[0] {
+		if len(c) == 1 && c[0].kind == sqlWord {
+			columns = append(columns, strings.Trim(c[0].text, "`"))
+		}
+	}
+	return table, columns, groups[1:], true
+}
+
+// parenthesizedLists splits the top-level parenthesized lists of tokens by commas, e.g. (a, b) VALUES (1, 2), (3, 4).
+func parenthesizedLists(tokens []sqlToken) [][][]sqlToken {
+	if len(tokens) == 0 {
+		return nil
+	}
+	depth := tokens[0].depth
+	var lists [][][]sqlToken
+	var current []sqlToken
+	for _, t := range tokens {
+		switch {
+		case t.depth == depth && t.text == "(":
+			lists = append(lists, nil)
+			current = nil
+		case t.depth == depth && t.text == ")":
+			lists[len(lists)-1] = append(lists[len(lists)-1], current)
+		case t.depth == depth+1 && t.text == ",":
+			lists[len(lists)-1] = append(lists[len(lists)-1], current)
+			current = nil
+		case t.depth > depth:
+			current = append(current, t)
+		case t.isKeyword("SELECT") || t.isKeyword("THEN") || t.isKeyword("RETURNING"):
+			return lists
+		}
+	}
+	return lists
+}
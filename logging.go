@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newLogger creates a logger. Logs must not be written to stdout because it is used by the stdio transport.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %q", format)
+	}
+}
+
+// fatal logs the error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// loggingMiddleware logs every tool call with its duration and the Spanner error code.
+func loggingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+
+		attrs := []any{
+			"tool", request.Params.Name,
+			"session", sessionID(ctx),
+			"request_id", requestID(ctx),
+			"duration", time.Since(start),
+		}
+		switch {
+		case err != nil:
+			slog.ErrorContext(ctx, "tool call failed", append(attrs, "code", spanner.ErrCode(err).String(), "error", err)...)
+		case result != nil && result.IsError:
+			slog.WarnContext(ctx, "tool call returned error result", attrs...)
+		default:
+			slog.InfoContext(ctx, "tool call", attrs...)
+		}
+		slog.DebugContext(ctx, "tool call arguments", "tool", request.Params.Name, "request_id", requestID(ctx), "arguments", request.GetArguments())
+		return result, err
+	}
+}
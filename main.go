@@ -2,134 +2,1781 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/apstndb/lox"
-	"github.com/go-viper/mapstructure/v2"
+	"github.com/apstndb/spannerplanviz/plantree"
+	"github.com/apstndb/spannerplanviz/queryplan"
 	"github.com/golang/protobuf/proto"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/olekukonko/tablewriter"
 	"github.com/samber/lo"
-	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/types/descriptorpb"
-	"github.com/apstndb/spannerplanviz/plantree"
-	"github.com/apstndb/spannerplanviz/queryplan"
 )
 
-func mapToStruct[T any](m map[string]any) (T, error) {
-	var zero T
-	var result T
-	if err := mapstructure.Decode(m, &result); err != nil {
-		return zero, err
-	}
-	return result, nil
-}
+const version = "0.1.0"
 
 func main() {
+	transport := flag.String("transport", "stdio", "Transport of the MCP server (stdio, sse, http)")
+	listen := flag.String("listen", defaultListenAddr(), "Address to listen on for the sse and http transports")
+	baseURL := flag.String("base-url", "", "Base URL of the server advertised to sse clients (e.g. https://spanner-mcp.example.com)")
+	authToken := flag.String("auth-token", os.Getenv("SPANNER_MCP_AUTH_TOKEN"), "Static bearer token required for the sse and http transports (env: SPANNER_MCP_AUTH_TOKEN)")
+	oidcAudience := flag.String("oidc-audience", "", "Accept Google-signed OIDC ID tokens with this audience for the sse and http transports")
+	oidcAllowedPrincipals := flag.String("oidc-allowed-principals", "", "Comma-separated emails allowed to authenticate with OIDC ID tokens (default: any)")
+	configPath := flag.String("config", os.Getenv("SPANNER_MCP_CONFIG"), "Path to the YAML config file defining connection profiles (env: SPANNER_MCP_CONFIG)")
+	readOnly := flag.Bool("read-only", envBool("SPANNER_MCP_READ_ONLY"), "Do not expose tools which modify databases (env: SPANNER_MCP_READ_ONLY)")
+	enableTools := flag.String("enable-tools", "", "Comma-separated names of tools to expose (default: all tools)")
+	disableTools := flag.String("disable-tools", "", "Comma-separated names of tools not to expose")
+	clientIdleTimeout := flag.Duration("client-idle-timeout", 10*time.Minute, "Close cached Spanner clients which are not used for this duration (0 to keep them until shutdown)")
+	minSessions := flag.Uint64("min-sessions", 0, "Minimum number of sessions of each session pool (overrides client.min_sessions)")
+	maxSessions := flag.Uint64("max-sessions", 0, "Maximum number of sessions of each session pool (overrides client.max_sessions)")
+	multiplexedSessions := flag.Bool("multiplexed-sessions", false, "Use multiplexed sessions (overrides client.multiplexed_sessions)")
+	disableRouteToLeader := flag.Bool("disable-route-to-leader", false, "Do not route read-write transactions and partitioned DML to the leader region (overrides client.disable_route_to_leader)")
+	numChannels := flag.Int("num-channels", 0, "Number of gRPC channels of each client (overrides client.num_channels)")
+	endpoint := flag.String("endpoint", "", "Custom Spanner API endpoint (host:port) of all clients, e.g. a regional or Private Service Connect endpoint (overrides client.endpoint)")
+	insecureEndpoint := flag.Bool("insecure", false, "Connect to the endpoint without TLS and authentication, e.g. a local test proxy (overrides client.insecure)")
+	caCertFile := flag.String("ca-cert-file", "", "PEM file of CA certificates to verify the endpoint (overrides client.ca_cert_file)")
+	tlsServerName := flag.String("tls-server-name", "", "Server name to verify the certificate of the endpoint (overrides client.tls_server_name)")
+	credentialsFile := flag.String("credentials-file", "", "Credentials JSON file used instead of Application Default Credentials (overrides client.credentials_file)")
+	impersonateServiceAccount := flag.String("impersonate-service-account", "", "Service account to impersonate (overrides client.impersonate_service_account)")
+	quotaProject := flag.String("quota-project", "", "Project used for quota and billing (overrides client.quota_project)")
+	compression := flag.String("compression", "", "Compression of RPCs to Spanner: gzip or identity (overrides client.compression, default identity)")
+	keepaliveTime := flag.Duration("keepalive-time", 0, "Interval of gRPC keepalive pings on idle connections, e.g. behind proxies closing idle connections, 0 disables pings (overrides client.keepalive_time)")
+	keepaliveTimeout := flag.Duration("keepalive-timeout", 0, "Time to wait for the acknowledgement of a keepalive ping before closing the connection (overrides client.keepalive_timeout, default 20s)")
+	maxRecvMessageBytes := flag.Int("max-recv-message-bytes", 0, "Maximum size of gRPC messages received from Spanner (overrides client.max_recv_message_bytes)")
+	maxSendMessageBytes := flag.Int("max-send-message-bytes", 0, "Maximum size of gRPC messages sent to Spanner (overrides client.max_send_message_bytes)")
+	userAgent := flag.String("user-agent", "", "Suffix of the user agent of all requests to Google Cloud APIs, e.g. the name of the agent (overrides client.user_agent)")
+	rejectUnboundedDMLFlag := flag.Bool("reject-unbounded-dml", true, "Reject UPDATE and DELETE without a WHERE clause or with WHERE true unless allow_full_table is passed")
+	confirmDestructiveFlag := flag.Bool("confirm-destructive", true, "Ask the user to confirm destructive statements like DROP by elicitation. Such statements are rejected if the client doesn't support elicitation")
+	schemaPollInterval := flag.Duration("schema-poll-interval", 0, "Interval to poll DDL of profile databases to notify clients of schema changes made outside of this server (0 disables polling)")
+	logLevel := flag.String("log-level", envOr("SPANNER_MCP_LOG_LEVEL", "info"), "Log level (debug, info, warn, error) (env: SPANNER_MCP_LOG_LEVEL)")
+	logFormat := flag.String("log-format", "text", "Log format (text, json)")
+	enableOTel := flag.Bool("otel", envBool("SPANNER_MCP_OTEL"), "Export OpenTelemetry traces and metrics via OTLP/gRPC configured by OTEL_EXPORTER_OTLP_* environment variables (env: SPANNER_MCP_OTEL)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "Time to wait for in-flight tool calls on SIGINT or SIGTERM before cancelling them")
+	retryMaxAttempts := flag.Int("retry-max-attempts", 0, "Maximum attempts of calls failed with ABORTED, UNAVAILABLE or RESOURCE_EXHAUSTED including the first one, 1 disables retries (overrides retry.max_attempts, default 3)")
+	retryInitialBackoff := flag.Duration("retry-initial-backoff", 0, "Initial backoff of retries (overrides retry.initial_backoff, default 200ms)")
+	retryMaxBackoff := flag.Duration("retry-max-backoff", 0, "Maximum backoff of retries (overrides retry.max_backoff, default 5s)")
+	metadataTimeout := flag.Duration("metadata-timeout", 0, "Timeout of tool calls reading schemas and metadata (overrides timeouts.metadata, default 1m)")
+	queryTimeout := flag.Duration("query-timeout", 0, "Timeout of tool calls running queries, DML and transactions (overrides timeouts.query, default 10m)")
+	operationTimeout := flag.Duration("operation-timeout", 0, "Timeout of tool calls waiting for long-running operations such as DDL or moving data in bulk (overrides timeouts.operation, default 6h)")
+	metricsPath := flag.String("metrics-path", "/metrics", "Path to serve Prometheus metrics in the sse and http transports without authentication (empty disables)")
+	auditLogPath := flag.String("audit-log", os.Getenv("SPANNER_MCP_AUDIT_LOG"), "File to append the audit log of tool calls in JSON Lines, or stderr (env: SPANNER_MCP_AUDIT_LOG)")
+	maxConcurrentCalls := flag.Int64("max-concurrent-calls", 0, "Maximum number of concurrent tool calls, 0 means unlimited (overrides limits.max_concurrent_calls)")
+	maxOutputBytes := flag.Int("max-output-bytes", 0, "Truncate tool results larger than this size, 0 means unlimited (overrides output.max_bytes)")
+	maxOutputTokens := flag.Int("max-output-tokens", 0, "Truncate tool results larger than this number of tokens estimated from the size, 0 means unlimited (overrides output.max_tokens)")
+	tableStyle := flag.String("table-style", "", "Style of tables in outputs: box or plain without borders (overrides output.table_style, default box)")
+	eastAsianWidth := flag.Bool("east-asian-width", false, "Render characters of ambiguous width as wide in tables for clients with CJK fonts (overrides output.east_asian_width)")
+	protoFormatFlag := flag.String("proto-format", "", "Default format of proto messages in outputs of plan, get_ddl and update_ddl: prototext, protojson or none (overrides output.proto_format, default prototext)")
+	whatifEmulatorFlag := flag.String("whatif-emulator", os.Getenv("SPANNER_MCP_WHATIF_EMULATOR"), "host:port of the Spanner emulator where whatif creates shadow databases (empty disables whatif) (env: SPANNER_MCP_WHATIF_EMULATOR)")
+	dynamicToolsFlag := flag.Bool("dynamic-tools", false, "Register tools for property graphs and change streams only in sessions using databases which have them, detected on first use of each database")
+	artifactDirFlag := flag.String("artifact-dir", "", "Directory where execute_query writes results as Parquet or Arrow files with the artifact argument (empty disables artifacts)")
+	planDirFlag := flag.String("plan-dir", "", "Local directory or gs://{bucket}/{prefix} where plan saves snapshots with the save_as argument, which are served as spanner-plans:// resources (empty disables snapshots)")
+	ddlDirFlag := flag.String("ddl-dir", "", "Directory of schema files which update_ddl can read in addition to the roots of the client")
+	importDirFlag := flag.String("import-dir", "", "Directory of local files which import_data can read (empty disables local files)")
+	showVersion := flag.Bool("version", false, "Print the version and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println("spanner-mcp", version)
+		return
+	}
+
+	logger, err := newLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	// Prometheus metrics are only served by the sse and http transports.
+	enablePrometheus := *transport != "stdio" && *metricsPath != ""
+	var metricsHandler http.Handler
+	if *enableOTel || enablePrometheus {
+		shutdown, handler, err := setupTelemetry(context.Background(), version, *enableOTel, enablePrometheus)
+		if err != nil {
+			fatal("failed to set up OpenTelemetry", err)
+		}
+		metricsHandler = handler
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("failed to shut down OpenTelemetry", "error", err)
+			}
+		}()
+	} else {
+		enablePoolStats()
+	}
+
+	if *configPath != "" {
+		c, err := loadConfig(*configPath)
+		if err != nil {
+			fatal("failed to load config", err)
+		}
+		cfg = c
+	}
+
+	// Flags explicitly set override the config file.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-sessions":
+			cfg.Client.MinSessions = *minSessions
+		case "max-sessions":
+			cfg.Client.MaxSessions = *maxSessions
+		case "multiplexed-sessions":
+			cfg.Client.MultiplexedSessions = *multiplexedSessions
+		case "disable-route-to-leader":
+			cfg.Client.DisableRouteToLeader = *disableRouteToLeader
+		case "num-channels":
+			cfg.Client.NumChannels = *numChannels
+		case "endpoint":
+			cfg.Client.Endpoint = *endpoint
+		case "insecure":
+			cfg.Client.Insecure = *insecureEndpoint
+		case "ca-cert-file":
+			cfg.Client.CACertFile = *caCertFile
+		case "tls-server-name":
+			cfg.Client.TLSServerName = *tlsServerName
+		case "credentials-file":
+			cfg.Client.CredentialsFile = *credentialsFile
+		case "impersonate-service-account":
+			cfg.Client.ImpersonateServiceAccount = *impersonateServiceAccount
+		case "quota-project":
+			cfg.Client.QuotaProject = *quotaProject
+		case "compression":
+			cfg.Client.Compression = *compression
+		case "keepalive-time":
+			cfg.Client.KeepaliveTime = *keepaliveTime
+		case "keepalive-timeout":
+			cfg.Client.KeepaliveTimeout = *keepaliveTimeout
+		case "max-recv-message-bytes":
+			cfg.Client.MaxRecvMessageBytes = *maxRecvMessageBytes
+		case "max-send-message-bytes":
+			cfg.Client.MaxSendMessageBytes = *maxSendMessageBytes
+		case "user-agent":
+			cfg.Client.UserAgent = *userAgent
+		case "retry-max-attempts":
+			cfg.Retry.MaxAttempts = *retryMaxAttempts
+		case "retry-initial-backoff":
+			cfg.Retry.InitialBackoff = *retryInitialBackoff
+		case "retry-max-backoff":
+			cfg.Retry.MaxBackoff = *retryMaxBackoff
+		case "metadata-timeout":
+			cfg.Timeouts.Metadata = *metadataTimeout
+		case "query-timeout":
+			cfg.Timeouts.Query = *queryTimeout
+		case "operation-timeout":
+			cfg.Timeouts.Operation = *operationTimeout
+		case "max-concurrent-calls":
+			cfg.Limits.MaxConcurrentCalls = *maxConcurrentCalls
+		case "max-output-bytes":
+			cfg.Output.MaxBytes = *maxOutputBytes
+		case "max-output-tokens":
+			cfg.Output.MaxTokens = *maxOutputTokens
+		case "proto-format":
+			cfg.Output.ProtoFormat = *protoFormatFlag
+		case "table-style":
+			cfg.Output.TableStyle = *tableStyle
+		case "east-asian-width":
+			cfg.Output.EastAsianWidth = *eastAsianWidth
+		}
+	})
+	if err := cfg.Timeouts.validate(); err != nil {
+		fatal("invalid timeouts", err)
+	}
+	if s := cfg.Output.TableStyle; s != "" && !slices.Contains(tableStyles, s) {
+		fatal("invalid table style", fmt.Errorf("%q is not one of %v", s, tableStyles))
+	}
+	setEastAsianWidth(cfg.Output.EastAsianWidth)
+	if f := cfg.Output.ProtoFormat; f != "" && !slices.Contains(protoFormats, f) {
+		fatal("invalid proto format", fmt.Errorf("%q is not one of %v", f, protoFormats))
+	}
+	confirmDestructive = *confirmDestructiveFlag
+	rejectUnboundedDML = *rejectUnboundedDMLFlag
+	whatifEmulator = *whatifEmulatorFlag
+
+	if *ddlDirFlag != "" {
+		dir, err := filepath.Abs(*ddlDirFlag)
+		if err == nil {
+			dir, err = filepath.EvalSymlinks(dir)
+		}
+		if err != nil {
+			fatal("invalid DDL directory", err)
+		}
+		ddlDir = dir
+	}
+	if *importDirFlag != "" {
+		dir, err := filepath.Abs(*importDirFlag)
+		if err == nil {
+			dir, err = filepath.EvalSymlinks(dir)
+		}
+		if err != nil {
+			fatal("invalid import directory", err)
+		}
+		importDir = dir
+	}
+	if *artifactDirFlag != "" {
+		dir, err := filepath.Abs(*artifactDirFlag)
+		if err == nil {
+			err = os.MkdirAll(dir, 0o750)
+		}
+		if err != nil {
+			fatal("invalid artifact directory", err)
+		}
+		artifactDir = dir
+	}
+	if strings.HasPrefix(*planDirFlag, "gs://") {
+		bucket, prefix, err := parseGCSURI(*planDirFlag)
+		if err != nil {
+			fatal("invalid plan directory", err)
+		}
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		planDir = fmt.Sprintf("gs://%s/%s", bucket, prefix)
+	} else if *planDirFlag != "" {
+		dir, err := filepath.Abs(*planDirFlag)
+		if err == nil {
+			err = os.MkdirAll(dir, 0o750)
+		}
+		if err != nil {
+			fatal("invalid plan directory", err)
+		}
+		planDir = dir
+	}
+
+	if err := cfg.Client.applyEnv(); err != nil {
+		fatal("failed to apply client options", err)
+	}
+
+	auth := &authConfig{
+		token:             *authToken,
+		oidcAudience:      *oidcAudience,
+		allowedPrincipals: splitList(*oidcAllowedPrincipals),
+	}
+
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(forgetSessionDatabase)
+	hooks.AddOnUnregisterSession(forgetIndexBaseline)
+	hooks.AddOnUnregisterSession(forgetStagedChangeSet)
+	hooks.AddOnUnregisterSession(audit.forgetSession)
+	hooks.AddOnUnregisterSession(forgetSessionToolDatabase)
+
+	closeAuditLog, err := openAuditLog(*auditLogPath)
+	if err != nil {
+		fatal("failed to open audit log", err)
+	}
+	defer func() {
+		if err := closeAuditLog(); err != nil {
+			slog.Error("failed to close audit log", "error", err)
+		}
+	}()
+
+	toolMiddlewares = []server.ToolHandlerMiddleware{
+		requestIDMiddleware,
+		telemetryMiddleware,
+		loggingMiddleware,
+		auditMiddleware,
+		calls.middleware,
+		limitMiddleware(cfg.Limits),
+		timeoutMiddleware,
+		retryMiddleware,
+		shapingMiddleware,
+		errorMiddleware,
+		validationMiddleware,
+	}
+
+	opts := []server.ServerOption{
+		server.WithLogging(),
+		server.WithResourceCapabilities(false, false),
+		server.WithCompletions(),
+		server.WithResourceCompletionProvider(completionProvider{}),
+		server.WithPromptCapabilities(false),
+		server.WithPromptCompletionProvider(completionProvider{}),
+		server.WithElicitation(),
+		server.WithHooks(hooks),
+	}
+	for _, mw := range toolMiddlewares {
+		opts = append(opts, server.WithToolHandlerMiddleware(mw))
+	}
+
 	// Create MCP server
-	s := server.NewMCPServer(
-		"Spanner MCP",
-		"0.1.0",
+	s := server.NewMCPServer("Spanner MCP", version, opts...)
+
+	// Add tool
+	plan := mcp.NewTool("plan",
+		readOnlyAnnotation("Query plan"),
+		mcp.WithDescription("Get execution plan for the query. The first content is machine-readable QueryPlan message in proto_format, omitted if it is none. The last content is human-readable rendered query plan. For GQL queries, it also explains which operators scan nodes and edges, expand edges and evaluate quantified paths. If summary is true, it returns only a short summary of the plan."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("query text of SQL or GQL"),
+		),
+		withQueryArgs(),
+		withProtoFormatArg(),
+		mcp.WithBoolean("summary",
+			mcp.DefaultBool(false),
+			mcp.Description("Return only a short summary of the plan: scanned tables and indexes, full scans, join methods, distribution operators and parameters, instead of the plan tree"),
+		),
+		mcp.WithString("save_as",
+			mcp.Description("Save the plan as a snapshot of this name, which is read later as the spanner-plans://snapshots/{snapshot} resource and compared with other snapshots by spanner-plans://compare/{base}/{other}. Requires --plan-dir and existing snapshots are not overwritten"),
+		),
+		mcp.WithOutputSchema[planOutput](),
+	)
+
+	profileTopQuery := mcp.NewTool("profile_top_query",
+		readOnlyAnnotation("Profile top query"),
+		mcp.WithDescription("Drill down into a query of SPANNER_SYS.QUERY_STATS_TOP_* by its TEXT_FINGERPRINT, e.g. from the query-stats resource: returns the query text, its statistics of the latest intervals and its live plan with a summary. SPANNER_SYS doesn't keep plans, so if --plan-dir is set, the live plan is compared with the newest plan snapshot of the same query to show plan changes. Queries whose text is truncated in the statistics are not planned."),
+		mcp.WithString("fingerprint",
+			mcp.Required(),
+			mcp.Description("TEXT_FINGERPRINT of the query as a decimal integer"),
+		),
+		mcp.WithString("interval",
+			mcp.DefaultString("hour"),
+			mcp.Enum("minute", "10minute", "hour"),
+			mcp.Description("Interval of the statistics table"),
+		),
+		withQueryArgs(),
+		mcp.WithOutputSchema[profileTopQueryOutput](),
+	)
+
+	executeQuery := mcp.NewTool("execute_query",
+		readOnlyAnnotation("Execute query"),
+		mcp.WithDescription("Execute a query in a single-use read-only transaction. The content is the result rendered as a table whose headers are column names and types. Values are JSON, where NUMERIC, BYTES(base64), TIMESTAMP and DATE are strings. The structured content has the Spanner type of each column. Statistics of the execution (rows returned, elapsed time and CPU time) follow the table unless rows are omitted by max_rows."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("query text of SQL or GQL"),
+		),
+		withQueryArgs(),
+		mcp.WithNumber("max_rows",
+			mcp.DefaultNumber(defaultMaxRows),
+			mcp.Description("Maximum number of rows to return"),
+		),
+		withRenderArgs(),
+		mcp.WithString("proto_format",
+			mcp.Enum(protoFormats...),
+			mcp.Description("Format of PROTO values in the table. PROTO and ENUM values are decoded using proto_descriptors of the database, and PROTO values are protojson in the structured content. none leaves them as base64 bytes and numbers (default: the server setting, prototext unless configured)"),
+		),
+		mcp.WithString("query_mode",
+			mcp.Enum("WITH_STATS", "PROFILE"),
+			mcp.DefaultString("WITH_STATS"),
+			mcp.Description("Query mode of the execution. PROFILE also reports rows scanned in the statistics"),
+		),
+		mcp.WithString("artifact",
+			mcp.Enum(artifactFormats...),
+			mcp.Description("Write the rows to a local Parquet or Arrow IPC file in the artifact directory of the server and return a resource link to it instead of the rows, for large extracts read by pandas or DuckDB. max_rows defaults to 1000000. Requires --artifact-dir"),
+		),
+		mcp.WithOutputSchema[executeQueryOutput](),
+	)
+
+	executePartitionedQuery := mcp.NewTool("execute_partitioned_query",
+		readOnlyAnnotation("Execute partitioned query"),
+		mcp.WithDescription("Execute a root-partitionable query in a batch read-only transaction, executing partitions concurrently, for fast full-table analytical reads. Like execute_query, the content is the result rendered as a table, but rows are in no particular order. After max_rows rows are read, the remaining partitions are stopped. Progress notifications report the number of completed partitions."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query, which must be root-partitionable"),
+		),
+		withQueryArgs(),
+		mcp.WithNumber("max_rows",
+			mcp.DefaultNumber(defaultMaxRows),
+			mcp.Description("Maximum number of rows to return"),
+		),
+		mcp.WithNumber("parallelism",
+			mcp.DefaultNumber(defaultPartitionParallelism),
+			mcp.Min(1),
+			mcp.Max(maxPartitionParallelism),
+			mcp.Description("Number of partitions executed concurrently"),
+		),
+		mcp.WithBoolean("data_boost",
+			mcp.DefaultBool(false),
+			mcp.Description("Run the query on Data Boost to avoid impact on the provisioned compute of the instance"),
+		),
+		withRenderArgs(),
+		mcp.WithString("proto_format",
+			mcp.Enum(protoFormats...),
+			mcp.Description("Format of PROTO values in the table like execute_query (default: the server setting)"),
+		),
+		mcp.WithOutputSchema[executePartitionedQueryOutput](),
+	)
+
+	executeFanoutQuery := mcp.NewTool("execute_fanout_query",
+		readOnlyAnnotation("Execute fan-out query"),
+		mcp.WithDescription("Execute the same query in multiple databases of the instance, e.g. shards of a fleet, and merge the rows into one result with a source_database column. The columns of the first database which succeeds are the schema of the result; databases returning other column names or types are reported as incompatible instead of being merged. Errors of databases are isolated in their results, so the other databases are merged. Progress notifications report the number of completed databases."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query"),
+		),
+		mcp.WithString("profile",
+			mcp.Description("Name of the connection profile in the config file. Alternative to project and instance"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Google Cloud project"),
+		),
+		mcp.WithString("instance",
+			mcp.Description("Spanner instance id"),
+		),
+		mcp.WithString("database_role",
+			mcp.Description("Database role used for fine-grained access control (overrides the profile)"),
+		),
+		mcp.WithArray("databases",
+			mcp.WithStringItems(),
+			mcp.Description("Database IDs to query (default: all ready databases of the instance)"),
+		),
+		mcp.WithString("database_pattern",
+			mcp.Description("Regular expression filtering database IDs, e.g. ^shard-"),
+		),
+		mcp.WithNumber("max_rows",
+			mcp.DefaultNumber(defaultMaxRows),
+			mcp.Description("Maximum number of rows to read from each database and to return in total"),
+		),
+		mcp.WithNumber("parallelism",
+			mcp.DefaultNumber(defaultFanoutParallelism),
+			mcp.Min(1),
+			mcp.Max(maxFanoutParallelism),
+			mcp.Description("Number of databases queried concurrently"),
+		),
+		withRenderArgs(),
+		mcp.WithString("proto_format",
+			mcp.Enum(protoFormats...),
+			mcp.Description("Format of PROTO values in the table like execute_query (default: the server setting)"),
+		),
+		mcp.WithOutputSchema[executeFanoutQueryOutput](),
+	)
+
+	executeGQL := mcp.NewTool("execute_gql",
+		readOnlyAnnotation("Execute GQL"),
+		mcp.WithDescription("Execute a GQL query starting with GRAPH {name} in a single-use read-only transaction like execute_query. Nodes, edges and paths returned as JSON, e.g. by RETURN SAFE_TO_JSON(p), are formatted like path patterns (:Person {id: 1})-[:Owns]->(:Account {id: 7}) in the table and as objects in the structured content. mermaid additionally renders returned nodes and edges as a mermaid flowchart."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("GQL query"),
+		),
+		withQueryArgs(),
+		mcp.WithNumber("max_rows",
+			mcp.DefaultNumber(defaultMaxRows),
+			mcp.Description("Maximum number of rows to return"),
+		),
+		mcp.WithBoolean("mermaid",
+			mcp.Description("Render returned nodes and edges as a mermaid flowchart"),
+		),
+		withRenderArgs(),
+		mcp.WithOutputSchema[executeGQLOutput](),
+	)
+
+	lintQuery := mcp.NewTool("lint_query",
+		readOnlyAnnotation("Lint query"),
+		mcp.WithDescription("Check a query or a DML statement for Spanner-specific anti-patterns without executing it: literals instead of query parameters, SELECT *, functions of columns in predicates, LIKE with leading wildcards, full scans in the query plan, and inserts of monotonically increasing keys. Returns findings with severities and suggested fixes."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query or DML statement to lint"),
+		),
+		withQueryArgs(),
+		mcp.WithOutputSchema[lintQueryOutput](),
+	)
+
+	lintSchema := mcp.NewTool("lint_schema",
+		readOnlyAnnotation("Lint schema"),
+		mcp.WithDescription(fmt.Sprintf("Check the schema of the database against Spanner design guidance, for both GoogleSQL and PostgreSQL-dialect databases: monotonically increasing first key columns of root tables and indexes (timestamps, or integer IDs without bit-reversed sequences), interleave trees wider than %d child tables or deeper than %d levels, log-like tables without row deletion policies (TTL), STRING(MAX) and BYTES(MAX) key columns, and names in inconsistent styles. Returns findings with severities and suggested fixes.",
+			maxLintInterleaveChildren, maxLintInterleaveDepth-1)),
+		withQueryArgs(),
+		mcp.WithOutputSchema[lintSchemaOutput](),
+	)
+
+	estimateCost := mcp.NewTool("estimate_cost",
+		readOnlyAnnotation("Estimate cost"),
+		mcp.WithDescription("Estimate the cost of a query or a DML statement without executing it. Based on the query plan and table size statistics, returns scans with estimated rows of fully scanned tables, the number of distributed cross applies, and classifies the query as cheap, moderate or expensive. For queries, it also checks whether they are eligible for Data Boost by partitioning them, estimates the serverless processing by the bytes read, and recommends whether to set data_boost."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query or DML statement to estimate"),
+		),
+		withQueryArgs(),
+		mcp.WithOutputSchema[estimateCostOutput](),
+	)
+
+	indexImpact := mcp.NewTool("index_impact",
+		readOnlyAnnotation("Index impact"),
+		mcp.WithDescription("Analyze the impact of a new index on queries in two steps. First call with action baseline and the queries to capture their plans, and actual statistics if profile_queries is true, in this MCP session. Then create the index, e.g. by update_ddl, and call with action compare and the index name to re-plan the queries and report which plans changed, whether they use the index, and the changes of cost, rows scanned and times."),
+		mcp.WithString("action",
+			mcp.Required(),
+			mcp.Enum("baseline", "compare"),
+			mcp.Description("baseline captures the queries before creating the index, compare re-captures them after the index is created"),
+		),
+		mcp.WithArray("queries",
+			mcp.WithStringItems(),
+			mcp.Description(fmt.Sprintf("Queries or DML statements to capture for baseline, at most %d", maxImpactQueries)),
+		),
+		mcp.WithString("index",
+			mcp.Description("Name of the created index for compare. The index must be backfilled"),
+		),
+		mcp.WithBoolean("profile_queries",
+			mcp.Description("Execute the queries in PROFILE mode for baseline and compare to report actual rows scanned, CPU time and elapsed time. Only for queries, as DML would modify data"),
+		),
+		withQueryArgs(),
+		mcp.WithOutputSchema[indexImpactOutput](),
+	)
+
+	whatif := mcp.NewTool("whatif",
+		readOnlyAnnotation("What-if schema"),
+		mcp.WithDescription("Try hypothetical DDL, e.g. new indexes or column changes, without touching the real database. The schema of the database is copied into a shadow database in the Spanner emulator, the DDL is applied to it, and the queries are planned before and after the DDL. Returns whether each query is valid with the new schema, whether its plan changed, full scans and indexes used. The shadow database is dropped after the call. Requires the server to be started with --whatif-emulator."),
+		mcp.WithArray("ddl",
+			mcp.Required(),
+			mcp.WithStringItems(),
+			mcp.Description("Hypothetical DDL statements, e.g. CREATE INDEX"),
+		),
+		mcp.WithArray("queries",
+			mcp.Required(),
+			mcp.WithStringItems(),
+			mcp.Description(fmt.Sprintf("Queries or DML statements to plan, at most %d", maxImpactQueries)),
+		),
+		withQueryArgs(),
+		mcp.WithOutputSchema[whatifOutput](),
+	)
+
+	executeDML := mcp.NewTool("execute_dml",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Execute DML",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Execute an INSERT, UPDATE or DELETE statement in a read-write transaction and return the number of affected rows. DELETE must be confirmed by the user typing the database ID. UPDATE and DELETE of all rows are rejected unless allow_full_table is true. Use dry_run first to check the scope: it returns the query plan of the statement and, for UPDATE and DELETE, the number of rows matching the WHERE clause without modifying data."),
+		mcp.WithString("statement",
+			mcp.Required(),
+			mcp.Description("DML statement to execute"),
+		),
+		withQueryArgs(),
+		mcp.WithBoolean("dry_run",
+			mcp.DefaultBool(false),
+			mcp.Description("Only plan the statement and estimate affected rows by COUNT(*) of the WHERE clause in a read-only transaction"),
+		),
+		mcp.WithBoolean("allow_full_table",
+			mcp.DefaultBool(false),
+			mcp.Description("Allow UPDATE and DELETE without a WHERE clause or with WHERE true, which are rejected by default"),
+		),
+		mcp.WithOutputSchema[executeDMLOutput](),
+	)
+
+	sampleRows := mcp.NewTool("sample_rows",
+		readOnlyAnnotation("Sample rows"),
+		mcp.WithDescription("Get a small random sample of rows from the table using TABLESAMPLE, to look at representative data. The content is rendered like execute_query."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name, optionally qualified by the named schema"),
+		),
+		withQueryArgs(),
+		mcp.WithString("method",
+			mcp.Enum("reservoir", "bernoulli"),
+			mcp.DefaultString("reservoir"),
+			mcp.Description("reservoir samples exactly the number of rows. bernoulli samples each row with the percent probability"),
+		),
+		mcp.WithNumber("rows",
+			mcp.DefaultNumber(defaultSampleRows),
+			mcp.Max(maxSampleRows),
+			mcp.Description("Maximum number of rows to return"),
+		),
+		mcp.WithNumber("percent",
+			mcp.DefaultNumber(1),
+			mcp.Description("Percentage of rows sampled by bernoulli"),
+		),
+		withProtoFormatArg(),
+		mcp.WithOutputSchema[executeQueryOutput](),
+	)
+
+	countRows := mcp.NewTool("count_rows",
+		readOnlyAnnotation("Count rows"),
+		mcp.WithDescription("Count rows of the table. The exact mode runs COUNT(*), which may scan the whole table. The approximate mode quickly estimates it from the table size statistics updated hourly."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name, optionally qualified by the named schema"),
+		),
+		withQueryArgs(),
+		mcp.WithString("mode",
+			mcp.Enum("exact", "approximate"),
+			mcp.DefaultString("exact"),
+			mcp.Description("exact or approximate"),
+		),
+		mcp.WithString("filter",
+			mcp.Description("SQL expression of the WHERE clause to count only matching rows, only in the exact mode"),
+		),
+		mcp.WithOutputSchema[countRowsOutput](),
+	)
+
+	keyDistribution := mcp.NewTool("key_distribution",
+		readOnlyAnnotation("Key distribution"),
+		mcp.WithDescription("Profile the primary key space of the table: divide the range of the first key column into buckets of equal width, count rows of each key range by range scans in a single snapshot, and report skew and hotspot-prone patterns like timestamps and sequential IDs as the first key column. Each count reads at most max_rows_per_bucket rows."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name, optionally qualified by the named schema"),
+		),
+		withQueryArgs(),
+		mcp.WithNumber("buckets",
+			mcp.DefaultNumber(defaultKeyBuckets),
+			mcp.Min(1),
+			mcp.Max(maxKeyBuckets),
+			mcp.Description("Number of key ranges"),
+		),
+		mcp.WithNumber("max_rows_per_bucket",
+			mcp.DefaultNumber(defaultMaxRowsPerKeyBucket),
+			mcp.Min(1),
+			mcp.Max(maxMaxRowsPerKeyBucket),
+			mcp.Description("Maximum rows counted in each key range, which bounds the cost on large tables"),
+		),
+		mcp.WithOutputSchema[keyDistributionOutput](),
+	)
+
+	exportToGCS := mcp.NewTool("export_to_gcs",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Export to Cloud Storage",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithDescription("Export the result of a query to Cloud Storage for results too large for tool results. The query must be root-partitionable, and each partition is written to {destination}/part-NNNNN.{format}. Existing objects are not overwritten. In csv, NULL is an empty field and ARRAY and JSON are JSON. Returns the URIs of the objects."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query to export"),
+		),
+		mcp.WithString("destination",
+			mcp.Required(),
+			mcp.Description("gs://{bucket}/{prefix} to write objects"),
+		),
+		withQueryArgs(),
+		mcp.WithString("format",
+			mcp.Enum(exportFormats...),
+			mcp.DefaultString("csv"),
+			mcp.Description("Format of objects. jsonl is JSON Lines, and avro is Avro object container files"),
+		),
+		mcp.WithBoolean("data_boost",
+			mcp.DefaultBool(false),
+			mcp.Description("Run the query on Data Boost to avoid impact on the provisioned compute of the instance"),
+		),
+		mcp.WithOutputSchema[exportToGCSOutput](),
+	)
+
+	importData := mcp.NewTool("import_data",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Import data",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithDescription("Import rows from a CSV or JSON Lines file into the table using batched mutations, for test fixtures and small backfills. The first line of CSV is column names, an empty field is NULL, ARRAY is JSON and BYTES is base64. Each batch is committed separately, so rows before a failed batch remain imported. Use dry_run to validate and count rows first."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name, optionally qualified by the named schema"),
+		),
+		mcp.WithString("source",
+			mcp.Required(),
+			mcp.Description("gs://{bucket}/{object}, or a path of a local file in the import directory of the server"),
+		),
+		withQueryArgs(),
+		mcp.WithString("format",
+			mcp.Enum(importFormats...),
+			mcp.Description("Format of the source (default: the extension of the source)"),
+		),
+		mcp.WithNumber("batch_size",
+			mcp.DefaultNumber(defaultImportBatchSize),
+			mcp.Max(maxImportBatchSize),
+			mcp.Description("Number of rows committed in each transaction"),
+		),
+		mcp.WithString("on_conflict",
+			mcp.Enum("error", "update", "replace"),
+			mcp.DefaultString("error"),
+			mcp.Description("Behavior for existing rows: error fails the batch, update updates the given columns and replace deletes other columns"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.DefaultBool(false),
+			mcp.Description("Only validate and count rows without writing"),
+		),
+		mcp.WithOutputSchema[importDataOutput](),
+	)
+
+	generateData := mcp.NewTool("generate_data",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Generate data",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Insert synthetic rows into the table for load tests and demos. Values respect column types and lengths, and NOT NULL. Columns of foreign keys and the interleaved parent take values from sampled rows of the referenced tables, so generate referenced tables first. Columns with DEFAULT use the default unless generators are given. Keys are random by default to avoid hotspots. Each batch is committed separately. Use dry_run to preview rows."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name, optionally qualified by the named schema"),
+		),
+		mcp.WithNumber("rows",
+			mcp.Required(),
+			mcp.Min(1),
+			mcp.Max(maxGenerateRows),
+			mcp.Description("Number of rows to insert"),
+		),
+		withQueryArgs(),
+		mcp.WithObject("generators",
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+			mcp.Description("Generators by column name, overriding the defaults: uuid, sequence[:{start}], uniform:{min}..{max}, zipf:{min}..{max}[:{exponent}] (smaller values are more frequent), timestamp:{start}..{end} (RFC 3339, dates or now), string[:{min length}..{max length}], choice:{a}|{b}|..., const:{value} and null. For ARRAY columns, generators generate elements"),
+		),
+		mcp.WithNumber("seed",
+			mcp.Description("Seed of the generators to reproduce the same rows (default: random, returned in the result)"),
+		),
+		mcp.WithNumber("batch_size",
+			mcp.DefaultNumber(defaultImportBatchSize),
+			mcp.Max(maxImportBatchSize),
+			mcp.Description("Number of rows committed in each transaction"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.DefaultBool(false),
+			mcp.Description("Only generate rows and return the first rows without writing"),
+		),
+		mcp.WithOutputSchema[generateDataOutput](),
+	)
+
+	createTableAs := mcp.NewTool("create_table_as",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Create table as query",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Materialize the result of a query into a table using batched mutations, e.g. to build a scratch or report table during analysis. The create mode creates a new table whose columns are inferred from the result: STRING and BYTES are MAX, and STRUCT columns must be converted in the query. The append mode writes into an existing table whose columns have the names and types of the result columns. Result columns must be named uniquely, so give aliases to expressions. Each batch is committed separately, so rows before a failed batch remain written. Use dry_run to see the CREATE TABLE statement and count rows first. Creating a table is supported only in GoogleSQL databases."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name to write, optionally qualified by the named schema"),
+		),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query whose result is written, executed in a single-use read-only transaction"),
+		),
+		withQueryArgs(),
+		mcp.WithString("mode",
+			mcp.Enum("create", "append"),
+			mcp.DefaultString("create"),
+			mcp.Description("create creates the table, which must not exist. append writes into the existing table"),
+		),
+		mcp.WithArray("primary_key",
+			mcp.WithStringItems(),
+			mcp.Description("Result columns of the primary key of the table to create, required in the create mode"),
+		),
+		mcp.WithString("on_conflict",
+			mcp.Enum("error", "update", "replace"),
+			mcp.DefaultString("error"),
+			mcp.Description("Behavior for existing rows in the append mode: error fails the batch, update updates the columns of the result and replace deletes other columns"),
+		),
+		mcp.WithNumber("max_rows",
+			mcp.DefaultNumber(defaultCreateTableAsMaxRows),
+			mcp.Max(maxCreateTableAsRows),
+			mcp.Description("Maximum number of rows to write. Rows beyond it are not written and the result is marked as truncated"),
+		),
+		mcp.WithNumber("batch_size",
+			mcp.DefaultNumber(defaultImportBatchSize),
+			mcp.Max(maxImportBatchSize),
+			mcp.Description("Number of rows committed in each transaction"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.DefaultBool(false),
+			mcp.Description("Only infer the table and count rows without writing"),
+		),
+		mcp.WithOutputSchema[createTableAsOutput](),
+	)
+
+	generateAlertPolicies := mcp.NewTool("generate_alert_policies",
+		readOnlyAnnotation("Generate alert policies"),
+		mcp.WithDescription(fmt.Sprintf("Generate Cloud Monitoring alert policies recommended for the instance as AlertPolicy JSON for gcloud monitoring policies create, or google_monitoring_alert_policy Terraform resources: high priority CPU utilization (default %d%% for regional and %d%% for multi-region configurations), 24-hour smoothed CPU utilization over %d%%, storage utilization (default %d%%) and the p99 latency SLO of requests by method (default %dms). Nothing is created.",
+			defaultRegionalCPUThresholdPercent, defaultMultiRegionCPUThresholdPercent, defaultSmoothedCPUThresholdPercent, defaultStorageThresholdPercent, defaultLatencyThresholdMillis)),
+		mcp.WithString("profile",
+			mcp.Description("Name of the connection profile in the config file. Alternative to project and instance"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Google Cloud project"),
+		),
+		mcp.WithString("instance",
+			mcp.Description("Spanner instance id"),
+		),
+		mcp.WithString("format",
+			mcp.DefaultString("json"),
+			mcp.Enum(alertPolicyFormats...),
+			mcp.Description("json for the Cloud Monitoring API and gcloud, or terraform"),
+		),
+		mcp.WithArray("notification_channels",
+			mcp.WithStringItems(),
+			mcp.Description("Notification channels of the policies, e.g. projects/my-project/notificationChannels/123"),
+		),
+		mcp.WithNumber("cpu_threshold_percent",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Threshold of high priority CPU utilization (default: by the instance configuration)"),
+		),
+		mcp.WithNumber("storage_threshold_percent",
+			mcp.DefaultNumber(defaultStorageThresholdPercent),
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Threshold of storage utilization relative to the limit of the compute capacity"),
+		),
+		mcp.WithNumber("latency_threshold_ms",
+			mcp.DefaultNumber(defaultLatencyThresholdMillis),
+			mcp.Min(0),
+			mcp.Description("p99 latency SLO of requests in milliseconds"),
+		),
+		mcp.WithOutputSchema[generateAlertPoliciesOutput](),
+	)
+
+	capacityPlanning := mcp.NewTool("capacity_planning",
+		readOnlyAnnotation("Plan compute capacity"),
+		mcp.WithDescription(fmt.Sprintf("Project the compute capacity of the instance from Cloud Monitoring metrics of the last days: daily peaks of high priority CPU utilization, daily storage utilization and QPS by database. Utilizations are fitted linearly to estimate when they exceed the thresholds (default %d%% CPU for regional and %d%% for multi-region configurations, %d%% storage), and recommend processing units to stay under them now and at the horizon.",
+			defaultRegionalCPUThresholdPercent, defaultMultiRegionCPUThresholdPercent, defaultStorageThresholdPercent)),
+		mcp.WithString("profile",
+			mcp.Description("Name of the connection profile in the config file. Alternative to project and instance"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Google Cloud project"),
+		),
+		mcp.WithString("instance",
+			mcp.Description("Spanner instance id"),
+		),
+		mcp.WithNumber("days",
+			mcp.DefaultNumber(defaultCapacityDays),
+			mcp.Min(2),
+			mcp.Max(maxCapacityDays),
+			mcp.Description("Days of metrics to fit"),
+		),
+		mcp.WithNumber("horizon_days",
+			mcp.DefaultNumber(defaultCapacityHorizonDays),
+			mcp.Min(1),
+			mcp.Description("Days ahead to project utilizations and recommend capacity for"),
+		),
+		mcp.WithNumber("cpu_threshold_percent",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Maximum high priority CPU utilization (default: by the instance configuration)"),
+		),
+		mcp.WithNumber("storage_threshold_percent",
+			mcp.DefaultNumber(defaultStorageThresholdPercent),
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Maximum storage utilization relative to the limit of the compute capacity"),
+		),
+		mcp.WithOutputSchema[capacityPlanningOutput](),
+	)
+
+	listTables := mcp.NewTool("list_tables",
+		readOnlyAnnotation("List tables"),
+		mcp.WithDescription("List tables and views of the database with their interleaving from INFORMATION_SCHEMA, for both GoogleSQL and PostgreSQL-dialect databases. Tables in named schemas are qualified by the schema, and tables in the default schema (public in PostgreSQL) are not."),
+		withQueryArgs(),
+		mcp.WithString("schema",
+			mcp.Description("List only tables of the schema, e.g. public in PostgreSQL-dialect databases"),
+		),
+		mcp.WithOutputSchema[listTablesOutput](),
+	)
+
+	describeTable := mcp.NewTool("describe_table",
+		readOnlyAnnotation("Describe table"),
+		mcp.WithDescription("Describe the table: columns with types, nullability, defaults and generated expressions, the primary key, the interleaving, secondary indexes and its DDL statements, for both GoogleSQL and PostgreSQL-dialect databases. Types and DDL are in the dialect of the database."),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table name, optionally qualified by the named schema"),
+		),
+		mcp.WithOutputSchema[describeTableOutput](),
+	)
+
+	listCommitTimestampColumns := mcp.NewTool("list_commit_timestamp_columns",
+		readOnlyAnnotation("List commit timestamp columns"),
+		mcp.WithDescription(fmt.Sprintf("List columns which allow commit timestamps: TIMESTAMP columns with allow_commit_timestamp in GoogleSQL, and SPANNER.COMMIT_TIMESTAMP columns in PostgreSQL. In mutations of stage_mutation and import_data, write the commit timestamp by the placeholder %q, not the current time or PENDING_COMMIT_TIMESTAMP(), which is for DML.", commitTimestampPlaceholder)),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Description("List only columns of the table, optionally qualified by the named schema"),
+		),
+		mcp.WithOutputSchema[listCommitTimestampColumnsOutput](),
+	)
+
+	tableTree := mcp.NewTool("table_tree",
+		readOnlyAnnotation("Table tree"),
+		mcp.WithDescription("Show the interleave hierarchy of tables as an indented tree: root tables with nested interleaved tables and their ON DELETE behavior, optionally with interleaved indexes. Rows of interleaved tables are stored with the rows of their parents, so the tree shows the data locality of the schema."),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Description("Show only the subtree of the table, optionally qualified by the named schema"),
+		),
+		mcp.WithBoolean("include_indexes",
+			mcp.Description("Also show indexes interleaved in tables"),
+		),
+		mcp.WithOutputSchema[tableTreeOutput](),
+	)
+
+	listColumnExpressions := mcp.NewTool("list_column_expressions",
+		readOnlyAnnotation("List column expressions"),
+		mcp.WithDescription("List generated columns with their expressions and whether they are STORED, and DEFAULT expressions of columns across the schema. Also shows the primary key and indexes containing the columns, which are updated by writes of the columns in the expressions."),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Description("List only columns of the table, optionally qualified by the named schema"),
+		),
+		mcp.WithString("kind",
+			mcp.Enum(columnExpressionKinds...),
+			mcp.DefaultString("all"),
+			mcp.Description("generated for generated columns, default for columns with DEFAULT expressions, or all"),
+		),
+		mcp.WithOutputSchema[listColumnExpressionsOutput](),
+	)
+
+	listConstraints := mcp.NewTool("list_constraints",
+		readOnlyAnnotation("List constraints"),
+		mcp.WithDescription("List CHECK constraints and foreign keys with their ON DELETE actions and whether they are enforced, for data-integrity reviews. Implicit NOT NULL constraints are omitted."),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Description("List only constraints of the table and foreign keys referencing it, optionally qualified by the named schema"),
+		),
+		mcp.WithOutputSchema[listConstraintsOutput](),
+	)
+
+	checkReferentialIntegrity := mcp.NewTool("check_referential_integrity",
+		readOnlyAnnotation("Check referential integrity"),
+		mcp.WithDescription(fmt.Sprintf("Count orphaned rows of NOT ENFORCED foreign keys and informal references by anti-join queries, with samples of the orphaned rows and their primary keys, e.g. before enforcing foreign keys. Rows with NULL in any referencing column are not orphans like foreign keys. Queries scan the referencing tables, so run them on large tables with care. GoogleSQL databases only, at most %d samples per reference.", maxSampleRows)),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Description("Check only foreign keys of the referencing table, optionally qualified by the named schema"),
+		),
+		mcp.WithBoolean("include_enforced",
+			mcp.Description("Also check enforced foreign keys, which have no orphaned rows unless they are being validated"),
+		),
+		mcp.WithArray("references",
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"table":              map[string]any{"type": "string", "description": "Referencing table"},
+					"columns":            map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"referenced_table":   map[string]any{"type": "string"},
+					"referenced_columns": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+				"required": []string{"table", "columns", "referenced_table", "referenced_columns"},
+			}),
+			mcp.Description("Informal references without foreign keys to check, e.g. [{\"table\": \"Orders\", \"columns\": [\"CustomerId\"], \"referenced_table\": \"Customers\", \"referenced_columns\": [\"CustomerId\"]}]"),
+		),
+		mcp.WithNumber("samples",
+			mcp.DefaultNumber(defaultSampleRows),
+			mcp.Min(1),
+			mcp.Max(maxSampleRows),
+			mcp.Description("Orphaned rows to sample per reference"),
+		),
+		mcp.WithOutputSchema[checkReferentialIntegrityOutput](),
+	)
+
+	findRedundantIndexes := mcp.NewTool("find_redundant_indexes",
+		readOnlyAnnotation("Find redundant indexes"),
+		mcp.WithDescription("Find secondary indexes which can be dropped because other indexes or the primary key cover them: duplicates of the same key columns, and indexes whose key columns are a prefix of another index with their STORING columns. Indexes sharing the leading key column, and STORING columns which don't appear in the top queries of SPANNER_SYS.QUERY_STATS_TOP_HOUR are reported for review. Savings are estimated by the sizes of the indexes from SPANNER_SYS.TABLE_SIZES_STATS_1HOUR. Nothing is dropped; review queries using the indexes, e.g. by FORCE_INDEX hints, before dropping them."),
+		withQueryArgs(),
+		mcp.WithString("table",
+			mcp.Description("Check only indexes of the table, optionally qualified by the named schema"),
+		),
+		mcp.WithOutputSchema[findRedundantIndexesOutput](),
+	)
+
+	exportDBML := mcp.NewTool("export_dbml",
+		readOnlyAnnotation("Export DBML"),
+		mcp.WithDescription("Convert the tables of the database in INFORMATION_SCHEMA to DBML to visualize the schema on dbdiagram.io and similar tools: columns with their Spanner types, primary keys, secondary indexes, and Refs from foreign keys and interleaved tables. Refs of interleaved tables are from the key columns of the parent table, with the INTERLEAVE clause in the note of the child table. GoogleSQL databases only."),
+		withQueryArgs(),
+		mcp.WithOutputSchema[exportDBMLOutput](),
+	)
+
+	exportTerraform := mcp.NewTool("export_terraform",
+		readOnlyAnnotation("Export Terraform"),
+		mcp.WithDescription("Render the instance configuration and the current DDL of the database as google_spanner_instance and google_spanner_database resources of the Terraform Google provider, to capture interactive schema changes as code. Database options like version_retention_period and default_leader are in the ddl attribute as ALTER DATABASE statements. With managed autoscaling, the instance has autoscaling_config instead of num_nodes or processing_units."),
+		withDatabaseArgs(),
+		mcp.WithBoolean("include_instance",
+			mcp.DefaultBool(true),
+			mcp.Description("Include google_spanner_instance and reference it from the database. If false, the database refers to the instance by its ID"),
+		),
+		mcp.WithOutputSchema[exportTerraformOutput](),
+	)
+
+	generateClientCode := mcp.NewTool("generate_client_code",
+		readOnlyAnnotation("Generate client code"),
+		mcp.WithDescription("Generate Go, Java or Python code running the query with the Spanner client library: statement construction, parameter binding with placeholder values and row iteration into variables of the column types. Column types are taken from the query plan, so the query is analyzed but not executed. Parameters are bound to NULL of the given types in the analysis."),
+		withQueryArgs(),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query, which can have parameters like @id or $1"),
+		),
+		mcp.WithObject("params",
+			mcp.Description("GoogleSQL types of the parameters keyed by their names, e.g. {\"id\": \"INT64\", \"tags\": \"ARRAY<STRING>\"}. Names of PostgreSQL parameters are p1, p2 and so on"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("language",
+			mcp.DefaultString("go"),
+			mcp.Enum(clientCodeLanguages...),
+			mcp.Description("Language of the code"),
+		),
+		mcp.WithOutputSchema[generateClientCodeOutput](),
 	)
 
-	// Add tool
-	plan := mcp.NewTool("plan",
-		mcp.WithDescription("Get execution plan for the query. The first content is machine-readable prototext format of QueryPlan message. The second content is human-readable rendered query plan."),
-		mcp.WithString("query",
+	stageMutation := mcp.NewTool("stage_mutation",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Stage mutation",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription(fmt.Sprintf("Stage a mutation in this MCP session without writing it, to assemble a change set across calls. Rows and keys are validated against the table when staged. Show the change set to the user by preview_staged, then commit all staged mutations atomically by commit_staged or drop them by discard_staged. All staged mutations are for the same database, at most %d rows and keys in total. Values are JSON like import_data: ARRAY is a JSON array, BYTES is base64, and INT64 beyond 2^53 should be strings.", maxStagedRows)),
+		mcp.WithString("table",
 			mcp.Required(),
-			mcp.Description("query text of SQL or GQL"),
+			mcp.Description("Table name, optionally qualified by the named schema"),
 		),
-		mcp.WithString("project",
+		mcp.WithString("operation",
 			mcp.Required(),
-			mcp.Description("Google Cloud project"),
+			mcp.Enum(stageOperations...),
+			mcp.Description("insert fails on existing rows, update fails on missing rows, insert_or_update writes the given columns, replace deletes other columns, and delete deletes rows by keys"),
 		),
-		mcp.WithString("instance",
+		mcp.WithArray("rows",
+			mcp.Items(map[string]any{"type": "object"}),
+			mcp.Description("Rows to write as objects of column values, for operations other than delete"),
+		),
+		mcp.WithArray("keys",
+			mcp.Items(map[string]any{"type": "array"}),
+			mcp.Description("Primary keys of rows to delete as arrays of the values of all primary key columns in order, e.g. [[1, \"a\"]], for delete"),
+		),
+		mcp.WithBoolean("fill_commit_timestamps",
+			mcp.Description(fmt.Sprintf("Write the commit timestamp to the columns with allow_commit_timestamp which are not in the rows. A column can also be set to the commit timestamp by the value %q", commitTimestampPlaceholder)),
+		),
+		withQueryArgs(),
+		mcp.WithOutputSchema[stagedMutationsOutput](),
+	)
+
+	previewStaged := mcp.NewTool("preview_staged",
+		readOnlyAnnotation("Preview staged mutations"),
+		mcp.WithDescription("Show the mutations staged by stage_mutation in this MCP session with their numbers, rows and keys, for the user to review before commit_staged."),
+		mcp.WithOutputSchema[stagedMutationsOutput](),
+	)
+
+	commitStaged := mcp.NewTool("commit_staged",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Commit staged mutations",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Commit all mutations staged by stage_mutation in this MCP session atomically in a read-write transaction, and clear them. The user is asked to confirm deletes by typing the database ID. If the commit fails, nothing is written and the mutations remain staged."),
+		mcp.WithNumber("expected_rows",
+			mcp.Description("Number of staged rows and keys shown by preview_staged. The commit fails if it differs, e.g. if mutations are staged after the preview"),
+		),
+		mcp.WithOutputSchema[commitStagedOutput](),
+	)
+
+	discardStaged := mcp.NewTool("discard_staged",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Discard staged mutations",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Discard mutations staged by stage_mutation in this MCP session without writing them."),
+		mcp.WithNumber("index",
+			mcp.Min(1),
+			mcp.Description("Number of the mutation to discard shown by preview_staged (default: discard all). The remaining mutations are renumbered"),
+		),
+		mcp.WithOutputSchema[stagedMutationsOutput](),
+	)
+
+	truncateTable := mcp.NewTool("truncate_table",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Truncate table",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Delete all rows of the table by partitioned DML DELETE WHERE true, which isn't limited by the mutation limit of transactions. The user is asked to confirm by typing the database ID. Rows of interleaved tables are deleted if they are ON DELETE CASCADE, otherwise the deletion fails. Partitioned DML isn't atomic, so rows inserted concurrently may remain."),
+		mcp.WithString("table",
 			mcp.Required(),
-			mcp.Description("Spanner instance id"),
+			mcp.Description("Table name, optionally qualified by the named schema"),
 		),
-		mcp.WithString("database",
+		withQueryArgs(),
+		mcp.WithOutputSchema[truncateTableOutput](),
+	)
+
+	startDataflowExport := mcp.NewTool("start_dataflow_export",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Start Dataflow export",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithDescription("Launch the Google-provided Cloud Spanner to Avro Files on Cloud Storage Dataflow template to export the whole database with its schema, for backups in other projects and migrations. The job runs in the project of the database and is billed for Dataflow. Returns immediately with the job ID; use get_dataflow_job to track the status."),
+		mcp.WithString("output_dir",
 			mcp.Required(),
-			mcp.Description("Spanner database id"),
+			mcp.Description("gs://{bucket}/{prefix} to write the export. The template creates a subdirectory named by the instance, database and timestamp"),
 		),
+		withDatabaseArgs(),
+		withDataflowArgs(),
+		mcp.WithArray("tables",
+			mcp.WithStringItems(),
+			mcp.Description("Export only these tables (default: all tables)"),
+		),
+		mcp.WithString("snapshot_time",
+			mcp.Description("RFC 3339 timestamp to read the database at, within the version retention period (default: the launch time)"),
+		),
+		mcp.WithBoolean("data_boost",
+			mcp.DefaultBool(false),
+			mcp.Description("Read the database on Data Boost to avoid impact on the provisioned compute of the instance"),
+		),
+		mcp.WithOutputSchema[dataflowJobOutput](),
 	)
 
-	getDDL := mcp.NewTool("get_ddl",
-		mcp.WithDescription("Get DDL of the database. The first content is the whole response, and the second content is unmarshalled proto_descriptors (optional)."),
-		mcp.WithString("project",
+	startDataflowImport := mcp.NewTool("start_dataflow_import",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Start Dataflow import",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithDescription("Launch the Google-provided Avro Files on Cloud Storage to Cloud Spanner Dataflow template to import an export of start_dataflow_export into the database. The database must exist, and the tables in the export must not exist or be empty. The job runs in the project of the database and is billed for Dataflow. Returns immediately with the job ID; use get_dataflow_job to track the status."),
+		mcp.WithString("input_dir",
 			mcp.Required(),
-			mcp.Description("Google Cloud project"),
+			mcp.Description("gs://{bucket}/{prefix} of the export which contains spanner-export.json"),
 		),
-		mcp.WithString("instance",
+		withDatabaseArgs(),
+		withDataflowArgs(),
+		mcp.WithBoolean("wait_for_indexes",
+			mcp.DefaultBool(false),
+			mcp.Description("Wait for secondary indexes to be created before the job finishes"),
+		),
+		mcp.WithOutputSchema[dataflowJobOutput](),
+	)
+
+	getDataflowJob := mcp.NewTool("get_dataflow_job",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Get Dataflow job",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithDescription("Get the status of a Dataflow job started by start_dataflow_export or start_dataflow_import, with recent error messages if it failed."),
+		mcp.WithString("job_id",
 			mcp.Required(),
-			mcp.Description("Spanner instance id"),
+			mcp.Description("ID of the Dataflow job"),
 		),
-		mcp.WithString("database",
+		mcp.WithString("region",
 			mcp.Required(),
-			mcp.Description("Spanner database id"),
+			mcp.Description("Region of the Dataflow job"),
+		),
+		mcp.WithString("profile",
+			mcp.Description("Name of the connection profile in the config file. Alternative to project"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Google Cloud project of the job"),
 		),
+		mcp.WithOutputSchema[dataflowJobOutput](),
+	)
+
+	getDDL := mcp.NewTool("get_ddl",
+		readOnlyAnnotation("Get DDL"),
+		mcp.WithDescription("Get DDL of the database. The first content is the whole response in proto_format, and the second content is unmarshalled proto_descriptors (optional). If proto_format is none, the content is the DDL statements."),
+		withDatabaseArgs(),
 		mcp.WithBoolean("include_proto_descriptors",
 			mcp.DefaultBool(false),
 			mcp.Description("Enable only if proto_descriptors is needed."),
 		),
+		withProtoFormatArg(),
+		mcp.WithOutputSchema[getDDLOutput](),
 	)
 
 	updateDDL := mcp.NewTool("update_ddl",
-		mcp.WithDescription("Update DDL of the database"),
-		mcp.WithString("project",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Update DDL",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Update DDL of the database. Statements are given directly or read from a schema file in Cloud Storage or within the roots of the client. Destructive statements like DROP require the user to confirm by typing the database ID."),
+		withDatabaseArgs(),
+		mcp.WithArray("statements",
+			mcp.Description("DDL statements. Either statements or file is required"),
+		),
+		mcp.WithString("file",
+			mcp.Description("gs://{bucket}/{object}, or a path or file:// URI within the roots of the client or the DDL directory of the server, of a file of DDL statements separated by semicolons. Relative paths are resolved against the roots"),
+		),
+		withProtoFormatArg(),
+		mcp.WithOutputSchema[updateDDLOutput](),
+	)
+
+	listStatisticsPackages := mcp.NewTool("list_statistics_packages",
+		readOnlyAnnotation("List statistics packages"),
+		mcp.WithDescription("List optimizer statistics packages of the database from INFORMATION_SCHEMA.SPANNER_STATISTICS with whether they are garbage collected, and the package pinned by the optimizer_statistics_package database option."),
+		withQueryArgs(),
+		mcp.WithOutputSchema[listStatisticsPackagesOutput](),
+	)
+
+	setStatisticsPackage := mcp.NewTool("set_statistics_package",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Set statistics package",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Pin the optimizer statistics package used by queries of the database by ALTER DATABASE SET OPTIONS (optimizer_statistics_package), e.g. to keep plans stable or to roll back after a plan regression. An empty package unpins it, so the latest package is used. allow_gc: false additionally keeps the package from garbage collection."),
+		withDatabaseArgs(),
+		mcp.WithString("package",
+			mcp.Description("Name of the statistics package from list_statistics_packages, or empty to unpin"),
+		),
+		mcp.WithBoolean("allow_gc",
+			mcp.Description("Set allow_gc of the package by ALTER STATISTICS (default: unchanged)"),
+		),
+		mcp.WithOutputSchema[setStatisticsPackageOutput](),
+	)
+
+	statisticsFreshness := mcp.NewTool("statistics_freshness",
+		readOnlyAnnotation("Statistics freshness"),
+		mcp.WithDescription("Report the age of the optimizer statistics package used by queries of the database, which is the pinned package or the latest one, from the construction time in its name. Statistics older than max_age_hours are stale, e.g. because the automatic construction every three days is disabled or falls behind, or an old package is pinned. Stale statistics can cause bad plans after large data changes, and are fixed by analyze_database or by unpinning with set_statistics_package."),
+		withQueryArgs(),
+		mcp.WithNumber("max_age_hours",
+			mcp.DefaultNumber(defaultStatisticsMaxAgeHours),
+			mcp.Description("Age in hours beyond which statistics are stale"),
+		),
+		mcp.WithOutputSchema[statisticsFreshnessOutput](),
+	)
+
+	analyzeDatabase := mcp.NewTool("analyze_database",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Analyze database",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Construct a new optimizer statistics package by the ANALYZE DDL statement instead of waiting for the automatic construction, e.g. after loading data or when statistics_freshness reports stale statistics. Returns the name of the new package and the freshness of the statistics used by queries afterwards. It may take minutes on large databases, and progress is notified if requested. With if_older_than_hours, ANALYZE is skipped if the latest package is younger."),
+		withDatabaseArgs(),
+		mcp.WithNumber("if_older_than_hours",
+			mcp.Min(0),
+			mcp.Description("Run ANALYZE only if the latest statistics package is older than this number of hours (default: always run)"),
+		),
+		mcp.WithOutputSchema[analyzeDatabaseOutput](),
+	)
+
+	listPropertyGraphs := mcp.NewTool("list_property_graphs",
+		readOnlyAnnotation("List property graphs"),
+		mcp.WithDescription("List property graphs defined by CREATE PROPERTY GRAPH from INFORMATION_SCHEMA.PROPERTY_GRAPHS with their node and edge tables, labels and properties. Use it to explore the graph model before writing GQL queries."),
+		withQueryArgs(),
+		mcp.WithString("graph",
+			mcp.Description("Name of the property graph, optionally qualified by the named schema (default: all graphs)"),
+		),
+		mcp.WithOutputSchema[listPropertyGraphsOutput](),
+	)
+
+	graphSchemaDiagram := mcp.NewTool("graph_schema_diagram",
+		readOnlyAnnotation("Graph schema diagram"),
+		mcp.WithDescription("Render the node and edge tables of a property graph with their labels and properties as a diagram in mermaid flowchart or Graphviz DOT. Node tables are boxes and edge tables are arrows from the source to the destination node tables."),
+		withQueryArgs(),
+		mcp.WithString("graph",
 			mcp.Required(),
+			mcp.Description("Name of the property graph, optionally qualified by the named schema"),
+		),
+		mcp.WithString("format",
+			mcp.Enum(diagramFormats...),
+			mcp.DefaultString("mermaid"),
+			mcp.Description("Diagram language"),
+		),
+		mcp.WithOutputSchema[graphSchemaDiagramOutput](),
+	)
+
+	createBackup := mcp.NewTool("create_backup",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Create backup",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Start creating a backup of the database in its instance. Returns immediately with the long-running operation; use list_backups to track the state. The backup is encrypted like the database unless encryption_type is given."),
+		mcp.WithString("backup_id",
+			mcp.Required(),
+			mcp.Description("ID of the new backup"),
+		),
+		withDatabaseArgs(),
+		mcp.WithString("expire_time",
+			mcp.Description("RFC 3339 timestamp when the backup is deleted, at most 1 year after the creation (default: 7 days later)"),
+		),
+		mcp.WithString("version_time",
+			mcp.Description("RFC 3339 timestamp of the data to back up, within the version retention period (default: the creation time)"),
+		),
+		mcp.WithString("encryption_type",
+			mcp.Enum("use_database_encryption", "google_default_encryption", "customer_managed_encryption"),
+			mcp.Description("use_database_encryption, google_default_encryption, or customer_managed_encryption with kms_key_names (default: use_database_encryption)"),
+		),
+		mcp.WithArray("kms_key_names",
+			mcp.WithStringItems(),
+			mcp.Description("Cloud KMS keys projects/{project}/locations/{location}/keyRings/{key_ring}/cryptoKeys/{key} for customer_managed_encryption. Multi-region instances require a key in each region of the instance"),
+		),
+		mcp.WithOutputSchema[createBackupOutput](),
+	)
+
+	listBackups := mcp.NewTool("list_backups",
+		mcp.WithDescription("List backups in the instance, or only backups of the database if database is given. The content is tab-separated backup IDs, databases, states, times, sizes, encryption types and KMS key versions."),
+		readOnlyAnnotation("List backups"),
+		withDatabaseArgs(),
+		mcp.WithOutputSchema[listBackupsOutput](),
+	)
+
+	listBackupChains := mcp.NewTool("list_backup_chains",
+		mcp.WithDescription("Show incremental backup chains in the instance, or only chains of the database if database is given: the full backup and the backup each incremental backup depends on, the size of the chain and the range of restorable version times. Deleting a backup in a chain merges its data into the next backup, so freeable_size_bytes is the storage saved by deleting it."),
+		readOnlyAnnotation("List backup chains"),
+		withDatabaseArgs(),
+		mcp.WithOutputSchema[listBackupChainsOutput](),
+	)
+
+	backupCoverage := mcp.NewTool("backup_coverage",
+		mcp.WithDescription("Audit backups of every database in the instance: the latest ready backup and its age, backups expiring soon, and databases without backups. Status is unprotected without ready backups, stale if the latest backup is older than stale_after_hours, expiring if all backups expire within expiring_within_hours, and protected otherwise."),
+		readOnlyAnnotation("Backup coverage"),
+		mcp.WithString("profile",
+			mcp.Description("Name of the connection profile in the config file. Alternative to project and instance"),
+		),
+		mcp.WithString("project",
 			mcp.Description("Google Cloud project"),
 		),
 		mcp.WithString("instance",
-			mcp.Required(),
 			mcp.Description("Spanner instance id"),
 		),
-		mcp.WithString("database",
+		mcp.WithNumber("expiring_within_hours",
+			mcp.DefaultNumber(defaultExpiringWithinHours),
+			mcp.Description("Report backups expiring within this number of hours"),
+		),
+		mcp.WithNumber("stale_after_hours",
+			mcp.DefaultNumber(defaultStaleAfterHours),
+			mcp.Description("Report databases whose latest backup is older than this number of hours"),
+		),
+		mcp.WithOutputSchema[backupCoverageOutput](),
+	)
+
+	tailChangeStream := mcp.NewTool("tail_change_stream",
+		readOnlyAnnotation("Tail change stream"),
+		mcp.WithDescription("Follow a change stream from now for the duration or until max_records data change records are read. Each data change record is also sent as a log notification as it arrives, and progress is notified if requested. The content is the summary line followed by data change records in JSON Lines."),
+		withQueryArgs(),
+		mcp.WithString("change_stream",
 			mcp.Required(),
-			mcp.Description("Spanner database id"),
+			mcp.Description("Change stream name"),
 		),
-		mcp.WithArray("statements",
+		mcp.WithNumber("duration_seconds",
+			mcp.DefaultNumber(10),
+			mcp.Description("How long to follow the change stream in seconds"),
+		),
+		mcp.WithNumber("max_records",
+			mcp.DefaultNumber(100),
+			mcp.Description("Stop after this number of data change records"),
+		),
+		mcp.WithOutputSchema[tailChangeStreamOutput](),
+	)
+
+	inspectChangeStreamPartitions := mcp.NewTool("inspect_change_stream_partitions",
+		readOnlyAnnotation("Inspect change stream partitions"),
+		mcp.WithDescription("Walk child partition records of a change stream between start_timestamp and end_timestamp and render the partition lineage (splits and merges over time). The first content is partitions in JSON. The second content is human-readable rendered lineage tree."),
+		withQueryArgs(),
+		mcp.WithString("change_stream",
+			mcp.Required(),
+			mcp.Description("Change stream name"),
+		),
+		mcp.WithString("start_timestamp",
+			mcp.Description("Start timestamp in RFC 3339 format within the retention period (default: 10 minutes before end_timestamp)"),
+		),
+		mcp.WithString("end_timestamp",
+			mcp.Description("End timestamp in RFC 3339 format (default: now)"),
+		),
+		mcp.WithOutputSchema[inspectChangeStreamPartitionsOutput](),
+	)
+
+	useDatabase := mcp.NewTool("use_database",
+		readOnlyAnnotation("Use database"),
+		mcp.WithDescription("Set the default database of this MCP session like USE statement, so subsequent tool calls can omit profile, project, instance and database. Call without arguments to show the current default database."),
+		withDatabaseArgs(),
+		mcp.WithBoolean("clear",
+			mcp.DefaultBool(false),
+			mcp.Description("Clear the default database of this session"),
+		),
+		mcp.WithOutputSchema[useDatabaseOutput](),
+	)
+
+	serverInfo := mcp.NewTool("server_info",
+		mcp.WithDescription("Get the version of spanner-mcp, enabled tools, the default profile and database, client library versions and the principal which accesses Spanner. Useful to debug multi-environment setups."),
+		readOnlyAnnotation("Server info"),
+		mcp.WithOutputSchema[serverInfoOutput](),
+	)
+
+	ping := mcp.NewTool("ping",
+		mcp.WithDescription("Verify connectivity to the database: resolve the credentials, confirm the database exists and run SELECT 1. Returns the round-trip latency and the principal. Call this before a long workflow."),
+		readOnlyAnnotation("Ping"),
+		withQueryArgs(),
+		mcp.WithOutputSchema[pingOutput](),
+	)
+
+	poolStats := mcp.NewTool("pool_stats",
+		mcp.WithDescription("Get diagnostics of the Spanner clients cached by the server: tool calls using each client, and session pool metrics including open, in-use and idle sessions, the maximum in-use sessions in the last 10 minutes, checkouts and checkout timeouts, and the latency in Google's network. The client library doesn't measure checkout latency, so timeouts and max_in_use_sessions close to max_allowed_sessions indicate contention; raise client.max_sessions or enable multiplexed sessions."),
+		readOnlyAnnotation("Pool stats"),
+		mcp.WithOutputSchema[poolStatsOutput](),
+	)
+
+	sessionHistory := mcp.NewTool("session_history",
+		mcp.WithDescription("Get recent tool calls of this MCP session with their arguments, errors, started operations, commit timestamps and affected rows."),
+		readOnlyAnnotation("Session history"),
+		mcp.WithOutputSchema[sessionHistoryOutput](),
+	)
+
+	history := mcp.NewTool("history",
+		mcp.WithDescription("List queries, DML and DDL run by plan, execute_query, execute_partitioned_query, execute_fanout_query, execute_gql, execute_dml and update_ddl in this MCP session with their indexes for replay."),
+		readOnlyAnnotation("Query history"),
+		mcp.WithOutputSchema[historyOutput](),
+	)
+
+	replay := mcp.NewTool("replay",
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Replay",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithDescription("Run a query, DML or DDL in the history of this MCP session again by its index, optionally with modified arguments, e.g. {\"query\": \"...\"} to run a tuned query on the same database or {\"max_rows\": 10}. The result is the result of the replayed tool, which is called with the same checks and confirmations as a call from the client."),
+		mcp.WithNumber("index",
 			mcp.Required(),
-			mcp.Description("DDL statements"),
+			mcp.Description("Index of the tool call in history"),
 		),
+		mcp.WithObject("arguments",
+			mcp.Description("Arguments overriding the recorded arguments. null removes the argument"),
+		),
+	)
+
+	explainAsCLI := mcp.NewTool("explain_as_cli",
+		mcp.WithDescription("Get the gcloud and spanner-cli command lines equivalent to a tool call, e.g. to reproduce it outside of MCP or to share it with people without the server. Give tool and arguments, or index of a call in session_history. Arguments are resolved like the tool call, including profiles and the session database, but nothing is run."),
+		readOnlyAnnotation("Explain as CLI"),
+		mcp.WithString("tool",
+			mcp.Description("Name of the tool, e.g. execute_query"),
+		),
+		mcp.WithObject("arguments",
+			mcp.Description("Arguments of the tool call"),
+		),
+		mcp.WithNumber("index",
+			mcp.Description("Index of the tool call in session_history or history, instead of tool and arguments"),
+		),
+		mcp.WithOutputSchema[explainAsCLIOutput](),
+	)
+
+	listDatabases := mcp.NewTool("list_databases",
+		mcp.WithDescription("List databases in the instance. The content is tab-separated database IDs, states and dialects."),
+		readOnlyAnnotation("List databases"),
+		mcp.WithString("profile",
+			mcp.Description("Name of the connection profile in the config file. Alternative to project and instance"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Google Cloud project"),
+		),
+		mcp.WithString("instance",
+			mcp.Description("Spanner instance id"),
+		),
+		mcp.WithOutputSchema[listDatabasesOutput](),
 	)
 
-	// Add plan handler
-	s.AddTool(plan, planHandler)
-	s.AddTool(getDDL, getDDLHandler)
-	s.AddTool(updateDDL, updateDDLHandler)
+	// Add tools
+	tools := []toolEntry{
+		{tool: plan, handler: planHandler},
+		{tool: profileTopQuery, handler: profileTopQueryHandler},
+		{tool: executeQuery, handler: executeQueryHandler},
+		{tool: executePartitionedQuery, handler: executePartitionedQueryHandler},
+		{tool: executeFanoutQuery, handler: executeFanoutQueryHandler},
+		{tool: executeGQL, handler: executeGQLHandler},
+		{tool: executeDML, handler: executeDMLHandler},
+		{tool: lintQuery, handler: lintQueryHandler},
+		{tool: lintSchema, handler: lintSchemaHandler},
+		{tool: estimateCost, handler: estimateCostHandler},
+		{tool: indexImpact, handler: indexImpactHandler},
+		{tool: whatif, handler: whatifHandler},
+		{tool: sampleRows, handler: sampleRowsHandler},
+		{tool: countRows, handler: countRowsHandler},
+		{tool: keyDistribution, handler: keyDistributionHandler},
+		{tool: exportToGCS, handler: exportToGCSHandler},
+		{tool: importData, handler: importDataHandler},
+		{tool: generateData, handler: generateDataHandler},
+		{tool: createTableAs, handler: createTableAsHandler},
+		{tool: stageMutation, handler: stageMutationHandler},
+		{tool: previewStaged, handler: previewStagedHandler},
+		{tool: commitStaged, handler: commitStagedHandler},
+		{tool: discardStaged, handler: discardStagedHandler},
+		{tool: generateClientCode, handler: generateClientCodeHandler},
+		{tool: exportTerraform, handler: exportTerraformHandler},
+		{tool: exportDBML, handler: exportDBMLHandler},
+		{tool: listTables, handler: listTablesHandler},
+		{tool: describeTable, handler: describeTableHandler},
+		{tool: listCommitTimestampColumns, handler: listCommitTimestampColumnsHandler},
+		{tool: tableTree, handler: tableTreeHandler},
+		{tool: listColumnExpressions, handler: listColumnExpressionsHandler},
+		{tool: listConstraints, handler: listConstraintsHandler},
+		{tool: checkReferentialIntegrity, handler: checkReferentialIntegrityHandler},
+		{tool: findRedundantIndexes, handler: findRedundantIndexesHandler},
+		{tool: generateAlertPolicies, handler: generateAlertPoliciesHandler},
+		{tool: capacityPlanning, handler: capacityPlanningHandler},
+		{tool: truncateTable, handler: truncateTableHandler},
+		{tool: startDataflowExport, handler: startDataflowExportHandler},
+		{tool: startDataflowImport, handler: startDataflowImportHandler},
+		{tool: getDataflowJob, handler: getDataflowJobHandler},
+		{tool: getDDL, handler: getDDLHandler},
+		{tool: updateDDL, handler: updateDDLHandler},
+		{tool: listPropertyGraphs, handler: listPropertyGraphsHandler},
+		{tool: graphSchemaDiagram, handler: graphSchemaDiagramHandler},
+		{tool: listStatisticsPackages, handler: listStatisticsPackagesHandler},
+		{tool: setStatisticsPackage, handler: setStatisticsPackageHandler},
+		{tool: statisticsFreshness, handler: statisticsFreshnessHandler},
+		{tool: analyzeDatabase, handler: analyzeDatabaseHandler},
+		{tool: createBackup, handler: createBackupHandler},
+		{tool: listBackups, handler: listBackupsHandler},
+		{tool: listBackupChains, handler: listBackupChainsHandler},
+		{tool: backupCoverage, handler: backupCoverageHandler},
+		{tool: tailChangeStream, handler: tailChangeStreamHandler},
+		{tool: inspectChangeStreamPartitions, handler: inspectChangeStreamPartitionsHandler},
+		{tool: useDatabase, handler: useDatabaseHandler},
+		{tool: serverInfo, handler: serverInfoHandler},
+		{tool: ping, handler: pingHandler},
+		{tool: poolStats, handler: poolStatsHandler},
+		{tool: sessionHistory, handler: sessionHistoryHandler},
+		{tool: history, handler: historyHandler},
+		{tool: replay, handler: replayHandler},
+		{tool: explainAsCLI, handler: explainAsCLIHandler},
+		{tool: listDatabases, handler: listDatabasesHandler},
+	}
+	for name := range cfg.Limits.Tools {
+		if !slices.ContainsFunc(tools, func(t toolEntry) bool { return t.tool.Name == name }) {
+			fatal("invalid limits", fmt.Errorf("unknown tool: %s", name))
+		}
+	}
+
+	tools, err = filterTools(tools, *readOnly, splitList(*enableTools), splitList(*disableTools))
+	if err != nil {
+		fatal("invalid tool filter", err)
+	}
+	for i := range tools {
+		if tools[i].tool.Name != "replay" {
+			withTimeoutArg(&tools[i].tool)
+		}
+	}
+	toolTiers = lo.SliceToMap(tools, func(t toolEntry) (string, string) { return t.tool.Name, toolTier(t) })
+	if *dynamicToolsFlag {
+		dynamicTools, tools = lo.FilterReject(tools, func(t toolEntry, _ int) bool {
+			_, ok := featureTools[t.tool.Name]
+			return ok
+		})
+	}
+	for _, t := range tools {
+		s.AddTool(t.tool, t.handler)
+	}
+	registerResources(s)
+	registerPlanSnapshotResources(s)
+	registerPrompts(s)
+
+	c, err := newClientCache(context.Background(), *clientIdleTimeout, cfg.Client)
+	if err != nil {
+		fatal("failed to configure clients", err)
+	}
+	clients = c
+	defer clients.Close()
+
+	if *schemaPollInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go watchSchemas(ctx, s, *schemaPollInterval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := serve(ctx, s, *transport, *listen, *baseURL, auth, *shutdownTimeout, *metricsPath, metricsHandler); err != nil {
+		slog.Error("server error", "error", err)
+	}
+}
+
+// toolEntry is a tool and its handler.
+type toolEntry struct {
+	tool    mcp.Tool
+	handler server.ToolHandlerFunc
+}
+
+// readOnlyAnnotation annotates the tool which doesn't modify databases.
+// use_database is also read-only because it only changes the state of the MCP session.
+func readOnlyAnnotation(title string) mcp.ToolOption {
+	return mcp.WithToolAnnotation(mcp.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcp.ToBoolPtr(true),
+		DestructiveHint: mcp.ToBoolPtr(false),
+		IdempotentHint:  mcp.ToBoolPtr(true),
+		OpenWorldHint:   mcp.ToBoolPtr(false),
+	})
+}
+
+// filterTools returns the tools to expose.
+// Tools without readOnlyHint are not exposed at all in read-only mode regardless of enable.
+func filterTools(tools []toolEntry, readOnly bool, enable, disable []string) ([]toolEntry, error) {
+	names := lo.Map(tools, func(t toolEntry, _ int) string { return t.tool.Name })
+	if unknown, _ := lo.Difference(slices.Concat(enable, disable), names); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown tools: %s", strings.Join(unknown, ", "))
+	}
+
+	return lo.Filter(tools, func(t toolEntry, _ int) bool {
+		switch {
+		case readOnly && !lo.FromPtr(t.tool.Annotations.ReadOnlyHint):
+			return false
+		case len(enable) > 0 && !lo.Contains(enable, t.tool.Name):
+			return false
+		default:
+			return !lo.Contains(disable, t.tool.Name)
+		}
+	}), nil
+}
+
+// serve serves the MCP server until ctx is done, then drains in-flight tool calls.
+// metrics is served at metricsPath if not nil.
+func serve(ctx context.Context, s *server.MCPServer, transport, listen, baseURL string, auth *authConfig, shutdownTimeout time.Duration, metricsPath string, metrics http.Handler) error {
+	mux := http.NewServeMux()
+	switch transport {
+	case "stdio":
+		return serveStdio(ctx, s, shutdownTimeout)
+	case "sse":
+		mux.Handle("/", auth.middleware(server.NewSSEServer(s, server.WithBaseURL(baseURL))))
+		slog.Info("SSE server listening", "addr", listen)
+	case "http":
+		mux.Handle("/mcp", auth.middleware(server.NewStreamableHTTPServer(s)))
+		slog.Info("streamable HTTP server listening", "addr", listen, "path", "/mcp")
+	default:
+		return fmt.Errorf("unknown transport: %q", transport)
+	}
+
+	if metrics != nil {
+		mux.Handle(metricsPath, metrics)
+		slog.Info("serving Prometheus metrics", "path", metricsPath)
+	}
+
+	// Requests are cancelled by baseCtx after draining, so long-lived SSE streams don't block Shutdown.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	srv := &http.Server{
+		Addr:        listen,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+	calls.drain(shutdownTimeout)
+	cancelBase()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(err, srv.Close())
+	}
+	return nil
+}
+
+func serveStdio(ctx context.Context, s *server.MCPServer, shutdownTimeout time.Duration) error {
+	stdio := server.NewStdioServer(s)
+	stdio.SetErrorLogger(slog.NewLogLogger(slog.Default().Handler(), slog.LevelError))
+
+	// In-flight tool calls are derived from listenCtx, so it is cancelled after draining.
+	listenCtx, cancelListen := context.WithCancel(context.Background())
+	defer cancelListen()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- stdio.Listen(listenCtx, os.Stdin, os.Stdout) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+	calls.drain(shutdownTimeout)
+	return nil
+}
+
+// defaultListenAddr respects PORT environment variable set by Cloud Run.
+func defaultListenAddr() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":8080"
+}
 
-	// Start the stdio server
-	if err := server.ServeStdio(s); err != nil {
-		fmt.Printf("Server error: %v\n", err)
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return fallback
+}
+
+func envBool(key string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(key))
+	return b
+}
+
+func splitList(s string) []string {
+	return lo.Compact(lo.Map(strings.Split(s, ","), func(item string, _ int) string {
+		return strings.TrimSpace(item)
+	}))
 }
 
 func planHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	req, err := mapToStruct[struct {
-		Query    string
-		Project  string
-		Instance string
-		Database string
-	}](request.Params.Arguments)
-
-	client, err := spanner.NewClient(ctx, databasePath(req.Project, req.Instance, req.Database))
+		queryArgs   `mapstructure:",squash"`
+		Query       string `mapstructure:"query"`
+		ProtoFormat string `mapstructure:"proto_format"`
+		Summary     bool   `mapstructure:"summary"`
+		SaveAs      string `mapstructure:"save_as"`
+	}](request.GetArguments())
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
+	if req.SaveAs != "" {
+		if planDir == "" {
+			return nil, &validationError{Field: "save_as", Message: "plan snapshots are disabled, start the server with --plan-dir"}
+		}
+		if err := validatePlanSnapshotName("save_as", req.SaveAs); err != nil {
+			return nil, err
+		}
+	}
 
-	qp, err := client.Single().AnalyzeQuery(ctx, spanner.NewStatement(req.Query))
+	target, err := req.target(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	plan := queryplan.New(qp.GetPlanNodes())
-	processed, err := plantree.ProcessPlan(plan)
+	qp, processed, err := analyzeQuery(ctx, target, req.Query)
 	if err != nil {
 		return nil, err
 	}
@@ -138,35 +1785,89 @@ func planHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 	if err != nil {
 		return nil, err
 	}
+	operators := planOperators(processed)
+
+	var saved []mcp.Content
+	if req.SaveAs != "" {
+		if err := savePlanSnapshot(ctx, target, req.SaveAs, req.Query, qp, operators, result); err != nil {
+			return nil, err
+		}
+		saved = []mcp.Content{
+			mcp.NewTextContent(fmt.Sprintf("Saved the plan as %s\n", planSnapshotURI(req.SaveAs))),
+			planSnapshotLink(req.SaveAs),
+		}
+	}
+
+	if req.Summary {
+		summary := summarizePlan(qp)
+		output := planOutput{Summary: &summary}
+		if req.SaveAs != "" {
+			output.Snapshot = planSnapshotURI(req.SaveAs)
+		}
+		return &mcp.CallToolResult{
+			Content:           append([]mcp.Content{mcp.NewTextContent(renderPlanSummary(summary))}, saved...),
+			StructuredContent: output,
+		}, nil
+	}
+
+	format := protoFormat(req.ProtoFormat)
+	output := planOutput{Operators: operators, GraphOperators: gqlPlanOperators(ctx, target, req.Query, qp)}
+	result += renderGraphPlanOperators(output.GraphOperators)
+	if format != protoFormatNone {
+		if output.QueryPlan, err = protoToJSONValue(qp); err != nil {
+			return nil, err
+		}
+	}
+	if req.SaveAs != "" {
+		output.Snapshot = planSnapshotURI(req.SaveAs)
+	}
 
 	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.NewTextContent(prototext.Format(qp)),
-			mcp.NewTextContent(result),
-		},
+		Content:           append(append(formatProto(format, qp), mcp.NewTextContent(result)), saved...),
+		StructuredContent: output,
 	}, nil
 }
 
+// analyzeQuery returns the query plan of the query and its operators rendered as a tree.
+func analyzeQuery(ctx context.Context, target *profile, query string) (*sppb.QueryPlan, []plantree.RowWithPredicates, error) {
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	var qp *sppb.QueryPlan
+	err = retry(ctx, func(ctx context.Context) error {
+		qp, err = client.Single().AnalyzeQuery(ctx, spanner.NewStatement(query))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	processed, err := plantree.ProcessPlan(queryplan.New(qp.GetPlanNodes()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return qp, processed, nil
+}
+
 func getDDLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	req, err := mapToStruct[struct {
-		Project                 string
-		Instance                string
-		Database                string
-		IncludeProtoDescriptors bool
-	}](request.Params.Arguments)
+		databaseArgs            `mapstructure:",squash"`
+		IncludeProtoDescriptors bool   `mapstructure:"include_proto_descriptors"`
+		ProtoFormat             string `mapstructure:"proto_format"`
+	}](request.GetArguments())
 	if err != nil {
 		return nil, err
 	}
 
-	client, err := database.NewDatabaseAdminClient(ctx)
+	target, err := req.target(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
-	resp, err := client.GetDatabaseDdl(ctx, &databasepb.GetDatabaseDdlRequest{
-		Database: databasePath(req.Project, req.Instance, req.Database),
-	})
+	resp, err := getDatabaseDDL(ctx, target)
 	if err != nil {
 		return nil, err
 	}
@@ -177,46 +1878,116 @@ func getDDLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 	}
 
 	var contents []mcp.Content
+	output := getDDLOutput{Statements: resp.GetStatements()}
+	format := protoFormat(req.ProtoFormat)
 
-	if req.IncludeProtoDescriptors {
-		contents = append(contents, mcp.NewTextContent(prototext.Format(resp)))
-		contents = append(contents, mcp.NewTextContent(prototext.Format(&fds)))
-	} else {
+	switch {
+	case format == protoFormatNone:
+		contents = append(contents, mcp.NewTextContent(formatStatements(resp.GetStatements())))
+	case req.IncludeProtoDescriptors:
+		contents = append(contents, formatProto(format, resp)...)
+		contents = append(contents, formatProto(format, &fds)...)
+		if output.ProtoDescriptors, err = protoToJSONValue(&fds); err != nil {
+			return nil, err
+		}
+	default:
 		resp.ProtoDescriptors = nil
-		contents = append(contents, mcp.NewTextContent(prototext.Format(resp)))
+		contents = append(contents, formatProto(format, resp)...)
 	}
 
 	return &mcp.CallToolResult{
-		Content: contents,
+		Content:           contents,
+		StructuredContent: output,
 	}, nil
 }
 
 func updateDDLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	req, err := mapToStruct[struct {
-		Project    string
-		Instance   string
-		Database   string
-		Statements []string
-	}](request.Params.Arguments)
+		databaseArgs `mapstructure:",squash"`
+		Statements   []string `mapstructure:"statements"`
+		File         string   `mapstructure:"file"`
+		ProtoFormat  string   `mapstructure:"proto_format"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if (len(req.Statements) > 0) == (req.File != "") {
+		return nil, &validationError{Field: "statements", Message: "specify either statements or file"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.File != "" {
+		if req.Statements, err = readDDLFile(ctx, req.File); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := confirmStatements(ctx, target, req.Statements); err != nil {
+		return nil, err
+	}
+
+	metadata, err := applyDDL(ctx, target, req.Statements)
+	if err != nil {
+		return nil, err
+	}
+
+	format := protoFormat(req.ProtoFormat)
+	if format == protoFormatNone {
+		return mcp.NewToolResultStructured(updateDDLOutput{}, fmt.Sprintf("Applied %d statements to %s", len(req.Statements), target.databasePath())), nil
+	}
+
+	metadataJSON, err := protoToJSONValue(metadata)
 	if err != nil {
 		return nil, err
 	}
+	return &mcp.CallToolResult{
+		Content:           formatProto(format, metadata),
+		StructuredContent: updateDDLOutput{Metadata: metadataJSON},
+	}, nil
+}
 
-	client, err := database.NewDatabaseAdminClient(ctx)
+// applyDDL applies the statements to the database and waits for the operation. Callers confirm destructive statements beforehand.
+func applyDDL(ctx context.Context, target *profile, statements []string) (*databasepb.UpdateDatabaseDdlMetadata, error) {
+	return applyDDLWithProgress(ctx, target, statements, nil)
+}
+
+// ddlPollInterval is the interval of polling operations of DDL statements whose progress is reported.
+const ddlPollInterval = 5 * time.Second
+
+// applyDDLWithProgress is applyDDL which passes the metadata of the running operation to progress on each poll, if it is not nil.
+func applyDDLWithProgress(ctx context.Context, target *profile, statements []string, progress func(*databasepb.UpdateDatabaseDdlMetadata)) (*databasepb.UpdateDatabaseDdlMetadata, error) {
+	client, err := clients.adminClient(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
+	// UpdateDatabaseDdl is not retried because it is not idempotent.
 	resp, err := client.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
-		Database:   databasePath(req.Project, req.Instance, req.Database),
-		Statements: req.Statements,
+		Database:   target.databasePath(),
+		Statements: statements,
 	})
 	if err != nil {
 		return nil, err
 	}
-	err = resp.Wait(ctx)
+	auditOperation(ctx, resp.Name())
+
+	err = waitDDL(ctx, resp, progress)
 	if err != nil {
+		if ctx.Err() != nil {
+			// The tool call is cancelled, so the operation should not continue in background.
+			cancelOperation(ctx, client, resp.Name())
+			return nil, err
+		}
+		// Statements before the failed one are committed and have commit timestamps.
+		if metadata, metaErr := resp.Metadata(); metaErr == nil {
+			if i := len(metadata.GetCommitTimestamps()); i < len(statements) {
+				return nil, fmt.Errorf("statement %d of %d failed and the following statements are not applied: %s: %w", i+1, len(statements), statements[i], err)
+			}
+		}
 		return nil, err
 	}
 
@@ -225,7 +1996,47 @@ func updateDDLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		return nil, err
 	}
 
-	return mcp.NewToolResultText(prototext.Format(metadata)), nil
+	for _, ts := range metadata.GetCommitTimestamps() {
+		auditCommitTimestamps(ctx, ts.AsTime())
+	}
+
+	notifyResourcesUpdated(server.ServerFromContext(ctx), target, statements)
+	forgetDatabaseFeatures(target)
+	updateSessionTools(ctx, target)
+	return metadata, nil
+}
+
+func waitDDL(ctx context.Context, op *database.UpdateDatabaseDdlOperation, progress func(*databasepb.UpdateDatabaseDdlMetadata)) error {
+	if progress == nil {
+		return op.Wait(ctx)
+	}
+	ticker := time.NewTicker(ddlPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := op.Poll(ctx); err != nil || op.Done() {
+			return err
+		}
+		if metadata, err := op.Metadata(); err == nil && metadata != nil {
+			progress(metadata)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// cancelOperation cancels the long-running operation on a best-effort basis.
+func cancelOperation(ctx context.Context, client *database.DatabaseAdminClient, name string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if err := client.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: name}); err != nil {
+		slog.WarnContext(ctx, "failed to cancel operation", "operation", name, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "cancelled operation", "operation", name)
 }
 
 func databasePath(project string, instance string, database string) string {
@@ -234,11 +2045,8 @@ func databasePath(project string, instance string, database string) string {
 
 func printResult(rows []plantree.RowWithPredicates) (string, error) {
 	var b strings.Builder
-	table := tablewriter.NewWriter(&b)
-	table.SetAutoFormatHeaders(false)
-	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table := newTable(&b)
 	table.SetColumnAlignment([]int{tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
-	table.SetAutoWrapText(false)
 
 	for _, row := range rows {
 		table.Append([]string{row.FormatID(), row.Text()})
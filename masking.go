@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// Methods of masking rules.
+const (
+	maskHash   = "hash"
+	maskRedact = "redact"
+)
+
+// redactedValue replaces values of columns masked by redact.
+const redactedValue = "[REDACTED]"
+
+// maskingOptions configures masking of sensitive columns in results of queries and change streams,
+// so the values never reach the client even if the columns are queried by mistake.
+//
+//	masking:
+//	  rules:
+//	    - columns: [Users.Email, "*.PhoneNumber"]
+//	      method: hash
+//	    - columns: [Payments.Card*]
+//	      method: redact
+type maskingOptions struct {
+	// HashKey is the HMAC key of hashed values. If it is empty, a random key is generated at startup,
+	// so hashes can be compared only until the server restarts.
+	HashKey string        `yaml:"hash_key"`
+	Rules   []maskingRule `yaml:"rules"`
+}
+
+type maskingRule struct {
+	// Columns are patterns of table.column in the syntax of path.Match, compared case-insensitively.
+	// The table may be qualified by the named schema, e.g. sales.Orders.Address.
+	Columns []string `yaml:"columns"`
+
+	// Method is hash, which replaces values by keyed hashes to keep them comparable, or redact.
+	Method string `yaml:"method"`
+}
+
+func (o *maskingOptions) validate() error {
+	for i, r := range o.Rules {
+		if r.Method != maskHash && r.Method != maskRedact {
+			return fmt.Errorf("rule %d: method must be hash or redact", i)
+		}
+		if len(r.Columns) == 0 {
+			return fmt.Errorf("rule %d: columns are required", i)
+		}
+		for _, c := range r.Columns {
+			if !strings.Contains(c, ".") {
+				return fmt.Errorf("rule %d: %q is not a pattern of table.column", i, c)
+			}
+			if _, err := path.Match(c, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid pattern %q: %w", i, c, err)
+			}
+		}
+	}
+	return nil
+}
+
+var randomHashKey = sync.OnceValue(func() []byte {
+	return []byte(rand.Text())
+})
+
+// method returns the method of the first rule matching the column of a table which satisfies matchTable.
+func (o *maskingOptions) method(column string, matchTable func(pattern string) bool) string {
+	for _, r := range o.Rules {
+		for _, c := range r.Columns {
+			i := strings.LastIndex(c, ".")
+			if ok, _ := path.Match(strings.ToLower(c[i+1:]), strings.ToLower(column)); ok && matchTable(strings.ToLower(c[:i])) {
+				return r.Method
+			}
+		}
+	}
+	return ""
+}
+
+// mask masks the value by the method. NULL stays NULL.
+func (o *maskingOptions) mask(method string, v any) any {
+	if v == nil {
+		return nil
+	}
+	if method == maskRedact {
+		return redactedValue
+	}
+
+	key := []byte(o.HashKey)
+	if len(key) == 0 {
+		key = randomHashKey()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redactedValue
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(b)
+	return "hmac:" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// queryMasks returns the methods of masked columns which the query reads, keyed by the lowercase column names.
+// The columns are found from scans of the query plan like checkStatement, so tables referred through views, indexes and SELECT * are caught.
+// Result columns don't tell their sources, so the query is rejected if it reads a masked column other than by a plain reference
+// in a select list, e.g. by an alias, an expression, a filter or a whole row, which would return or reveal the raw values.
+func (o *maskingOptions) queryMasks(ctx context.Context, target *profile, field, sql string) (map[string]string, error) {
+	if len(o.Rules) == 0 {
+		return nil, nil
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var (
+		qp      *sppb.QueryPlan
+		rowType *sppb.StructType
+	)
+	err = retry(ctx, func(ctx context.Context) error {
+		it := client.Single().QueryWithOptions(ctx, spanner.NewStatement(sql), spanner.QueryOptions{Mode: sppb.ExecuteSqlRequest_PLAN.Enum()})
+		defer it.Stop()
+		if _, err := it.Next(); err != iterator.Done {
+			return err
+		}
+		qp, rowType = it.QueryPlan, it.Metadata.GetRowType()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scans := planScans(qp)
+	var indexTables map[string]string
+	if lo.ContainsBy(scans, func(s planScan) bool { return s.kind == "index" }) {
+		if indexTables, err = indexedTables(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+	masks := make(map[string]string)
+	sources := make(map[string]string)
+	for _, s := range scans {
+		table := s.target
+		switch s.kind {
+		case "batch":
+			continue
+		case "index":
+			table = lo.CoalesceOrEmpty(indexTables[strings.ToLower(s.target)], s.target)
+		}
+		for _, column := range s.columns {
+			if method := o.method(column, func(pattern string) bool { return matchPattern(pattern, table) }); method != "" {
+				masks[strings.ToLower(column)] = method
+				sources[strings.ToLower(column)] = table + "." + column
+			}
+		}
+	}
+	if len(masks) == 0 {
+		return nil, nil
+	}
+
+	rejected := func(column string) error {
+		return &validationError{Field: field, Message: fmt.Sprintf("column %s is masked by the server config, so it can be read only by its name in the select list without an alias or an expression", sources[column])}
+	}
+	for column := range masks {
+		if !slices.ContainsFunc(rowType.GetFields(), func(f *sppb.StructType_Field) bool { return strings.EqualFold(f.GetName(), column) }) {
+			return nil, rejected(column)
+		}
+	}
+	if column, ok := unplainReference(scanSQL(sql), masks); ok {
+		return nil, rejected(column)
+	}
+	return masks, nil
+}
+
+// selectListEnds are keywords ending a select list.
+var selectListEnds = []string{"FROM", "WHERE", "GROUP", "HAVING", "QUALIFY", "WINDOW", "ORDER", "LIMIT", "OFFSET", "UNION", "INTERSECT", "EXCEPT", "NEXT"}
+
+// setOperators combine results of queries.
+var setOperators = []string{"UNION", "INTERSECT", "EXCEPT"}
+
+// unplainReference returns the first reference to the columns in the SQL which is not a plain item of a select list,
+// that is a column name optionally qualified, preceded by SELECT, DISTINCT, ALL or a comma and followed by a comma, FROM or the end of the list.
+// Select lists of subqueries count only in FROM, WITH and set operations, since other subqueries are parts of expressions.
+// Double-quoted strings are also references because they are identifiers in PostgreSQL.
+func unplainReference(tokens []sqlToken, columns map[string]string) (string, bool) {
+	selectList := make(map[int]bool)
+	tableQuery := map[int]bool{0: true}
+	for i, t := range tokens {
+		switch {
+		case t.isKeyword("SELECT") || t.isKeyword("RETURN"):
+			selectList[t.depth] = true
+		case slices.ContainsFunc(selectListEnds, t.isKeyword):
+			selectList[t.depth] = false
+		case t.kind == sqlSymbol && (t.text == "(" || t.text == "["):
+			selectList[t.depth+1] = false
+			table := i == 0
+			if i > 0 {
+				prev := tokens[i-1]
+				table = prev.isKeyword("FROM") || prev.isKeyword("JOIN") || prev.isKeyword("AS") || prev.text == "(" ||
+					slices.ContainsFunc(setOperators, prev.isKeyword) ||
+					(prev.isKeyword("ALL") || prev.isKeyword("DISTINCT")) && i > 1 && slices.ContainsFunc(setOperators, tokens[i-2].isKeyword)
+			}
+			tableQuery[t.depth+1] = tableQuery[t.depth] && t.text == "(" && table
+		}
+
+		name := t.text
+		switch {
+		case t.kind == sqlWord:
+			name = strings.ReplaceAll(name, "`", "")
+		case t.kind == sqlString && strings.HasPrefix(name, `"`):
+			name = strings.Trim(name, `"`)
+		default:
+			continue
+		}
+		column := strings.ToLower(name)
+		if _, ok := columns[column]; !ok {
+			continue
+		}
+
+		j := i - 1
+		for j >= 1 && tokens[j].text == "." && tokens[j-1].kind == sqlWord {
+			j -= 2
+		}
+		plain := selectList[t.depth] && tableQuery[t.depth] && j >= 0 && tokens[j].depth == t.depth &&
+			(tokens[j].text == "," || tokens[j].isKeyword("SELECT") || tokens[j].isKeyword("RETURN") || tokens[j].isKeyword("DISTINCT") || tokens[j].isKeyword("ALL"))
+		if i+1 < len(tokens) {
+			next := tokens[i+1]
+			plain = plain && (next.depth < t.depth || next.depth == t.depth && (next.text == "," || next.text == ";" || next.isKeyword("FROM")))
+		}
+		if !plain {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// maskQueryResult masks columns of the result in place by the methods returned by queryMasks,
+// and returns the row type with masked columns as STRING.
+func (o *maskingOptions) maskQueryResult(masks map[string]string, rowType *sppb.StructType, rows [][]any) *sppb.StructType {
+	var masked *sppb.StructType
+	for i, field := range rowType.GetFields() {
+		method, ok := masks[strings.ToLower(field.GetName())]
+		if !ok {
+			continue
+		}
+		for _, row := range rows {
+			row[i] = o.mask(method, row[i])
+		}
+		if masked == nil {
+			masked = proto.Clone(rowType).(*sppb.StructType)
+		}
+		masked.GetFields()[i].Type = &sppb.Type{Code: sppb.TypeCode_STRING}
+	}
+	if masked == nil {
+		return rowType
+	}
+	return masked
+}
+
+// maskDataChangeRecord masks keys, new values and old values of columns in the mods of the data change record.
+func (o *maskingOptions) maskDataChangeRecord(record json.RawMessage) json.RawMessage {
+	if len(o.Rules) == 0 {
+		return record
+	}
+
+	d := json.NewDecoder(bytes.NewReader(record))
+	d.UseNumber()
+	var r map[string]any
+	if err := d.Decode(&r); err != nil {
+		// The record can't be masked, so it is not returned at all.
+		return json.RawMessage(`{"error":"the data change record can't be masked"}`)
+	}
+	table, _ := r["table_name"].(string)
+	matchTable := func(pattern string) bool {
+		ok, _ := path.Match(pattern, strings.ToLower(table))
+		return ok
+	}
+
+	mods, _ := r["mods"].([]any)
+	for _, mod := range mods {
+		mod, ok := mod.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"keys", "new_values", "old_values"} {
+			values, ok := mod[key].(map[string]any)
+			if !ok {
+				continue
+			}
+			for column, v := range values {
+				if method := o.method(column, matchTable); method != "" {
+					values[column] = o.mask(method, v)
+				}
+			}
+		}
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return json.RawMessage(`{"error":"the data change record can't be masked"}`)
+	}
+	return b
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// sendLogNotification sends data as a log message notification to the current client.
+// Notifications are best-effort, so failures are ignored.
+func sendLogNotification(ctx context.Context, level mcp.LoggingLevel, data any) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return
+	}
+
+	_ = srv.SendNotificationToClient(ctx, "notifications/message", map[string]any{
+		"level":  level,
+		"logger": "spanner-mcp",
+		"data":   data,
+	})
+}
+
+// sendProgressNotification sends a progress notification if the client requested it.
+func sendProgressNotification(ctx context.Context, request mcp.CallToolRequest, progress, total int) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return
+	}
+
+	params := map[string]any{
+		"progressToken": request.Params.Meta.ProgressToken,
+		"progress":      progress,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	_ = srv.SendNotificationToClient(ctx, "notifications/progress", params)
+}
@@ -0,0 +1,902 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/apstndb/spannerplanviz/plantree"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// Structured contents of tools. Their JSON schemas are declared as the output schemas of tools.
+
+type planOutput struct {
+	QueryPlan any            `json:"query_plan,omitempty" jsonschema:"QueryPlan message in protojson format unless proto_format is none"`
+	Operators []planOperator `json:"operators,omitempty" jsonschema:"Operators of rendered query plan in pre-order. Omitted if summary is true"`
+
+	GraphOperators []graphPlanOperator `json:"graph_operators,omitempty" jsonschema:"Graph-specific roles of operators of GQL queries"`
+
+	Summary *planSummary `json:"summary,omitempty" jsonschema:"Summary of the plan if summary is true"`
+
+	Snapshot string `json:"snapshot,omitempty" jsonschema:"URI of the snapshot resource if save_as is set"`
+}
+
+type profileTopQueryOutput struct {
+	Fingerprint string           `json:"fingerprint" jsonschema:"TEXT_FINGERPRINT of the query"`
+	Interval    string           `json:"interval"`
+	Query       string           `json:"query" jsonschema:"TEXT of the query from the statistics"`
+	Truncated   bool             `json:"truncated,omitempty" jsonschema:"True if the query text is truncated in the statistics, so the query is not planned"`
+	Stats       []map[string]any `json:"stats" jsonschema:"Statistics of the query in the latest intervals, newest first"`
+	Summary     *planSummary     `json:"summary,omitempty" jsonschema:"Summary of the live plan"`
+	Operators   []planOperator   `json:"operators,omitempty" jsonschema:"Operators of the live plan in pre-order"`
+	Baseline    *planComparison  `json:"baseline,omitempty" jsonschema:"Comparison of the newest plan snapshot of the same query with the live plan, if --plan-dir has one"`
+	Note        string           `json:"note,omitempty"`
+}
+
+type planSummary struct {
+	Tables       []string `json:"tables" jsonschema:"Scanned tables"`
+	Indexes      []string `json:"indexes" jsonschema:"Scanned indexes"`
+	FullScans    []string `json:"full_scans" jsonschema:"Tables and indexes scanned without seek conditions"`
+	Joins        []string `json:"joins" jsonschema:"Join and apply operators with the number of occurrences"`
+	Distribution []string `json:"distribution" jsonschema:"Distributed operators which run on multiple splits"`
+	Parameters   []string `json:"parameters" jsonschema:"Query parameters referenced by the plan"`
+}
+
+type graphPlanOperator struct {
+	ID          int32  `json:"id"`
+	Kind        string `json:"kind" jsonschema:"node-scan, edge-scan, edge-expansion or path"`
+	Description string `json:"description"`
+}
+
+type planOperator struct {
+	ID         int32    `json:"id"`
+	Operator   string   `json:"operator" jsonschema:"Operator text with tree prefix"`
+	Predicates []string `json:"predicates,omitempty"`
+}
+
+type getDDLOutput struct {
+	Statements       []string `json:"statements"`
+	ProtoDescriptors any      `json:"proto_descriptors,omitempty" jsonschema:"FileDescriptorSet in protojson format if include_proto_descriptors is true"`
+}
+
+type updateDDLOutput struct {
+	Metadata any `json:"metadata,omitempty" jsonschema:"UpdateDatabaseDdlMetadata in protojson format unless proto_format is none"`
+}
+
+type executeQueryOutput struct {
+	Columns     []queryColumn  `json:"columns"`
+	Rows        [][]any        `json:"rows" jsonschema:"Rows as arrays of values in the order of columns"`
+	HasMoreRows bool           `json:"has_more_rows,omitempty" jsonschema:"True if rows after max_rows are omitted"`
+	Stats       *queryStats    `json:"stats,omitempty" jsonschema:"Statistics of the execution, absent if rows are omitted by max_rows"`
+	Artifact    *queryArtifact `json:"artifact,omitempty" jsonschema:"File the rows are written to if artifact is specified, in which case rows are empty"`
+}
+
+type queryArtifact struct {
+	Path      string `json:"path" jsonschema:"Local path of the file"`
+	URI       string `json:"uri" jsonschema:"file:// URI of the file"`
+	Format    string `json:"format" jsonschema:"parquet or arrow (Arrow IPC file)"`
+	Rows      int    `json:"rows" jsonschema:"Number of rows written to the file"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+type queryStats struct {
+	RowsReturned *int64 `json:"rows_returned,omitempty"`
+	RowsScanned  *int64 `json:"rows_scanned,omitempty" jsonschema:"Rows read from tables and indexes, reported in PROFILE mode"`
+	ElapsedTime  string `json:"elapsed_time,omitempty" jsonschema:"Elapsed time in the server, e.g. 1.23 msecs"`
+	CPUTime      string `json:"cpu_time,omitempty" jsonschema:"CPU time in the server, e.g. 0.98 msecs"`
+}
+
+type executePartitionedQueryOutput struct {
+	Columns             []queryColumn `json:"columns"`
+	Rows                [][]any       `json:"rows" jsonschema:"Rows as arrays of values in the order of columns. Rows of partitions are in no particular order"`
+	HasMoreRows         bool          `json:"has_more_rows,omitempty" jsonschema:"True if rows after max_rows are omitted and the remaining partitions are stopped"`
+	Partitions          int           `json:"partitions" jsonschema:"Number of partitions of the query"`
+	CompletedPartitions int           `json:"completed_partitions" jsonschema:"Number of partitions read to the end"`
+	Parallelism         int           `json:"parallelism" jsonschema:"Number of partitions executed concurrently"`
+}
+
+type executeFanoutQueryOutput struct {
+	Columns         []queryColumn          `json:"columns" jsonschema:"Columns of the merged rows, starting with source_database"`
+	Rows            [][]any                `json:"rows" jsonschema:"Rows of all databases in the order of databases, prefixed with the database ID"`
+	HasMoreRows     bool                   `json:"has_more_rows" jsonschema:"Whether rows are omitted by max_rows"`
+	Databases       []fanoutDatabaseResult `json:"databases"`
+	FailedDatabases int                    `json:"failed_databases,omitempty" jsonschema:"Number of databases which failed or returned incompatible columns, whose rows are not merged"`
+}
+
+type fanoutDatabaseResult struct {
+	Database       string `json:"database"`
+	Rows           int    `json:"rows" jsonschema:"Number of merged rows of the database"`
+	HasMoreRows    bool   `json:"has_more_rows,omitempty"`
+	DurationMillis int64  `json:"duration_millis"`
+	Error          string `json:"error,omitempty"`
+}
+
+type executeGQLOutput struct {
+	Columns     []queryColumn `json:"columns"`
+	Rows        [][]any       `json:"rows" jsonschema:"Rows as arrays of values in the order of columns. Graph elements and paths returned as JSON are objects with element or path"`
+	HasMoreRows bool          `json:"has_more_rows,omitempty" jsonschema:"True if rows after max_rows are omitted"`
+	Stats       *queryStats   `json:"stats,omitempty" jsonschema:"Statistics of the execution, absent if rows are omitted by max_rows"`
+	Mermaid     string        `json:"mermaid,omitempty" jsonschema:"Mermaid flowchart of returned nodes and edges if mermaid is true"`
+}
+
+type queryColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type" jsonschema:"Spanner type in GoogleSQL syntax, e.g. NUMERIC, ARRAY<STRING> or STRUCT<id INT64, name STRING>. PROTO and ENUM columns are their fully qualified names"`
+}
+
+type countRowsOutput struct {
+	Table            string     `json:"table"`
+	Mode             string     `json:"mode"`
+	Count            *int64     `json:"count,omitempty" jsonschema:"Exact number of rows in the exact mode"`
+	EstimatedRows    *int64     `json:"estimated_rows,omitempty" jsonschema:"Rough estimate of the number of rows in the approximate mode"`
+	UsedBytes        *int64     `json:"used_bytes,omitempty" jsonschema:"Size of the table from SPANNER_SYS.TABLE_SIZES_STATS_1HOUR in the approximate mode"`
+	StatsIntervalEnd *time.Time `json:"stats_interval_end,omitempty" jsonschema:"End of the interval of the table size statistics"`
+	Warning          string     `json:"warning,omitempty"`
+	Note             string     `json:"note,omitempty"`
+}
+
+type keyDistributionOutput struct {
+	Table       string        `json:"table"`
+	KeyColumns  []string      `json:"key_columns" jsonschema:"Primary key columns. Buckets are ranges of the first column"`
+	KeyType     string        `json:"key_type,omitempty" jsonschema:"Type of the first key column"`
+	Min         string        `json:"min,omitempty" jsonschema:"Minimum non-NULL value of the first key column"`
+	Max         string        `json:"max,omitempty" jsonschema:"Maximum value of the first key column"`
+	Buckets     []keyBucket   `json:"buckets" jsonschema:"Key ranges of equal width between min and max"`
+	SampledRows int64         `json:"sampled_rows" jsonschema:"Total rows counted in the buckets"`
+	SkewRatio   float64       `json:"skew_ratio,omitempty" jsonschema:"Rows of the largest bucket divided by the average of buckets. 1 is uniform"`
+	Findings    []lintFinding `json:"findings" jsonschema:"Hotspot-prone patterns: monotonic-key, skew, sparse or saturated"`
+	Note        string        `json:"note,omitempty"`
+}
+
+type keyBucket struct {
+	Start     string  `json:"start" jsonschema:"Inclusive start of the key range"`
+	End       string  `json:"end" jsonschema:"Exclusive end of the key range, inclusive in the last bucket"`
+	Rows      int64   `json:"rows"`
+	Percent   float64 `json:"percent" jsonschema:"Percentage of sampled rows in the bucket"`
+	Saturated bool    `json:"saturated,omitempty" jsonschema:"Whether the count reached max_rows_per_bucket, so it is a lower bound"`
+}
+
+type exportToGCSOutput struct {
+	Rows    int64            `json:"rows" jsonschema:"Total number of exported rows"`
+	Objects []exportedObject `json:"objects" jsonschema:"Objects written for each partition of the query"`
+}
+
+type exportedObject struct {
+	URI  string `json:"uri"`
+	Rows int64  `json:"rows"`
+}
+
+type importDataOutput struct {
+	Table   string `json:"table"`
+	Rows    int64  `json:"rows" jsonschema:"Number of imported rows, or valid rows in dry run"`
+	Batches int    `json:"batches" jsonschema:"Number of committed batches, or batches to commit in dry run"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+
+	Transaction *transactionDiagnostics `json:"transaction,omitempty" jsonschema:"Aborts of the transactions of all batches retried by the client library, omitted if none is aborted"`
+}
+
+type createTableAsOutput struct {
+	Table     string        `json:"table"`
+	Mode      string        `json:"mode" jsonschema:"create or append"`
+	DDL       string        `json:"ddl,omitempty" jsonschema:"CREATE TABLE statement of the table inferred from the result, in the create mode"`
+	Columns   []queryColumn `json:"columns" jsonschema:"Columns of the result written into the table"`
+	Rows      int64         `json:"rows" jsonschema:"Number of written rows, or rows to write in dry run"`
+	Batches   int           `json:"batches" jsonschema:"Number of committed batches, or batches to commit in dry run"`
+	Truncated bool          `json:"truncated,omitempty" jsonschema:"True if the result has more rows than max_rows, which are not written"`
+	DryRun    bool          `json:"dry_run,omitempty"`
+
+	Transaction *transactionDiagnostics `json:"transaction,omitempty" jsonschema:"Aborts of the transactions of all batches retried by the client library, omitted if none is aborted"`
+}
+
+type stagedMutation struct {
+	Index     int              `json:"index" jsonschema:"Number of the mutation from 1 for discard_staged"`
+	Operation string           `json:"operation" jsonschema:"insert, update, insert_or_update, replace or delete"`
+	Table     string           `json:"table"`
+	Rows      []map[string]any `json:"rows,omitempty" jsonschema:"Column values of the rows to write"`
+	Keys      [][]any          `json:"keys,omitempty" jsonschema:"Primary keys of the rows to delete"`
+}
+
+type stagedMutationsOutput struct {
+	Database  string           `json:"database,omitempty" jsonschema:"Database which the mutations are committed to, empty if no mutations are staged"`
+	Mutations []stagedMutation `json:"mutations"`
+	Rows      int              `json:"rows" jsonschema:"Number of staged rows and keys, which is expected_rows of commit_staged"`
+}
+
+type commitStagedOutput struct {
+	Database        string `json:"database"`
+	Mutations       int    `json:"mutations"`
+	Rows            int    `json:"rows"`
+	CommitTimestamp string `json:"commit_timestamp"`
+
+	Transaction *transactionDiagnostics `json:"transaction,omitempty" jsonschema:"Aborts of the transaction retried by the client library, omitted if it is not aborted"`
+}
+
+type dataflowJobOutput struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Project    string   `json:"project"`
+	Region     string   `json:"region"`
+	State      string   `json:"state,omitempty" jsonschema:"Current state of the job, e.g. JOB_STATE_RUNNING and JOB_STATE_DONE"`
+	StateTime  string   `json:"state_time,omitempty"`
+	CreateTime string   `json:"create_time,omitempty"`
+	ConsoleURL string   `json:"console_url" jsonschema:"URL of the job in Google Cloud console"`
+	Errors     []string `json:"errors,omitempty" jsonschema:"Recent error messages of the job"`
+}
+
+type lintQueryOutput struct {
+	Findings []lintFinding `json:"findings"`
+}
+
+type lintFinding struct {
+	Rule       string `json:"rule" jsonschema:"parameterization, select-star, non-sargable, leading-wildcard, full-scan or monotonic-key"`
+	Severity   string `json:"severity" jsonschema:"error, warning or info"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+type findRedundantIndexesOutput struct {
+	Findings              []redundantIndex `json:"findings"`
+	EstimatedSavingsBytes int64            `json:"estimated_savings_bytes" jsonschema:"Sum of the estimated savings of the findings"`
+	Notes                 []string         `json:"notes,omitempty"`
+}
+
+type redundantIndex struct {
+	Rule                  string   `json:"rule" jsonschema:"duplicate, prefix, primary-key-prefix, shared-leading-column or unused-storing"`
+	Severity              string   `json:"severity" jsonschema:"warning if the index can be dropped, or info"`
+	Table                 string   `json:"table"`
+	Index                 string   `json:"index"`
+	Other                 string   `json:"other,omitempty" jsonschema:"The index covering or overlapping the index"`
+	StoringColumns        []string `json:"storing_columns,omitempty" jsonschema:"STORING columns which don't appear in the top queries"`
+	Message               string   `json:"message"`
+	Suggestion            string   `json:"suggestion" jsonschema:"DDL to apply after reviewing queries using the index"`
+	UsedBytes             *int64   `json:"used_bytes,omitempty" jsonschema:"Size of the index from SPANNER_SYS.TABLE_SIZES_STATS_1HOUR"`
+	EstimatedSavingsBytes *int64   `json:"estimated_savings_bytes,omitempty" jsonschema:"The size of the index if it can be dropped, or the share of the STORING columns by the number of columns"`
+}
+
+type estimateCostOutput struct {
+	Cost                    string             `json:"cost" jsonschema:"cheap, moderate or expensive"`
+	EstimatedRowsScanned    *int64             `json:"estimated_rows_scanned,omitempty" jsonschema:"Estimated rows read by full scans of tables, absent if the size of a scanned table or index is not available"`
+	Scans                   []costScan         `json:"scans,omitempty"`
+	DistributedCrossApplies int                `json:"distributed_cross_applies"`
+	Reasons                 []string           `json:"reasons,omitempty" jsonschema:"Reasons of the cost unless the query is cheap"`
+	DataBoost               *dataBoostEstimate `json:"data_boost,omitempty" jsonschema:"Whether the query can and should run on Data Boost, absent for DML"`
+	Note                    string             `json:"note"`
+}
+
+type dataBoostEstimate struct {
+	Eligible              bool   `json:"eligible" jsonschema:"True if the query is root-partitionable, which Data Boost requires"`
+	Partitions            int    `json:"partitions,omitempty" jsonschema:"Number of partitions of the query"`
+	EstimatedBytesScanned *int64 `json:"estimated_bytes_scanned,omitempty" jsonschema:"Bytes read by full scans from table size statistics, which drive the serverless processing consumed. Absent if a size is not available"`
+	Recommended           bool   `json:"recommended" jsonschema:"True if data_boost is worth setting for the query"`
+	Reason                string `json:"reason"`
+}
+
+type costScan struct {
+	Node          int32  `json:"node" jsonschema:"Index of the Scan node in the query plan"`
+	Target        string `json:"target"`
+	Kind          string `json:"kind" jsonschema:"table, index or batch"`
+	Full          bool   `json:"full"`
+	EstimatedRows *int64 `json:"estimated_rows,omitempty" jsonschema:"Estimated rows of the table from table size statistics for full scans"`
+	UsedBytes     *int64 `json:"used_bytes,omitempty" jsonschema:"Size of the table or the index from table size statistics for full scans"`
+}
+
+type indexImpactOutput struct {
+	Action   string             `json:"action"`
+	Database string             `json:"database"`
+	Index    string             `json:"index,omitempty"`
+	Queries  []indexImpactQuery `json:"queries"`
+}
+
+type indexImpactQuery struct {
+	Query               string `json:"query"`
+	PlanChanged         bool   `json:"plan_changed,omitempty"`
+	UsesIndex           bool   `json:"uses_index,omitempty" jsonschema:"Whether the new plan scans the index"`
+	BaselineCost        string `json:"baseline_cost" jsonschema:"cheap, moderate or expensive by estimate_cost"`
+	Cost                string `json:"cost,omitempty"`
+	BaselineRowsScanned *int64 `json:"baseline_rows_scanned,omitempty" jsonschema:"Actual rows scanned if profiled, otherwise estimated rows scanned by full scans"`
+	RowsScanned         *int64 `json:"rows_scanned,omitempty"`
+	BaselineCPUTime     string `json:"baseline_cpu_time,omitempty"`
+	CPUTime             string `json:"cpu_time,omitempty"`
+	BaselineElapsedTime string `json:"baseline_elapsed_time,omitempty"`
+	ElapsedTime         string `json:"elapsed_time,omitempty"`
+	Improvement         string `json:"improvement,omitempty" jsonschema:"Summary of changes of rows scanned, full scans and times"`
+}
+
+type whatifOutput struct {
+	ShadowDatabase string        `json:"shadow_database" jsonschema:"Dropped database in the emulator where the hypothetical DDL was applied"`
+	Queries        []whatifQuery `json:"queries"`
+	Note           string        `json:"note"`
+}
+
+type whatifQuery struct {
+	Query             string         `json:"query"`
+	Valid             bool           `json:"valid" jsonschema:"Whether the query is valid with the hypothetical schema"`
+	Error             string         `json:"error,omitempty"`
+	PlanChanged       *bool          `json:"plan_changed,omitempty" jsonschema:"Whether the hypothetical DDL changed the plan, absent if the emulator doesn't return plans"`
+	BaselineFullScans int            `json:"baseline_full_scans"`
+	FullScans         int            `json:"full_scans"`
+	Indexes           []string       `json:"indexes,omitempty" jsonschema:"Indexes scanned by the plan with the hypothetical schema"`
+	Operators         []planOperator `json:"operators,omitempty" jsonschema:"Operators of the plan with the hypothetical schema"`
+}
+
+type listStatisticsPackagesOutput struct {
+	Packages []statisticsPackage `json:"packages"`
+	Pinned   string              `json:"pinned,omitempty" jsonschema:"Package set by the optimizer_statistics_package database option, absent if the latest package is used"`
+}
+
+type statisticsPackage struct {
+	Name    string `json:"name"`
+	AllowGC bool   `json:"allow_gc" jsonschema:"Whether the package is garbage collected after 30 days unless it is pinned"`
+}
+
+type setStatisticsPackageOutput struct {
+	Package    string   `json:"package,omitempty" jsonschema:"Pinned package, absent if unpinned"`
+	Statements []string `json:"statements" jsonschema:"Applied DDL statements"`
+}
+
+type analyzeDatabaseOutput struct {
+	Package         string                    `json:"package,omitempty" jsonschema:"Name of the constructed statistics package"`
+	Pinned          string                    `json:"pinned,omitempty" jsonschema:"Package pinned by the database option, which queries use instead of the new package"`
+	Skipped         bool                      `json:"skipped,omitempty" jsonschema:"True if ANALYZE is skipped because the latest package is younger than if_older_than_hours"`
+	DurationSeconds float64                   `json:"duration_seconds,omitempty" jsonschema:"Time to construct the package"`
+	Freshness       statisticsFreshnessOutput `json:"freshness" jsonschema:"Freshness of the statistics used by queries after the call"`
+}
+
+type statisticsFreshnessOutput struct {
+	Package       string     `json:"package,omitempty" jsonschema:"Statistics package used by queries: the pinned package or the latest package"`
+	Pinned        bool       `json:"pinned,omitempty" jsonschema:"True if the package is pinned by the optimizer_statistics_package database option"`
+	ConstructedAt *time.Time `json:"constructed_at,omitempty" jsonschema:"Construction time of the package from its name"`
+	AgeHours      float64    `json:"age_hours,omitempty"`
+	Latest        string     `json:"latest,omitempty" jsonschema:"Latest package if queries use another pinned package"`
+	Stale         bool       `json:"stale" jsonschema:"True if the package is older than the maximum age or the database has no packages"`
+	Reason        string     `json:"reason,omitempty" jsonschema:"Why the statistics are stale or their freshness is unknown"`
+}
+
+type listPropertyGraphsOutput struct {
+	Graphs []propertyGraph `json:"graphs"`
+}
+
+type propertyGraph struct {
+	Name       string              `json:"name" jsonschema:"Graph name, qualified by the named schema if any"`
+	NodeTables []graphElementTable `json:"node_tables"`
+	EdgeTables []graphElementTable `json:"edge_tables,omitempty"`
+	Labels     []graphLabel        `json:"labels"`
+	Properties []graphProperty     `json:"properties" jsonschema:"Property declarations with their types"`
+}
+
+type graphElementTable struct {
+	Name        string                    `json:"name" jsonschema:"Element table name, which is the alias of the base table in the graph"`
+	BaseTable   string                    `json:"base_table"`
+	KeyColumns  []string                  `json:"key_columns"`
+	Labels      []string                  `json:"labels"`
+	Properties  []graphPropertyDefinition `json:"properties,omitempty"`
+	Source      *graphNodeReference       `json:"source,omitempty" jsonschema:"Source node table of the edge"`
+	Destination *graphNodeReference       `json:"destination,omitempty" jsonschema:"Destination node table of the edge"`
+}
+
+type graphPropertyDefinition struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression" jsonschema:"SQL expression of the property on the base table"`
+}
+
+type graphNodeReference struct {
+	NodeTable   string   `json:"node_table"`
+	EdgeColumns []string `json:"edge_columns"`
+	NodeColumns []string `json:"node_columns"`
+}
+
+type graphLabel struct {
+	Name       string   `json:"name"`
+	Properties []string `json:"properties"`
+}
+
+type graphProperty struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type graphSchemaDiagramOutput struct {
+	Graph   string `json:"graph"`
+	Format  string `json:"format" jsonschema:"mermaid or dot"`
+	Diagram string `json:"diagram"`
+}
+
+type executeDMLOutput struct {
+	RowCount        *int64         `json:"row_count,omitempty" jsonschema:"Number of affected rows"`
+	CommitTimestamp *time.Time     `json:"commit_timestamp,omitempty"`
+	DryRun          bool           `json:"dry_run,omitempty"`
+	EstimatedRows   *int64         `json:"estimated_rows,omitempty" jsonschema:"Number of rows matching the WHERE clause of UPDATE or DELETE in dry run"`
+	Operators       []planOperator `json:"operators,omitempty" jsonschema:"Operators of rendered query plan of the statement in dry run"`
+	Note            string         `json:"note,omitempty"`
+
+	Transaction *transactionDiagnostics `json:"transaction,omitempty" jsonschema:"Aborts of the transaction retried by the client library, omitted if it is not aborted"`
+}
+
+type transactionDiagnostics struct {
+	Attempts  int                `json:"attempts" jsonschema:"Number of attempts of the transaction including the first one"`
+	Aborts    []transactionAbort `json:"aborts"`
+	LockStats []map[string]any   `json:"lock_stats,omitempty" jsonschema:"Rows of SPANNER_SYS.LOCK_STATS_TOP_MINUTE of the latest interval for the conflicting tables, ordered by lock wait"`
+	Note      string             `json:"note,omitempty"`
+}
+
+type transactionAbort struct {
+	RPC        string    `json:"rpc" jsonschema:"RPC which returned ABORTED, e.g. ExecuteSql or Commit"`
+	Message    string    `json:"message" jsonschema:"Reason of the abort, which describes the conflicting keys, column and table if they are known"`
+	Table      string    `json:"table,omitempty" jsonschema:"Conflicting table parsed from the message"`
+	Column     string    `json:"column,omitempty" jsonschema:"Conflicting column parsed from the message"`
+	RetryDelay string    `json:"retry_delay,omitempty" jsonschema:"Delay before the retry suggested by the server"`
+	At         time.Time `json:"at"`
+}
+
+type truncateTableOutput struct {
+	Table       string `json:"table"`
+	DeletedRows int64  `json:"deleted_rows" jsonschema:"Lower bound of the number of deleted rows, which excludes rows of interleaved tables deleted by ON DELETE CASCADE"`
+}
+
+type generateAlertPoliciesOutput struct {
+	Instance  string        `json:"instance"`
+	Config    string        `json:"config" jsonschema:"Instance configuration, which decides the CPU threshold by default"`
+	Policies  []alertPolicy `json:"policies" jsonschema:"AlertPolicy resources of the Cloud Monitoring API"`
+	Terraform string        `json:"terraform,omitempty" jsonschema:"google_monitoring_alert_policy resources if format is terraform"`
+}
+
+type capacityPlanningOutput struct {
+	Instance        string                 `json:"instance"`
+	Config          string                 `json:"config" jsonschema:"Instance configuration, which decides the CPU threshold by default"`
+	ProcessingUnits int32                  `json:"processing_units" jsonschema:"Current compute capacity of the instance"`
+	Autoscaling     bool                   `json:"autoscaling,omitempty" jsonschema:"Whether the instance uses managed autoscaling"`
+	Days            int                    `json:"days" jsonschema:"Days of metrics used for the projection"`
+	HorizonDays     int                    `json:"horizon_days"`
+	CPU             capacityProjection     `json:"cpu" jsonschema:"Daily peaks of hourly mean high priority CPU utilization"`
+	Storage         capacityProjection     `json:"storage" jsonschema:"Daily means of storage utilization relative to the limit of the compute capacity"`
+	Databases       []databaseQPS          `json:"databases,omitempty" jsonschema:"Requests per second by database in descending order of the mean"`
+	Recommendation  capacityRecommendation `json:"recommendation"`
+	Notes           []string               `json:"notes,omitempty"`
+}
+
+type capacityProjection struct {
+	ThresholdPercent    float64          `json:"threshold_percent"`
+	CurrentPercent      float64          `json:"current_percent" jsonschema:"Fitted value of the last day"`
+	PeakPercent         float64          `json:"peak_percent" jsonschema:"Highest daily value"`
+	GrowthPercentPerDay float64          `json:"growth_percent_per_day" jsonschema:"Slope of the least squares fit of daily values"`
+	ProjectedPercent    float64          `json:"projected_percent" jsonschema:"Projected value at the horizon"`
+	DaysToThreshold     *float64         `json:"days_to_threshold,omitempty" jsonschema:"Days until the projection exceeds the threshold, 0 if already over, absent if not growing"`
+	Samples             int              `json:"samples" jsonschema:"Number of days with metrics"`
+	Daily               []capacitySample `json:"daily,omitempty"`
+}
+
+type capacitySample struct {
+	Date    string  `json:"date"`
+	Percent float64 `json:"percent"`
+}
+
+type databaseQPS struct {
+	Database   string  `json:"database"`
+	MeanQPS    float64 `json:"mean_qps"`
+	LastDayQPS float64 `json:"last_day_qps"`
+}
+
+type capacityRecommendation struct {
+	ProcessingUnits        int32   `json:"processing_units" jsonschema:"Processing units to keep the current peaks under the thresholds"`
+	Nodes                  float64 `json:"nodes"`
+	HorizonProcessingUnits int32   `json:"horizon_processing_units" jsonschema:"Processing units to keep the projections at the horizon under the thresholds"`
+	HorizonNodes           float64 `json:"horizon_nodes"`
+}
+
+type exportDBMLOutput struct {
+	DBML   string `json:"dbml"`
+	Tables int    `json:"tables"`
+	Refs   int    `json:"refs" jsonschema:"Number of Refs from foreign keys and interleaving"`
+}
+
+type tableTreeOutput struct {
+	Tree     string           `json:"tree" jsonschema:"Indented tree of the interleave hierarchy"`
+	Tables   []tableTreeEntry `json:"tables" jsonschema:"Tables and interleaved indexes in the order of the tree"`
+	Roots    int              `json:"roots" jsonschema:"Number of root tables"`
+	MaxDepth int              `json:"max_depth" jsonschema:"Depth of the deepest interleaved table, 0 if no tables are interleaved"`
+}
+
+type tableTreeEntry struct {
+	Table      string `json:"table"`
+	Parent     string `json:"parent,omitempty"`
+	Depth      int    `json:"depth"`
+	Interleave string `json:"interleave,omitempty" jsonschema:"IN PARENT or IN for interleaved tables"`
+	OnDelete   string `json:"on_delete,omitempty" jsonschema:"CASCADE or NO ACTION of INTERLEAVE IN PARENT"`
+	Children   int    `json:"children,omitempty" jsonschema:"Number of tables interleaved directly in the table"`
+	Index      bool   `json:"index,omitempty" jsonschema:"Whether the entry is an index interleaved in the parent"`
+}
+
+type listColumnExpressionsOutput struct {
+	Columns   []columnExpression `json:"columns"`
+	Generated int                `json:"generated" jsonschema:"Number of generated columns"`
+	Defaults  int                `json:"defaults" jsonschema:"Number of columns with DEFAULT expressions"`
+}
+
+type columnExpression struct {
+	Table      string   `json:"table"`
+	Column     string   `json:"column"`
+	Type       string   `json:"type"`
+	Generated  string   `json:"generated,omitempty" jsonschema:"Expression of the generated column"`
+	Stored     bool     `json:"stored,omitempty" jsonschema:"Whether the generated column is STORED"`
+	Default    string   `json:"default,omitempty" jsonschema:"DEFAULT expression used when inserts omit the column"`
+	PrimaryKey bool     `json:"primary_key,omitempty" jsonschema:"Whether the column is a part of the primary key"`
+	Indexes    []string `json:"indexes,omitempty" jsonschema:"Indexes containing the column"`
+}
+
+type listTablesOutput struct {
+	Dialect string         `json:"dialect" jsonschema:"GOOGLE_STANDARD_SQL or POSTGRESQL"`
+	Tables  []catalogTable `json:"tables"`
+}
+
+type catalogTable struct {
+	Name     string `json:"name" jsonschema:"Table name qualified by the named schema unless it is in the default schema"`
+	Type     string `json:"type" jsonschema:"BASE TABLE or VIEW"`
+	Parent   string `json:"parent,omitempty" jsonschema:"Parent table if the table is interleaved"`
+	OnDelete string `json:"on_delete,omitempty" jsonschema:"CASCADE or NO ACTION of the interleaved table"`
+}
+
+type describeTableOutput struct {
+	Dialect    string          `json:"dialect" jsonschema:"GOOGLE_STANDARD_SQL or POSTGRESQL"`
+	Table      catalogTable    `json:"table"`
+	Columns    []catalogColumn `json:"columns"`
+	PrimaryKey []string        `json:"primary_key" jsonschema:"Columns of the primary key in order, with DESC if descending"`
+	Indexes    []catalogIndex  `json:"indexes" jsonschema:"Secondary indexes"`
+	DDL        []string        `json:"ddl" jsonschema:"DDL statements of the table and its indexes in the dialect of the database"`
+}
+
+type catalogColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type" jsonschema:"Type in the dialect of the database"`
+	Nullable   bool   `json:"nullable"`
+	Default    string `json:"default,omitempty"`
+	Generation string `json:"generation,omitempty" jsonschema:"Expression of the generated column"`
+
+	CommitTimestamp bool `json:"commit_timestamp,omitempty" jsonschema:"Whether the column allows commit timestamps"`
+}
+
+type listCommitTimestampColumnsOutput struct {
+	Dialect       string                  `json:"dialect"`
+	Columns       []commitTimestampColumn `json:"columns"`
+	MutationValue string                  `json:"mutation_value" jsonschema:"Value of the columns in rows of mutations, which Spanner replaces by the commit timestamp"`
+	DMLValue      string                  `json:"dml_value" jsonschema:"Function writing the commit timestamp in DML"`
+}
+
+type commitTimestampColumn struct {
+	Table      string `json:"table"`
+	Column     string `json:"column"`
+	Type       string `json:"type"`
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primary_key,omitempty" jsonschema:"Whether the column is in the primary key"`
+}
+
+type catalogIndex struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type" jsonschema:"INDEX, SEARCH or VECTOR"`
+	Columns      []string `json:"columns" jsonschema:"Key columns in order, with DESC if descending"`
+	Unique       bool     `json:"unique,omitempty"`
+	NullFiltered bool     `json:"null_filtered,omitempty"`
+	Parent       string   `json:"parent,omitempty" jsonschema:"Table which the index is interleaved in"`
+	State        string   `json:"state,omitempty" jsonschema:"READ_WRITE, or WRITE_ONLY while the index is backfilled"`
+}
+
+type listConstraintsOutput struct {
+	Checks      []checkConstraint      `json:"checks" jsonschema:"CHECK constraints except implicit NOT NULL constraints"`
+	ForeignKeys []foreignKeyConstraint `json:"foreign_keys"`
+}
+
+type checkConstraint struct {
+	Table    string `json:"table"`
+	Name     string `json:"name"`
+	Clause   string `json:"clause" jsonschema:"Expression of the CHECK constraint"`
+	Enforced bool   `json:"enforced"`
+	State    string `json:"state,omitempty" jsonschema:"COMMITTED, or VALIDATING_DATA while existing rows are validated"`
+}
+
+type foreignKeyConstraint struct {
+	Table             string   `json:"table" jsonschema:"Referencing table"`
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns" jsonschema:"Referencing columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	OnDelete          string   `json:"on_delete" jsonschema:"CASCADE or NO ACTION"`
+	Enforced          bool     `json:"enforced" jsonschema:"false for informational foreign keys created with NOT ENFORCED"`
+	State             string   `json:"state,omitempty" jsonschema:"COMMITTED, or VALIDATING_DATA while existing rows are validated"`
+}
+
+type checkReferentialIntegrityOutput struct {
+	References []referenceCheck `json:"references"`
+	Skipped    []string         `json:"skipped,omitempty" jsonschema:"Foreign keys which are not checked because their tables are denied"`
+}
+
+type referenceCheck struct {
+	foreignKeyConstraint
+	Informal      bool             `json:"informal,omitempty" jsonschema:"Whether the reference is given by the references argument rather than a foreign key"`
+	OrphanedRows  int64            `json:"orphaned_rows" jsonschema:"Rows whose referencing columns are not NULL and have no referenced rows"`
+	MissingKeys   int64            `json:"missing_keys" jsonschema:"Distinct values of the referencing columns of the orphaned rows"`
+	SampleColumns []string         `json:"sample_columns,omitempty" jsonschema:"The primary key and the referencing columns"`
+	Samples       []map[string]any `json:"samples,omitempty" jsonschema:"Orphaned rows"`
+	SQL           string           `json:"sql" jsonschema:"Anti-join query counting the orphaned rows"`
+}
+
+type exportTerraformOutput struct {
+	Terraform  string `json:"terraform" jsonschema:"google_spanner_instance and google_spanner_database resources in HCL"`
+	Statements int    `json:"statements" jsonschema:"Number of DDL statements in the ddl attribute"`
+}
+
+type generateClientCodeOutput struct {
+	Language string        `json:"language"`
+	Code     string        `json:"code"`
+	Columns  []queryColumn `json:"columns" jsonschema:"Columns of the query read by the code"`
+}
+
+type generateDataOutput struct {
+	Table   string           `json:"table"`
+	Rows    int64            `json:"rows" jsonschema:"Number of inserted rows, or rows to insert in dry run"`
+	Batches int              `json:"batches"`
+	Seed    int64            `json:"seed" jsonschema:"Seed of the generators to reproduce the same rows"`
+	DryRun  bool             `json:"dry_run,omitempty"`
+	Preview []map[string]any `json:"preview,omitempty" jsonschema:"First generated rows in dry run"`
+
+	Transaction *transactionDiagnostics `json:"transaction,omitempty" jsonschema:"Aborts of the transactions of all batches retried by the client library, omitted if none is aborted"`
+}
+
+type tailChangeStreamOutput struct {
+	ChangeStream      string    `json:"change_stream"`
+	StartTimestamp    time.Time `json:"start_timestamp"`
+	EndTimestamp      time.Time `json:"end_timestamp"`
+	DataChangeRecords []any     `json:"data_change_records"`
+}
+
+type inspectChangeStreamPartitionsOutput struct {
+	Partitions []*partitionInfo `json:"partitions"`
+}
+
+type useDatabaseOutput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database path of the default database, or empty if not set"`
+}
+
+type serverInfoOutput struct {
+	Version         string            `json:"version"`
+	GoVersion       string            `json:"go_version"`
+	Tools           []string          `json:"tools" jsonschema:"Names of enabled tools"`
+	DefaultProfile  string            `json:"default_profile,omitempty"`
+	SessionDatabase string            `json:"session_database,omitempty" jsonschema:"Database path set by use_database in this session"`
+	Modules         map[string]string `json:"modules" jsonschema:"Versions of the client libraries"`
+	Principal       string            `json:"principal,omitempty" jsonschema:"Email of the principal which accesses Spanner"`
+	PrincipalError  string            `json:"principal_error,omitempty" jsonschema:"Error if the principal cannot be resolved"`
+	UserAgent       string            `json:"user_agent" jsonschema:"User agent of the requests to Spanner"`
+}
+
+type poolStatsOutput struct {
+	MultiplexedSessions bool                `json:"multiplexed_sessions" jsonschema:"True if the clients use multiplexed sessions"`
+	Clients             []cachedClientStats `json:"clients" jsonschema:"Spanner clients cached by the server"`
+	Pools               []sessionPoolStats  `json:"pools" jsonschema:"Session pools of the clients"`
+}
+
+type cachedClientStats struct {
+	Database      string  `json:"database"`
+	DatabaseRole  string  `json:"database_role,omitempty"`
+	RouteToLeader string  `json:"route_to_leader,omitempty" jsonschema:"route_to_leader argument of the tool calls using the client"`
+	InUseCalls    int     `json:"in_use_calls" jsonschema:"Number of tool calls using the client"`
+	IdleSeconds   float64 `json:"idle_seconds,omitempty" jsonschema:"Seconds since the last tool call released the client"`
+}
+
+type sessionPoolStats struct {
+	ClientID            string  `json:"client_id"`
+	Instance            string  `json:"instance"`
+	Database            string  `json:"database"`
+	OpenSessions        int64   `json:"open_sessions"`
+	MaxAllowedSessions  int64   `json:"max_allowed_sessions"`
+	InUseSessions       int64   `json:"in_use_sessions"`
+	IdleSessions        int64   `json:"idle_sessions" jsonschema:"Sessions available in the pool"`
+	MaxInUseSessions    int64   `json:"max_in_use_sessions" jsonschema:"Maximum in-use sessions in the last 10 minutes"`
+	MultiplexedSessions int64   `json:"multiplexed_sessions,omitempty"`
+	AcquiredSessions    int64   `json:"acquired_sessions" jsonschema:"Sessions checked out since the client is created"`
+	ReleasedSessions    int64   `json:"released_sessions"`
+	GetSessionTimeouts  int64   `json:"get_session_timeouts" jsonschema:"Checkouts which timed out because the pool is exhausted"`
+	GFELatencyCount     uint64  `json:"gfe_latency_count,omitempty" jsonschema:"Number of RPCs with the latency in Google's network"`
+	GFELatencyAvgMillis float64 `json:"gfe_latency_avg_millis,omitempty"`
+	GFELatencyMaxMillis int64   `json:"gfe_latency_max_millis,omitempty"`
+
+	gfeLatencySum int64
+}
+
+type pingOutput struct {
+	Database      string  `json:"database"`
+	State         string  `json:"state" jsonschema:"State of the database"`
+	Dialect       string  `json:"dialect"`
+	DatabaseRole  string  `json:"database_role,omitempty"`
+	Principal     string  `json:"principal,omitempty" jsonschema:"Email of the principal which accesses Spanner"`
+	LatencyMillis float64 `json:"latency_millis" jsonschema:"Round-trip latency of SELECT 1 in milliseconds"`
+}
+
+type sessionHistoryOutput struct {
+	Entries []*auditEntry `json:"entries" jsonschema:"Recent tool calls of this session in chronological order"`
+}
+
+type historyOutput struct {
+	Entries []historyEntry `json:"entries" jsonschema:"Recent queries, DML and DDL of this session in chronological order"`
+}
+
+type explainAsCLIOutput struct {
+	Tool     string       `json:"tool"`
+	Commands []cliCommand `json:"commands" jsonschema:"Equivalent command lines, which are empty if the tool has no equivalent"`
+	Note     string       `json:"note,omitempty" jsonschema:"Differences between the tool call and the commands"`
+}
+
+type cliCommand struct {
+	Tool    string `json:"tool" jsonschema:"gcloud or spanner-cli"`
+	Command string `json:"command" jsonschema:"Command line quoted for POSIX shells"`
+}
+
+type historyEntry struct {
+	Index          int            `json:"index" jsonschema:"Index of the tool call to replay"`
+	Time           time.Time      `json:"time"`
+	Tool           string         `json:"tool"`
+	Statement      string         `json:"statement" jsonschema:"SQL, GQL or DDL statements of the tool call"`
+	Arguments      map[string]any `json:"arguments,omitempty"`
+	DurationMillis int64          `json:"duration_millis"`
+	Error          string         `json:"error,omitempty"`
+}
+
+type createBackupOutput struct {
+	Backup     string `json:"backup" jsonschema:"Backup ID"`
+	Database   string `json:"database"`
+	Operation  string `json:"operation" jsonschema:"Name of the long-running operation creating the backup"`
+	ExpireTime string `json:"expire_time"`
+}
+
+type listBackupsOutput struct {
+	Backups []listedBackup `json:"backups"`
+}
+
+type listedBackup struct {
+	Backup           string   `json:"backup" jsonschema:"Backup ID"`
+	Database         string   `json:"database" jsonschema:"ID of the source database"`
+	State            string   `json:"state" jsonschema:"CREATING or READY"`
+	CreateTime       string   `json:"create_time,omitempty"`
+	VersionTime      string   `json:"version_time,omitempty" jsonschema:"Timestamp of the backed up data"`
+	ExpireTime       string   `json:"expire_time,omitempty"`
+	SizeBytes        int64    `json:"size_bytes"`
+	EncryptionType   string   `json:"encryption_type,omitempty" jsonschema:"GOOGLE_DEFAULT_ENCRYPTION or CUSTOMER_MANAGED_ENCRYPTION"`
+	KMSKeyVersions   []string `json:"kms_key_versions,omitempty" jsonschema:"Cloud KMS key versions encrypting the backup, one per region in multi-region instances"`
+	EncryptionErrors []string `json:"encryption_errors,omitempty" jsonschema:"Errors of the KMS keys, e.g. disabled or destroyed key versions"`
+}
+
+type listBackupChainsOutput struct {
+	Chains []backupChain `json:"chains"`
+}
+
+type backupChain struct {
+	ChainID              string              `json:"chain_id" jsonschema:"Incremental backup chain ID"`
+	Database             string              `json:"database" jsonschema:"ID of the source database"`
+	Backups              []backupChainMember `json:"backups" jsonschema:"Backups from the full backup to the newest incremental backup"`
+	SizeBytes            int64               `json:"size_bytes" jsonschema:"Total size of the backups in the chain"`
+	OldestRestorableTime string              `json:"oldest_restorable_time,omitempty" jsonschema:"Version time of the oldest ready backup in the chain"`
+	LatestRestorableTime string              `json:"latest_restorable_time,omitempty" jsonschema:"Version time of the newest ready backup in the chain"`
+	OldestVersionTime    string              `json:"oldest_version_time,omitempty" jsonschema:"Version time of the oldest backup which ever existed in the chain"`
+	BackupSchedules      []string            `json:"backup_schedules,omitempty" jsonschema:"Backup schedules which created the backups"`
+}
+
+type backupChainMember struct {
+	Backup             string `json:"backup" jsonschema:"Backup ID"`
+	State              string `json:"state"`
+	VersionTime        string `json:"version_time"`
+	ExpireTime         string `json:"expire_time"`
+	ExclusiveSizeBytes int64  `json:"exclusive_size_bytes" jsonschema:"Size of the data stored only in this backup"`
+	FreeableSizeBytes  int64  `json:"freeable_size_bytes" jsonschema:"Size freed by deleting this backup"`
+	DependsOn          string `json:"depends_on,omitempty" jsonschema:"Previous backup which this incremental backup depends on. Empty for the full backup"`
+}
+
+type backupCoverageOutput struct {
+	Databases           []databaseBackupCoverage `json:"databases"`
+	Unprotected         []string                 `json:"unprotected,omitempty" jsonschema:"Databases without ready backups"`
+	Stale               []string                 `json:"stale,omitempty" jsonschema:"Databases whose latest backup is older than stale_after_hours"`
+	ExpiringWithinHours float64                  `json:"expiring_within_hours"`
+	StaleAfterHours     float64                  `json:"stale_after_hours"`
+}
+
+type databaseBackupCoverage struct {
+	Database          string           `json:"database"`
+	Status            string           `json:"status" jsonschema:"protected, expiring, stale or unprotected"`
+	Backups           int              `json:"backups" jsonschema:"Number of ready backups"`
+	LatestBackup      string           `json:"latest_backup,omitempty" jsonschema:"ID of the ready backup with the latest version time"`
+	LatestVersionTime string           `json:"latest_version_time,omitempty"`
+	LatestAgeHours    float64          `json:"latest_age_hours,omitempty" jsonschema:"Hours since the version time of the latest backup"`
+	Expiring          []expiringBackup `json:"expiring,omitempty" jsonschema:"Ready backups expiring within expiring_within_hours"`
+}
+
+type expiringBackup struct {
+	Backup     string `json:"backup"`
+	ExpireTime string `json:"expire_time"`
+	Latest     bool   `json:"latest,omitempty" jsonschema:"True if the backup is the latest backup of the database"`
+}
+
+type listDatabasesOutput struct {
+	Databases []listedDatabase `json:"databases"`
+}
+
+type listedDatabase struct {
+	Database string `json:"database" jsonschema:"Database ID"`
+	State    string `json:"state"`
+	Dialect  string `json:"dialect"`
+}
+
+// Formats of proto messages in outputs.
+const (
+	protoFormatText = "prototext"
+	protoFormatJSON = "protojson"
+	protoFormatNone = "none"
+)
+
+var protoFormats = []string{protoFormatText, protoFormatJSON, protoFormatNone}
+
+// withProtoFormatArg adds the proto_format argument to the tool.
+func withProtoFormatArg() mcp.ToolOption {
+	return mcp.WithString("proto_format",
+		mcp.Enum(protoFormats...),
+		mcp.Description("Format of proto messages in the text content. none omits raw proto messages also from the structured content (default: the server setting, prototext unless configured)"),
+	)
+}
+
+// protoFormat returns the format given by the argument, or the default of the server.
+func protoFormat(arg string) string {
+	switch {
+	case arg != "":
+		return arg
+	case cfg.Output.ProtoFormat != "":
+		return cfg.Output.ProtoFormat
+	default:
+		return protoFormatText
+	}
+}
+
+// formatProto formats the message as a text content. It returns no contents if the format is none.
+func formatProto(format string, m proto.Message) []mcp.Content {
+	switch format {
+	case protoFormatNone:
+		return nil
+	case protoFormatJSON:
+		return []mcp.Content{mcp.NewTextContent(protojson.MarshalOptions{Multiline: true}.Format(m))}
+	default:
+		return []mcp.Content{mcp.NewTextContent(prototext.Format(m))}
+	}
+}
+
+// protoToJSONValue converts the message into a value which is marshalled as the protojson format.
+func protoToJSONValue(m proto.Message) (any, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func planOperators(rows []plantree.RowWithPredicates) []planOperator {
+	return lo.Map(rows, func(row plantree.RowWithPredicates, _ int) planOperator {
+		return planOperator{ID: row.ID, Operator: row.Text(), Predicates: row.Predicates}
+	})
+}
+
+type lintSchemaOutput struct {
+	Dialect  string              `json:"dialect"`
+	Tables   int                 `json:"tables" jsonschema:"Number of checked tables"`
+	Findings []schemaLintFinding `json:"findings"`
+}
+
+type schemaLintFinding struct {
+	Rule       string `json:"rule" jsonschema:"monotonic-key, wide-interleave-tree, deep-interleave-tree, missing-ttl, unbounded-key-column or naming-convention"`
+	Severity   string `json:"severity" jsonschema:"error, warning or info"`
+	Table      string `json:"table,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
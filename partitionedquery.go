@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+// Parallelism of execute_partitioned_query, which is the number of partitions executed concurrently.
+const (
+	defaultPartitionParallelism = 4
+	maxPartitionParallelism     = 32
+)
+
+// errEnoughRows stops the remaining partitions after max_rows rows are read.
+var errEnoughRows = errors.New("enough rows")
+
+func executePartitionedQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs     `mapstructure:",squash"`
+		renderOptions `mapstructure:",squash"`
+		Query         string `mapstructure:"query"`
+		MaxRows       int    `mapstructure:"max_rows"`
+		Parallelism   int    `mapstructure:"parallelism"`
+		DataBoost     bool   `mapstructure:"data_boost"`
+		ProtoFormat   string `mapstructure:"proto_format"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxRows <= 0 {
+		req.MaxRows = defaultMaxRows
+	}
+	if req.Parallelism == 0 {
+		req.Parallelism = defaultPartitionParallelism
+	}
+	if req.Parallelism < 1 || req.Parallelism > maxPartitionParallelism {
+		return nil, &validationError{Field: "parallelism", Message: fmt.Sprintf("must be between 1 and %d", maxPartitionParallelism)}
+	}
+
+	format := protoFormat(req.ProtoFormat)
+	r, err := newRenderer(cfg.Output.Render.override(req.renderOptions), format)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkStatement(ctx, target, "query", req.Query); err != nil {
+		return nil, err
+	}
+	masks, err := cfg.Masking.queryMasks(ctx, target, "query", req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	txn, err := client.BatchReadOnlyTransaction(ctx, spanner.StrongRead())
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	stmt := spanner.NewStatement(req.Query)
+	var partitions []*spanner.Partition
+	err = retry(ctx, func(ctx context.Context) error {
+		partitions, err = txn.PartitionQueryWithOptions(ctx, stmt, spanner.PartitionOptions{},
+			spanner.QueryOptions{DataBoostEnabled: req.DataBoost})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := executePartitionedQueryOutput{Rows: [][]any{}, Partitions: len(partitions), Parallelism: req.Parallelism}
+	var (
+		mu      sync.Mutex
+		rowType *sppb.StructType
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(req.Parallelism)
+	for _, p := range partitions {
+		g.Go(func() error {
+			it := txn.Execute(gctx, p)
+			defer it.Stop()
+			for {
+				row, err := it.Next()
+				if err != nil && err != iterator.Done {
+					return err
+				}
+
+				mu.Lock()
+				// The row type is available after the first Next.
+				if rowType == nil {
+					rowType = it.Metadata.GetRowType()
+				}
+				mu.Unlock()
+				if row == nil {
+					break
+				}
+
+				values, err := decodeRowValues(row)
+				if err != nil {
+					return err
+				}
+
+				mu.Lock()
+				if len(out.Rows) == req.MaxRows {
+					out.HasMoreRows = true
+					mu.Unlock()
+					return errEnoughRows
+				}
+				out.Rows = append(out.Rows, values)
+				mu.Unlock()
+			}
+
+			mu.Lock()
+			out.CompletedPartitions++
+			completed := out.CompletedPartitions
+			mu.Unlock()
+			sendProgressNotification(ctx, request, completed, len(partitions))
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil && !errors.Is(err, errEnoughRows) {
+		return nil, err
+	}
+
+	if format != protoFormatNone {
+		if err := decodeProtoColumns(ctx, target, rowType, out.Rows); err != nil {
+			return nil, err
+		}
+	}
+	rowType = cfg.Masking.maskQueryResult(masks, rowType, out.Rows)
+	out.Columns = queryColumns(rowType)
+
+	text, err := renderQueryResult(executeQueryOutput{Columns: out.Columns, Rows: out.Rows, HasMoreRows: out.HasMoreRows}, rowType, r)
+	if err != nil {
+		return nil, err
+	}
+	text += fmt.Sprintf("%d of %d partitions completed with parallelism %d\n", out.CompletedPartitions, out.Partitions, out.Parallelism)
+	return mcp.NewToolResultStructured(out, text), nil
+}
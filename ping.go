@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+func pingHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[queryArgs](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, err := cfg.Client.principal(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve credentials: %w", err)
+	}
+
+	admin, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var db *databasepb.Database
+	err = retry(ctx, func(ctx context.Context) error {
+		db, err = admin.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: target.databasePath()})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database %s: %w", target.databasePath(), err)
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	start := time.Now()
+	err = client.Single().Query(ctx, spanner.NewStatement("SELECT 1")).Do(func(*spanner.Row) error { return nil })
+	if err != nil {
+		return nil, fmt.Errorf("failed to run SELECT 1: %w", err)
+	}
+	latency := time.Since(start)
+
+	out := pingOutput{
+		Database:      target.databasePath(),
+		State:         db.GetState().String(),
+		Dialect:       db.GetDatabaseDialect().String(),
+		DatabaseRole:  target.DatabaseRole,
+		Principal:     principal,
+		LatencyMillis: float64(latency.Microseconds()) / 1000,
+	}
+	return mcp.NewToolResultStructured(out, fmt.Sprintf("OK: %s (%s) as %s, SELECT 1 took %v",
+		out.Database, out.State, lo.CoalesceOrEmpty(principal, "unknown principal"), latency)), nil
+}
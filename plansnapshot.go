@@ -0,0 +1,400 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"cloud.google.com/go/storage"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// planDir is the local directory or the gs://{bucket}/{prefix} where plan saves snapshots. It is set by --plan-dir.
+var planDir string
+
+const (
+	planSnapshotsURI          = "spanner-plans://snapshots"
+	planSnapshotURITemplate   = "spanner-plans://snapshots/{snapshot}"
+	planComparisonURITemplate = "spanner-plans://compare/{base}/{other}"
+	planSnapshotExt           = ".json"
+	maxPlanSnapshotNameLength = 100
+	maxListedPlanSnapshots    = 100
+)
+
+// planSnapshotNameRe matches names of snapshots, which are also their file names.
+var planSnapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// planSnapshot is a query plan saved by plan with save_as.
+type planSnapshot struct {
+	Name      string         `json:"name"`
+	Database  string         `json:"database"`
+	Query     string         `json:"query"`
+	SavedAt   time.Time      `json:"saved_at"`
+	Summary   planSummary    `json:"summary"`
+	Operators []planOperator `json:"operators"`
+	Plan      string         `json:"plan"`
+	QueryPlan any            `json:"query_plan"`
+}
+
+// planSnapshotEntry is a snapshot in the list of snapshots.
+type planSnapshotEntry struct {
+	Name     string    `json:"name"`
+	URI      string    `json:"uri,omitempty"`
+	Database string    `json:"database"`
+	Query    string    `json:"query"`
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// planComparison is the difference between two snapshots.
+type planComparison struct {
+	Base          planSnapshotEntry `json:"base"`
+	Other         planSnapshotEntry `json:"other"`
+	SameDatabase  bool              `json:"same_database"`
+	SameQuery     bool              `json:"same_query"`
+	PlanChanged   bool              `json:"plan_changed"`
+	Summary       map[string]any    `json:"summary_changes"`
+	OperatorsDiff []string          `json:"operators_diff,omitempty"`
+}
+
+func validatePlanSnapshotName(field, name string) error {
+	if len(name) > maxPlanSnapshotNameLength || !planSnapshotNameRe.MatchString(name) {
+		return &validationError{Field: field, Message: fmt.Sprintf("must be at most %d letters, digits, '.', '_' and '-' starting with a letter or a digit", maxPlanSnapshotNameLength)}
+	}
+	return nil
+}
+
+func planSnapshotURI(name string) string {
+	return planSnapshotsURI + "/" + name
+}
+
+// savePlanSnapshot saves the plan of the query as a new snapshot in planDir. Existing snapshots are not overwritten.
+func savePlanSnapshot(ctx context.Context, target *profile, name, query string, qp *sppb.QueryPlan, operators []planOperator, plan string) error {
+	queryPlan, err := protoToJSONValue(qp)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(planSnapshot{
+		Name:      name,
+		Database:  target.databasePath(),
+		Query:     query,
+		SavedAt:   time.Now().UTC(),
+		Summary:   summarizePlan(qp),
+		Operators: operators,
+		Plan:      plan,
+		QueryPlan: queryPlan,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	exists := &validationError{Field: "save_as", Message: fmt.Sprintf("snapshot %s already exists", name)}
+	if bucket, prefix, ok := planDirGCS(); ok {
+		storageClient, err := clients.storageClient(ctx)
+		if err != nil {
+			return err
+		}
+		w := storageClient.Bucket(bucket).Object(prefix + name + planSnapshotExt).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+		w.ContentType = "application/json"
+		if _, err := w.Write(b); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			if isPreconditionFailed(err) {
+				return exists
+			}
+			return fmt.Errorf("failed to save snapshot %s: %w", name, err)
+		}
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(planDir, name+planSnapshotExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if errors.Is(err, os.ErrExist) {
+		return exists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	return f.Close()
+}
+
+// planDirGCS returns the bucket and the object prefix of planDir if it is in Cloud Storage.
+func planDirGCS() (bucket, prefix string, ok bool) {
+	if !strings.HasPrefix(planDir, "gs://") {
+		return "", "", false
+	}
+	bucket, prefix, _ = parseGCSURI(planDir)
+	return bucket, prefix, true
+}
+
+// isPreconditionFailed reports whether the write failed because the object exists, by the JSON or the gRPC API.
+func isPreconditionFailed(err error) bool {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code == http.StatusPreconditionFailed
+	}
+	return status.Code(err) == codes.FailedPrecondition
+}
+
+// loadPlanSnapshot reads the snapshot of the name.
+func loadPlanSnapshot(ctx context.Context, name string) (*planSnapshot, error) {
+	if err := validatePlanSnapshotName("snapshot", name); err != nil {
+		return nil, err
+	}
+	notFound := fmt.Errorf("snapshot %s is not found", name)
+
+	var r io.ReadCloser
+	if bucket, prefix, ok := planDirGCS(); ok {
+		storageClient, err := clients.storageClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, err = storageClient.Bucket(bucket).Object(prefix + name + planSnapshotExt).NewReader(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, notFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		f, err := os.Open(filepath.Join(planDir, name+planSnapshotExt))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, notFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	var s planSnapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	return &s, nil
+}
+
+// planSnapshotNames returns the names of snapshots in planDir, the most recently written first.
+func planSnapshotNames(ctx context.Context) ([]string, error) {
+	type file struct {
+		name    string
+		modTime time.Time
+	}
+	var files []file
+	if bucket, prefix, ok := planDirGCS(); ok {
+		storageClient, err := clients.storageClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		it := storageClient.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+		for {
+			attrs, err := it.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			// Prefixes of subdirectories have no names.
+			if attrs.Name != "" {
+				files = append(files, file{strings.TrimPrefix(attrs.Name, prefix), attrs.Updated})
+			}
+		}
+	} else {
+		entries, err := os.ReadDir(planDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			files = append(files, file{e.Name(), info.ModTime()})
+		}
+	}
+	slices.SortFunc(files, func(a, b file) int { return cmp.Or(b.modTime.Compare(a.modTime), cmp.Compare(a.name, b.name)) })
+	return lo.FilterMap(files, func(f file, _ int) (string, bool) {
+		name, ok := strings.CutSuffix(f.name, planSnapshotExt)
+		return name, ok && validatePlanSnapshotName("snapshot", name) == nil
+	}), nil
+}
+
+func (s *planSnapshot) entry() planSnapshotEntry {
+	return planSnapshotEntry{Name: s.Name, URI: planSnapshotURI(s.Name), Database: s.Database, Query: s.Query, SavedAt: s.SavedAt}
+}
+
+func registerPlanSnapshotResources(s *server.MCPServer) {
+	if planDir == "" {
+		return
+	}
+	s.AddResource(mcp.NewResource(planSnapshotsURI, "Plan snapshots",
+		mcp.WithResourceDescription(fmt.Sprintf("Query plans saved by plan with save_as in %s, up to %d newest first", planDir, maxListedPlanSnapshots)),
+		mcp.WithMIMEType("application/json"),
+	), planSnapshotsResourceHandler)
+
+	s.AddResourceTemplate(mcp.NewResourceTemplate(planSnapshotURITemplate, "Plan snapshot",
+		mcp.WithTemplateDescription("Query plan saved by plan with save_as: the database, the query, the time, the summary, the rendered plan and the QueryPlan message in protojson format"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), planSnapshotResourceHandler)
+
+	s.AddResourceTemplate(mcp.NewResourceTemplate(planComparisonURITemplate, "Plan snapshot comparison",
+		mcp.WithTemplateDescription("Difference between two saved plans: changes of scanned tables and indexes, full scans, joins and distribution operators, and the diff of the rendered plans"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), planComparisonResourceHandler)
+}
+
+func planSnapshotsResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	names, err := planSnapshotNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > maxListedPlanSnapshots {
+		names = names[:maxListedPlanSnapshots]
+	}
+
+	entries := make([]planSnapshotEntry, 0, len(names))
+	for _, name := range names {
+		s, err := loadPlanSnapshot(ctx, name)
+		if err != nil {
+			// A broken file doesn't hide the other snapshots.
+			slog.Warn("failed to read plan snapshot", "snapshot", name, "error", err)
+			continue
+		}
+		entries = append(entries, s.entry())
+	}
+	slices.SortFunc(entries, func(a, b planSnapshotEntry) int {
+		return cmp.Or(b.SavedAt.Compare(a.SavedAt), cmp.Compare(a.Name, b.Name))
+	})
+	return jsonResourceContents(request.Params.URI, map[string]any{"snapshots": entries})
+}
+
+func planSnapshotResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	name, ok := strings.CutPrefix(request.Params.URI, planSnapshotsURI+"/")
+	if !ok {
+		return nil, fmt.Errorf("unknown resource: %s", request.Params.URI)
+	}
+	s, err := loadPlanSnapshot(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResourceContents(request.Params.URI, s)
+}
+
+func planComparisonResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	rest, ok := strings.CutPrefix(request.Params.URI, "spanner-plans://compare/")
+	base, other, ok2 := strings.Cut(rest, "/")
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("unknown resource: %s", request.Params.URI)
+	}
+	a, err := loadPlanSnapshot(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	b, err := loadPlanSnapshot(ctx, other)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResourceContents(request.Params.URI, comparePlanSnapshots(a, b))
+}
+
+// comparePlanSnapshots compares the summaries and the rendered operators of the snapshots.
+func comparePlanSnapshots(a, b *planSnapshot) planComparison {
+	c := planComparison{
+		Base:         a.entry(),
+		Other:        b.entry(),
+		SameDatabase: a.Database == b.Database,
+		SameQuery:    a.Query == b.Query,
+		PlanChanged:  a.Plan != b.Plan,
+		Summary:      map[string]any{},
+	}
+	for _, f := range []struct {
+		name string
+		a, b []string
+	}{
+		{"tables", a.Summary.Tables, b.Summary.Tables},
+		{"indexes", a.Summary.Indexes, b.Summary.Indexes},
+		{"full_scans", a.Summary.FullScans, b.Summary.FullScans},
+		{"joins", a.Summary.Joins, b.Summary.Joins},
+		{"distribution", a.Summary.Distribution, b.Summary.Distribution},
+		{"parameters", a.Summary.Parameters, b.Summary.Parameters},
+	} {
+		removed, added := lo.Difference(f.a, f.b)
+		if len(removed) > 0 || len(added) > 0 {
+			c.Summary[f.name] = map[string][]string{"removed": removed, "added": added}
+		}
+	}
+	if c.PlanChanged {
+		text := func(ops []planOperator) []string {
+			return lo.Map(ops, func(op planOperator, _ int) string { return op.Operator })
+		}
+		c.OperatorsDiff = lineDiff(text(a.Operators), text(b.Operators))
+	}
+	return c
+}
+
+// lineDiff returns the lines of a and b prefixed by "  " if they are common, "- " if only in a and "+ " if only in b,
+// in the order of the longest common subsequence.
+func lineDiff(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// planSnapshotLink is the content of the plan result linking the saved snapshot.
+func planSnapshotLink(name string) mcp.Content {
+	return mcp.NewResourceLink(planSnapshotURI(name), name, "Saved query plan", "application/json")
+}
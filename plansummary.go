@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/samber/lo"
+)
+
+// summarizePlan extracts the key facts of the plan: scanned tables and indexes, full scans, join methods,
+// distribution operators and referenced parameters.
+func summarizePlan(qp *sppb.QueryPlan) planSummary {
+	var s planSummary
+	for _, scan := range planScans(qp) {
+		switch scan.kind {
+		case "table":
+			s.Tables = append(s.Tables, scan.target)
+		case "index":
+			s.Indexes = append(s.Indexes, scan.target)
+		default:
+			continue
+		}
+		if scan.full {
+			s.FullScans = append(s.FullScans, fmt.Sprintf("%s %s", scan.kind, scan.target))
+		}
+	}
+
+	for _, node := range qp.GetPlanNodes() {
+		name := node.GetDisplayName()
+		switch {
+		case node.GetKind() == sppb.PlanNode_SCALAR:
+			if name == "Parameter" {
+				s.Parameters = append(s.Parameters, node.GetShortRepresentation().GetDescription())
+			}
+			continue
+		case strings.Contains(name, "Join") || strings.Contains(name, "Apply"):
+			s.Joins = append(s.Joins, joinMethod(node))
+		}
+		if strings.Contains(name, "Distributed") {
+			s.Distribution = append(s.Distribution, name)
+		}
+	}
+
+	s.Tables, s.Indexes, s.FullScans, s.Parameters = lo.Uniq(s.Tables), lo.Uniq(s.Indexes), lo.Uniq(s.FullScans), lo.Uniq(s.Parameters)
+	s.Joins, s.Distribution = countNames(s.Joins), countNames(s.Distribution)
+	return s
+}
+
+// joinMethod returns the display name of the join with its join type, e.g. Hash Join (LEFT OUTER).
+func joinMethod(node *sppb.PlanNode) string {
+	if typ := node.GetMetadata().GetFields()["join_type"].GetStringValue(); typ != "" && typ != "INNER" {
+		return fmt.Sprintf("%s (%s)", node.GetDisplayName(), typ)
+	}
+	return node.GetDisplayName()
+}
+
+// countNames deduplicates the names in the order of appearance with the number of occurrences, e.g. Hash Join x2.
+func countNames(names []string) []string {
+	counts := lo.CountValues(names)
+	return lo.Map(lo.Uniq(names), func(name string, _ int) string {
+		if counts[name] > 1 {
+			return fmt.Sprintf("%s x%d", name, counts[name])
+		}
+		return name
+	})
+}
+
+// renderPlanSummary renders the summary as a line per fact.
+func renderPlanSummary(s planSummary) string {
+	none := func(values []string) string {
+		if len(values) == 0 {
+			return "none"
+		}
+		return strings.Join(values, ", ")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tables: %s\n", none(s.Tables))
+	fmt.Fprintf(&b, "Indexes: %s\n", none(s.Indexes))
+	fmt.Fprintf(&b, "Full scans: %s\n", none(s.FullScans))
+	fmt.Fprintf(&b, "Joins: %s\n", none(s.Joins))
+	fmt.Fprintf(&b, "Distribution: %s\n", none(s.Distribution))
+	fmt.Fprintf(&b, "Parameters: %s\n", none(s.Parameters))
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// poolStatsReader reads the session pool metrics recorded by the Spanner client for pool_stats.
+// It is registered to the global meter provider by setupTelemetry or enablePoolStats.
+var poolStatsReader = sdkmetric.NewManualReader()
+
+// enablePoolStats records the metrics of the Spanner client only for pool_stats when telemetry is not set up.
+func enablePoolStats() {
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(poolStatsReader)))
+	spanner.EnableOpenTelemetryMetrics()
+}
+
+func poolStatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, err := mapToStruct[struct{}](request.GetArguments()); err != nil {
+		return nil, err
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := poolStatsReader.Collect(ctx, &rm); err != nil {
+		return nil, err
+	}
+
+	out := poolStatsOutput{
+		MultiplexedSessions: cfg.Client.MultiplexedSessions,
+		Clients:             clients.stats(time.Now()),
+		Pools:               sessionPoolStatsOf(rm),
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d cached clients, multiplexed sessions %t\n", len(out.Clients), out.MultiplexedSessions)
+	for _, c := range out.Clients {
+		fmt.Fprintf(&b, "  %s", c.Database)
+		if c.DatabaseRole != "" {
+			fmt.Fprintf(&b, " role=%s", c.DatabaseRole)
+		}
+		if c.RouteToLeader != "" {
+			fmt.Fprintf(&b, " route_to_leader=%s", c.RouteToLeader)
+		}
+		fmt.Fprintf(&b, "\tin-use calls=%d\tidle=%.0fs\n", c.InUseCalls, c.IdleSeconds)
+	}
+	for _, p := range out.Pools {
+		fmt.Fprintf(&b, "Pool %s of %s/%s: open=%d/%d in-use=%d idle=%d max-in-use(10m)=%d acquired=%d released=%d timeouts=%d",
+			p.ClientID, p.Instance, p.Database, p.OpenSessions, p.MaxAllowedSessions, p.InUseSessions, p.IdleSessions,
+			p.MaxInUseSessions, p.AcquiredSessions, p.ReleasedSessions, p.GetSessionTimeouts)
+		if p.GFELatencyCount > 0 {
+			fmt.Fprintf(&b, " gfe-latency avg=%.1fms max=%dms", p.GFELatencyAvgMillis, p.GFELatencyMaxMillis)
+		}
+		b.WriteString("\n")
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// stats returns the cached clients in the order of databases.
+func (c *clientCache) stats(now time.Time) []cachedClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]cachedClientStats, 0, len(c.entries))
+	for key, entry := range c.entries {
+		s := cachedClientStats{Database: key.databasePath(), DatabaseRole: key.DatabaseRole, RouteToLeader: key.routeToLeader, InUseCalls: entry.inUse}
+		if entry.inUse == 0 && !entry.lastUsed.IsZero() {
+			s.IdleSeconds = now.Sub(entry.lastUsed).Seconds()
+		}
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b cachedClientStats) int {
+		return cmp.Or(cmp.Compare(a.Database, b.Database), cmp.Compare(a.DatabaseRole, b.DatabaseRole), cmp.Compare(a.RouteToLeader, b.RouteToLeader))
+	})
+	return stats
+}
+
+// sessionPoolStatsOf groups the spanner/ metrics of the Spanner client by clients.
+// Metrics of multiplexed sessions have is_multiplexed=true and are not counted in the pool.
+func sessionPoolStatsOf(rm metricdata.ResourceMetrics) []sessionPoolStats {
+	pools := make(map[string]*sessionPoolStats)
+	pool := func(attrs attribute.Set) *sessionPoolStats {
+		id, _ := attrs.Value("client_id")
+		p, ok := pools[id.AsString()]
+		if !ok {
+			instance, _ := attrs.Value("instance_id")
+			database, _ := attrs.Value("database")
+			p = &sessionPoolStats{ClientID: id.AsString(), Instance: instance.AsString(), Database: database.AsString()}
+			pools[id.AsString()] = p
+		}
+		return p
+	}
+	multiplexed := func(attrs attribute.Set) bool {
+		v, _ := attrs.Value("is_multiplexed")
+		return v.AsString() == "true"
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != spanner.OtInstrumentationScope {
+			continue
+		}
+		for _, m := range sm.Metrics {
+			name, ok := strings.CutPrefix(m.Name, "spanner/")
+			if !ok {
+				continue
+			}
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					p := pool(dp.Attributes)
+					switch typ, _ := dp.Attributes.Value("type"); {
+					case multiplexed(dp.Attributes):
+						if name == "open_session_count" {
+							p.MultiplexedSessions = dp.Value
+						}
+					case name == "open_session_count":
+						p.OpenSessions = dp.Value
+					case name == "max_allowed_sessions":
+						p.MaxAllowedSessions = dp.Value
+					case name == "max_in_use_sessions":
+						p.MaxInUseSessions = dp.Value
+					case name == "num_sessions_in_pool" && typ.AsString() == "num_in_use_sessions":
+						p.InUseSessions = dp.Value
+					case name == "num_sessions_in_pool" && typ.AsString() == "num_sessions":
+						p.IdleSessions = dp.Value
+					}
+				}
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					p := pool(dp.Attributes)
+					switch name {
+					case "get_session_timeouts":
+						p.GetSessionTimeouts += dp.Value
+					case "num_acquired_sessions":
+						p.AcquiredSessions += dp.Value
+					case "num_released_sessions":
+						p.ReleasedSessions += dp.Value
+					}
+				}
+			case metricdata.Histogram[int64]:
+				if name != "gfe_latency" {
+					continue
+				}
+				for _, dp := range data.DataPoints {
+					p := pool(dp.Attributes)
+					p.GFELatencyCount += dp.Count
+					p.gfeLatencySum += dp.Sum
+					if v, ok := dp.Max.Value(); ok {
+						p.GFELatencyMaxMillis = max(p.GFELatencyMaxMillis, v)
+					}
+				}
+			}
+		}
+	}
+
+	stats := make([]sessionPoolStats, 0, len(pools))
+	for _, p := range pools {
+		if p.GFELatencyCount > 0 {
+			p.GFELatencyAvgMillis = float64(p.gfeLatencySum) / float64(p.GFELatencyCount)
+		}
+		stats = append(stats, *p)
+	}
+	slices.SortFunc(stats, func(a, b sessionPoolStats) int {
+		return cmp.Or(cmp.Compare(a.Instance, b.Instance), cmp.Compare(a.Database, b.Database), cmp.Compare(a.ClientID, b.ClientID))
+	})
+	return stats
+}
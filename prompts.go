@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+func registerPrompts(s *server.MCPServer) {
+	s.AddPrompt(mcp.NewPrompt("optimize_query",
+		mcp.WithPromptDescription("Optimize a query using its query plan, the schema and the query statistics"),
+		mcp.WithArgument("query", mcp.ArgumentDescription("SQL query to optimize"), mcp.RequiredArgument()),
+		withDatabasePromptArgs(),
+	), optimizeQueryPrompt)
+
+	s.AddPrompt(mcp.NewPrompt("review_schema",
+		mcp.WithPromptDescription("Review the schema design of the database"),
+		withDatabasePromptArgs(),
+	), reviewSchemaPrompt)
+
+	s.AddPrompt(mcp.NewPrompt("diagnose_slow_database",
+		mcp.WithPromptDescription("Diagnose a slow database using the query statistics and the lock statistics"),
+		withDatabasePromptArgs(),
+	), diagnoseSlowDatabasePrompt)
+}
+
+// withDatabasePromptArgs is the prompt counterpart of withDatabaseArgs.
+func withDatabasePromptArgs() mcp.PromptOption {
+	return func(p *mcp.Prompt) {
+		for _, opt := range []mcp.PromptOption{
+			mcp.WithArgument("profile", mcp.ArgumentDescription("Name of the profile in the config file")),
+			mcp.WithArgument("project", mcp.ArgumentDescription("Project ID (overrides the profile)")),
+			mcp.WithArgument("instance", mcp.ArgumentDescription("Instance ID (overrides the profile)")),
+			mcp.WithArgument("database", mcp.ArgumentDescription("Database ID, projects/{project}/instances/{instance}/databases/{database} or spanner://{project}/{instance}/{database} (overrides the profile)")),
+		} {
+			opt(p)
+		}
+	}
+}
+
+// promptArgs decodes arguments of the prompt like arguments of tools.
+func promptArgs[T any](request mcp.GetPromptRequest) (T, error) {
+	return mapToStruct[T](lo.MapValues(request.Params.Arguments, func(v string, _ string) any { return v }))
+}
+
+func optimizeQueryPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	req, err := promptArgs[struct {
+		databaseArgs `mapstructure:",squash"`
+		Query        string `mapstructure:"query"`
+	}](request)
+	if err != nil {
+		return nil, err
+	}
+	if req.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, processed, err := analyzeQuery(ctx, target, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := printResult(processed)
+	if err != nil {
+		return nil, err
+	}
+
+	ddl, err := embedResource(ctx, ddlResourceHandler, resourceURI(target, "ddl"))
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	stats, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT INTERVAL_END, EXECUTION_COUNT, AVG_LATENCY_SECONDS, AVG_ROWS, AVG_BYTES, AVG_ROWS_SCANNED, AVG_CPU_SECONDS
+FROM SPANNER_SYS.QUERY_STATS_TOP_HOUR
+WHERE TEXT = @query
+ORDER BY INTERVAL_END DESC`,
+		Params: map[string]any{"query": req.Query},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statsText := "No statistics of the query are found in SPANNER_SYS.QUERY_STATS_TOP_HOUR."
+	if len(stats) > 0 {
+		b, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		statsText = "Hourly statistics of the query from SPANNER_SYS.QUERY_STATS_TOP_HOUR:\n" + string(b)
+	}
+
+	return mcp.NewGetPromptResult("Optimize the query", []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf(`Optimize the following query on the Cloud Spanner database %s.
+Explain the bottlenecks in the query plan and suggest rewrites of the query, indexes or schema changes with their trade-offs.
+
+%s`, target.databasePath(), req.Query))),
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Query plan:\n"+plan)),
+		ddl,
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(statsText)),
+	}), nil
+}
+
+func reviewSchemaPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	req, err := promptArgs[databaseArgs](request)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ddl, err := embedResource(ctx, ddlResourceHandler, resourceURI(target, "ddl"))
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewGetPromptResult("Review the schema design", []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf(`Review the schema design of the Cloud Spanner database %s.
+Check primary keys which cause hotspots, interleaving, secondary indexes, data types and foreign keys, and suggest improvements with DDL statements.`, target.databasePath()))),
+		ddl,
+	}), nil
+}
+
+func diagnoseSlowDatabasePrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	req, err := promptArgs[databaseArgs](request)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryStats, err := embedResource(ctx, queryStatsResourceHandler, resourceURI(target, "query-stats/hour"))
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	lockStats, err := queryRows(ctx, client, spanner.NewStatement(`SELECT INTERVAL_END, ROW_RANGE_START_KEY, LOCK_WAIT_SECONDS, SAMPLE_LOCK_REQUESTS
+FROM SPANNER_SYS.LOCK_STATS_TOP_HOUR
+WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM SPANNER_SYS.LOCK_STATS_TOP_HOUR)
+ORDER BY LOCK_WAIT_SECONDS DESC`))
+	if err != nil {
+		return nil, err
+	}
+	lockStatsJSON, err := json.MarshalIndent(lockStats, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewGetPromptResult("Diagnose the slow database", []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf(`Diagnose why the Cloud Spanner database %s is slow.
+Identify expensive queries and lock contentions from the statistics of the latest hour, and suggest next steps including the plan tool to inspect the queries.`, target.databasePath()))),
+		queryStats,
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Lock statistics from SPANNER_SYS.LOCK_STATS_TOP_HOUR:\n"+string(lockStatsJSON))),
+	}), nil
+}
+
+// embedResource reads the resource using the handler and returns it as a prompt message.
+func embedResource(ctx context.Context, handler server.ResourceHandlerFunc, uri string) (mcp.PromptMessage, error) {
+	contents, err := handler(ctx, mcp.ReadResourceRequest{Params: mcp.ReadResourceParams{URI: uri}})
+	if err != nil {
+		return mcp.PromptMessage{}, err
+	}
+	if len(contents) == 0 {
+		return mcp.PromptMessage{}, fmt.Errorf("resource %s is empty", uri)
+	}
+	return mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(contents[0])), nil
+}
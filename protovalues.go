@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protoValue is a decoded value of a PROTO column. It is marshalled in protojson format,
+// and rendered in tables in prototext format if requested.
+type protoValue struct {
+	message proto.Message
+}
+
+func (v *protoValue) MarshalJSON() ([]byte, error) {
+	return protojson.Marshal(v.message)
+}
+
+func (v *protoValue) String() string {
+	return prototext.MarshalOptions{}.Format(v.message)
+}
+
+// protoDecoder decodes PROTO and ENUM values using proto_descriptors of the database.
+type protoDecoder struct {
+	files *protoregistry.Files
+}
+
+// newProtoDecoder returns a decoder if the row type has PROTO or ENUM columns, otherwise nil.
+func newProtoDecoder(ctx context.Context, target *profile, rowType *sppb.StructType) (*protoDecoder, error) {
+	if !hasProtoType(&sppb.Type{Code: sppb.TypeCode_STRUCT, StructType: rowType}) {
+		return nil, nil
+	}
+
+	resp, err := getDatabaseDDL(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proto descriptors: %w", err)
+	}
+
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(resp.GetProtoDescriptors(), &fds); err != nil {
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(&fds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto descriptors: %w", err)
+	}
+	return &protoDecoder{files: files}, nil
+}
+
+func hasProtoType(typ *sppb.Type) bool {
+	switch typ.GetCode() {
+	case sppb.TypeCode_PROTO, sppb.TypeCode_ENUM:
+		return true
+	case sppb.TypeCode_ARRAY:
+		return hasProtoType(typ.GetArrayElementType())
+	case sppb.TypeCode_STRUCT:
+		for _, field := range typ.GetStructType().GetFields() {
+			if hasProtoType(field.GetType()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decode replaces PROTO values with *protoValue and ENUM values with names of the enum values.
+// Values which can't be decoded, e.g. unknown types, are left as is.
+func (d *protoDecoder) decode(typ *sppb.Type, v any) any {
+	switch v := v.(type) {
+	case []any:
+		result := make([]any, len(v))
+		for i, elem := range v {
+			result[i] = d.decode(typ.GetArrayElementType(), elem)
+		}
+		return result
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for _, field := range typ.GetStructType().GetFields() {
+			result[field.GetName()] = d.decode(field.GetType(), v[field.GetName()])
+		}
+		return result
+	case string:
+		switch typ.GetCode() {
+		case sppb.TypeCode_PROTO:
+			if m, err := d.decodeMessage(typ.GetProtoTypeFqn(), v); err == nil {
+				return &protoValue{message: m}
+			}
+		case sppb.TypeCode_ENUM:
+			if name, err := d.decodeEnum(typ.GetProtoTypeFqn(), v); err == nil {
+				return name
+			}
+		}
+	}
+	return v
+}
+
+func (d *protoDecoder) decodeMessage(fqn, s string) (proto.Message, error) {
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(fqn))
+	if err != nil {
+		return nil, err
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", fqn)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	m := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (d *protoDecoder) decodeEnum(fqn, s string) (string, error) {
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(fqn))
+	if err != nil {
+		return "", err
+	}
+	ed, ok := desc.(protoreflect.EnumDescriptor)
+	if !ok {
+		return "", fmt.Errorf("%s is not an enum", fqn)
+	}
+
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return "", err
+	}
+	value := ed.Values().ByNumber(protoreflect.EnumNumber(n))
+	if value == nil {
+		return "", fmt.Errorf("unknown value %d of %s", n, fqn)
+	}
+	return string(value.Name()), nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/api/iterator"
+)
+
+// defaultMaxRows is the default of max_rows of execute_query.
+const defaultMaxRows = 1000
+
+func executeQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs     `mapstructure:",squash"`
+		renderOptions `mapstructure:",squash"`
+		Query         string `mapstructure:"query"`
+		MaxRows       int    `mapstructure:"max_rows"`
+		ProtoFormat   string `mapstructure:"proto_format"`
+		QueryMode     string `mapstructure:"query_mode"`
+		Artifact      string `mapstructure:"artifact"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case req.MaxRows > 0:
+	case req.Artifact != "":
+		req.MaxRows = defaultArtifactMaxRows
+	default:
+		req.MaxRows = defaultMaxRows
+	}
+	if req.QueryMode != "" && req.QueryMode != "WITH_STATS" && req.QueryMode != "PROFILE" {
+		return nil, &validationError{Field: "query_mode", Message: "must be WITH_STATS or PROFILE"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Artifact != "" {
+		return artifactResult(ctx, target, spanner.NewStatement(req.Query), req.MaxRows, req.Artifact, req.QueryMode == "PROFILE")
+	}
+	return queryResult(ctx, target, spanner.NewStatement(req.Query), req.MaxRows, req.renderOptions, req.ProtoFormat, req.QueryMode == "PROFILE")
+}
+
+// queryResult executes the statement in a single-use read-only transaction and returns at most maxRows rows
+// as the result of execute_query. opts and format override the server settings.
+func queryResult(ctx context.Context, target *profile, stmt spanner.Statement, maxRows int, opts renderOptions, format string, profile bool) (*mcp.CallToolResult, error) {
+	format = protoFormat(format)
+	r, err := newRenderer(cfg.Output.Render.override(opts), format)
+	if err != nil {
+		return nil, err
+	}
+
+	out, rowType, err := runQuery(ctx, target, stmt, maxRows, format, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := renderQueryResult(out, rowType, r)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultStructured(out, text), nil
+}
+
+// runQuery executes the statement in a single-use read-only transaction and returns at most maxRows rows with the row type.
+// PROTO and ENUM values are decoded unless format is none, and sensitive columns are masked by the masking config.
+// The statistics of the execution are returned if all rows are read, and include rows scanned if profile is true, which executes it in PROFILE mode.
+func runQuery(ctx context.Context, target *profile, stmt spanner.Statement, maxRows int, format string, profile bool) (executeQueryOutput, *sppb.StructType, error) {
+	if err := cfg.Access.checkStatement(ctx, target, "query", stmt.SQL); err != nil {
+		return executeQueryOutput{}, nil, err
+	}
+	masks, err := cfg.Masking.queryMasks(ctx, target, "query", stmt.SQL)
+	if err != nil {
+		return executeQueryOutput{}, nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return executeQueryOutput{}, nil, err
+	}
+	defer release()
+
+	mode := sppb.ExecuteSqlRequest_WITH_STATS
+	if profile {
+		mode = sppb.ExecuteSqlRequest_PROFILE
+	}
+
+	var out executeQueryOutput
+	var rowType *sppb.StructType
+	err = retry(ctx, func(ctx context.Context) error {
+		out = executeQueryOutput{Rows: [][]any{}}
+		it := client.Single().QueryWithOptions(ctx, stmt, spanner.QueryOptions{Mode: &mode})
+		defer it.Stop()
+		for {
+			row, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if len(out.Rows) == maxRows {
+				out.HasMoreRows = true
+				break
+			}
+			values, err := decodeRowValues(row)
+			if err != nil {
+				return err
+			}
+			out.Rows = append(out.Rows, values)
+		}
+		rowType = it.Metadata.GetRowType()
+		out.Columns = queryColumns(rowType)
+		// The statistics are sent after the last row, so they are not available if rows are omitted.
+		out.Stats = queryStatsOf(it.QueryStats)
+		return nil
+	})
+	if err != nil {
+		return executeQueryOutput{}, nil, err
+	}
+
+	if format != protoFormatNone {
+		if err := decodeProtoColumns(ctx, target, rowType, out.Rows); err != nil {
+			return executeQueryOutput{}, nil, err
+		}
+	}
+
+	rowType = cfg.Masking.maskQueryResult(masks, rowType, out.Rows)
+	out.Columns = queryColumns(rowType)
+	return out, rowType, nil
+}
+
+// decodeProtoColumns decodes values of PROTO and ENUM columns in place using proto_descriptors of the database.
+func decodeProtoColumns(ctx context.Context, target *profile, rowType *sppb.StructType, rows [][]any) error {
+	decoder, err := newProtoDecoder(ctx, target, rowType)
+	if err != nil || decoder == nil {
+		return err
+	}
+
+	for _, row := range rows {
+		for i, field := range rowType.GetFields() {
+			row[i] = decoder.decode(field.GetType(), row[i])
+		}
+	}
+	return nil
+}
+
+// decodeRowValues converts a row into values in the order of columns.
+func decodeRowValues(row *spanner.Row) ([]any, error) {
+	result := make([]any, row.Size())
+	for i := range result {
+		var gcv spanner.GenericColumnValue
+		if err := row.Column(i, &gcv); err != nil {
+			return nil, err
+		}
+		v, err := decodeGenericColumnValue(gcv)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+func queryColumns(rowType *sppb.StructType) []queryColumn {
+	return lo.Map(rowType.GetFields(), func(field *sppb.StructType_Field, _ int) queryColumn {
+		return queryColumn{Name: field.GetName(), Type: formatType(field.GetType())}
+	})
+}
+
+// formatType formats the type in GoogleSQL syntax, e.g. ARRAY<STRUCT<SingerId INT64, Info examples.SingerInfo>>.
+// PROTO and ENUM are formatted as their fully qualified names.
+func formatType(typ *sppb.Type) string {
+	switch typ.GetCode() {
+	case sppb.TypeCode_ARRAY:
+		return fmt.Sprintf("ARRAY<%s>", formatType(typ.GetArrayElementType()))
+	case sppb.TypeCode_STRUCT:
+		fields := lo.Map(typ.GetStructType().GetFields(), func(field *sppb.StructType_Field, _ int) string {
+			if field.GetName() == "" {
+				return formatType(field.GetType())
+			}
+			return field.GetName() + " " + formatType(field.GetType())
+		})
+		return fmt.Sprintf("STRUCT<%s>", strings.Join(fields, ", "))
+	case sppb.TypeCode_PROTO, sppb.TypeCode_ENUM:
+		return typ.GetProtoTypeFqn()
+	}
+
+	switch typ.GetTypeAnnotation() {
+	case sppb.TypeAnnotationCode_PG_NUMERIC, sppb.TypeAnnotationCode_PG_JSONB, sppb.TypeAnnotationCode_PG_OID:
+		return typ.GetTypeAnnotation().String()
+	default:
+		return typ.GetCode().String()
+	}
+}
+
+// renderQueryResult renders the result as a table. Headers contain column types.
+func renderQueryResult(out executeQueryOutput, rowType *sppb.StructType, r *renderer) (string, error) {
+	var b strings.Builder
+	table := newTable(&b)
+	table.SetHeader(lo.Map(out.Columns, func(c queryColumn, _ int) string {
+		return strings.TrimSpace(c.Name + " " + c.Type)
+	}))
+
+	for _, row := range out.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			s, err := r.render(rowType.GetFields()[i].GetType(), v)
+			if err != nil {
+				return "", err
+			}
+			cells[i] = s
+		}
+		table.Append(cells)
+	}
+	if len(out.Columns) > 0 {
+		table.Render()
+	}
+
+	fmt.Fprintf(&b, "%d rows", len(out.Rows))
+	if out.HasMoreRows {
+		b.WriteString(" (more rows are omitted by max_rows)")
+	}
+	b.WriteString("\n")
+	b.WriteString(renderQueryStats(out.Stats))
+	return b.String(), nil
+}
+
+// queryStatsOf returns the statistics of the ResultSetStats of the query, or nil if they are not available.
+func queryStatsOf(stats map[string]any) *queryStats {
+	if len(stats) == 0 {
+		return nil
+	}
+	count := func(key string) *int64 {
+		s, _ := stats[key].(string)
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return &n
+	}
+	s := &queryStats{RowsReturned: count("rows_returned"), RowsScanned: count("rows_scanned")}
+	s.ElapsedTime, _ = stats["elapsed_time"].(string)
+	s.CPUTime, _ = stats["cpu_time"].(string)
+	return s
+}
+
+// renderQueryStats renders the statistics as a line, or returns an empty string if they are not available.
+func renderQueryStats(s *queryStats) string {
+	if s == nil {
+		return ""
+	}
+	var stats []string
+	if s.RowsReturned != nil {
+		stats = append(stats, fmt.Sprintf("rows returned %d", *s.RowsReturned))
+	}
+	if s.RowsScanned != nil {
+		stats = append(stats, fmt.Sprintf("rows scanned %d", *s.RowsScanned))
+	}
+	if s.ElapsedTime != "" {
+		stats = append(stats, "elapsed time "+s.ElapsedTime)
+	}
+	if s.CPUTime != "" {
+		stats = append(stats, "CPU time "+s.CPUTime)
+	}
+	if len(stats) == 0 {
+		return ""
+	}
+	return "Stats: " + strings.Join(stats, ", ") + "\n"
+}
+
+// Limits of sample_rows.
+const (
+	defaultSampleRows = 10
+	maxSampleRows     = 100
+)
+
+// tableNameRe matches a table name optionally qualified by a named schema.
+var tableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// quoteTableName validates the table name and quotes it as a GoogleSQL identifier.
+func quoteTableName(table string) (string, error) {
+	table = strings.ReplaceAll(table, "`", "")
+	if !tableNameRe.MatchString(table) {
+		return "", &validationError{Field: "table", Message: fmt.Sprintf("%q is not a valid table name", table)}
+	}
+	return "`" + strings.ReplaceAll(table, ".", "`.`") + "`", nil
+}
+
+func sampleRowsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs   `mapstructure:",squash"`
+		Table       string  `mapstructure:"table"`
+		Method      string  `mapstructure:"method"`
+		Rows        int     `mapstructure:"rows"`
+		Percent     float64 `mapstructure:"percent"`
+		ProtoFormat string  `mapstructure:"proto_format"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := quoteTableName(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+	if req.Rows <= 0 {
+		req.Rows = defaultSampleRows
+	}
+	if req.Rows > maxSampleRows {
+		return nil, &validationError{Field: "rows", Message: fmt.Sprintf("must not be greater than %d", maxSampleRows)}
+	}
+
+	var sql string
+	switch req.Method {
+	case "", "reservoir":
+		sql = fmt.Sprintf("SELECT * FROM %s TABLESAMPLE RESERVOIR (%d ROWS)", table, req.Rows)
+	case "bernoulli":
+		if req.Percent == 0 {
+			req.Percent = 1
+		}
+		if req.Percent < 0 || req.Percent > 100 {
+			return nil, &validationError{Field: "percent", Message: "must be between 0 and 100"}
+		}
+		// The number of rows sampled by BERNOULLI is not bounded, so the result is limited.
+		sql = fmt.Sprintf("SELECT * FROM %s TABLESAMPLE BERNOULLI (%g PERCENT) LIMIT %d", table, req.Percent, req.Rows)
+	default:
+		return nil, &validationError{Field: "method", Message: "must be reservoir or bernoulli"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryResult(ctx, target, spanner.NewStatement(sql), req.Rows, renderOptions{}, req.ProtoFormat, false)
+}
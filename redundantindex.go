@@ -0,0 +1,325 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// catalogIndexKeys is a secondary index or the primary key of a table with its key columns in order and STORING columns.
+type catalogIndexKeys struct {
+	table        string
+	name         string
+	primaryKey   bool
+	unique       bool
+	nullFiltered bool
+	keys         []string
+	storing      []string
+}
+
+func (i *catalogIndexKeys) label() string {
+	if i.primaryKey {
+		return "the primary key of " + i.table
+	}
+	return i.name
+}
+
+// indexKeys returns the primary keys and the secondary indexes of the tables, or of the table if it is not empty.
+// Search and vector indexes are not included because they are not used for lookups by keys.
+func (c *schemaCatalog) indexKeys(ctx context.Context, table string) ([]*catalogIndexKeys, error) {
+	sql := `SELECT i.TABLE_SCHEMA, i.TABLE_NAME, i.INDEX_NAME, i.INDEX_TYPE, i.IS_UNIQUE, i.IS_NULL_FILTERED,
+  ic.COLUMN_NAME, ic.ORDINAL_POSITION, ic.COLUMN_ORDERING
+FROM INFORMATION_SCHEMA.INDEXES AS i
+JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+  ON ic.TABLE_SCHEMA = i.TABLE_SCHEMA AND ic.TABLE_NAME = i.TABLE_NAME AND ic.INDEX_NAME = i.INDEX_NAME
+WHERE i.TABLE_SCHEMA NOT IN ` + catalogSystemSchemas + ` AND i.INDEX_TYPE IN ('INDEX', 'PRIMARY_KEY')`
+	var args []any
+	if table != "" {
+		schema, name := c.splitTable(table)
+		sql += " AND i.TABLE_SCHEMA = @p1 AND i.TABLE_NAME = @p2"
+		args = append(args, schema, name)
+	}
+	rows, err := c.query(ctx, sql+"\nORDER BY i.TABLE_SCHEMA, i.TABLE_NAME, i.INDEX_NAME", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []*catalogIndexKeys
+	for _, group := range lo.PartitionBy(rows, func(row map[string]any) string {
+		return catalogString(row, "TABLE_SCHEMA") + "." + catalogString(row, "TABLE_NAME") + "." + catalogString(row, "INDEX_NAME")
+	}) {
+		// STORING columns have no ORDINAL_POSITION, which is ordered differently by the dialects.
+		slices.SortStableFunc(group, func(a, b map[string]any) int {
+			pa, _ := a["ORDINAL_POSITION"].(int64)
+			pb, _ := b["ORDINAL_POSITION"].(int64)
+			return cmp.Compare(pa, pb)
+		})
+		row := group[0]
+		i := &catalogIndexKeys{
+			table:        c.qualifiedName(catalogString(row, "TABLE_SCHEMA"), catalogString(row, "TABLE_NAME")),
+			name:         c.qualifiedName(catalogString(row, "TABLE_SCHEMA"), catalogString(row, "INDEX_NAME")),
+			primaryKey:   catalogString(row, "INDEX_TYPE") == "PRIMARY_KEY",
+			unique:       catalogBool(row, "IS_UNIQUE"),
+			nullFiltered: catalogBool(row, "IS_NULL_FILTERED"),
+		}
+		for _, column := range group {
+			if column["ORDINAL_POSITION"] == nil {
+				i.storing = append(i.storing, catalogString(column, "COLUMN_NAME"))
+			} else {
+				i.keys = append(i.keys, catalogKeyColumn(catalogString(column, "COLUMN_NAME"), catalogString(column, "COLUMN_ORDERING")))
+			}
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// latestSizes returns the latest sizes of tables and indexes from SPANNER_SYS.TABLE_SIZES_STATS_1HOUR by their names.
+func (c *schemaCatalog) latestSizes(ctx context.Context) (map[string]int64, error) {
+	rows, err := c.query(ctx, `SELECT TABLE_NAME, USED_BYTES FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR
+WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR)`)
+	if err != nil {
+		return nil, err
+	}
+	return lo.SliceToMap(rows, func(row map[string]any) (string, int64) {
+		bytes, _ := row["USED_BYTES"].(int64)
+		return catalogString(row, "TABLE_NAME"), bytes
+	}), nil
+}
+
+// queryTexts returns the distinct texts of the top queries of the retained hours from SPANNER_SYS.QUERY_STATS_TOP_HOUR.
+func (c *schemaCatalog) queryTexts(ctx context.Context) ([]string, error) {
+	rows, err := c.query(ctx, `SELECT DISTINCT TEXT FROM SPANNER_SYS.QUERY_STATS_TOP_HOUR`)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(rows, func(row map[string]any, _ int) string { return catalogString(row, "TEXT") }), nil
+}
+
+// redundantIndexes finds secondary indexes which are covered by other indexes or the primary key of the same table.
+// An index is covered if its key columns are the same as or a prefix of the other, in the same order, and the other has its
+// STORING columns. Unique indexes enforce constraints and are only covered by unique indexes of the same key columns,
+// and NULL_FILTERED indexes can't cover indexes which have rows with NULL.
+func redundantIndexes(indexes []*catalogIndexKeys) []redundantIndex {
+	var findings []redundantIndex
+	for _, group := range lo.PartitionBy(indexes, func(i *catalogIndexKeys) string { return i.table }) {
+		primaryKey, _ := lo.Find(group, func(i *catalogIndexKeys) bool { return i.primaryKey })
+		// Indexes of the same key columns are not reported against each other.
+		reported := make(map[string]bool)
+		for _, i := range group {
+			if i.primaryKey {
+				continue
+			}
+			for _, other := range group {
+				if other == i || reported[other.name] {
+					continue
+				}
+				if f, ok := coveredIndex(i, other, primaryKey); ok {
+					findings = append(findings, f)
+					reported[i.name] = true
+					break
+				}
+			}
+		}
+		// Indexes which can be dropped are not reported for merges.
+		for _, i := range group {
+			if i.primaryKey || reported[i.name] {
+				continue
+			}
+			for _, other := range group {
+				if other != i && !other.primaryKey && !reported[other.name] && i.name < other.name && i.keys[0] == other.keys[0] {
+					findings = append(findings, redundantIndex{
+						Rule:     "shared-leading-column",
+						Severity: "info",
+						Table:    i.table,
+						Index:    i.name,
+						Other:    other.name,
+						Message: fmt.Sprintf("%s (%s) and %s (%s) share the leading key column %s, so queries by %s can use either of them",
+							i.name, strings.Join(i.keys, ", "), other.name, strings.Join(other.keys, ", "), i.keys[0], i.keys[0]),
+						Suggestion: "Consider merging them into an index of the key columns of one and the other as STORING columns if queries of both are served by it",
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// coveredIndex returns a finding if the index is covered by the other index or the primary key of the table.
+func coveredIndex(i, other, primaryKey *catalogIndexKeys) (redundantIndex, bool) {
+	if len(i.keys) > len(other.keys) || !slices.Equal(i.keys, other.keys[:len(i.keys)]) {
+		return redundantIndex{}, false
+	}
+	same := len(i.keys) == len(other.keys)
+	switch {
+	case i.unique && !(same && other.unique):
+		return redundantIndex{}, false
+	case other.nullFiltered && !(i.nullFiltered && same):
+		return redundantIndex{}, false
+	case same && !other.primaryKey && !otherPreferred(i, other):
+		// The other is reported against this index.
+		return redundantIndex{}, false
+	}
+	if !other.primaryKey {
+		// Columns of the primary key of the table are in all indexes.
+		covered := lo.Map(slices.Concat(other.keys, other.storing, lo.FromPtr(primaryKey).keys), func(k string, _ int) string { return strings.TrimSuffix(k, " DESC") })
+		if !lo.Every(covered, i.storing) {
+			return redundantIndex{}, false
+		}
+	}
+
+	f := redundantIndex{Severity: "warning", Table: i.table, Index: i.name, Other: other.name, Suggestion: fmt.Sprintf("DROP INDEX %s", i.name)}
+	switch {
+	case other.primaryKey:
+		f.Rule, f.Other = "primary-key-prefix", ""
+		f.Message = fmt.Sprintf("The key columns of %s (%s) are %s %s (%s), which is sorted by them already and has all columns",
+			i.name, strings.Join(i.keys, ", "), lo.Ternary(same, "the same as", "a prefix of"), other.label(), strings.Join(other.keys, ", "))
+	case same:
+		f.Rule = "duplicate"
+		f.Message = fmt.Sprintf("%s has the same key columns (%s) as %s, which has its STORING columns", i.name, strings.Join(i.keys, ", "), other.name)
+	default:
+		f.Rule = "prefix"
+		f.Message = fmt.Sprintf("The key columns of %s (%s) are a prefix of %s (%s), which has its STORING columns, so queries by the prefix can use %s",
+			i.name, strings.Join(i.keys, ", "), other.name, strings.Join(other.keys, ", "), other.name)
+	}
+	return f, true
+}
+
+// otherPreferred reports whether the other index of the same key columns should be kept rather than the index,
+// which is the unique one, the one with more STORING columns, or the first by name.
+func otherPreferred(i, other *catalogIndexKeys) bool {
+	if i.unique != other.unique {
+		return other.unique
+	}
+	if len(i.storing) != len(other.storing) {
+		return len(other.storing) > len(i.storing)
+	}
+	return other.name < i.name
+}
+
+// unusedStoringColumns finds STORING columns which don't appear in the texts of the top queries.
+func unusedStoringColumns(indexes []*catalogIndexKeys, texts []string) []redundantIndex {
+	var findings []redundantIndex
+	for _, i := range indexes {
+		unused := lo.Filter(i.storing, func(column string, _ int) bool {
+			re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(column) + `\b`)
+			return !lo.ContainsBy(texts, re.MatchString)
+		})
+		if len(unused) == 0 {
+			continue
+		}
+		findings = append(findings, redundantIndex{
+			Rule:           "unused-storing",
+			Severity:       "info",
+			Table:          i.table,
+			Index:          i.name,
+			StoringColumns: unused,
+			Message: fmt.Sprintf("STORING columns %s of %s don't appear in the top queries of SPANNER_SYS.QUERY_STATS_TOP_HOUR",
+				strings.Join(unused, ", "), i.name),
+			Suggestion: strings.Join(lo.Map(unused, func(column string, _ int) string {
+				return fmt.Sprintf("ALTER INDEX %s DROP STORED COLUMN %s", i.name, column)
+			}), "; ") + ", if no queries read them by the index",
+		})
+	}
+	return findings
+}
+
+func findRedundantIndexesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	catalog, err := newSchemaCatalog(ctx, target, client)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := catalog.indexKeys(ctx, req.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	out := findRedundantIndexesOutput{Findings: redundantIndexes(indexes)}
+	secondary := lo.Filter(indexes, func(i *catalogIndexKeys, _ int) bool { return !i.primaryKey })
+	redundant := lo.SliceToMap(out.Findings, func(f redundantIndex) (string, bool) { return f.Index, f.Severity == "warning" })
+	if texts, err := catalog.queryTexts(ctx); err != nil {
+		slog.WarnContext(ctx, "failed to read query statistics", "error", err)
+		out.Notes = append(out.Notes, fmt.Sprintf("STORING columns are not checked because SPANNER_SYS.QUERY_STATS_TOP_HOUR can't be read: %v", err))
+	} else if len(texts) == 0 {
+		out.Notes = append(out.Notes, "STORING columns are not checked because SPANNER_SYS.QUERY_STATS_TOP_HOUR has no queries yet")
+	} else {
+		// Indexes which can be dropped are not checked for their columns.
+		out.Findings = append(out.Findings, unusedStoringColumns(lo.Filter(secondary, func(i *catalogIndexKeys, _ int) bool { return !redundant[i.name] }), texts)...)
+		out.Notes = append(out.Notes, "Queries reading STORING columns by names of other tables or SELECT * are not distinguished, and queries which are not in the top queries of any hour are not seen")
+	}
+
+	sizes, err := catalog.latestSizes(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read table sizes", "error", err)
+		out.Notes = append(out.Notes, fmt.Sprintf("Savings are not estimated because SPANNER_SYS.TABLE_SIZES_STATS_1HOUR can't be read: %v", err))
+	}
+	columns := lo.SliceToMap(secondary, func(i *catalogIndexKeys) (string, int) { return i.name, len(i.keys) + len(i.storing) })
+	for i := range out.Findings {
+		f := &out.Findings[i]
+		size, ok := sizes[f.Index]
+		if !ok {
+			continue
+		}
+		f.UsedBytes = &size
+		switch f.Severity {
+		case "warning":
+			f.EstimatedSavingsBytes = &size
+		case "info":
+			if len(f.StoringColumns) > 0 {
+				// Rows of the index are assumed to be even by columns, so dropping columns saves their share.
+				savings := size * int64(len(f.StoringColumns)) / int64(columns[f.Index])
+				f.EstimatedSavingsBytes = &savings
+			}
+		}
+	}
+	out.EstimatedSavingsBytes = lo.SumBy(out.Findings, func(f redundantIndex) int64 { return lo.FromPtr(f.EstimatedSavingsBytes) })
+
+	if len(out.Findings) == 0 {
+		out.Findings = []redundantIndex{}
+		return mcp.NewToolResultStructured(out, fmt.Sprintf("No redundant indexes in %d indexes\n", len(secondary))), nil
+	}
+	return mcp.NewToolResultStructured(out, renderRedundantIndexes(out)), nil
+}
+
+func renderRedundantIndexes(out findRedundantIndexesOutput) string {
+	var b strings.Builder
+	for _, f := range out.Findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n  Suggestion: %s\n", f.Severity, f.Rule, f.Message, f.Suggestion)
+		if f.EstimatedSavingsBytes != nil {
+			fmt.Fprintf(&b, "  Estimated savings: %d of %d bytes\n", *f.EstimatedSavingsBytes, *f.UsedBytes)
+		}
+	}
+	if out.EstimatedSavingsBytes > 0 {
+		fmt.Fprintf(&b, "\nEstimated savings in total: %d bytes\n", out.EstimatedSavingsBytes)
+	}
+	for _, note := range out.Notes {
+		fmt.Fprintf(&b, "Note: %s\n", note)
+	}
+	return b.String()
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var (
+	bytesFormats = []string{"base64", "hex", "length"}
+	jsonFormats  = []string{"compact", "pretty"}
+)
+
+// renderOptions configures how values of query results are rendered in text contents.
+// The structured content always has the values as decoded by decodeValue.
+type renderOptions struct {
+	// Null is the token of NULL, NULL by default.
+	Null string `yaml:"null" mapstructure:"null_token"`
+
+	// Bytes is the format of BYTES values: base64 (default), hex or length.
+	Bytes string `yaml:"bytes" mapstructure:"bytes_format"`
+
+	// JSON is the format of JSON values: compact (default) or pretty.
+	JSON string `yaml:"json" mapstructure:"json_format"`
+
+	// TimeZone is the IANA time zone of TIMESTAMP values, UTC by default.
+	TimeZone string `yaml:"time_zone" mapstructure:"time_zone"`
+
+	// MaxCellLength truncates rendered values longer than this number of characters. 0 means unlimited.
+	MaxCellLength int `yaml:"max_cell_length" mapstructure:"max_cell_length"`
+}
+
+func (o renderOptions) validate() error {
+	if o.Bytes != "" && !slices.Contains(bytesFormats, o.Bytes) {
+		return fmt.Errorf("bytes format must be one of %v", bytesFormats)
+	}
+	if o.JSON != "" && !slices.Contains(jsonFormats, o.JSON) {
+		return fmt.Errorf("json format must be one of %v", jsonFormats)
+	}
+	if _, err := time.LoadLocation(o.TimeZone); err != nil {
+		return err
+	}
+	if o.MaxCellLength < 0 {
+		return fmt.Errorf("max cell length must not be negative")
+	}
+	return nil
+}
+
+// override returns the options where non-zero fields of other take precedence.
+func (o renderOptions) override(other renderOptions) renderOptions {
+	if other.Null != "" {
+		o.Null = other.Null
+	}
+	if other.Bytes != "" {
+		o.Bytes = other.Bytes
+	}
+	if other.JSON != "" {
+		o.JSON = other.JSON
+	}
+	if other.TimeZone != "" {
+		o.TimeZone = other.TimeZone
+	}
+	if other.MaxCellLength != 0 {
+		o.MaxCellLength = other.MaxCellLength
+	}
+	return o
+}
+
+// withRenderArgs adds the arguments of renderOptions to the tool.
+func withRenderArgs() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		for _, opt := range []mcp.ToolOption{
+			mcp.WithString("null_token",
+				mcp.Description("Token to render NULL in the table (default: NULL)"),
+			),
+			mcp.WithString("bytes_format",
+				mcp.Enum(bytesFormats...),
+				mcp.Description("Format of BYTES in the table. length renders only the number of bytes (default: base64)"),
+			),
+			mcp.WithString("json_format",
+				mcp.Enum(jsonFormats...),
+				mcp.Description("Format of JSON in the table (default: compact)"),
+			),
+			mcp.WithString("time_zone",
+				mcp.Description("IANA time zone to render TIMESTAMP in the table, e.g. Asia/Tokyo (default: UTC)"),
+			),
+			mcp.WithNumber("max_cell_length",
+				mcp.Description("Truncate values in the table longer than this number of characters, 0 means unlimited"),
+			),
+		} {
+			opt(t)
+		}
+	}
+}
+
+// renderer renders values decoded by decodeValue as table cells.
+type renderer struct {
+	renderOptions
+	location *time.Location
+
+	// protoFormat is the format of values decoded by protoDecoder.
+	protoFormat string
+}
+
+func newRenderer(opts renderOptions, protoFormat string) (*renderer, error) {
+	if err := opts.validate(); err != nil {
+		return nil, &validationError{Message: err.Error()}
+	}
+	location, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Null == "" {
+		opts.Null = "NULL"
+	}
+	return &renderer{renderOptions: opts, location: location, protoFormat: protoFormat}, nil
+}
+
+func (r *renderer) render(typ *sppb.Type, v any) (string, error) {
+	if v == nil {
+		return r.Null, nil
+	}
+
+	v = r.convert(typ, v)
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case *protoValue:
+		if r.protoFormat == protoFormatText {
+			s = v.String()
+		} else {
+			b, err := v.MarshalJSON()
+			if err != nil {
+				return "", err
+			}
+			s = string(b)
+		}
+	case json.RawMessage:
+		s = string(v)
+		if r.JSON == "pretty" {
+			var b bytes.Buffer
+			if err := json.Indent(&b, v, "", "  "); err == nil {
+				s = b.String()
+			}
+		}
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		s = string(b)
+	}
+	return r.truncate(s), nil
+}
+
+// convert applies the options to the value and the elements of ARRAY and STRUCT.
+func (r *renderer) convert(typ *sppb.Type, v any) any {
+	switch v := v.(type) {
+	case []any:
+		result := make([]any, len(v))
+		for i, elem := range v {
+			result[i] = r.convert(typ.GetArrayElementType(), elem)
+		}
+		return result
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for _, field := range typ.GetStructType().GetFields() {
+			result[field.GetName()] = r.convert(field.GetType(), v[field.GetName()])
+		}
+		return result
+	case string:
+		switch typ.GetCode() {
+		case sppb.TypeCode_BYTES:
+			return r.convertBytes(v)
+		case sppb.TypeCode_TIMESTAMP:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t.In(r.location).Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return v
+}
+
+func (r *renderer) convertBytes(s string) string {
+	switch r.Bytes {
+	case "hex":
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return hex.EncodeToString(b)
+		}
+	case "length":
+		return fmt.Sprintf("(%d bytes)", base64.StdEncoding.DecodedLen(len(s))-paddingLength(s))
+	}
+	return s
+}
+
+func paddingLength(s string) int {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '='; i-- {
+		n++
+	}
+	return n
+}
+
+// truncate truncates s to MaxCellLength characters with a marker of the number of truncated characters.
+func (r *renderer) truncate(s string) string {
+	if r.MaxCellLength <= 0 || utf8.RuneCountInString(s) <= r.MaxCellLength {
+		return s
+	}
+	runes := []rune(s)
+	return fmt.Sprintf("%s…(%d more chars)", string(runes[:r.MaxCellLength]), len(runes)-r.MaxCellLength)
+}
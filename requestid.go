@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDHeader is the metadata header which carries the request ID of the tool call in outgoing RPCs.
+const requestIDHeader = "x-spanner-mcp-request-id"
+
+type requestIDKey struct{}
+
+// requestID returns the ID of the current tool call, or empty outside of tool calls.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware generates an ID of every tool call, which is logged, sent with the RPCs of the call and
+// returned in _meta of the result or in the error, so that the call can be correlated with backend logs.
+func requestIDMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := newRequestID()
+		result, err := next(context.WithValue(ctx, requestIDKey{}, id), request)
+		if err != nil {
+			return result, fmt.Errorf("%w (request ID: %s)", err, id)
+		}
+		if result != nil {
+			if result.Meta == nil {
+				result.Meta = &mcp.Meta{}
+			}
+			if result.Meta.AdditionalFields == nil {
+				result.Meta.AdditionalFields = make(map[string]any)
+			}
+			result.Meta.AdditionalFields["request_id"] = id
+		}
+		return result, err
+	}
+}
+
+func requestIDContext(ctx context.Context) context.Context {
+	if id := requestID(ctx); id != "" {
+		return metadata.AppendToOutgoingContext(ctx, requestIDHeader, id)
+	}
+	return ctx
+}
+
+func requestIDUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+	return invoker(requestIDContext(ctx), method, req, reply, cc, callOpts...)
+}
+
+func requestIDStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(requestIDContext(ctx), desc, cc, method, callOpts...)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+const (
+	ddlURITemplate      = "spanner://{project}/{instance}/{database}/ddl"
+	tableDDLURITemplate = "spanner://{project}/{instance}/{database}/ddl/{table}"
+	tableURITemplate    = "spanner://{project}/{instance}/{database}/tables/{table}"
+	queryStatsTemplate  = "spanner://{project}/{instance}/{database}/query-stats/{interval}"
+)
+
+// queryStatsTables maps intervals of query-stats resources to the tables of query statistics.
+var queryStatsTables = map[string]string{
+	"minute":   "SPANNER_SYS.QUERY_STATS_TOP_MINUTE",
+	"10minute": "SPANNER_SYS.QUERY_STATS_TOP_10MINUTE",
+	"hour":     "SPANNER_SYS.QUERY_STATS_TOP_HOUR",
+}
+
+// databaseURIRe matches spanner://{project}/{instance}/{database}/{rest}.
+var databaseURIRe = regexp.MustCompile(`^spanner://([^/]+)/([^/]+)/([^/]+)/(.+)$`)
+
+// parseDatabaseURI parses a resource URI and returns the target database and the rest of the path.
+func parseDatabaseURI(uri string) (*profile, string, error) {
+	m := databaseURIRe.FindStringSubmatch(uri)
+	if m == nil {
+		return nil, "", fmt.Errorf("invalid resource URI: %s", uri)
+	}
+	return &profile{Project: m[1], Instance: m[2], Database: m[3]}, m[4], nil
+}
+
+// resourceURI returns the URI of the resource of the database, e.g. resourceURI(t, "ddl").
+func resourceURI(t *profile, path string) string {
+	return fmt.Sprintf("spanner://%s/%s/%s/%s", t.Project, t.Instance, t.Database, path)
+}
+
+func registerResources(s *server.MCPServer) {
+	s.AddResourceTemplate(mcp.NewResourceTemplate(ddlURITemplate, "Database DDL",
+		mcp.WithTemplateDescription("All DDL statements of the database"),
+		mcp.WithTemplateMIMEType("application/sql"),
+	), ddlResourceHandler)
+
+	s.AddResourceTemplate(mcp.NewResourceTemplate(tableDDLURITemplate, "Table DDL",
+		mcp.WithTemplateDescription("DDL statements of the table and its indexes"),
+		mcp.WithTemplateMIMEType("application/sql"),
+	), ddlResourceHandler)
+
+	s.AddResourceTemplate(mcp.NewResourceTemplate(tableURITemplate, "Table",
+		mcp.WithTemplateDescription("Columns, primary key and indexes of the table from INFORMATION_SCHEMA"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), tableResourceHandler)
+
+	s.AddResourceTemplate(mcp.NewResourceTemplate(queryStatsTemplate, "Query statistics",
+		mcp.WithTemplateDescription("Top queries of the latest interval from SPANNER_SYS.QUERY_STATS_TOP_*. interval is one of minute, 10minute and hour"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), queryStatsResourceHandler)
+
+	// Databases of profiles are listed as concrete resources so clients can attach them without knowing the template.
+	for name, p := range cfg.Profiles {
+		if p.Project == "" || p.Instance == "" || p.Database == "" {
+			continue
+		}
+		s.AddResource(mcp.NewResource(resourceURI(p, "ddl"), fmt.Sprintf("DDL of %s", name),
+			mcp.WithResourceDescription(fmt.Sprintf("All DDL statements of %s (profile %s)", p.databasePath(), name)),
+			mcp.WithMIMEType("application/sql"),
+		), ddlResourceHandler)
+	}
+}
+
+// ddlResourceHandler handles both the database DDL and the table DDL resources.
+func ddlResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	target, rest, err := parseDatabaseURI(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := databaseStatements(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if table, ok := strings.CutPrefix(rest, "ddl/"); ok {
+		statements = tableStatements(statements, table)
+		if len(statements) == 0 {
+			return nil, fmt.Errorf("table %s is not found in %s", table, target.databasePath())
+		}
+	} else if rest != "ddl" {
+		return nil, fmt.Errorf("unknown resource: %s", request.Params.URI)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/sql",
+			Text:     formatStatements(statements),
+		},
+	}, nil
+}
+
+func tableResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	target, rest, err := parseDatabaseURI(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	table, ok := strings.CutPrefix(rest, "tables/")
+	if !ok || table == "" {
+		return nil, fmt.Errorf("unknown resource: %s", request.Params.URI)
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// The catalog queries INFORMATION_SCHEMA of both dialects, and tables in named schemas are referred as schema.table.
+	catalog, err := newSchemaCatalog(ctx, target, client)
+	if err != nil {
+		return nil, err
+	}
+	schema, name := catalog.splitTable(table)
+
+	tables, err := catalog.query(ctx, `SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, PARENT_TABLE_NAME, ON_DELETE_ACTION
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_SCHEMA = @p1 AND TABLE_NAME = @p2`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("table %s is not found in %s", table, target.databasePath())
+	}
+
+	columns, err := catalog.query(ctx, `SELECT c.COLUMN_NAME, c.SPANNER_TYPE, c.IS_NULLABLE, c.COLUMN_DEFAULT, c.GENERATION_EXPRESSION,
+  ic.ORDINAL_POSITION AS PRIMARY_KEY_POSITION, ic.COLUMN_ORDERING AS PRIMARY_KEY_ORDERING
+FROM INFORMATION_SCHEMA.COLUMNS AS c
+LEFT JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+  ON ic.TABLE_SCHEMA = c.TABLE_SCHEMA AND ic.TABLE_NAME = c.TABLE_NAME
+  AND ic.COLUMN_NAME = c.COLUMN_NAME AND ic.INDEX_TYPE = 'PRIMARY_KEY'
+WHERE c.TABLE_SCHEMA = @p1 AND c.TABLE_NAME = @p2
+ORDER BY c.ORDINAL_POSITION`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := catalog.query(ctx, `SELECT INDEX_NAME, INDEX_TYPE, IS_UNIQUE, IS_NULL_FILTERED, PARENT_TABLE_NAME, INDEX_STATE
+FROM INFORMATION_SCHEMA.INDEXES
+WHERE TABLE_SCHEMA = @p1 AND TABLE_NAME = @p2 AND INDEX_TYPE != 'PRIMARY_KEY'
+ORDER BY INDEX_NAME`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonResourceContents(request.Params.URI, map[string]any{
+		"table":   tables[0],
+		"columns": columns,
+		"indexes": indexes,
+	})
+}
+
+func queryStatsResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	target, rest, err := parseDatabaseURI(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, ok := strings.CutPrefix(rest, "query-stats/")
+	if !ok {
+		return nil, fmt.Errorf("unknown resource: %s", request.Params.URI)
+	}
+	statsTable, ok := queryStatsTables[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown interval: %q, must be one of minute, 10minute and hour", interval)
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rows, err := queryRows(ctx, client, spanner.NewStatement(fmt.Sprintf(`SELECT INTERVAL_END, TEXT, TEXT_TRUNCATED, TEXT_FINGERPRINT,
+  EXECUTION_COUNT, AVG_LATENCY_SECONDS, AVG_ROWS, AVG_BYTES, AVG_ROWS_SCANNED, AVG_CPU_SECONDS
+FROM %[1]s
+WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM %[1]s)
+ORDER BY EXECUTION_COUNT * AVG_CPU_SECONDS DESC`, statsTable)))
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonResourceContents(request.Params.URI, map[string]any{
+		"interval": interval,
+		"queries":  rows,
+	})
+}
+
+func jsonResourceContents(uri string, v any) ([]mcp.ResourceContents, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(b),
+		},
+	}, nil
+}
+
+func databaseStatements(ctx context.Context, target *profile) ([]string, error) {
+	resp, err := getDatabaseDDL(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetStatements(), nil
+}
+
+// getDatabaseDDL calls GetDatabaseDdl with retries.
+func getDatabaseDDL(ctx context.Context, target *profile) (*databasepb.GetDatabaseDdlResponse, error) {
+	client, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *databasepb.GetDatabaseDdlResponse
+	err = retry(ctx, func(ctx context.Context) error {
+		resp, err = client.GetDatabaseDdl(ctx, &databasepb.GetDatabaseDdlRequest{
+			Database: target.databasePath(),
+		})
+		return err
+	})
+	return resp, err
+}
+
+// tableStatementRe captures the table name of DDL statements which belong to a table.
+// Names are quoted by backticks in GoogleSQL and double quotes in PostgreSQL.
+var tableStatementRe = regexp.MustCompile(`(?is)^\s*(?:CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([` + "`" + `"\w.]+)` +
+	`|CREATE\s+(?:UNIQUE\s+)?(?:NULL_FILTERED\s+)?(?:SEARCH\s+|VECTOR\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?[` + "`" + `"\w.]+\s+ON\s+([` + "`" + `"\w.]+)` +
+	`|(?:ALTER|DROP)\s+TABLE\s+(?:IF\s+EXISTS\s+)?([` + "`" + `"\w.]+))`)
+
+// statementTable returns the name of the table which the statement creates, alters or drops, including its indexes.
+func statementTable(stmt string) (string, bool) {
+	m := tableStatementRe.FindStringSubmatch(stmt)
+	if m == nil {
+		return "", false
+	}
+	return strings.NewReplacer("`", "", `"`, "").Replace(m[1] + m[2] + m[3]), true
+}
+
+// tableStatements returns the statements which create or alter the table, including its indexes.
+func tableStatements(statements []string, table string) []string {
+	return lo.Filter(statements, func(stmt string, _ int) bool {
+		name, ok := statementTable(stmt)
+		return ok && strings.EqualFold(name, table)
+	})
+}
+
+func formatStatements(statements []string) string {
+	var b strings.Builder
+	for _, stmt := range statements {
+		b.WriteString(stmt)
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
+
+// notifyResourcesUpdated notifies clients that the resources of the database are updated by the statements.
+// mcp-go does not route resources/subscribe requests, so notifications are sent to all sessions instead of subscribers.
+func notifyResourcesUpdated(s *server.MCPServer, target *profile, statements []string) {
+	if s == nil {
+		return
+	}
+
+	uris := []string{resourceURI(target, "ddl")}
+	tables := lo.Uniq(lo.FilterMap(statements, func(stmt string, _ int) (string, bool) {
+		return statementTable(stmt)
+	}))
+	for _, table := range tables {
+		uris = append(uris, resourceURI(target, "ddl/"+table), resourceURI(target, "tables/"+table))
+	}
+
+	for _, uri := range uris {
+		slog.Debug("resource updated", "uri", uri)
+		s.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+	}
+}
+
+// watchSchemas polls DDL of profile databases and notifies clients of schema changes until ctx is done.
+func watchSchemas(ctx context.Context, s *server.MCPServer, interval time.Duration) {
+	targets := lo.UniqBy(lo.Filter(lo.Values(cfg.Profiles), func(p *profile, _ int) bool {
+		return p.Project != "" && p.Instance != "" && p.Database != ""
+	}), func(p *profile) string { return p.databasePath() })
+
+	last := make(map[string][]string)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, target := range targets {
+			statements, err := databaseStatements(ctx, target)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("failed to poll DDL", "database", target.databasePath(), "error", err)
+				continue
+			}
+
+			prev, ok := last[target.databasePath()]
+			last[target.databasePath()] = statements
+			if !ok {
+				continue
+			}
+
+			if removed, added := lo.Difference(prev, statements); len(removed) > 0 || len(added) > 0 {
+				slog.Info("schema changed", "database", target.databasePath())
+				notifyResourcesUpdated(s, target, append(removed, added...))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"google.golang.org/grpc/codes"
+)
+
+// retryOptions configures retries of transient errors. Zero values mean the defaults.
+type retryOptions struct {
+	// MaxAttempts is the maximum number of attempts including the first one. 1 disables retries.
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+func (o *retryOptions) maxAttempts() int {
+	if o.MaxAttempts > 0 {
+		return o.MaxAttempts
+	}
+	return 3
+}
+
+func (o *retryOptions) backoff() gax.Backoff {
+	b := gax.Backoff{Initial: 200 * time.Millisecond, Max: 5 * time.Second, Multiplier: 2}
+	if o.InitialBackoff > 0 {
+		b.Initial = o.InitialBackoff
+	}
+	if o.MaxBackoff > 0 {
+		b.Max = o.MaxBackoff
+	}
+	return b
+}
+
+func isRetryable(err error) bool {
+	switch spanner.ErrCode(err) {
+	case codes.Aborted, codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+type retryRecorderKey struct{}
+
+// retryRecorder records transient errors retried in a tool call. Calls may retry concurrently, e.g. partitions of a query.
+type retryRecorder struct {
+	mu      sync.Mutex
+	retries []retriedError
+}
+
+// retriedError is a transient error which is retried after the delay.
+type retriedError struct {
+	Attempt int    `json:"attempt"`
+	Code    string `json:"code"`
+	Delay   string `json:"delay"`
+	Error   string `json:"error"`
+}
+
+func (r *retryRecorder) record(attempt int, err error, delay time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries = append(r.retries, retriedError{Attempt: attempt, Code: spanner.ErrCode(err).String(), Delay: delay.String(), Error: spanner.ErrDesc(err)})
+}
+
+// retry calls fn until it succeeds, fails with a non-transient error or reaches the maximum attempts.
+// fn must be idempotent, e.g. a read-only query which discards partial results on each attempt.
+func retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	opts := &cfg.Retry
+	backoff := opts.backoff()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !isRetryable(err) || attempt >= opts.maxAttempts() {
+			return err
+		}
+
+		delay, ok := spanner.ExtractRetryDelay(err)
+		if !ok {
+			delay = backoff.Pause()
+		}
+		slog.DebugContext(ctx, "retrying transient error", "attempt", attempt, "delay", delay, "error", err)
+		if r, ok := ctx.Value(retryRecorderKey{}).(*retryRecorder); ok {
+			r.record(attempt, err, delay)
+		}
+
+		if err := gax.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// renderRetries renders the retried errors following the result of a tool.
+func renderRetries(retries []retriedError) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nRetried %d transient errors:\n", len(retries))
+	for i, r := range retries {
+		fmt.Fprintf(&b, "%d. %s on attempt %d, retried after %s: %s\n", i+1, r.Code, r.Attempt, r.Delay, r.Error)
+	}
+	return b.String()
+}
+
+// retryMiddleware reports the transient errors retried in the tool call in the retries section of the result:
+// _meta.retries and the last text content, or the error if the call failed after retries.
+func retryMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		recorder := &retryRecorder{}
+		result, err := next(context.WithValue(ctx, retryRecorderKey{}, recorder), request)
+
+		recorder.mu.Lock()
+		retries := slices.Clone(recorder.retries)
+		recorder.mu.Unlock()
+		if len(retries) == 0 {
+			return result, err
+		}
+		slog.InfoContext(ctx, "tool call retried transient errors", "tool", request.Params.Name, "retries", len(retries))
+		report := renderRetries(retries)
+		if err != nil {
+			return result, fmt.Errorf("%w%s", err, strings.TrimRight(report, "\n"))
+		}
+		if result != nil {
+			if result.Meta == nil {
+				result.Meta = &mcp.Meta{}
+			}
+			if result.Meta.AdditionalFields == nil {
+				result.Meta.AdditionalFields = make(map[string]any)
+			}
+			result.Meta.AdditionalFields["retries"] = retries
+			appendText(result, report)
+		}
+		return result, err
+	}
+}
+
+// appendText appends the text to the last text content of the result, or as a new text content if it has none,
+// so the human-readable content stays last.
+func appendText(result *mcp.CallToolResult, text string) {
+	for i := len(result.Content) - 1; i >= 0; i-- {
+		if c, ok := result.Content[i].(mcp.TextContent); ok {
+			c.Text += text
+			result.Content[i] = c
+			return
+		}
+	}
+	result.Content = append(result.Content, mcp.NewTextContent(strings.TrimPrefix(text, "\n")))
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// Thresholds of interleave trees in lint_schema. Spanner allows interleaving up to 7 levels.
+const (
+	maxLintInterleaveChildren = 10
+	maxLintInterleaveDepth    = 5
+)
+
+// logTableWords are the last words of names of tables which grow with time, like logs and events.
+var logTableWords = []string{"log", "logs", "event", "events", "history", "histories", "audit", "audits", "trace", "traces",
+	"metric", "metrics", "activity", "activities", "notification", "notifications", "journal", "journals"}
+
+// schemaLintTable is a table with its columns for lint_schema.
+type schemaLintTable struct {
+	name      string
+	parent    string
+	ttl       string
+	columns   []schemaLintColumn
+	primary   *catalogIndexKeys
+	indexes   []*catalogIndexKeys
+	depth     int
+	children  []string
+	timestamp string
+}
+
+type schemaLintColumn struct {
+	name       string
+	typ        string
+	expression string
+}
+
+func (t *schemaLintTable) column(name string) schemaLintColumn {
+	c, _ := lo.Find(t.columns, func(c schemaLintColumn) bool { return c.name == strings.TrimSuffix(name, " DESC") })
+	return c
+}
+
+// schemaLintTables returns the base tables with their columns, keys and indexes.
+func (c *schemaCatalog) schemaLintTables(ctx context.Context) ([]*schemaLintTable, error) {
+	tableRows, err := c.query(ctx, `SELECT TABLE_SCHEMA, TABLE_NAME, PARENT_TABLE_NAME, ROW_DELETION_POLICY_EXPRESSION
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN `+catalogSystemSchemas+`
+ORDER BY TABLE_SCHEMA, TABLE_NAME`)
+	if err != nil {
+		return nil, err
+	}
+	columnRows, err := c.query(ctx, `SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, SPANNER_TYPE, COLUMN_DEFAULT, GENERATION_EXPRESSION
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA NOT IN `+catalogSystemSchemas+`
+ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := c.indexKeys(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]*schemaLintTable)
+	var out []*schemaLintTable
+	for _, row := range tableRows {
+		schema := catalogString(row, "TABLE_SCHEMA")
+		t := &schemaLintTable{
+			name: c.qualifiedName(schema, catalogString(row, "TABLE_NAME")),
+			ttl:  catalogString(row, "ROW_DELETION_POLICY_EXPRESSION"),
+		}
+		if parent := catalogString(row, "PARENT_TABLE_NAME"); parent != "" {
+			t.parent = c.qualifiedName(schema, parent)
+		}
+		tables[t.name] = t
+		out = append(out, t)
+	}
+	for _, row := range columnRows {
+		t, ok := tables[c.qualifiedName(catalogString(row, "TABLE_SCHEMA"), catalogString(row, "TABLE_NAME"))]
+		if !ok {
+			continue
+		}
+		t.columns = append(t.columns, schemaLintColumn{
+			name:       catalogString(row, "COLUMN_NAME"),
+			typ:        catalogString(row, "SPANNER_TYPE"),
+			expression: catalogString(row, "COLUMN_DEFAULT") + catalogString(row, "GENERATION_EXPRESSION"),
+		})
+	}
+	for _, i := range indexes {
+		t, ok := tables[i.table]
+		if !ok {
+			continue
+		}
+		if i.primaryKey {
+			t.primary = i
+		} else {
+			t.indexes = append(t.indexes, i)
+		}
+	}
+	for _, t := range out {
+		if p, ok := tables[t.parent]; ok {
+			p.children = append(p.children, t.name)
+		}
+		for p := tables[t.parent]; p != nil && t.depth < len(out); p = tables[p.parent] {
+			t.depth++
+		}
+		if column, ok := lo.Find(t.columns, func(c schemaLintColumn) bool { return isTimeType(c.typ) }); ok {
+			t.timestamp = column.name
+		}
+	}
+	return out, nil
+}
+
+// isTimeType reports whether the type of either dialect is a point in time.
+func isTimeType(typ string) bool {
+	typ = strings.ToUpper(typ)
+	return strings.HasPrefix(typ, "TIMESTAMP") || typ == "DATE" || typ == "SPANNER.COMMIT_TIMESTAMP"
+}
+
+// isUnboundedKeyType reports whether the type of either dialect is STRING or BYTES without a length.
+func isUnboundedKeyType(typ string) bool {
+	return lo.Contains([]string{"STRING(MAX)", "BYTES(MAX)", "CHARACTER VARYING", "BYTEA", "TEXT"}, strings.ToUpper(typ))
+}
+
+// isSequenceExpression reports whether the default of the column generates values by a sequence or an identity column,
+// which are bit-reversed in Spanner.
+func isSequenceExpression(expression string) bool {
+	expression = strings.ToUpper(expression)
+	return strings.Contains(expression, "GET_NEXT_SEQUENCE_VALUE") || strings.Contains(expression, "NEXTVAL") || strings.Contains(expression, "IDENTITY")
+}
+
+// nameWords splits the name in snake_case, camelCase or PascalCase into words.
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			if len(word) > 0 {
+				words, word = append(words, string(word)), nil
+			}
+			continue
+		case unicode.IsUpper(r) && len(word) > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])):
+			words, word = append(words, string(word)), nil
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// namingStyle returns snake_case, camelCase, PascalCase or UPPER_CASE of the name.
+func namingStyle(name string) string {
+	hasLower := strings.ContainsFunc(name, unicode.IsLower)
+	hasUpper := strings.ContainsFunc(name, unicode.IsUpper)
+	first, _ := lo.First([]rune(name))
+	switch {
+	case !hasLower:
+		return "UPPER_CASE"
+	case !hasUpper:
+		return "snake_case"
+	case strings.Contains(name, "_"):
+		return "mixed"
+	case unicode.IsUpper(first):
+		return "PascalCase"
+	default:
+		return "camelCase"
+	}
+}
+
+func lintMonotonicKeys(tables []*schemaLintTable) []schemaLintFinding {
+	var findings []schemaLintFinding
+	for _, t := range tables {
+		// Rows of interleaved tables are distributed by the keys of their root tables.
+		if t.parent == "" && t.primary != nil && len(t.primary.keys) > 0 {
+			key := t.column(t.primary.keys[0])
+			f := schemaLintFinding{Rule: "monotonic-key", Table: t.name}
+			switch {
+			case isTimeType(key.typ):
+				f.Severity = "warning"
+				f.Message = fmt.Sprintf("The first key column %s of %s is %s, so inserts of recent values concentrate on a single split (hotspot)", key.name, t.name, key.typ)
+				f.Suggestion = "Put a UUID or a hash-based shard column before the timestamp in the primary key"
+			case slices.ContainsFunc(monotonicFunctions, func(fn string) bool { return strings.Contains(strings.ToUpper(key.expression), fn) }):
+				f.Severity = "warning"
+				f.Message = fmt.Sprintf("The first key column %s of %s is generated by %s, which increases monotonically and causes a hotspot", key.name, t.name, key.expression)
+				f.Suggestion = "Put a UUID or a hash-based shard column before the column in the primary key"
+			case strings.EqualFold(key.typ, "INT64") || strings.EqualFold(key.typ, "bigint"):
+				if isSequenceExpression(key.expression) {
+					break
+				}
+				words := nameWords(key.name)
+				if last, _ := lo.Last(words); !lo.Contains([]string{"id", "seq", "sequence", "no", "number", "num"}, strings.ToLower(last)) {
+					break
+				}
+				f.Severity = "info"
+				f.Message = fmt.Sprintf("The first key column %s of %s is an integer ID without a sequence, which causes a hotspot if the application assigns sequential values", key.name, t.name)
+				f.Suggestion = "Generate the IDs by a bit-reversed sequence or an IDENTITY column, or use UUIDs"
+			}
+			if f.Severity != "" {
+				findings = append(findings, f)
+			}
+		}
+
+		for _, i := range t.indexes {
+			if key := t.column(i.keys[0]); isTimeType(key.typ) {
+				findings = append(findings, schemaLintFinding{
+					Rule:       "monotonic-key",
+					Severity:   "warning",
+					Table:      t.name,
+					Message:    fmt.Sprintf("The first key column %s of the index %s is %s, so writes of recent values concentrate on a single split of the index (hotspot)", key.name, i.name, key.typ),
+					Suggestion: "Put a shard column before the timestamp in the index, e.g. a generated column of MOD(FARM_FINGERPRINT(...), N), or a NULL_FILTERED index of recent rows only",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func lintInterleaveTrees(tables []*schemaLintTable) []schemaLintFinding {
+	var findings []schemaLintFinding
+	for _, t := range tables {
+		if len(t.children) > maxLintInterleaveChildren {
+			findings = append(findings, schemaLintFinding{
+				Rule:     "wide-interleave-tree",
+				Severity: "warning",
+				Table:    t.name,
+				Message: fmt.Sprintf("%d tables are interleaved in %s, e.g. %s, so a row of %s and its children can grow too large to be split",
+					len(t.children), t.name, strings.Join(lo.Slice(t.children, 0, maxLintExamples), ", "), t.name),
+				Suggestion: "Interleave only tables which are read or written with their parent rows, and make other tables top-level tables with foreign keys",
+			})
+		}
+		if t.depth+1 >= maxLintInterleaveDepth {
+			findings = append(findings, schemaLintFinding{
+				Rule:       "deep-interleave-tree",
+				Severity:   "info",
+				Table:      t.name,
+				Message:    fmt.Sprintf("%s is at level %d of an interleave tree, and Spanner allows 7 levels", t.name, t.depth+1),
+				Suggestion: "Keep the tree shallow so the primary keys of deep tables don't grow with all keys of their ancestors",
+			})
+		}
+	}
+	return findings
+}
+
+func lintMissingTTLs(tables []*schemaLintTable, postgreSQL bool) []schemaLintFinding {
+	var findings []schemaLintFinding
+	for _, t := range tables {
+		last, _ := lo.Last(nameWords(t.name[strings.LastIndex(t.name, ".")+1:]))
+		if t.ttl != "" || t.timestamp == "" || !lo.Contains(logTableWords, strings.ToLower(last)) {
+			continue
+		}
+		suggestion := fmt.Sprintf("ALTER TABLE %s ADD ROW DELETION POLICY (OLDER_THAN(%s, INTERVAL 30 DAY))", t.name, t.timestamp)
+		if postgreSQL {
+			suggestion = fmt.Sprintf("ALTER TABLE %s ADD TTL INTERVAL '30 days' ON %s", t.name, t.timestamp)
+		}
+		findings = append(findings, schemaLintFinding{
+			Rule:       "missing-ttl",
+			Severity:   "info",
+			Table:      t.name,
+			Message:    fmt.Sprintf("%s looks like a table which grows with time and has the timestamp column %s, but has no row deletion policy", t.name, t.timestamp),
+			Suggestion: suggestion + ", with the retention period of the data",
+		})
+	}
+	return findings
+}
+
+func lintUnboundedKeys(tables []*schemaLintTable) []schemaLintFinding {
+	var findings []schemaLintFinding
+	for _, t := range tables {
+		for _, i := range append(lo.Compact([]*catalogIndexKeys{t.primary}), t.indexes...) {
+			for _, k := range i.keys {
+				c := t.column(k)
+				if !isUnboundedKeyType(c.typ) {
+					continue
+				}
+				findings = append(findings, schemaLintFinding{
+					Rule:       "unbounded-key-column",
+					Severity:   "warning",
+					Table:      t.name,
+					Message:    fmt.Sprintf("The key column %s of %s is %s, and keys are limited to 8 KiB in total including all key columns", c.name, i.label(), c.typ),
+					Suggestion: fmt.Sprintf("Bound the length of %s by the values stored, e.g. STRING(36) for UUIDs, and enforce it in the application", c.name),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// lintNaming finds names of tables and columns in other styles than most names of the schema.
+func lintNaming(tables []*schemaLintTable) []schemaLintFinding {
+	var findings []schemaLintFinding
+	for _, kind := range []struct {
+		name  string
+		names []string
+	}{
+		{"Tables", lo.Map(tables, func(t *schemaLintTable, _ int) string { return t.name[strings.LastIndex(t.name, ".")+1:] })},
+		{"Columns", lo.Uniq(lo.FlatMap(tables, func(t *schemaLintTable, _ int) []string {
+			return lo.Map(t.columns, func(c schemaLintColumn, _ int) string { return c.name })
+		}))},
+	} {
+		// Single words are the same in all styles but upper case.
+		styled := lo.Filter(kind.names, func(name string, _ int) bool { return len(nameWords(name)) > 1 })
+		styles := lo.GroupBy(styled, namingStyle)
+		if len(styles) < 2 {
+			continue
+		}
+		dominant := lo.MaxBy(lo.Keys(styles), func(a, b string) bool {
+			return len(styles[a]) > len(styles[b]) || len(styles[a]) == len(styles[b]) && a < b
+		})
+		others := lo.Flatten(lo.Values(lo.OmitByKeys(styles, []string{dominant})))
+		slices.Sort(others)
+		findings = append(findings, schemaLintFinding{
+			Rule:     "naming-convention",
+			Severity: "info",
+			Message: fmt.Sprintf("%s are mostly named in %s, but %d of %d are in other styles, e.g. %s", kind.name, dominant, len(others), len(styled),
+				strings.Join(lo.Slice(others, 0, maxLintExamples), ", ")),
+			Suggestion: fmt.Sprintf("Name new %s in %s, and rename others when they are migrated", strings.ToLower(kind.name), dominant),
+		})
+	}
+	return findings
+}
+
+func lintSchemaHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	catalog, err := newSchemaCatalog(ctx, target, client)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := catalog.schemaLintTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := slices.Concat(lintMonotonicKeys(tables), lintInterleaveTrees(tables), lintMissingTTLs(tables, catalog.postgreSQL),
+		lintUnboundedKeys(tables), lintNaming(tables))
+	slices.SortStableFunc(findings, func(a, b schemaLintFinding) int {
+		return cmp.Compare(slices.Index(lintSeverities, a.Severity), slices.Index(lintSeverities, b.Severity))
+	})
+
+	out := lintSchemaOutput{Dialect: catalog.dialect(), Tables: len(tables), Findings: findings}
+	if len(findings) == 0 {
+		out.Findings = []schemaLintFinding{}
+		return mcp.NewToolResultStructured(out, fmt.Sprintf("No findings in %d tables\n", len(tables))), nil
+	}
+	var b strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n  Suggestion: %s\n", f.Severity, f.Rule, f.Message, f.Suggestion)
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
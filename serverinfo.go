@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// reportedModules are the modules whose versions are reported by server_info.
+var reportedModules = []string{
+	"cloud.google.com/go/spanner",
+	"github.com/mark3labs/mcp-go",
+	"google.golang.org/grpc",
+}
+
+func serverInfoHandler(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	out := serverInfoOutput{
+		Version:        version,
+		GoVersion:      runtime.Version(),
+		DefaultProfile: cfg.DefaultProfile,
+		UserAgent:      cfg.Client.userAgent(),
+		Modules:        make(map[string]string),
+	}
+
+	if s := server.ServerFromContext(ctx); s != nil {
+		out.Tools = lo.Keys(s.ListTools())
+		slices.Sort(out.Tools)
+	}
+
+	if args, ok := sessionDatabase(ctx); ok {
+		if t, err := args.target(ctx); err == nil {
+			out.SessionDatabase = t.databasePath()
+		}
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if slices.Contains(reportedModules, dep.Path) {
+				out.Modules[dep.Path] = dep.Version
+			}
+		}
+	}
+
+	principal, err := cfg.Client.principal(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to resolve principal", "error", err)
+		out.PrincipalError = err.Error()
+	}
+	out.Principal = principal
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultStructured(out, string(b)), nil
+}
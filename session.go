@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// sessionDatabases holds the default database set by use_database, keyed by MCP session ID.
+var sessionDatabases sync.Map
+
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// sessionDatabase returns the default database of the current session set by use_database.
+func sessionDatabase(ctx context.Context) (databaseArgs, bool) {
+	id := sessionID(ctx)
+	if id == "" {
+		return databaseArgs{}, false
+	}
+
+	v, ok := sessionDatabases.Load(id)
+	if !ok {
+		return databaseArgs{}, false
+	}
+	return v.(databaseArgs), true
+}
+
+func forgetSessionDatabase(_ context.Context, session server.ClientSession) {
+	sessionDatabases.Delete(session.SessionID())
+}
+
+func useDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs `mapstructure:",squash"`
+		Clear        bool `mapstructure:"clear"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	id := sessionID(ctx)
+	if id == "" {
+		return nil, fmt.Errorf("use_database requires a stateful MCP session")
+	}
+
+	if req.Clear {
+		sessionDatabases.Delete(id)
+		return mcp.NewToolResultStructured(useDatabaseOutput{}, "Cleared the default database of this session"), nil
+	}
+
+	if req.databaseArgs == (databaseArgs{}) {
+		args, ok := sessionDatabase(ctx)
+		if !ok {
+			return mcp.NewToolResultStructured(useDatabaseOutput{}, "No default database is set in this session"), nil
+		}
+		target, err := args.target(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return useDatabaseResult(target), nil
+	}
+
+	// Resolve the arguments eagerly to report errors now rather than on subsequent tool calls.
+	target, err := req.databaseArgs.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionDatabases.Store(id, req.databaseArgs)
+	return useDatabaseResult(target), nil
+}
+
+func useDatabaseResult(target *profile) *mcp.CallToolResult {
+	return mcp.NewToolResultStructured(useDatabaseOutput{Database: target.databasePath()}, fmt.Sprintf("Using %s", target.databasePath()))
+}
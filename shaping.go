@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// bytesPerToken is a rough estimate to convert a token budget into bytes.
+const bytesPerToken = 4
+
+// outputOptions configures outputs of tools.
+type outputOptions struct {
+	// MaxBytes limits the size of text contents and structured content of each tool result. 0 means unlimited.
+	MaxBytes int `yaml:"max_bytes"`
+
+	// MaxTokens is an alternative to MaxBytes estimated as bytesPerToken bytes per token.
+	MaxTokens int `yaml:"max_tokens"`
+
+	// ProtoFormat is the default format of proto messages in text contents. See protoFormats.
+	ProtoFormat string `yaml:"proto_format"`
+
+	// TableStyle is the style of tables: box (default) or plain, which has no borders for plain-text clients.
+	TableStyle string `yaml:"table_style"`
+
+	// EastAsianWidth renders characters of ambiguous width as wide for clients with CJK fonts.
+	EastAsianWidth bool `yaml:"east_asian_width"`
+
+	// Render configures rendering of query results. Tools can override it by arguments.
+	Render renderOptions `yaml:"render"`
+}
+
+func (o *outputOptions) maxBytes() int {
+	switch {
+	case o.MaxBytes > 0 && o.MaxTokens > 0:
+		return min(o.MaxBytes, o.MaxTokens*bytesPerToken)
+	case o.MaxTokens > 0:
+		return o.MaxTokens * bytesPerToken
+	default:
+		return o.MaxBytes
+	}
+}
+
+// shapingMiddleware truncates results which exceed the output budget so they fit in the context window of the model.
+func shapingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if limit := cfg.Output.maxBytes(); err == nil && result != nil && limit > 0 {
+			shapeResult(result, limit)
+		}
+		return result, err
+	}
+}
+
+// shapeResult truncates text contents to share the limit, and structured content to the limit.
+func shapeResult(result *mcp.CallToolResult, limit int) {
+	truncated := false
+
+	remaining := limit
+	for i, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		if s, ok := truncateText(text.Text, max(remaining, 0)); ok {
+			text.Text = s
+			result.Content[i] = text
+			truncated = true
+		}
+		remaining -= len(text.Text)
+	}
+
+	if result.StructuredContent != nil {
+		if v, ok, err := truncateStructured(result.StructuredContent, limit); err == nil && ok {
+			result.StructuredContent = v
+			truncated = true
+		}
+	}
+
+	if truncated {
+		if result.Meta == nil {
+			result.Meta = &mcp.Meta{}
+		}
+		if result.Meta.AdditionalFields == nil {
+			result.Meta.AdditionalFields = make(map[string]any)
+		}
+		result.Meta.AdditionalFields["truncated"] = true
+	}
+}
+
+// truncateText keeps leading lines, e.g. headers of tables, within the limit and appends a summary of omitted lines.
+func truncateText(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	summary := func(kept int) string {
+		return fmt.Sprintf("... truncated %d of %d lines (%d bytes) to fit the output budget\n", len(lines)-kept, len(lines), len(s))
+	}
+	// The summary is the longest when no lines are kept, so its length is reserved.
+	reserve := len(summary(0))
+	if limit <= reserve {
+		// The summary is ASCII, so it is cut at any byte.
+		return summary(0)[:max(limit, 0)], true
+	}
+
+	var b strings.Builder
+	kept := 0
+	for _, line := range lines {
+		// Reserve room for the summary line.
+		if b.Len()+len(line) > limit-reserve {
+			break
+		}
+		b.WriteString(line)
+		kept++
+	}
+	if kept == 0 {
+		// A single long line, e.g. JSON, is cut in the middle at a boundary of UTF-8 characters, leaving room for the newline.
+		end := limit - reserve - 1
+		for end > 0 && !utf8.RuneStart(s[end]) {
+			end--
+		}
+		b.WriteString(s[:end])
+		b.WriteString("\n")
+	}
+	b.WriteString(summary(kept))
+	return b.String(), true
+}
+
+// truncateStructured halves the largest top-level array of the structured content until it fits in the limit.
+// Other fields are kept as is, so the result still conforms to the output schema.
+func truncateStructured(v any, limit int) (any, bool, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(b) <= limit {
+		return v, false, err
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return v, false, err
+	}
+
+	for size := len(b); size > limit; {
+		key, largest := "", 0
+		for k, field := range m {
+			arr, ok := field.([]any)
+			if !ok || len(arr) == 0 {
+				continue
+			}
+			fb, err := json.Marshal(arr)
+			if err != nil {
+				return v, false, err
+			}
+			if len(fb) > largest {
+				key, largest = k, len(fb)
+			}
+		}
+		if key == "" {
+			break
+		}
+
+		arr := m[key].([]any)
+		m[key] = arr[:len(arr)/2]
+
+		b, err := json.Marshal(m)
+		if err != nil {
+			return v, false, err
+		}
+		size = len(b)
+	}
+	return m, true, nil
+}
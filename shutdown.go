@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// callTracker tracks in-flight tool calls to drain them on shutdown.
+type callTracker struct {
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+
+	// abort cancels in-flight tool calls which don't finish until the deadline of drain.
+	abortCtx context.Context
+	abort    context.CancelFunc
+}
+
+var calls = newCallTracker()
+
+func newCallTracker() *callTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &callTracker{abortCtx: ctx, abort: cancel}
+}
+
+// middleware rejects tool calls after drain is started and tracks the others.
+func (t *callTracker) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t.mu.Lock()
+		if t.draining {
+			t.mu.Unlock()
+			return nil, errShuttingDown
+		}
+		t.wg.Add(1)
+		t.mu.Unlock()
+		defer t.wg.Done()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(t.abortCtx, cancel)
+		defer stop()
+
+		return next(ctx, request)
+	}
+}
+
+// drain stops accepting new tool calls and waits for in-flight tool calls until the timeout.
+// Tool calls which are still running after the timeout are cancelled.
+func (t *callTracker) drain(timeout time.Duration) {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("drained in-flight tool calls")
+		return
+	case <-time.After(timeout):
+	}
+
+	slog.Warn("cancelling in-flight tool calls", "timeout", timeout)
+	t.abort()
+
+	// Give cancelled calls a moment to clean up, e.g. cancel long-running operations.
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		slog.Warn("in-flight tool calls didn't return after cancellation")
+	}
+}
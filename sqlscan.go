@@ -0,0 +1,185 @@
+package main
+
+import "strings"
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlString
+	sqlNumber
+	sqlParam
+	sqlSymbol
+)
+
+// sqlToken is a token of GoogleSQL or PostgreSQL. Comments are skipped.
+type sqlToken struct {
+	kind       sqlTokenKind
+	text       string
+	start, end int
+
+	// depth is the depth of parentheses, brackets and braces, where opening and closing ones have the outer depth.
+	depth int
+}
+
+// twoCharSymbols are operators of two characters.
+var twoCharSymbols = []string{"<=", ">=", "<>", "!=", "||", "<<", ">>", "=>"}
+
+// scanSQL splits the statement into tokens. It is lenient and never fails, so unterminated literals extend to the end.
+func scanSQL(s string) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		token := sqlToken{start: i, depth: depth}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(s[i:], "--") || c == '#':
+			if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(s)
+			}
+			continue
+		case strings.HasPrefix(s[i:], "/*"):
+			if j := strings.Index(s[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(s)
+			}
+			continue
+		case c == '\'' || c == '"':
+			token.kind, i = sqlString, skipQuoted(s, i)
+		case c == '`':
+			token.kind, i = sqlWord, skipQuoted(s, i)
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (isWordByte(s[j]) || s[j] == '.') {
+				j++
+			}
+			token.kind, i = sqlNumber, j
+		case (c == '@' || c == '$') && i+1 < len(s) && (isWordByte(s[i+1]) || s[i+1] == '@'):
+			j := i + 1
+			for j < len(s) && (isWordByte(s[j]) || s[j] == '@') {
+				j++
+			}
+			token.kind, i = sqlParam, j
+		case isWordByte(c):
+			j := i
+			for j < len(s) && isWordByte(s[j]) {
+				j++
+			}
+			token.kind, i = sqlWord, j
+		default:
+			token.kind, i = sqlSymbol, i+1
+			for _, sym := range twoCharSymbols {
+				if strings.HasPrefix(s[token.start:], sym) {
+					i = token.start + len(sym)
+					break
+				}
+			}
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+				token.depth = depth
+			}
+		}
+		token.end = i
+		token.text = s[token.start:token.end]
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// topLevelWords returns keywords and identifiers outside of parentheses, literals, quoted identifiers and comments.
+func topLevelWords(s string) []sqlToken {
+	return filterTopLevelWords(scanSQL(s))
+}
+
+// statementWords returns topLevelWords after the leading statement hint, or nil if the hint is not closed.
+func statementWords(s string) []sqlToken {
+	tokens, ok := skipStatementHint(scanSQL(s))
+	if !ok {
+		return nil
+	}
+	return filterTopLevelWords(tokens)
+}
+
+func filterTopLevelWords(tokens []sqlToken) []sqlToken {
+	var words []sqlToken
+	for _, t := range tokens {
+		if t.kind == sqlWord && t.depth == 0 && !strings.HasPrefix(t.text, "`") {
+			words = append(words, t)
+		}
+	}
+	return words
+}
+
+// skipStatementHint returns the tokens after the leading statement hint @{...} of GoogleSQL.
+// It returns false if the hint is not closed.
+func skipStatementHint(tokens []sqlToken) ([]sqlToken, bool) {
+	if len(tokens) < 2 || tokens[0].text != "@" || tokens[1].text != "{" {
+		return tokens, true
+	}
+	for i, t := range tokens {
+		if t.kind == sqlSymbol && t.text == "}" && t.depth == 0 {
+			return tokens[i+1:], true
+		}
+	}
+	return nil, false
+}
+
+// splitStatements splits a script into statements separated by semicolons outside of parentheses, literals and comments.
+// Comments before and after statements are removed.
+func splitStatements(s string) []string {
+	var statements []string
+	start, end := -1, -1
+	for _, t := range scanSQL(s) {
+		if t.kind == sqlSymbol && t.text == ";" && t.depth == 0 {
+			if start >= 0 {
+				statements = append(statements, s[start:end])
+			}
+			start = -1
+			continue
+		}
+		if start < 0 {
+			start = t.start
+		}
+		end = t.end
+	}
+	if start >= 0 {
+		statements = append(statements, s[start:end])
+	}
+	return statements
+}
+
+// skipQuoted returns the index after the quoted literal or identifier starting at i, including triple-quoted strings.
+func skipQuoted(s string, i int) int {
+	quote := s[i : i+1]
+	if strings.HasPrefix(s[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	for j := i + len(quote); j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if strings.HasPrefix(s[j:], quote) {
+			return j + len(quote)
+		}
+	}
+	return len(s)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// isKeyword reports whether the token is the word case-insensitively.
+func (t sqlToken) isKeyword(word string) bool {
+	return t.kind == sqlWord && strings.EqualFold(t.text, word)
+}
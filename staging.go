@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxStagedRows is the number of rows and keys which can be staged in a session.
+const maxStagedRows = maxImportBatchSize
+
+var stageOperations = []string{"insert", "update", "insert_or_update", "replace", "delete"}
+
+// stageMutations are the mutations of the write operations of stage_mutation.
+var stageMutations = map[string]func(table string, columns []string, values []any) *spanner.Mutation{
+	"insert":           spanner.Insert,
+	"update":           spanner.Update,
+	"insert_or_update": spanner.InsertOrUpdate,
+	"replace":          spanner.Replace,
+}
+
+// stagedChangeSets holds the mutations staged by stage_mutation, keyed by MCP session ID.
+var stagedChangeSets sync.Map
+
+// stagedChangeSet is the mutations staged in a session, which are committed to a database in a transaction.
+type stagedChangeSet struct {
+	mu      sync.Mutex
+	target  *profile
+	entries []stagedEntry
+}
+
+// stagedEntry is a stage_mutation call with a mutation per row or key.
+type stagedEntry struct {
+	view      stagedMutation
+	mutations []*spanner.Mutation
+}
+
+func forgetStagedChangeSet(_ context.Context, session server.ClientSession) {
+	stagedChangeSets.Delete(session.SessionID())
+}
+
+// stagedChanges returns the change set of the session, or an error if the session is stateless.
+func stagedChanges(ctx context.Context) (*stagedChangeSet, error) {
+	id := sessionID(ctx)
+	if id == "" {
+		return nil, fmt.Errorf("staged mutations require a stateful MCP session")
+	}
+	v, _ := stagedChangeSets.LoadOrStore(id, &stagedChangeSet{})
+	return v.(*stagedChangeSet), nil
+}
+
+func stageMutationHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string           `mapstructure:"table"`
+		Operation string           `mapstructure:"operation"`
+		Rows      []map[string]any `mapstructure:"rows"`
+		Keys      [][]any          `mapstructure:"keys"`
+
+		FillCommitTimestamps bool `mapstructure:"fill_commit_timestamps"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := quoteTableName(req.Table); err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+	switch {
+	case !lo.Contains(stageOperations, req.Operation):
+		return nil, &validationError{Field: "operation", Message: fmt.Sprintf("must be one of %v", stageOperations)}
+	case req.Operation == "delete" && (len(req.Keys) == 0 || len(req.Rows) > 0):
+		return nil, &validationError{Field: "keys", Message: "delete requires keys and doesn't take rows"}
+	case req.Operation != "delete" && (len(req.Rows) == 0 || len(req.Keys) > 0):
+		return nil, &validationError{Field: "rows", Message: fmt.Sprintf("%s requires rows and doesn't take keys", req.Operation)}
+	case req.Operation == "delete" && req.FillCommitTimestamps:
+		return nil, &validationError{Field: "fill_commit_timestamps", Message: "delete doesn't write columns"}
+	}
+
+	changes, err := stagedChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+	if changes.target != nil && changes.target.databasePath() != target.databasePath() {
+		return nil, &validationError{Field: "database", Message: fmt.Sprintf("mutations for %s are staged, commit or discard them before staging mutations for another database", changes.target.databasePath())}
+	}
+	if staged := changes.rows(); staged+len(req.Rows)+len(req.Keys) > maxStagedRows {
+		return nil, &validationError{Field: "rows", Message: fmt.Sprintf("at most %d rows and keys can be staged, and %d are already staged", maxStagedRows, staged)}
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if req.Operation != "delete" {
+		if req.Rows, err = commitTimestampRows(ctx, client, req.Table, req.Rows, req.FillCommitTimestamps); err != nil {
+			return nil, err
+		}
+	}
+	entry := stagedEntry{view: stagedMutation{Operation: req.Operation, Table: req.Table, Rows: req.Rows, Keys: req.Keys}}
+	if req.Operation == "delete" {
+		entry.mutations, err = deleteMutations(ctx, client, req.Table, req.Keys)
+	} else {
+		entry.mutations, err = writeMutations(ctx, client, stageMutations[req.Operation], req.Table, req.Rows)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes.target = target
+	changes.entries = append(changes.entries, entry)
+	out := changes.output()
+	text := fmt.Sprintf("Staged %s of %d rows in %s as mutation %d. %d mutations (%d rows) are staged for %s, call preview_staged to show them and commit_staged to commit them\n",
+		req.Operation, len(entry.mutations), req.Table, len(changes.entries), len(out.Mutations), out.Rows, out.Database)
+	return mcp.NewToolResultStructured(out, text), nil
+}
+
+// writeMutations validates the rows against the columns of the table and converts them into mutations.
+func writeMutations(ctx context.Context, client *spanner.Client, newMutation func(string, []string, []any) *spanner.Mutation, table string, rows []map[string]any) ([]*spanner.Mutation, error) {
+	columns, err := tableColumnTypes(ctx, client, table)
+	if err != nil {
+		return nil, err
+	}
+	mutations := make([]*spanner.Mutation, len(rows))
+	for i, row := range rows {
+		if len(row) == 0 {
+			return nil, &validationError{Field: "rows", Message: fmt.Sprintf("row %d has no columns", i+1)}
+		}
+		if mutations[i], err = importMutation(newMutation, table, columns, row); err != nil {
+			return nil, &validationError{Field: "rows", Message: fmt.Sprintf("row %d: %v", i+1, err)}
+		}
+	}
+	return mutations, nil
+}
+
+// deleteMutations converts the keys into delete mutations. Keys are the values of all primary key columns in order.
+func deleteMutations(ctx context.Context, client *spanner.Client, table string, keys [][]any) ([]*spanner.Mutation, error) {
+	columns, types, err := primaryKeyTypes(ctx, client, table)
+	if err != nil {
+		return nil, err
+	}
+	mutations := make([]*spanner.Mutation, len(keys))
+	for i, key := range keys {
+		if len(key) != len(columns) {
+			return nil, &validationError{Field: "keys", Message: fmt.Sprintf("key %d must have %d values of the primary key (%s)", i+1, len(columns), strings.Join(columns, ", "))}
+		}
+		parts := make(spanner.Key, len(key))
+		for j, v := range key {
+			value, err := importValue(types[j], v)
+			if err != nil {
+				return nil, &validationError{Field: "keys", Message: fmt.Sprintf("key %d: column %s: %v", i+1, columns[j], err)}
+			}
+			parts[j] = keyPart(value)
+		}
+		mutations[i] = spanner.Delete(table, parts)
+	}
+	return mutations, nil
+}
+
+// primaryKeyTypes returns the primary key columns of the table and their types.
+func primaryKeyTypes(ctx context.Context, client *spanner.Client, table string) ([]string, []*sppb.Type, error) {
+	schema, name, ok := strings.Cut(strings.ReplaceAll(table, "`", ""), ".")
+	if !ok {
+		schema, name = "", schema
+	}
+	rows, err := queryRows(ctx, client, spanner.Statement{
+		SQL: `SELECT ic.COLUMN_NAME, c.SPANNER_TYPE
+FROM INFORMATION_SCHEMA.INDEX_COLUMNS AS ic
+JOIN INFORMATION_SCHEMA.COLUMNS AS c
+  ON c.TABLE_SCHEMA = ic.TABLE_SCHEMA AND c.TABLE_NAME = ic.TABLE_NAME AND c.COLUMN_NAME = ic.COLUMN_NAME
+WHERE ic.TABLE_SCHEMA = @schema AND ic.TABLE_NAME = @table AND ic.INDEX_TYPE = 'PRIMARY_KEY'
+ORDER BY ic.ORDINAL_POSITION`,
+		Params: map[string]any{"schema": schema, "table": name},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, &validationError{Field: "table", Message: fmt.Sprintf("table %s is not found", table)}
+	}
+
+	columns := make([]string, len(rows))
+	types := make([]*sppb.Type, len(rows))
+	for i, row := range rows {
+		columns[i], _ = row["COLUMN_NAME"].(string)
+		spannerType, _ := row["SPANNER_TYPE"].(string)
+		if types[i], err = parseSpannerType(spannerType); err != nil {
+			return nil, nil, fmt.Errorf("column %s: %w", columns[i], err)
+		}
+	}
+	return columns, types, nil
+}
+
+// keyPart returns the key part encoded as the value. Keys are sent as the values without types, e.g. INT64 as a string,
+// so the Go value of the same kind is encoded the same.
+func keyPart(v *structpb.Value) any {
+	switch v := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return v.StringValue
+	case *structpb.Value_NumberValue:
+		return v.NumberValue
+	case *structpb.Value_BoolValue:
+		return v.BoolValue
+	default:
+		return spanner.NullString{}
+	}
+}
+
+// rows returns the number of staged rows and keys. The caller holds the lock.
+func (c *stagedChangeSet) rows() int {
+	return lo.SumBy(c.entries, func(e stagedEntry) int { return len(e.mutations) })
+}
+
+// output returns the staged mutations numbered from 1. The caller holds the lock.
+func (c *stagedChangeSet) output() stagedMutationsOutput {
+	out := stagedMutationsOutput{Mutations: make([]stagedMutation, len(c.entries)), Rows: c.rows()}
+	if c.target != nil {
+		out.Database = c.target.databasePath()
+	}
+	for i, e := range c.entries {
+		out.Mutations[i] = e.view
+		out.Mutations[i].Index = i + 1
+	}
+	return out
+}
+
+func previewStagedHandler(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	changes, err := stagedChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	out := changes.output()
+	if len(out.Mutations) == 0 {
+		return mcp.NewToolResultStructured(out, "No mutations are staged\n"), nil
+	}
+	return mcp.NewToolResultStructured(out, renderStagedMutations(out)), nil
+}
+
+// renderStagedMutations renders the mutations with a line per row or key, for the user to review before commit_staged.
+func renderStagedMutations(out stagedMutationsOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d mutations (%d rows) are staged for %s:\n", len(out.Mutations), out.Rows, out.Database)
+	table := newTable(&b)
+	table.SetHeader([]string{"#", "Operation", "Table", "Rows or keys"})
+	for _, m := range out.Mutations {
+		values := lo.Map(m.Rows, func(row map[string]any, _ int) string {
+			j, _ := json.Marshal(row)
+			return string(j)
+		})
+		if m.Operation == "delete" {
+			values = lo.Map(m.Keys, func(key []any, _ int) string {
+				j, _ := json.Marshal(key)
+				return string(j)
+			})
+		}
+		table.Append([]string{fmt.Sprint(m.Index), strings.ToUpper(m.Operation), m.Table, strings.Join(values, "\n")})
+	}
+	table.Render()
+	b.WriteString("The mutations are committed atomically by commit_staged with expected_rows\n")
+	return b.String()
+}
+
+func commitStagedHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		ExpectedRows *int `mapstructure:"expected_rows"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := stagedChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	if len(changes.entries) == 0 {
+		return nil, &validationError{Message: "no mutations are staged, call stage_mutation first"}
+	}
+	rows := changes.rows()
+	// The user reviews the preview, so mutations staged after it are not committed silently.
+	if req.ExpectedRows != nil && *req.ExpectedRows != rows {
+		return nil, &validationError{Field: "expected_rows", Message: fmt.Sprintf("%d rows are staged, which differs from %d, call preview_staged again", rows, *req.ExpectedRows)}
+	}
+	target := changes.target
+	if err := checkTier(ctx, target); err != nil {
+		return nil, err
+	}
+
+	// Deletes are confirmed like destructive DML.
+	var deletes []string
+	for _, e := range changes.entries {
+		if e.view.Operation != "delete" {
+			continue
+		}
+		for _, key := range e.view.Keys {
+			j, _ := json.Marshal(key)
+			deletes = append(deletes, fmt.Sprintf("DELETE FROM %s KEY %s", e.view.Table, j))
+		}
+	}
+	if err := confirmStatements(ctx, target, deletes); err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Apply commits all mutations in a read-write transaction and retries it if it is aborted.
+	txnCtx, aborts := recordAborts(ctx)
+	ts, err := client.Apply(txnCtx, lo.FlatMap(changes.entries, func(e stagedEntry, _ int) []*spanner.Mutation { return e.mutations }))
+	diagnostics := aborts.diagnostics(ctx, client, err)
+	if err != nil {
+		return nil, withAbortDiagnostics(fmt.Errorf("failed to commit the staged mutations, which remain staged: %w", err), diagnostics)
+	}
+	auditCommitTimestamps(ctx, ts)
+	auditAffectedRows(ctx, int64(rows))
+
+	out := commitStagedOutput{Database: target.databasePath(), Mutations: len(changes.entries), Rows: rows, CommitTimestamp: ts.UTC().Format(time.RFC3339Nano), Transaction: diagnostics}
+	changes.target, changes.entries = nil, nil
+	text := fmt.Sprintf("Committed %d mutations (%d rows) to %s at %s\n", out.Mutations, out.Rows, out.Database, out.CommitTimestamp)
+	return mcp.NewToolResultStructured(out, text+renderTransactionDiagnostics(diagnostics)), nil
+}
+
+func discardStagedHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		Index int `mapstructure:"index"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := stagedChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	var text string
+	switch {
+	case req.Index == 0:
+		text = fmt.Sprintf("Discarded %d staged mutations\n", len(changes.entries))
+		changes.entries = nil
+	case req.Index < 0 || req.Index > len(changes.entries):
+		return nil, &validationError{Field: "index", Message: fmt.Sprintf("must be between 1 and %d", len(changes.entries))}
+	default:
+		changes.entries = append(changes.entries[:req.Index-1], changes.entries[req.Index:]...)
+		text = fmt.Sprintf("Discarded staged mutation %d, %d mutations remain and are renumbered\n", req.Index, len(changes.entries))
+	}
+	if len(changes.entries) == 0 {
+		changes.target = nil
+	}
+	return mcp.NewToolResultStructured(changes.output(), text), nil
+}
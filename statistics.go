@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+var statisticsPackageRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// statisticsPackageTimeRe captures the construction time in names of packages constructed automatically and by ANALYZE,
+// e.g. auto_20191128_14_47_22UTC.
+var statisticsPackageTimeRe = regexp.MustCompile(`(\d{8}_\d{2}_\d{2}_\d{2})UTC$`)
+
+// defaultStatisticsMaxAgeHours is the age of stale statistics by default.
+// Spanner constructs a new package automatically every three days.
+const defaultStatisticsMaxAgeHours = 72
+
+// statisticsPackageTime returns the construction time of the package from its name.
+func statisticsPackageTime(name string) (time.Time, bool) {
+	m := statisticsPackageTimeRe.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102_15_04_05", m[1])
+	return t, err == nil
+}
+
+// freshness returns the age of the package used by queries, which is the pinned package or the latest one.
+func (o *listStatisticsPackagesOutput) freshness(maxAge time.Duration, now time.Time) statisticsFreshnessOutput {
+	var f statisticsFreshnessOutput
+	var latestTime time.Time
+	for _, p := range o.Packages {
+		if t, ok := statisticsPackageTime(p.Name); ok && t.After(latestTime) {
+			f.Latest, latestTime = p.Name, t
+		}
+	}
+
+	f.Package, f.Pinned = lo.CoalesceOrEmpty(o.Pinned, f.Latest), o.Pinned != ""
+	t, ok := statisticsPackageTime(f.Package)
+	switch {
+	case f.Package == "":
+		f.Stale, f.Reason = true, "the database has no statistics packages"
+	case !ok:
+		f.Reason = "the construction time is unknown from the package name"
+	default:
+		f.ConstructedAt = &t
+		age := now.Sub(t)
+		f.AgeHours = age.Hours()
+		if age > maxAge {
+			f.Stale = true
+			f.Reason = fmt.Sprintf("the package is older than %.0f hours", maxAge.Hours())
+			if f.Pinned && f.Latest != f.Package {
+				f.Reason += fmt.Sprintf(" because the database is pinned to it, while the latest package is %s", f.Latest)
+			}
+		}
+	}
+	if f.Latest == f.Package {
+		f.Latest = ""
+	}
+	return f
+}
+
+func renderStatisticsFreshness(f statisticsFreshnessOutput) string {
+	if f.Package == "" {
+		return "The database has no optimizer statistics packages\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Queries use the %s statistics package %s", lo.Ternary(f.Pinned, "pinned", "latest"), f.Package)
+	if f.ConstructedAt != nil {
+		fmt.Fprintf(&b, " constructed at %s (%.1f hours ago)", f.ConstructedAt.Format(time.RFC3339), f.AgeHours)
+	}
+	b.WriteString("\n")
+	if f.Latest != "" {
+		fmt.Fprintf(&b, "The latest package is %s\n", f.Latest)
+	}
+	switch {
+	case f.Stale && f.Pinned:
+		fmt.Fprintf(&b, "Stale: %s. Unpin the package by set_statistics_package, or pin a newer package after analyze_database\n", f.Reason)
+	case f.Stale:
+		fmt.Fprintf(&b, "Stale: %s. Construct a new package by analyze_database\n", f.Reason)
+	case f.Reason != "":
+		fmt.Fprintf(&b, "Freshness is unknown: %s\n", f.Reason)
+	default:
+		b.WriteString("Fresh\n")
+	}
+	return b.String()
+}
+
+func statisticsFreshnessHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs   `mapstructure:",squash"`
+		MaxAgeHours float64 `mapstructure:"max_age_hours"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxAgeHours < 0 {
+		return nil, &validationError{Field: "max_age_hours", Message: "must not be negative"}
+	}
+	if req.MaxAgeHours == 0 {
+		req.MaxAgeHours = defaultStatisticsMaxAgeHours
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	packages, err := statisticsPackages(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	out := packages.freshness(time.Duration(req.MaxAgeHours*float64(time.Hour)), time.Now())
+	return mcp.NewToolResultStructured(out, renderStatisticsFreshness(out)), nil
+}
+
+func listStatisticsPackagesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[queryArgs](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	out, err := statisticsPackages(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pinned package: %s\n", lo.CoalesceOrEmpty(out.Pinned, "none (the latest package is used)"))
+	for _, p := range out.Packages {
+		fmt.Fprintf(&b, "%s\tallow_gc=%t", p.Name, p.AllowGC)
+		if p.Name == out.Pinned {
+			b.WriteString("\tpinned")
+		}
+		b.WriteString("\n")
+	}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// statisticsPackages returns the optimizer statistics packages of the database and the package pinned by the database option.
+func statisticsPackages(ctx context.Context, client *spanner.Client) (*listStatisticsPackagesOutput, error) {
+	rows, err := queryRows(ctx, client, spanner.NewStatement(`SELECT PACKAGE_NAME, ALLOW_GC FROM INFORMATION_SCHEMA.SPANNER_STATISTICS
+ORDER BY PACKAGE_NAME DESC`))
+	if err != nil {
+		return nil, err
+	}
+	out := &listStatisticsPackagesOutput{Packages: lo.Map(rows, func(row map[string]any, _ int) statisticsPackage {
+		name, _ := row["PACKAGE_NAME"].(string)
+		allowGC, _ := row["ALLOW_GC"].(bool)
+		return statisticsPackage{Name: name, AllowGC: allowGC}
+	})}
+
+	options, err := queryRows(ctx, client, spanner.NewStatement(`SELECT OPTION_VALUE FROM INFORMATION_SCHEMA.DATABASE_OPTIONS
+WHERE SCHEMA_NAME = '' AND OPTION_NAME = 'optimizer_statistics_package'`))
+	if err != nil {
+		return nil, err
+	}
+	if len(options) > 0 {
+		out.Pinned, _ = options[0]["OPTION_VALUE"].(string)
+	}
+	return out, nil
+}
+
+func setStatisticsPackageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs `mapstructure:",squash"`
+		Package      string `mapstructure:"package"`
+		AllowGC      *bool  `mapstructure:"allow_gc"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Package != "" && !statisticsPackageRe.MatchString(req.Package) {
+		return nil, &validationError{Field: "package", Message: fmt.Sprintf("%q is not a statistics package name", req.Package)}
+	}
+	if req.Package == "" && req.AllowGC != nil {
+		return nil, &validationError{Field: "allow_gc", Message: "requires package"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A typo of the package name would be accepted by ALTER DATABASE, so the package is checked beforehand.
+	if req.Package != "" {
+		client, release, err := clients.client(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		packages, err := statisticsPackages(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		if !lo.ContainsBy(packages.Packages, func(p statisticsPackage) bool { return p.Name == req.Package }) {
+			return nil, &validationError{Field: "package", Message: fmt.Sprintf("package %s is not found, use list_statistics_packages", req.Package)}
+		}
+	}
+
+	value := "NULL"
+	if req.Package != "" {
+		value = fmt.Sprintf("'%s'", req.Package)
+	}
+	statements := []string{fmt.Sprintf("ALTER DATABASE `%s` SET OPTIONS (optimizer_statistics_package = %s)", target.Database, value)}
+	if req.AllowGC != nil {
+		statements = append(statements, fmt.Sprintf("ALTER STATISTICS %s SET OPTIONS (allow_gc = %t)", req.Package, *req.AllowGC))
+	}
+
+	if _, err := applyDDL(ctx, target, statements); err != nil {
+		return nil, err
+	}
+
+	out := setStatisticsPackageOutput{Package: req.Package, Statements: statements}
+	if req.Package == "" {
+		return mcp.NewToolResultStructured(out, fmt.Sprintf("Unpinned the optimizer statistics package of %s, so the latest package is used", target.databasePath())), nil
+	}
+	return mcp.NewToolResultStructured(out, fmt.Sprintf("Pinned the optimizer statistics package of %s to %s", target.databasePath(), req.Package)), nil
+}
+
+func analyzeDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs     `mapstructure:",squash"`
+		IfOlderThanHours float64 `mapstructure:"if_older_than_hours"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	if req.IfOlderThanHours < 0 {
+		return nil, &validationError{Field: "if_older_than_hours", Message: "must not be negative"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	before, err := statisticsPackages(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.IfOlderThanHours > 0 {
+		maxAge := time.Duration(req.IfOlderThanHours * float64(time.Hour))
+		// ANALYZE constructs the latest package, so it is skipped if the latest package is fresh even if another package is pinned.
+		latest := (&listStatisticsPackagesOutput{Packages: before.Packages}).freshness(maxAge, time.Now())
+		if !latest.Stale && latest.ConstructedAt != nil {
+			out := analyzeDatabaseOutput{Pinned: before.Pinned, Skipped: true, Freshness: before.freshness(maxAge, time.Now())}
+			text := fmt.Sprintf("Skipped ANALYZE because the latest statistics package %s is younger than %g hours\n", latest.Package, req.IfOlderThanHours)
+			return mcp.NewToolResultStructured(out, text+renderStatisticsFreshness(out.Freshness)), nil
+		}
+	}
+
+	// ANALYZE is a DDL statement which constructs a new statistics package, and may take minutes on large databases.
+	// Its progress is notified while it runs.
+	start := time.Now()
+	_, err = applyDDLWithProgress(ctx, target, []string{"ANALYZE"}, func(metadata *databasepb.UpdateDatabaseDdlMetadata) {
+		if p := metadata.GetProgress(); len(p) > 0 {
+			sendProgressNotification(ctx, request, int(p[0].GetProgressPercent()), 100)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := statisticsPackages(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names of packages constructed by ANALYZE and automatically are not ordered, so the new package is found by the difference.
+	out := analyzeDatabaseOutput{
+		Pinned:          after.Pinned,
+		DurationSeconds: time.Since(start).Seconds(),
+		Freshness:       after.freshness(time.Duration(lo.CoalesceOrEmpty(req.IfOlderThanHours, defaultStatisticsMaxAgeHours)*float64(time.Hour)), time.Now()),
+	}
+	if p, ok := lo.Find(after.Packages, func(p statisticsPackage) bool {
+		return !lo.ContainsBy(before.Packages, func(b statisticsPackage) bool { return b.Name == p.Name })
+	}); ok {
+		out.Package = p.Name
+	}
+	text := fmt.Sprintf("Constructed optimizer statistics package %s of %s in %.0f seconds", out.Package, target.databasePath(), out.DurationSeconds)
+	if out.Pinned != "" {
+		text += fmt.Sprintf("\nThe database is pinned to %s, so queries don't use the new package until it is unpinned by set_statistics_package", out.Pinned)
+	}
+	return mcp.NewToolResultStructured(out, text), nil
+}
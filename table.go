@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Styles of tables in text contents.
+const (
+	tableStyleBox   = "box"
+	tableStylePlain = "plain"
+)
+
+var tableStyles = []string{tableStyleBox, tableStylePlain}
+
+// setEastAsianWidth sets whether characters of ambiguous width like box drawing characters, "…" and Greek letters are wide.
+// runewidth guesses it from the locale of the server, which may differ from the font of the client.
+func setEastAsianWidth(wide bool) {
+	runewidth.DefaultCondition.EastAsianWidth = wide
+}
+
+// newTable returns a table writer in the style of cfg.Output.TableStyle.
+// Widths of cells are measured by runewidth, so CJK characters are aligned.
+func newTable(w io.Writer) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+
+	if cfg.Output.TableStyle == tableStylePlain {
+		// Columns are aligned by spaces without borders and separators.
+		table.SetBorder(false)
+		table.SetHeaderLine(false)
+		table.SetColumnSeparator("")
+		table.SetCenterSeparator("")
+		table.SetRowSeparator("")
+		table.SetTablePadding("  ")
+		table.SetNoWhiteSpace(true)
+	}
+	return table
+}
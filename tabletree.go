@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// tableTreeNode is a table or an interleaved index in the interleave hierarchy.
+type tableTreeNode struct {
+	entry    tableTreeEntry
+	children []*tableTreeNode
+}
+
+func tableTreeHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs      `mapstructure:",squash"`
+		Table          string `mapstructure:"table"`
+		IncludeIndexes bool   `mapstructure:"include_indexes"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	req.Table = strings.ReplaceAll(req.Table, "`", "")
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tables, err := queryRows(ctx, client, spanner.NewStatement(`SELECT TABLE_SCHEMA, TABLE_NAME, PARENT_TABLE_NAME, ON_DELETE_ACTION, INTERLEAVE_TYPE
+FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN ('INFORMATION_SCHEMA', 'SPANNER_SYS')
+ORDER BY TABLE_SCHEMA, TABLE_NAME`))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []map[string]any
+	if req.IncludeIndexes {
+		// Indexes interleaved in tables have the parent table, and the other indexes are global.
+		indexes, err = queryRows(ctx, client, spanner.NewStatement(`SELECT TABLE_SCHEMA, INDEX_NAME, PARENT_TABLE_NAME
+FROM INFORMATION_SCHEMA.INDEXES
+WHERE INDEX_TYPE = 'INDEX' AND PARENT_TABLE_NAME != '' AND TABLE_SCHEMA NOT IN ('INFORMATION_SCHEMA', 'SPANNER_SYS')
+ORDER BY TABLE_SCHEMA, INDEX_NAME`))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	roots := buildTableTree(tables, indexes)
+	if req.Table != "" {
+		node := findTableTreeNode(roots, req.Table)
+		if node == nil {
+			return nil, &validationError{Field: "table", Message: fmt.Sprintf("table %s is not found", req.Table)}
+		}
+		roots = []*tableTreeNode{node}
+	}
+
+	out := renderTableTree(roots)
+	if len(out.Tables) == 0 {
+		return mcp.NewToolResultStructured(out, "The database has no tables\n"), nil
+	}
+	return mcp.NewToolResultStructured(out, out.Tree), nil
+}
+
+// buildTableTree returns root tables with their interleaved tables and indexes. Children are tables in the order of names
+// followed by indexes. Parents are in the same schema as their children.
+func buildTableTree(tables, indexes []map[string]any) []*tableTreeNode {
+	str := func(row map[string]any, key string) string {
+		s, _ := row[key].(string)
+		return s
+	}
+	nodes := make(map[string]*tableTreeNode, len(tables))
+	for _, row := range tables {
+		schema := str(row, "TABLE_SCHEMA")
+		entry := tableTreeEntry{Table: qualifiedTableName(schema, str(row, "TABLE_NAME"))}
+		if parent := str(row, "PARENT_TABLE_NAME"); parent != "" {
+			entry.Parent = qualifiedTableName(schema, parent)
+			entry.Interleave = "IN PARENT"
+			if str(row, "INTERLEAVE_TYPE") == "IN" {
+				entry.Interleave = "IN"
+			} else {
+				entry.OnDelete = lo.CoalesceOrEmpty(str(row, "ON_DELETE_ACTION"), "NO ACTION")
+			}
+		}
+		nodes[entry.Table] = &tableTreeNode{entry: entry}
+	}
+
+	var roots []*tableTreeNode
+	for _, row := range tables {
+		node := nodes[qualifiedTableName(str(row, "TABLE_SCHEMA"), str(row, "TABLE_NAME"))]
+		// Tables whose parent is not visible, e.g. by fine-grained access control, are shown as roots.
+		if parent, ok := nodes[node.entry.Parent]; ok {
+			parent.children = append(parent.children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, row := range indexes {
+		schema := str(row, "TABLE_SCHEMA")
+		if parent, ok := nodes[qualifiedTableName(schema, str(row, "PARENT_TABLE_NAME"))]; ok {
+			parent.children = append(parent.children, &tableTreeNode{entry: tableTreeEntry{
+				Table:  qualifiedTableName(schema, str(row, "INDEX_NAME")),
+				Parent: parent.entry.Table,
+				Index:  true,
+			}})
+		}
+	}
+	for _, n := range nodes {
+		slices.SortStableFunc(n.children, func(a, b *tableTreeNode) int {
+			return cmp.Or(compareBool(a.entry.Index, b.entry.Index), cmp.Compare(a.entry.Table, b.entry.Table))
+		})
+	}
+	return roots
+}
+
+// renderTableTree renders the trees like the tree command and lists the entries in the order of the trees.
+func renderTableTree(roots []*tableTreeNode) tableTreeOutput {
+	out := tableTreeOutput{Tables: []tableTreeEntry{}, Roots: len(roots)}
+	var b strings.Builder
+	var walk func(nodes []*tableTreeNode, depth int, prefix string)
+	walk = func(nodes []*tableTreeNode, depth int, prefix string) {
+		for i, n := range nodes {
+			n.entry.Depth = depth
+			n.entry.Children = lo.CountBy(n.children, func(c *tableTreeNode) bool { return !c.entry.Index })
+			out.Tables = append(out.Tables, n.entry)
+			if !n.entry.Index {
+				out.MaxDepth = max(out.MaxDepth, depth)
+			}
+
+			branch, next := "", ""
+			if depth > 0 {
+				branch, next = "├── ", "│   "
+				if i == len(nodes)-1 {
+					branch, next = "└── ", "    "
+				}
+			}
+			fmt.Fprintf(&b, "%s%s%s\n", prefix, branch, tableTreeLabel(n.entry))
+			walk(n.children, depth+1, prefix+next)
+		}
+	}
+	walk(roots, 0, "")
+	out.Tree = b.String()
+	return out
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func findTableTreeNode(nodes []*tableTreeNode, table string) *tableTreeNode {
+	for _, n := range nodes {
+		if !n.entry.Index && strings.EqualFold(n.entry.Table, table) {
+			return n
+		}
+		if found := findTableTreeNode(n.children, table); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func tableTreeLabel(e tableTreeEntry) string {
+	switch {
+	case e.Index:
+		return e.Table + " [index]"
+	case e.Interleave == "IN":
+		return e.Table + " (INTERLEAVE IN)"
+	case e.OnDelete != "":
+		return fmt.Sprintf("%s (ON DELETE %s)", e.Table, e.OnDelete)
+	default:
+		return e.Table
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	promexporter "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "spanner-mcp"
+
+// setupTelemetry configures OpenTelemetry.
+// If otlp is true, traces and metrics are exported via OTLP/gRPC configured by the standard OTEL_EXPORTER_OTLP_* environment variables.
+// If prometheus is true, metrics are also exposed by the returned handler in the Prometheus format.
+func setupTelemetry(ctx context.Context, version string, otlp, prometheus bool) (shutdown func(context.Context) error, metrics http.Handler, err error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(instrumentationName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var shutdowns []func(context.Context) error
+	shutdown = func(ctx context.Context) error {
+		var errs []error
+		for _, f := range shutdowns {
+			errs = append(errs, f(ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	metricOpts := []sdkmetric.Option{sdkmetric.WithResource(res), sdkmetric.WithReader(poolStatsReader)}
+	if prometheus {
+		registry := promclient.NewRegistry()
+		exporter, err := promexporter.New(promexporter.WithRegisterer(registry))
+		if err != nil {
+			return nil, nil, err
+		}
+		metricOpts = append(metricOpts, sdkmetric.WithReader(exporter))
+		metrics = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	}
+
+	if otlp {
+		traceExporter, err := otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res))
+		shutdowns = append(shutdowns, tp.Shutdown)
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+		metricExporter, err := otlpmetricgrpc.New(ctx)
+		if err != nil {
+			return nil, nil, errors.Join(err, shutdown(ctx))
+		}
+		metricOpts = append(metricOpts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))
+
+		// The Spanner client traces using the global tracer provider.
+		if err := os.Setenv("GOOGLE_API_GO_EXPERIMENTAL_TELEMETRY_PLATFORM_TRACING", "opentelemetry"); err != nil {
+			return nil, nil, errors.Join(err, shutdown(ctx))
+		}
+	}
+
+	mp := sdkmetric.NewMeterProvider(metricOpts...)
+	shutdowns = append(shutdowns, mp.Shutdown)
+	otel.SetMeterProvider(mp)
+
+	// The Spanner client records metrics including session pool statistics using the global meter provider.
+	spanner.EnableOpenTelemetryMetrics()
+
+	return shutdown, metrics, nil
+}
+
+// toolMetrics are instruments of tool calls.
+// They are created from the global meter provider, so they are no-op until setupTelemetry is called.
+var toolMetrics struct {
+	calls    metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+	rows     metric.Int64Counter
+}
+
+func init() {
+	if err := initToolMetrics(); err != nil {
+		panic(err)
+	}
+}
+
+func initToolMetrics() error {
+	meter := otel.Meter(instrumentationName)
+
+	var errs []error
+	var err error
+	toolMetrics.calls, err = meter.Int64Counter("spanner_mcp.tool.calls", metric.WithDescription("Number of tool calls"))
+	errs = append(errs, err)
+	toolMetrics.errors, err = meter.Int64Counter("spanner_mcp.tool.errors", metric.WithDescription("Number of failed tool calls"))
+	errs = append(errs, err)
+	toolMetrics.duration, err = meter.Float64Histogram("spanner_mcp.tool.duration", metric.WithDescription("Latency of tool calls"), metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300))
+	errs = append(errs, err)
+	toolMetrics.rows, err = meter.Int64Counter("spanner_mcp.tool.rows", metric.WithDescription("Number of rows or records returned by tools"))
+	errs = append(errs, err)
+	return errors.Join(errs...)
+}
+
+type toolNameKey struct{}
+
+// recordRows records the number of rows or records returned by the current tool call.
+func recordRows(ctx context.Context, n int) {
+	name, _ := ctx.Value(toolNameKey{}).(string)
+	toolMetrics.rows.Add(ctx, int64(n), metric.WithAttributes(attribute.String("tool", name)))
+}
+
+// telemetryMiddleware creates a span and records metrics for every tool call.
+func telemetryMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	tracer := otel.Tracer(instrumentationName)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		ctx = context.WithValue(ctx, toolNameKey{}, name)
+		ctx, span := tracer.Start(ctx, "tools/call "+name, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("mcp.tool.name", name), attribute.String("mcp.request_id", requestID(ctx))))
+		defer span.End()
+
+		start := time.Now()
+		result, err := next(ctx, request)
+
+		code := "OK"
+		switch {
+		case err != nil:
+			code = spanner.ErrCode(err).String()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case result != nil && result.IsError:
+			code = "TOOL_ERROR"
+			span.SetStatus(codes.Error, "tool returned error result")
+		}
+
+		attrs := metric.WithAttributes(attribute.String("tool", name), attribute.String("code", code))
+		toolMetrics.calls.Add(ctx, 1, attrs)
+		if code != "OK" {
+			toolMetrics.errors.Add(ctx, 1, attrs)
+		}
+		toolMetrics.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+		return result, err
+	}
+}
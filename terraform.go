@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// terraformNameRe matches characters which are not allowed in names of Terraform resources.
+var terraformNameRe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// terraformName returns the name of the Terraform resource for the Spanner resource ID.
+func terraformName(id string) string {
+	name := terraformNameRe.ReplaceAllString(id, "_")
+	if name == "" || !(name[0] == '_' || name[0] >= 'A' && name[0] <= 'Z' || name[0] >= 'a' && name[0] <= 'z') {
+		name = "_" + name
+	}
+	return name
+}
+
+// quoteHCL quotes s as an HCL string literal. Template sequences are escaped so DDL is not interpolated.
+func quoteHCL(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{")
+	return `"` + r.Replace(s) + `"`
+}
+
+func hclList(values []string) string {
+	return "[" + strings.Join(lo.Map(values, func(v string, _ int) string { return quoteHCL(v) }), ", ") + "]"
+}
+
+// hclBlock builds a Terraform block with aligned attributes like terraform fmt.
+type hclBlock struct {
+	header     string
+	attributes [][2]string
+	blocks     []*hclBlock
+}
+
+func (b *hclBlock) set(name, value string) {
+	b.attributes = append(b.attributes, [2]string{name, value})
+}
+
+func (b *hclBlock) block(header string) *hclBlock {
+	child := &hclBlock{header: header}
+	b.blocks = append(b.blocks, child)
+	return child
+}
+
+func (b *hclBlock) write(w *strings.Builder, indent string) {
+	fmt.Fprintf(w, "%s%s {\n", indent, b.header)
+	width := lo.Max(lo.Map(b.attributes, func(a [2]string, _ int) int {
+		// Multi-line values are not aligned by terraform fmt.
+		if strings.Contains(a[1], "\n") {
+			return 0
+		}
+		return len(a[0])
+	}))
+	for _, a := range b.attributes {
+		if strings.Contains(a[1], "\n") {
+			fmt.Fprintf(w, "%s  %s = %s\n", indent, a[0], a[1])
+		} else {
+			fmt.Fprintf(w, "%s  %-*s = %s\n", indent, width, a[0], a[1])
+		}
+	}
+	for i, child := range b.blocks {
+		if i > 0 || len(b.attributes) > 0 {
+			w.WriteString("\n")
+		}
+		child.write(w, indent+"  ")
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+func exportTerraformHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		databaseArgs    `mapstructure:",squash"`
+		IncludeInstance *bool `mapstructure:"include_instance"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	includeInstance := req.IncludeInstance == nil || *req.IncludeInstance
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var db *databasepb.Database
+	err = retry(ctx, func(ctx context.Context) error {
+		db, err = admin.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: target.databasePath()})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	statements, err := databaseStatements(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	instanceRef := quoteHCL(target.Instance)
+	if includeInstance {
+		instanceAdmin, err := clients.instanceAdminClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var inst *instancepb.Instance
+		err = retry(ctx, func(ctx context.Context) error {
+			inst, err = instanceAdmin.GetInstance(ctx, &instancepb.GetInstanceRequest{
+				Name: fmt.Sprintf("projects/%s/instances/%s", target.Project, target.Instance),
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		name := terraformName(target.Instance)
+		terraformInstance(target, inst, name).write(&b, "")
+		b.WriteString("\n")
+		instanceRef = fmt.Sprintf("google_spanner_instance.%s.name", name)
+	}
+	terraformDatabase(target, db, statements, instanceRef).write(&b, "")
+
+	out := exportTerraformOutput{Terraform: b.String(), Statements: len(statements)}
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+func terraformInstance(target *profile, inst *instancepb.Instance, name string) *hclBlock {
+	b := &hclBlock{header: fmt.Sprintf("resource \"google_spanner_instance\" %s", quoteHCL(name))}
+	b.set("project", quoteHCL(target.Project))
+	b.set("name", quoteHCL(target.Instance))
+	b.set("config", quoteHCL(path.Base(inst.GetConfig())))
+	b.set("display_name", quoteHCL(inst.GetDisplayName()))
+	if inst.GetEdition() != instancepb.Instance_EDITION_UNSPECIFIED {
+		b.set("edition", quoteHCL(inst.GetEdition().String()))
+	}
+
+	// Compute capacity managed by the autoscaler must not be set.
+	if autoscaling := inst.GetAutoscalingConfig(); autoscaling != nil {
+		ac := b.block("autoscaling_config")
+		limits := ac.block("autoscaling_limits")
+		if l := autoscaling.GetAutoscalingLimits(); l.GetMinNodes() > 0 {
+			limits.set("min_nodes", fmt.Sprint(l.GetMinNodes()))
+			limits.set("max_nodes", fmt.Sprint(l.GetMaxNodes()))
+		} else {
+			limits.set("min_processing_units", fmt.Sprint(l.GetMinProcessingUnits()))
+			limits.set("max_processing_units", fmt.Sprint(l.GetMaxProcessingUnits()))
+		}
+		targets := ac.block("autoscaling_targets")
+		targets.set("high_priority_cpu_utilization_percent", fmt.Sprint(autoscaling.GetAutoscalingTargets().GetHighPriorityCpuUtilizationPercent()))
+		targets.set("storage_utilization_percent", fmt.Sprint(autoscaling.GetAutoscalingTargets().GetStorageUtilizationPercent()))
+	} else if inst.GetProcessingUnits()%1000 == 0 {
+		b.set("num_nodes", fmt.Sprint(inst.GetNodeCount()))
+	} else {
+		b.set("processing_units", fmt.Sprint(inst.GetProcessingUnits()))
+	}
+
+	if labels := inst.GetLabels(); len(labels) > 0 {
+		keys := slices.Sorted(maps.Keys(labels))
+		width := lo.Max(lo.Map(keys, func(k string, _ int) int { return len(quoteHCL(k)) }))
+		lines := lo.Map(keys, func(k string, _ int) string {
+			return fmt.Sprintf("    %-*s = %s\n", width, quoteHCL(k), quoteHCL(labels[k]))
+		})
+		b.set("labels", "{\n"+strings.Join(lines, "")+"  }")
+	}
+	return b
+}
+
+// terraformDatabase returns the google_spanner_database resource. Database options like version_retention_period
+// and default_leader are ALTER DATABASE statements in the DDL, so they are not attributes of the resource.
+func terraformDatabase(target *profile, db *databasepb.Database, statements []string, instanceRef string) *hclBlock {
+	b := &hclBlock{header: fmt.Sprintf("resource \"google_spanner_database\" %s", quoteHCL(terraformName(target.Database)))}
+	b.set("project", quoteHCL(target.Project))
+	b.set("instance", instanceRef)
+	b.set("name", quoteHCL(target.Database))
+	if db.GetDatabaseDialect() == databasepb.DatabaseDialect_POSTGRESQL {
+		b.set("database_dialect", quoteHCL(db.GetDatabaseDialect().String()))
+	}
+	if db.GetEnableDropProtection() {
+		b.set("enable_drop_protection", "true")
+	}
+	if len(statements) > 0 {
+		lines := lo.Map(statements, func(stmt string, _ int) string { return "    " + quoteHCL(stmt) + ",\n" })
+		b.set("ddl", "[\n"+strings.Join(lines, "")+"  ]")
+	}
+
+	if enc := db.GetEncryptionConfig(); enc.GetKmsKeyName() != "" || len(enc.GetKmsKeyNames()) > 0 {
+		e := b.block("encryption_config")
+		if enc.GetKmsKeyName() != "" {
+			e.set("kms_key_name", quoteHCL(enc.GetKmsKeyName()))
+		} else {
+			e.set("kms_key_names", hclList(enc.GetKmsKeyNames()))
+		}
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// Permission tiers of profiles in the order of privileges. A profile of a tier can call tools of the tier and lower tiers.
+const (
+	tierReadOnly  = "read-only"
+	tierReadWrite = "read-write"
+	tierAdmin     = "admin"
+)
+
+var tiers = []string{tierReadOnly, tierReadWrite, tierAdmin}
+
+// adminTools change schemas, options and backups of databases or launch jobs, which require the admin tier.
+// Other tools which are not read-only require the read-write tier.
+var adminTools = []string{
+	"update_ddl",
+	"create_table_as",
+	"set_statistics_package",
+	"analyze_database",
+	"create_backup",
+	"start_dataflow_export",
+	"start_dataflow_import",
+}
+
+// toolTiers are the tiers required by the registered tools, set in main.
+var toolTiers map[string]string
+
+// toolTier returns the tier required by the tool from its annotations.
+func toolTier(t toolEntry) string {
+	switch {
+	case lo.Contains(adminTools, t.tool.Name):
+		return tierAdmin
+	case lo.FromPtr(t.tool.Annotations.ReadOnlyHint):
+		return tierReadOnly
+	default:
+		return tierReadWrite
+	}
+}
+
+func validateTier(tier string) error {
+	if tier != "" && !lo.Contains(tiers, tier) {
+		return fmt.Errorf("unknown tier: %q, must be read-only, read-write or admin", tier)
+	}
+	return nil
+}
+
+// targetTier returns the tier of the target, which is the lowest tier of the profiles of the same database, or the same instance
+// if the target is an instance. Explicit arguments can't escalate the tier because the tier follows the resolved target rather than
+// the requested profile. Targets without profiles of tiers have default_tier, and are unrestricted if it is empty.
+func targetTier(t *profile) string {
+	var matched []string
+	for _, p := range cfg.Profiles {
+		if p.Tier == "" || p.Project != t.Project || p.Instance != t.Instance {
+			continue
+		}
+		if t.Database == "" || p.Database == "" || p.Database == t.Database {
+			matched = append(matched, p.Tier)
+		}
+	}
+	if len(matched) == 0 {
+		return lo.CoalesceOrEmpty(cfg.DefaultTier, tierAdmin)
+	}
+	return lo.MinBy(matched, func(a, b string) bool { return slices.Index(tiers, a) < slices.Index(tiers, b) })
+}
+
+// checkTier returns an error if the tool of the current call requires a higher tier than the target.
+func checkTier(ctx context.Context, t *profile) error {
+	name, _ := ctx.Value(toolNameKey{}).(string)
+	required, ok := toolTiers[name]
+	if !ok {
+		return nil
+	}
+	if tier := targetTier(t); slices.Index(tiers, required) > slices.Index(tiers, tier) {
+		path := fmt.Sprintf("projects/%s/instances/%s", t.Project, t.Instance)
+		if t.Database != "" {
+			path = t.databasePath()
+		}
+		return &validationError{Message: fmt.Sprintf("%s requires the %s tier, but %s is %s by the server config", name, required, path, tier)}
+	}
+	return nil
+}
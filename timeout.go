@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/samber/lo"
+)
+
+// Classes of tools by their expected duration, which have separate default timeouts.
+const (
+	timeoutClassMetadata  = "metadata"
+	timeoutClassQuery     = "query"
+	timeoutClassOperation = "operation"
+)
+
+// Default timeouts of the classes.
+const (
+	defaultMetadataTimeout  = time.Minute
+	defaultQueryTimeout     = 10 * time.Minute
+	defaultOperationTimeout = 6 * time.Hour
+)
+
+// metadataTools read schemas, metadata of resources or the state of the server, which return quickly.
+var metadataTools = []string{
+	"get_ddl",
+	"list_databases",
+	"list_tables",
+	"describe_table",
+	"list_commit_timestamp_columns",
+	"list_column_expressions",
+	"list_constraints",
+	"lint_schema",
+	"list_statistics_packages",
+	"statistics_freshness",
+	"list_property_graphs",
+	"graph_schema_diagram",
+	"list_backups",
+	"list_backup_chains",
+	"backup_coverage",
+	"table_tree",
+	"export_dbml",
+	"export_terraform",
+	"generate_client_code",
+	"generate_alert_policies",
+	"capacity_planning",
+	"get_dataflow_job",
+	"start_dataflow_export",
+	"start_dataflow_import",
+	"create_backup",
+	"preview_staged",
+	"discard_staged",
+	"use_database",
+	"server_info",
+	"ping",
+	"pool_stats",
+	"session_history",
+	"history",
+}
+
+// operationTools wait for long-running operations such as DDL, or move data in bulk.
+// Other tools run queries, DML and transactions.
+var operationTools = []string{
+	"update_ddl",
+	"create_table_as",
+	"set_statistics_package",
+	"analyze_database",
+	"whatif",
+	"export_to_gcs",
+	"import_data",
+	"generate_data",
+	"truncate_table",
+	"tail_change_stream",
+}
+
+// timeoutOptions configures timeouts of tool calls. Zero values mean the defaults of the classes.
+// Calls can override them by the timeout argument.
+//
+//	timeouts:
+//	  metadata: 30s
+//	  query: 5m
+//	  operation: 2h
+//	  tools:
+//	    execute_partitioned_query: 30m
+type timeoutOptions struct {
+	Metadata  time.Duration            `yaml:"metadata"`
+	Query     time.Duration            `yaml:"query"`
+	Operation time.Duration            `yaml:"operation"`
+	Tools     map[string]time.Duration `yaml:"tools"`
+}
+
+func (o *timeoutOptions) validate() error {
+	for name, d := range map[string]time.Duration{"metadata": o.Metadata, "query": o.Query, "operation": o.Operation} {
+		if d < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	for name, d := range o.Tools {
+		if d <= 0 {
+			return fmt.Errorf("timeout of tool %q must be positive", name)
+		}
+	}
+	return nil
+}
+
+// timeoutClass returns the class of the tool.
+func timeoutClass(tool string) string {
+	switch {
+	case lo.Contains(metadataTools, tool):
+		return timeoutClassMetadata
+	case lo.Contains(operationTools, tool):
+		return timeoutClassOperation
+	default:
+		return timeoutClassQuery
+	}
+}
+
+// timeout returns the default timeout of the tool and where it is configured.
+func (o *timeoutOptions) timeout(tool string) (time.Duration, string) {
+	if d, ok := o.Tools[tool]; ok {
+		return d, fmt.Sprintf("timeouts.tools.%s", tool)
+	}
+	class := timeoutClass(tool)
+	switch class {
+	case timeoutClassMetadata:
+		return lo.CoalesceOrEmpty(o.Metadata, defaultMetadataTimeout), "timeouts.metadata"
+	case timeoutClassOperation:
+		return lo.CoalesceOrEmpty(o.Operation, defaultOperationTimeout), "timeouts.operation"
+	default:
+		return lo.CoalesceOrEmpty(o.Query, defaultQueryTimeout), "timeouts.query"
+	}
+}
+
+// withTimeoutArg adds the timeout argument to the tool.
+func withTimeoutArg(t *mcp.Tool) {
+	d, _ := cfg.Timeouts.timeout(t.Name)
+	mcp.WithString("timeout",
+		mcp.Description(fmt.Sprintf("Timeout of the call as a Go duration, e.g. 30s or 1h (default %s for %s tools). Operations such as DDL are cancelled on timeout", d, timeoutClass(t.Name))),
+	)(t)
+}
+
+// timeoutMiddleware bounds tool calls by the timeout argument or the configured timeout of the tool,
+// and reports calls which time out in error results.
+func timeoutMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		if name == "replay" {
+			// The replayed call is bounded by itself.
+			return next(ctx, request)
+		}
+
+		timeout, source := cfg.Timeouts.timeout(name)
+		args := request.GetArguments()
+		if v, ok := args["timeout"]; ok {
+			s, _ := v.(string)
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return mcp.NewToolResultError((&validationError{Field: "timeout", Message: "must be a positive duration, e.g. 30s or 1h"}).Error()), nil
+			}
+			timeout, source = d, "the timeout argument"
+			// Handlers decode arguments strictly, so the argument is consumed here.
+			args = maps.Clone(args)
+			delete(args, "timeout")
+			request.Params.Arguments = args
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		result, err := next(callCtx, request)
+		if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			slog.WarnContext(ctx, "tool call timed out", "tool", name, "timeout", timeout, "error", err)
+			return mcp.NewToolResultError(fmt.Sprintf("tool call %s timed out after %s set by %s; retry with a longer timeout argument if the call is expected to take longer: %v", name, timeout, source, err)), nil
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+)
+
+// maxTopQueryIntervals is the number of the latest intervals of statistics reported by profile_top_query.
+const maxTopQueryIntervals = 24
+
+func profileTopQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs   `mapstructure:",squash"`
+		Fingerprint string `mapstructure:"fingerprint"`
+		Interval    string `mapstructure:"interval"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := strconv.ParseInt(strings.TrimSpace(req.Fingerprint), 10, 64)
+	if err != nil {
+		return nil, &validationError{Field: "fingerprint", Message: "must be TEXT_FINGERPRINT of SPANNER_SYS.QUERY_STATS_TOP_* as a decimal integer"}
+	}
+	interval := lo.CoalesceOrEmpty(req.Interval, "hour")
+	statsTable, ok := queryStatsTables[interval]
+	if !ok {
+		return nil, &validationError{Field: "interval", Message: "must be one of minute, 10minute and hour"}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	stats, err := queryRows(ctx, client, spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT INTERVAL_END, TEXT, TEXT_TRUNCATED, EXECUTION_COUNT, AVG_LATENCY_SECONDS, AVG_ROWS, AVG_BYTES,
+  AVG_ROWS_SCANNED, AVG_CPU_SECONDS, ALL_FAILED_EXECUTION_COUNT, CANCELLED_OR_DISCONNECTED_EXECUTION_COUNT, TIMED_OUT_EXECUTION_COUNT
+FROM %s
+WHERE TEXT_FINGERPRINT = @fingerprint
+ORDER BY INTERVAL_END DESC
+LIMIT @limit`, statsTable),
+		Params: map[string]any{"fingerprint": fingerprint, "limit": maxTopQueryIntervals},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return nil, &validationError{Field: "fingerprint", Message: fmt.Sprintf("no statistics of the fingerprint are found in %s, which keeps only the top queries of each interval", statsTable)}
+	}
+
+	// TEXT is the same in all intervals of the fingerprint, so it is reported once.
+	out := profileTopQueryOutput{Fingerprint: strconv.FormatInt(fingerprint, 10), Interval: interval}
+	out.Query, _ = stats[0]["TEXT"].(string)
+	out.Truncated, _ = stats[0]["TEXT_TRUNCATED"].(bool)
+	for _, row := range stats {
+		delete(row, "TEXT")
+		delete(row, "TEXT_TRUNCATED")
+	}
+	out.Stats = stats
+
+	if out.Truncated {
+		out.Note = "The query text is truncated in the statistics, so the query is not planned. Plan the full query text by plan"
+		return mcp.NewToolResultStructured(out, renderProfileTopQuery(out, "")), nil
+	}
+
+	qp, processed, err := analyzeQuery(ctx, target, out.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan the query of the fingerprint: %w", err)
+	}
+	plan, err := printResult(processed)
+	if err != nil {
+		return nil, err
+	}
+	summary := summarizePlan(qp)
+	out.Summary, out.Operators = &summary, planOperators(processed)
+
+	// SPANNER_SYS has no plans of the queries, so the plan is compared with the newest snapshot of the same query saved by plan.
+	if planDir != "" {
+		live := &planSnapshot{Name: "live", Database: target.databasePath(), Query: out.Query, SavedAt: time.Now().UTC(), Summary: summary, Operators: out.Operators, Plan: plan}
+		if base := findPlanSnapshot(ctx, live.Database, live.Query); base != nil {
+			c := comparePlanSnapshots(base, live)
+			c.Other.URI = ""
+			out.Baseline = &c
+		}
+	}
+	return mcp.NewToolResultStructured(out, renderProfileTopQuery(out, plan)), nil
+}
+
+// findPlanSnapshot returns the newest of the latest maxListedPlanSnapshots snapshots of the query on the database, or nil if none.
+func findPlanSnapshot(ctx context.Context, database, query string) *planSnapshot {
+	names, err := planSnapshotNames(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to list plan snapshots", "error", err)
+		return nil
+	}
+	for _, name := range lo.Slice(names, 0, maxListedPlanSnapshots) {
+		s, err := loadPlanSnapshot(ctx, name)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to read plan snapshot", "snapshot", name, "error", err)
+			continue
+		}
+		if s.Database == database && s.Query == query {
+			return s
+		}
+	}
+	return nil
+}
+
+func renderProfileTopQuery(out profileTopQueryOutput, plan string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Query of fingerprint %s%s:\n%s\n\n", out.Fingerprint, lo.Ternary(out.Truncated, " (truncated)", ""), out.Query)
+
+	fmt.Fprintf(&b, "Statistics of the latest %d intervals from SPANNER_SYS.QUERY_STATS_TOP_%s:\n", len(out.Stats), strings.ToUpper(out.Interval))
+	table := newTable(&b)
+	table.SetHeader([]string{"Interval end", "Executions", "Avg latency seconds", "Avg CPU seconds", "Avg rows", "Avg rows scanned", "Failed"})
+	for _, row := range out.Stats {
+		table.Append(lo.Map([]string{"INTERVAL_END", "EXECUTION_COUNT", "AVG_LATENCY_SECONDS", "AVG_CPU_SECONDS", "AVG_ROWS", "AVG_ROWS_SCANNED", "ALL_FAILED_EXECUTION_COUNT"},
+			func(column string, _ int) string { return fmt.Sprint(row[column]) }))
+	}
+	table.Render()
+
+	if out.Summary != nil {
+		b.WriteString("\nLive plan:\n")
+		b.WriteString(renderPlanSummary(*out.Summary))
+		b.WriteString(plan)
+	}
+	if c := out.Baseline; c != nil {
+		fmt.Fprintf(&b, "\nCompared with the plan snapshot %s saved at %s: ", c.Base.Name, c.Base.SavedAt.Format(time.RFC3339))
+		if !c.PlanChanged {
+			b.WriteString("the plan is unchanged\n")
+		} else {
+			b.WriteString("the plan is changed\n")
+			b.WriteString(strings.Join(c.OperatorsDiff, "\n") + "\n")
+		}
+	}
+	if out.Note != "" {
+		fmt.Fprintf(&b, "\nNote: %s\n", out.Note)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func truncateTableHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		Table     string `mapstructure:"table"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := quoteTableName(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Access.checkTable("table", req.Table); err != nil {
+		return nil, err
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE true", table)
+	if err := confirmStatements(ctx, target, []string{stmt}); err != nil {
+		return nil, err
+	}
+
+	client, release, err := clients.client(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Partitioned DML retries partitions in the client library and isn't limited by the mutation limit of transactions.
+	count, err := client.PartitionedUpdate(ctx, spanner.NewStatement(stmt))
+	if err != nil {
+		return nil, err
+	}
+	auditAffectedRows(ctx, count)
+
+	out := truncateTableOutput{Table: strings.ReplaceAll(req.Table, "`", ""), DeletedRows: count}
+	return mcp.NewToolResultStructured(out, fmt.Sprintf("Deleted at least %d rows from %s", count, out.Table)), nil
+}
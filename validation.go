@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// validationError is an invalid argument of a tool call. It is returned to the model as a tool error.
+type validationError struct {
+	// Field is the name of the offending argument, or empty if it is unknown.
+	Field   string
+	Message string
+}
+
+func (e *validationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid arguments: %s", e.Message)
+	}
+	return fmt.Sprintf("invalid argument %s: %s", e.Field, e.Message)
+}
+
+// mapToStruct decodes the arguments strictly. Unknown arguments and mismatched types are errors.
+func mapToStruct[T any](m map[string]any) (T, error) {
+	var zero T
+	var result T
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused: true,
+		Result:      &result,
+	})
+	if err != nil {
+		return zero, err
+	}
+	if err := decoder.Decode(m); err != nil {
+		return zero, &validationError{Message: err.Error()}
+	}
+	return result, nil
+}
+
+// Formats of resource IDs. See https://cloud.google.com/spanner/docs/reference/rpc/google.spanner.admin.database.v1.
+var (
+	projectIDRe  = regexp.MustCompile(`^([a-z][a-z0-9.-]*:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	instanceIDRe = regexp.MustCompile(`^[a-z][-a-z0-9]{0,62}[a-z0-9]$`)
+	databaseIDRe = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,28}[a-z0-9]$`)
+)
+
+// validateTarget checks the formats of IDs which are not empty.
+func validateTarget(t *profile) error {
+	for _, f := range []struct {
+		field, value string
+		re           *regexp.Regexp
+	}{
+		{"project", t.Project, projectIDRe},
+		{"instance", t.Instance, instanceIDRe},
+		{"database", t.Database, databaseIDRe},
+	} {
+		if f.value != "" && !f.re.MatchString(f.value) {
+			return &validationError{Field: f.field, Message: fmt.Sprintf("%q is not a valid %s ID", f.value, f.field)}
+		}
+	}
+	return nil
+}
+
+// validateArguments checks the arguments against the input schema of the tool.
+func validateArguments(tool mcp.Tool, args map[string]any) error {
+	for name := range args {
+		if _, ok := tool.InputSchema.Properties[name]; !ok {
+			return &validationError{Field: name, Message: "unknown argument"}
+		}
+	}
+
+	for _, name := range tool.InputSchema.Required {
+		switch v := args[name].(type) {
+		case nil:
+			return &validationError{Field: name, Message: "required"}
+		case string:
+			if v == "" {
+				return &validationError{Field: name, Message: "must not be empty"}
+			}
+		case []any:
+			if len(v) == 0 {
+				return &validationError{Field: name, Message: "must not be empty"}
+			}
+		}
+	}
+
+	for name, v := range args {
+		prop, _ := tool.InputSchema.Properties[name].(map[string]any)
+		typ, _ := prop["type"].(string)
+		if v == nil || typ == "" || matchesType(v, typ) {
+			continue
+		}
+		return &validationError{Field: name, Message: fmt.Sprintf("must be %s but got %T", typ, v)}
+	}
+
+	for name, v := range args {
+		prop, _ := tool.InputSchema.Properties[name].(map[string]any)
+		if enum, ok := prop["enum"].([]string); ok && v != nil && !slices.Contains(enum, fmt.Sprint(v)) {
+			return &validationError{Field: name, Message: fmt.Sprintf("must be one of %v", enum)}
+		}
+	}
+	return nil
+}
+
+func matchesType(v any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number", "integer":
+		_, ok := v.(float64)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	default:
+		return true
+	}
+}
+
+// validationMiddleware validates arguments before calling the handler,
+// and returns validation errors as tool errors so the model can correct the arguments.
+func validationMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s := server.ServerFromContext(ctx); s != nil {
+			if tool := s.GetTool(request.Params.Name); tool != nil {
+				if err := validateArguments(tool.Tool, request.GetArguments()); err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+			}
+		}
+
+		result, err := next(ctx, request)
+		if verr := (*validationError)(nil); errors.As(err, &verr) {
+			return mcp.NewToolResultError(verr.Error()), nil
+		}
+		return result, err
+	}
+}
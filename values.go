@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// decodeGenericColumnValue converts a column value into a value which can be marshalled as JSON.
+func decodeGenericColumnValue(gcv spanner.GenericColumnValue) (any, error) {
+	return decodeValue(gcv.Type, gcv.Value)
+}
+
+// decodeValue converts a Spanner value into nil, bool, int64, float64, string, json.RawMessage, []any or map[string]any.
+func decodeValue(typ *sppb.Type, value *structpb.Value) (any, error) {
+	if _, ok := value.GetKind().(*structpb.Value_NullValue); ok {
+		return nil, nil
+	}
+
+	switch typ.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return value.GetBoolValue(), nil
+	case sppb.TypeCode_INT64:
+		return strconv.ParseInt(value.GetStringValue(), 10, 64)
+	case sppb.TypeCode_FLOAT32, sppb.TypeCode_FLOAT64:
+		if s, ok := value.GetKind().(*structpb.Value_StringValue); ok {
+			// NaN and Infinity are encoded as string
+			return s.StringValue, nil
+		}
+		return value.GetNumberValue(), nil
+	case sppb.TypeCode_JSON:
+		s := value.GetStringValue()
+		if !json.Valid([]byte(s)) {
+			return s, nil
+		}
+		return json.RawMessage(s), nil
+	case sppb.TypeCode_ARRAY:
+		values := value.GetListValue().GetValues()
+		result := make([]any, 0, len(values))
+		for _, v := range values {
+			elem, err := decodeValue(typ.GetArrayElementType(), v)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, elem)
+		}
+		return result, nil
+	case sppb.TypeCode_STRUCT:
+		fields := typ.GetStructType().GetFields()
+		values := value.GetListValue().GetValues()
+		if len(fields) != len(values) {
+			return nil, fmt.Errorf("struct has %d fields but %d values", len(fields), len(values))
+		}
+		result := make(map[string]any, len(fields))
+		for i, field := range fields {
+			v, err := decodeValue(field.GetType(), values[i])
+			if err != nil {
+				return nil, err
+			}
+			result[field.GetName()] = v
+		}
+		return result, nil
+	default:
+		// STRING, BYTES(base64), TIMESTAMP, DATE, NUMERIC, PROTO, ENUM, INTERVAL and UUID are encoded as string
+		return value.GetStringValue(), nil
+	}
+}
+
+// decodeRow converts a row into a map keyed by column names.
+func decodeRow(row *spanner.Row) (map[string]any, error) {
+	result := make(map[string]any, row.Size())
+	for i, name := range row.ColumnNames() {
+		var gcv spanner.GenericColumnValue
+		if err := row.Column(i, &gcv); err != nil {
+			return nil, err
+		}
+		v, err := decodeGenericColumnValue(gcv)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = v
+	}
+	return result, nil
+}
+
+// queryRows executes the statement in a single-use read-only transaction with retries and decodes all rows.
+func queryRows(ctx context.Context, client *spanner.Client, stmt spanner.Statement) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := retry(ctx, func(ctx context.Context) error {
+		rows = nil
+		return client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			m, err := decodeRow(row)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, m)
+			return nil
+		})
+	})
+	return rows, err
+}
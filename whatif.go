@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplanviz/plantree"
+	"github.com/apstndb/spannerplanviz/queryplan"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/samber/lo"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// whatifEmulator is the host:port of the Spanner emulator for shadow databases of whatif. It is set by --whatif-emulator.
+// Shadow databases are never created in real instances because the emulator is free and has no data.
+var whatifEmulator string
+
+// whatifInstance is the emulator instance of shadow databases, which is created in the project of the source database.
+const whatifInstance = "spanner-mcp-whatif"
+
+// whatifDropTimeout is the time to drop the shadow database after the tool call is done.
+const whatifDropTimeout = 10 * time.Second
+
+func whatifHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := mapToStruct[struct {
+		queryArgs `mapstructure:",squash"`
+		DDL       []string `mapstructure:"ddl"`
+		Queries   []string `mapstructure:"queries"`
+	}](request.GetArguments())
+	if err != nil {
+		return nil, err
+	}
+
+	if whatifEmulator == "" {
+		return nil, errors.New("whatif requires the Spanner emulator, start the server with --whatif-emulator")
+	}
+	if len(req.DDL) == 0 {
+		return nil, &validationError{Field: "ddl", Message: "is required"}
+	}
+	if len(req.Queries) == 0 {
+		return nil, &validationError{Field: "queries", Message: "is required"}
+	}
+	if len(req.Queries) > maxImpactQueries {
+		return nil, &validationError{Field: "queries", Message: fmt.Sprintf("must have at most %d queries", maxImpactQueries)}
+	}
+
+	target, err := req.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shadow, err := newShadowDatabase(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer shadow.close(ctx)
+
+	baseline := lo.Map(req.Queries, func(query string, _ int) shadowPlan { return shadow.plan(ctx, query) })
+	if err := shadow.update(ctx, req.DDL); err != nil {
+		return nil, err
+	}
+
+	out := whatifOutput{
+		ShadowDatabase: shadow.path,
+		Note:           "Plans are made by the emulator on the copied schema without data and statistics, so they may differ from plans of the real database. The shadow database is dropped after the call",
+	}
+	plans := lo.Map(req.Queries, func(query string, _ int) shadowPlan { return shadow.plan(ctx, query) })
+	for i, query := range req.Queries {
+		before, after := baseline[i], plans[i]
+		q := whatifQuery{Query: query, Valid: after.err == nil, Operators: after.operators}
+		if after.err != nil {
+			q.Error = after.err.Error()
+		}
+		if before.plan != "" && after.plan != "" {
+			q.PlanChanged = lo.ToPtr(before.plan != after.plan)
+		}
+		q.BaselineFullScans = lo.CountBy(before.scans, func(s planScan) bool { return s.full })
+		q.FullScans = lo.CountBy(after.scans, func(s planScan) bool { return s.full })
+		q.Indexes = lo.Uniq(lo.FilterMap(after.scans, func(s planScan, _ int) (string, bool) { return s.target, s.kind == "index" }))
+		out.Queries = append(out.Queries, q)
+	}
+	if slices.ContainsFunc(out.Queries, func(q whatifQuery) bool { return q.Valid && q.PlanChanged == nil }) {
+		out.Note = "The emulator doesn't return query plans, so the queries are only validated against the hypothetical schema by executing them. " + out.Note
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Applied %d hypothetical statements to a copy of the schema of %s\n", len(req.DDL), target.databasePath())
+	for i, q := range out.Queries {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, q.Query)
+		if !q.Valid {
+			fmt.Fprintf(&b, "   Invalid: %s\n", q.Error)
+			continue
+		}
+		if q.PlanChanged != nil {
+			fmt.Fprintf(&b, "   plan changed: %t, full scans: %d -> %d", *q.PlanChanged, q.BaselineFullScans, q.FullScans)
+			if len(q.Indexes) > 0 {
+				fmt.Fprintf(&b, ", indexes: %s", strings.Join(q.Indexes, ", "))
+			}
+			b.WriteString("\n")
+		}
+		if baseline[i].err != nil {
+			b.WriteString("   Valid only with the hypothetical schema\n")
+		}
+		b.WriteString(indent(plans[i].plan, "   "))
+	}
+	fmt.Fprintf(&b, "Note: %s\n", out.Note)
+	return mcp.NewToolResultStructured(out, b.String()), nil
+}
+
+// shadowDatabase is a database in the emulator with the schema of a real database.
+type shadowDatabase struct {
+	admin  *database.DatabaseAdminClient
+	client *spanner.Client
+	path   string
+}
+
+func emulatorOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(whatifEmulator),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithoutAuthentication(),
+	}
+}
+
+// newShadowDatabase creates a database with a random ID in the emulator, which has the DDL and proto bundle of the source database.
+func newShadowDatabase(ctx context.Context, source *profile) (*shadowDatabase, error) {
+	sourceAdmin, err := clients.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var db *databasepb.Database
+	err = retry(ctx, func(ctx context.Context) error {
+		db, err = sourceAdmin.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: source.databasePath()})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database %s: %w", source.databasePath(), err)
+	}
+	ddl, err := getDatabaseDDL(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureEmulatorInstance(ctx, source.Project); err != nil {
+		return nil, err
+	}
+
+	admin, err := database.NewDatabaseAdminClient(ctx, emulatorOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 4)
+	rand.Read(id)
+	databaseID := "whatif-" + hex.EncodeToString(id)
+	createStatement := fmt.Sprintf("CREATE DATABASE `%s`", databaseID)
+	if db.GetDatabaseDialect() == databasepb.DatabaseDialect_POSTGRESQL {
+		createStatement = fmt.Sprintf("CREATE DATABASE %q", databaseID)
+	}
+
+	parent := fmt.Sprintf("projects/%s/instances/%s", source.Project, whatifInstance)
+	op, err := admin.CreateDatabase(ctx, &databasepb.CreateDatabaseRequest{
+		Parent:           parent,
+		CreateStatement:  createStatement,
+		ExtraStatements:  ddl.GetStatements(),
+		DatabaseDialect:  db.GetDatabaseDialect(),
+		ProtoDescriptors: ddl.GetProtoDescriptors(),
+	})
+	if err != nil {
+		admin.Close()
+		return nil, fmt.Errorf("failed to create the shadow database: %w", err)
+	}
+	created, err := op.Wait(ctx)
+	if err != nil {
+		// The database may be left behind by a failure of the extra statements, so it is dropped.
+		(&shadowDatabase{admin: admin, path: parent + "/databases/" + databaseID}).close(ctx)
+		return nil, fmt.Errorf("failed to copy the schema to the shadow database, which may use features the emulator doesn't support: %w", err)
+	}
+
+	s := &shadowDatabase{admin: admin, path: created.GetName()}
+	s.client, err = spanner.NewClient(ctx, s.path, emulatorOptions()...)
+	if err != nil {
+		s.close(ctx)
+		return nil, err
+	}
+	return s, nil
+}
+
+// ensureEmulatorInstance creates whatifInstance in the emulator unless it exists.
+func ensureEmulatorInstance(ctx context.Context, project string) error {
+	admin, err := instance.NewInstanceAdminClient(ctx, emulatorOptions()...)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	name := fmt.Sprintf("projects/%s/instances/%s", project, whatifInstance)
+	_, err = admin.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: name})
+	if status.Code(err) != codes.NotFound {
+		if err != nil {
+			return fmt.Errorf("failed to connect to the emulator at %s: %w", whatifEmulator, err)
+		}
+		return nil
+	}
+
+	op, err := admin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + project,
+		InstanceId: whatifInstance,
+		Instance: &instancepb.Instance{
+			Config:      fmt.Sprintf("projects/%s/instanceConfigs/emulator-config", project),
+			DisplayName: "spanner-mcp whatif",
+			NodeCount:   1,
+		},
+	})
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return nil
+		}
+		return fmt.Errorf("failed to create the emulator instance: %w", err)
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
+
+// update applies the hypothetical DDL to the shadow database.
+func (s *shadowDatabase) update(ctx context.Context, statements []string) error {
+	op, err := s.admin.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+		Database:   s.path,
+		Statements: statements,
+	})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
+	if err != nil {
+		return &validationError{Field: "ddl", Message: fmt.Sprintf("failed to apply to the copied schema: %v", err)}
+	}
+	return nil
+}
+
+// shadowPlan is the plan of a query in the shadow database. plan is empty if the emulator doesn't return plans.
+type shadowPlan struct {
+	plan      string
+	operators []planOperator
+	scans     []planScan
+	err       error
+}
+
+// plan plans the statement, or executes it to validate if the emulator doesn't support plans.
+// Executing DML is harmless because the shadow database has no data and is dropped.
+func (s *shadowDatabase) plan(ctx context.Context, statement string) shadowPlan {
+	var qp *sppb.QueryPlan
+	var err error
+	if isDML(statement) {
+		qp, err = analyzeDML(ctx, s.client, statement)
+	} else {
+		qp, err = s.client.Single().AnalyzeQuery(ctx, spanner.NewStatement(statement))
+	}
+	if status.Code(err) == codes.Unimplemented || (err == nil && len(qp.GetPlanNodes()) == 0) {
+		return shadowPlan{err: s.execute(ctx, statement)}
+	}
+	if err != nil {
+		return shadowPlan{err: err}
+	}
+
+	processed, err := plantree.ProcessPlan(queryplan.New(qp.GetPlanNodes()))
+	if err != nil {
+		return shadowPlan{err: err}
+	}
+	text, err := printResult(processed)
+	if err != nil {
+		return shadowPlan{err: err}
+	}
+	return shadowPlan{plan: text, operators: planOperators(processed), scans: planScans(qp)}
+}
+
+func (s *shadowDatabase) execute(ctx context.Context, statement string) error {
+	if isDML(statement) {
+		_, err := s.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+			_, err := txn.Update(ctx, spanner.NewStatement(statement))
+			return err
+		})
+		return err
+	}
+	return s.client.Single().Query(ctx, spanner.NewStatement(statement)).Do(func(*spanner.Row) error { return nil })
+}
+
+// close drops the shadow database even if the tool call is cancelled.
+func (s *shadowDatabase) close(ctx context.Context) {
+	if s.client != nil {
+		s.client.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), whatifDropTimeout)
+	defer cancel()
+	if err := s.admin.DropDatabase(ctx, &databasepb.DropDatabaseRequest{Database: s.path}); err != nil {
+		slog.Warn("failed to drop the shadow database", "database", s.path, "error", err)
+	}
+	if err := s.admin.Close(); err != nil {
+		slog.Warn("failed to close admin client", "error", err)
+	}
+}
+
+func indent(s, prefix string) string {
+	if s == "" {
+		return ""
+	}
+	return prefix + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n"+prefix) + "\n"
+}